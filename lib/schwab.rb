@@ -2,11 +2,20 @@
 
 require_relative "schwab/version"
 require_relative "schwab/error"
+require_relative "schwab/identifiers"
 require_relative "schwab/configuration"
 require_relative "schwab/oauth"
+require_relative "schwab/response"
 require_relative "schwab/client"
 require_relative "schwab/market_data"
 require_relative "schwab/accounts"
+require_relative "schwab/household"
+require_relative "schwab/trading"
+require_relative "schwab/order_template"
+require_relative "schwab/instruments"
+require_relative "schwab/streaming/book"
+require_relative "schwab/streaming/bars"
+require_relative "schwab/streaming/balances"
 
 # Main namespace for the Schwab API SDK
 # @see https://developer.schwab.com/
@@ -42,5 +51,94 @@ module Schwab
     def reset_configuration!
       @configuration = Configuration.new
     end
+
+    # Label the API requests made inside the block with a business-level operation name
+    # The label reaches the +on_request+ callback and log lines. It is fiber-local, so
+    # concurrent operations on other threads keep their own labels.
+    #
+    # @example Attribute requests to a rebalance job
+    #   Schwab.with_operation("rebalance") do
+    #     Schwab::Accounts.get_positions(account_number)
+    #   end
+    #
+    # @param name [String, Symbol] The operation name
+    # @yield Block whose requests carry the label
+    # @return [Object] The block's result
+    def with_operation(name)
+      previous = Thread.current[:schwab_operation]
+      Thread.current[:schwab_operation] = name.to_s
+      yield
+    ensure
+      Thread.current[:schwab_operation] = previous
+    end
+
+    # Get the operation label set by {with_operation}
+    #
+    # @return [String, nil] The current operation name
+    def current_operation
+      Thread.current[:schwab_operation]
+    end
+
+    # Attach labels to the API requests made inside the block
+    # Labels reach the +on_request+ callback as +:labels+ and are appended to log lines, for
+    # metrics tags or audit details such as why an order was canceled. Nested blocks merge
+    # their labels over the outer ones. Like {with_operation}, labels are fiber-local.
+    #
+    # @example Tag requests with a strategy name
+    #   Schwab.with_labels(strategy: "momentum") do
+    #     Schwab::Trading.place_order(account_number, order: order)
+    #   end
+    #
+    # @param labels [Hash{Symbol => Object}] The labels
+    # @yield Block whose requests carry the labels
+    # @return [Object] The block's result
+    def with_labels(**labels)
+      previous = Thread.current[:schwab_labels]
+      Thread.current[:schwab_labels] = current_labels.merge(labels).freeze
+      yield
+    ensure
+      Thread.current[:schwab_labels] = previous
+    end
+
+    # Run a block and get its result along with the HTTP responses behind it
+    # Service methods return only decoded data; use this when you also need response
+    # headers, such as the correlation ID or rate limit details. Fiber-local, like {with_labels}.
+    #
+    # @example Place an order and keep the response headers
+    #   response = Schwab.with_response { Schwab::Trading.place_order(account_number, order: order) }
+    #   order = response.data
+    #   response.headers["Location"]
+    #
+    # @yield Block whose requests are captured
+    # @return [Response] The block's result as +data+, with every response received
+    def with_response(&block)
+      Response.capture(&block)
+    end
+
+    # Get the labels set by {with_labels}
+    #
+    # @return [Hash{Symbol => Object}] The current labels (empty when none are set)
+    def current_labels
+      Thread.current[:schwab_labels] || {}
+    end
+
+    # Start a thread that keeps the caller's {with_operation} name, {with_labels} labels, and
+    # {with_response} captures
+    # Those are fiber-local, so a bare Thread.new would drop them for the requests it makes.
+    #
+    # @param args [Array] Arguments passed to the block
+    # @yield The block the thread runs
+    # @return [Thread] The started thread
+    def context_thread(*args)
+      operation = current_operation
+      labels = Thread.current[:schwab_labels]
+      captures = Thread.current[:schwab_response_captures]&.dup
+      Thread.new(*args) do |*thread_args|
+        Thread.current[:schwab_operation] = operation
+        Thread.current[:schwab_labels] = labels
+        Thread.current[:schwab_response_captures] = captures
+        yield(*thread_args)
+      end
+    end
   end
 end
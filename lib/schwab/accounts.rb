@@ -1,11 +1,90 @@
 # frozen_string_literal: true
 
 require "uri"
+require_relative "quantity"
+require_relative "identifiers"
+require_relative "price"
+require_relative "symbols"
+require_relative "transaction_type"
+require_relative "timestamps"
+require_relative "realized_gains"
+require_relative "performance"
+require_relative "risk"
 
 module Schwab
   # Account Management API endpoints for retrieving account information,
   # positions, transactions, and orders
   module Accounts
+    # Widest date range, in days, Schwab accepts for a single transactions request
+    TRANSACTION_WINDOW_DAYS = 365
+
+    # Transaction types that move money into or out of an account, used as cash flows by {get_returns}
+    EXTERNAL_FLOW_TRANSACTION_TYPES = [
+      TransactionType::ACH_RECEIPT,
+      TransactionType::ACH_DISBURSEMENT,
+      TransactionType::CASH_RECEIPT,
+      TransactionType::CASH_DISBURSEMENT,
+      TransactionType::ELECTRONIC_FUND,
+      TransactionType::WIRE_OUT,
+      TransactionType::WIRE_IN,
+      TransactionType::JOURNAL,
+    ].freeze
+
+    # Account statuses that will never become tradeable, ending {wait_until_ready}
+    TERMINAL_ACCOUNT_STATUSES = ["CLOSED", "RESTRICTED", "SUSPENDED"].freeze
+
+    # Account response blocks {get_fields} can select. Only positions costs anything to request:
+    # Schwab leaves it out unless asked, and always sends the other blocks.
+    ACCOUNT_FIELDS = ["positions", "initialBalances", "currentBalances", "projectedBalances", "aggregatedBalance"].freeze
+
+    # Account keys {get_fields} always keeps so the result still identifies the account
+    ACCOUNT_IDENTITY_FIELDS = ["accountNumber", "type"].freeze
+
+    # Default number of concurrent requests made by {get_many}
+    GET_MANY_CONCURRENCY = 4
+
+    # Per-account results from {get_many} and {fetch_many}, with the failures kept per account number
+    ManyResult = Struct.new(:accounts, :errors, keyword_init: true) do
+      # @return [Boolean] True when every account was fetched
+      def success?
+        errors.empty?
+      end
+
+      # @return [MultiError, nil] Every failure as one error, or nil when every account was fetched
+      def error
+        MultiError.new(errors) unless success?
+      end
+
+      # Get the accounts, raising if any failed
+      #
+      # @return [Hash{String => Object}] The accounts by account number
+      # @raise [MultiError] if any account could not be fetched
+      def accounts!
+        raise error unless success?
+
+        accounts
+      end
+    end
+
+    # A position valued at its live last price next to the values Schwab reported, as returned by
+    # {get_live_positions}. The live_* fields are nil when no quote was available.
+    LivePosition = Struct.new(
+      :position,
+      :symbol,
+      :quantity,
+      :last_price,
+      :market_value,
+      :live_market_value,
+      :unrealized_pnl,
+      :live_unrealized_pnl,
+      :unrealized_pnl_percentage,
+      :live_unrealized_pnl_percentage,
+      keyword_init: true,
+    )
+
+    # End-of-day cash balance for one date, as returned by {get_balance_history}
+    BalanceSnapshot = Struct.new(:date, :cash_balance, :net_change, keyword_init: true)
+
     class << self
       # Get all accounts for the authenticated user
       #
@@ -44,39 +123,348 @@ module Schwab
         client.get(path, params, Resources::Account)
       end
 
+      # Get selected blocks of an account
+      #
+      # Schwab's +fields+ parameter can only add positions to the account response; the balance
+      # blocks are always sent. So positions are requested only when selected, and the response is
+      # trimmed to the selected blocks plus the account number and type. Polling balances this way
+      # never downloads positions.
+      #
+      # @param account_number [String] The account number
+      # @param fields [String, Symbol, Array] Blocks to return, from ACCOUNT_FIELDS
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Hash{String => Object}] The selected blocks (a block Schwab did not send is left out)
+      # @raise [InvalidRequestError] If a field is not in ACCOUNT_FIELDS
+      # @example Poll current balances only
+      #   Schwab::Accounts.get_fields("123456", ["currentBalances"])["currentBalances"]["buyingPower"]
+      def get_fields(account_number, fields, client: nil)
+        fields = Array(fields).map(&:to_s).uniq
+        unknown = fields - ACCOUNT_FIELDS
+        unless unknown.empty?
+          message = "Unknown account fields: #{unknown.join(", ")}. Use #{ACCOUNT_FIELDS.join(", ")}"
+          raise InvalidRequestError.new(message, errors: unknown.map { |field| "Unknown account field: #{field}" })
+        end
+        raise InvalidRequestError, "At least one account field is required" if fields.empty?
+
+        response = get_account(
+          account_number,
+          fields: fields.include?("positions") ? "positions" : nil,
+          client: client,
+        ).to_h
+        account = response[:securitiesAccount] || response["securitiesAccount"] || response
+
+        (ACCOUNT_IDENTITY_FIELDS + fields).each_with_object({}) do |field, selected|
+          source = field == "aggregatedBalance" ? response : account
+          value = source[field.to_sym] || source[field]
+          selected[field] = value unless value.nil?
+        end
+      end
+
+      # Get an account with its registration and holder details
+      #
+      # Combines the account with its entry from the user preferences (nickname, display ID,
+      # primary flag), so list views can keep using the lighter {get_account}. Registration type
+      # and owners are read when Schwab includes them in the account response; the public Trader
+      # API does not always report them, in which case they are nil and empty.
+      #
+      # @param account_number [String] The account number
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Resources::Account] The account, with #registration_type, #owners, and #nickname
+      # @example Show the registration on a compliance screen
+      #   details = Schwab::Accounts.get_details("123456")
+      #   details.registration_type # => "JOINT"
+      #   details.owners.map(&:name) # => ["Jane Doe", "John Doe"]
+      def get_details(account_number, client: nil)
+        client ||= default_client
+        data = get_account(account_number, client: client).to_h
+        account = (data[:securitiesAccount] || data["securitiesAccount"] || data).to_h
+
+        number = account[:accountNumber] || account["accountNumber"] || account_number
+        preferences = get_user_preferences(client: client).to_h
+        preference = Array(preferences[:accounts] || preferences["accounts"]).find do |entry|
+          (entry[:accountNumber] || entry["accountNumber"]).to_s == number.to_s
+        end
+
+        preference = preference.to_h.select { |key, _| ["nickName", "displayAcctId", "primaryAccount"].include?(key.to_s) }
+        Resources::Account.new(account.merge(preference), client)
+      end
+
+      # Get several accounts at once
+      # Fetches each account with {get_account}, running up to +concurrency+ requests in
+      # parallel. A failed account does not stop the others; its error is returned instead.
+      #
+      # @param account_numbers [Array<String>] The account numbers
+      # @param fields [String, Array<String>, nil] Fields to include
+      # @param concurrency [Integer] Maximum requests in flight (default: 4)
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [ManyResult] +accounts+ maps each fetched account number to its details (in the
+      #   order given); +errors+ maps each failed account number to its Schwab::Error
+      # @raise [ArgumentError] if concurrency is not a positive integer
+      # @example Render a multi-account overview
+      #   result = Schwab::Accounts.get_many(["123456", "789012"], fields: "positions")
+      #   result.accounts.each { |number, account| puts number }
+      #   result.errors.each { |number, error| warn "#{number}: #{error.message}" }
+      # @example Fail the whole batch if any account failed
+      #   accounts = Schwab::Accounts.get_many(["123456", "789012"]).accounts!
+      def get_many(account_numbers, fields: nil, concurrency: GET_MANY_CONCURRENCY, client: nil)
+        client ||= default_client
+        fetch_many(account_numbers, concurrency: concurrency) do |number|
+          get_account(number, fields: fields, client: client)
+        end
+      end
+
+      # Run a per-account request for several accounts concurrently
+      # The building block of {get_many} and {Household}: runs the block for each account number on
+      # up to +concurrency+ threads, keeping going when one fails.
+      #
+      # @param account_numbers [Array<String>] The account numbers (duplicates are fetched once)
+      # @param concurrency [Integer] Maximum requests in flight (default: 4)
+      # @yieldparam account_number [String] One account number
+      # @yieldreturn [Object] That account's result
+      # @return [ManyResult] +accounts+ maps each account number to its block result (in the order
+      #   given); +errors+ maps each failed account number to its Schwab::Error
+      # @raise [ArgumentError] if concurrency is not a positive integer
+      # @example Fetch working orders for several accounts
+      #   Schwab::Accounts.fetch_many(numbers) { |number| Schwab::Accounts.get_orders(number, status: "WORKING") }
+      def fetch_many(account_numbers, concurrency: GET_MANY_CONCURRENCY)
+        raise ArgumentError, "concurrency must be a positive integer" unless concurrency.is_a?(Integer) && concurrency.positive?
+
+        account_numbers = account_numbers.uniq
+        queue = Queue.new
+        account_numbers.each { |number| queue << number }
+        queue.close
+
+        results = {}
+        errors = {}
+        mutex = Mutex.new
+        workers = Array.new([concurrency, account_numbers.size].min) do
+          Schwab.context_thread do
+            while (number = queue.pop)
+              begin
+                result = yield(number)
+                mutex.synchronize { results[number] = result }
+              rescue Error => e
+                mutex.synchronize { errors[number] = e }
+              end
+            end
+          end
+        end
+        workers.each(&:join)
+
+        ManyResult.new(
+          accounts: account_numbers.select { |number| results.key?(number) }.to_h { |number| [number, results[number]] },
+          errors: account_numbers.select { |number| errors.key?(number) }.to_h { |number| [number, errors[number]] },
+        )
+      end
+
+      # Get an account's current balances
+      #
+      # @param account_number [String] The account number
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Hash] The currentBalances of the account (empty if Schwab sent none)
+      # @example
+      #   Schwab::Accounts.get_balances("123456")[:cashBalance] # => 1250.0
+      def get_balances(account_number, client: nil)
+        account = get_account(account_number, client: client).to_h
+        account = account[:securitiesAccount] || account["securitiesAccount"] || account
+        balances = account[:currentBalances] || account["currentBalances"] || {}
+        balances.to_h
+      end
+
+      # Stream an account's balances as orders fill
+      # Schwab's account activity stream carries no balance figures, so balances are re-fetched
+      # with {get_balances} after each fill message (see {Streaming::BalanceStream}).
+      #
+      # @param account_number [String] The plain account number
+      # @param streamer [Streaming::Streamer, nil] Shared streamer to multiplex over (optional)
+      # @param client [Schwab::Client, nil] Optional client instance (uses Schwab.client if not provided)
+      # @yieldparam update [Streaming::BalanceUpdate] The initial balances, then the balances after each fill
+      # @return [Streaming::BalanceStream] The running stream; call #latest for the last update and #close to stop
+      # @example Track buying power
+      #   Schwab::Accounts.stream_balances("123456") { |update| risk.update(update.buying_power) }
+      def stream_balances(account_number, streamer: nil, client: nil, &block)
+        Streaming::BalanceStream.new(account_number, streamer: streamer, client: client, &block).start
+      end
+
+      # Wait for an account to become ready for trading
+      # Polls {get_account} until the account is active. Accounts that report no status are
+      # treated as ready; accounts restricted to closing transactions are not.
+      #
+      # @param account_number [String] The account number
+      # @param interval [Numeric] Seconds between polls (default: 5)
+      # @param timeout [Numeric, nil] Seconds to wait before giving up, or nil to wait indefinitely (default: 300)
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Hash, Resources::Account] The account details from the final poll
+      # @raise [AccountNotTradeableError] If the account reaches a status that can never trade
+      # @raise [AccountNotReadyError] If the account is still pending when the timeout expires
+      # @example Wait for a newly linked account
+      #   Schwab::Accounts.wait_until_ready("123456", interval: 10, timeout: 600)
+      def wait_until_ready(account_number, interval: 5, timeout: 300, client: nil)
+        deadline = Process.clock_gettime(Process::CLOCK_MONOTONIC) + timeout if timeout
+
+        loop do
+          response = get_account(account_number, client: client)
+          account = securities_account(response)
+          status = account.status&.upcase
+
+          if TERMINAL_ACCOUNT_STATUSES.include?(status) || account.closing_only_restricted?
+            raise AccountNotTradeableError.new(account_number, status || "CLOSING_ONLY")
+          end
+          return response if status.nil? || account.active?
+
+          remaining = deadline && deadline - Process.clock_gettime(Process::CLOCK_MONOTONIC)
+          raise AccountNotReadyError.new(account_number, status) if remaining && remaining <= 0
+
+          sleep(remaining ? [interval, remaining].min : interval)
+        end
+      end
+
       # Get positions for a specific account
       #
+      # An empty array always means the account holds no positions (Schwab omits the field or
+      # sends null in that case). A response that cannot be read raises instead of looking empty.
+      #
+      # Schwab cannot filter positions server-side, so +asset_type+ is applied to the fetched list.
+      #
       # @param account_number [String] The account number
+      # @param asset_type [String, Symbol, Array, nil] Keep only positions of these asset types
+      #   (e.g., "EQUITY", "OPTION"; default: nil for all)
       # @param client [Schwab::Client, nil] Optional client instance
-      # @return [Array<Hash>, Array<Resources::Position>] List of positions
+      # @return [Array<Hash>, Array<Resources::Position>] List of positions, never nil
+      # @raise [UnexpectedResponseError] If the response has no securitiesAccount to read positions from
       # @example Get all positions
       #   Schwab::Accounts.get_positions("123456")
-      def get_positions(account_number, client: nil)
-        account_data = get_account(account_number, fields: "positions", client: client)
+      # @example Get only option positions
+      #   Schwab::Accounts.get_positions("123456", asset_type: "OPTION")
+      # @example Handle an empty account
+      #   positions = Schwab::Accounts.get_positions("123456")
+      #   puts "No positions" if positions.empty?
+      def get_positions(account_number, asset_type: nil, client: nil)
+        positions = fetch_positions(account_number, client)
+        return positions unless asset_type
 
-        if account_data.is_a?(Hash)
-          # Positions are nested under securitiesAccount
-          securities_account = account_data["securitiesAccount"] || account_data[:securitiesAccount]
-          positions = securities_account ? (securities_account["positions"] || securities_account[:positions]) : nil
-        elsif account_data.respond_to?(:positions)
-          positions = account_data.positions
+        types = Array(asset_type).map { |type| type.to_s.upcase }
+        positions.select { |position| types.include?(position_asset_type(position).to_s.upcase) }
+      end
+
+      # Get a single position by symbol
+      #
+      # Schwab has no per-symbol position endpoint, so this fetches the
+      # account's positions and returns the matching one.
+      #
+      # @param account_number [String] The account number
+      # @param symbol [String] The symbol to look up (case-insensitive)
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Hash, Resources::Position] The matching position
+      # @raise [NotFoundError] If the account does not hold the symbol
+      # @example Check a holding before selling
+      #   Schwab::Accounts.get_position("123456", "AAPL")
+      def get_position(account_number, symbol, client: nil)
+        target = Identifiers.symbol!(symbol).upcase
+        position = get_positions(account_number, client: client).find do |pos|
+          position_symbol(pos).to_s.upcase == target
         end
 
-        positions || []
+        position || raise(NotFoundError, "No position in #{target} for account #{account_number}")
+      end
+
+      # Summarize market value and P&L across all positions in an account
+      #
+      # Day change is computed from each position's quantity and the move between
+      # its prior close and last price, fetched with a single quotes request.
+      # Positions without a quote fall back to Schwab's reported day P&L.
+      #
+      # @param account_number [String] The account number
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Hash] Totals (:market_value, :cost_basis, :unrealized_pnl, :day_change,
+      #   :position_count) plus a :by_asset_type breakdown with the same keys.
+      #   Accounts without positions return zero totals.
+      # @example Get a portfolio summary
+      #   summary = Schwab::Accounts.get_positions_summary("123456")
+      #   summary[:day_change]                       # => 152.5
+      #   summary[:by_asset_type]["EQUITY"][:market_value] # => 48250.0
+      def get_positions_summary(account_number, client: nil)
+        client ||= default_client
+        positions = get_positions(account_number, client: client).map { |pos| wrap_position(pos, client) }
+        quotes = fetch_position_quotes(positions.map(&:symbol).compact.uniq, client)
+
+        summary = empty_pnl_totals.merge(by_asset_type: {})
+        positions.each do |position|
+          figures = position_pnl_figures(position, quotes[position.symbol.to_s])
+          add_pnl_figures(summary, figures)
+          add_pnl_figures(summary[:by_asset_type][position.asset_type || "UNKNOWN"] ||= empty_pnl_totals, figures)
+        end
+
+        summary
+      end
+
+      # Get positions valued at live prices
+      #
+      # Schwab's marketValue can lag the market. This fetches the positions and their quotes
+      # (in a single quotes request) and recomputes market value and unrealized P&L from each
+      # last price, keeping the reported figures alongside. Option values use a multiplier of 100.
+      #
+      # @param account_number [String] The account number
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Array<LivePosition>] One entry per position, in the order Schwab returned them
+      # @example Compare reported and live values
+      #   Schwab::Accounts.get_live_positions("123456").each do |pos|
+      #     puts "#{pos.symbol}: #{pos.market_value} reported, #{pos.live_market_value} live"
+      #   end
+      def get_live_positions(account_number, client: nil)
+        client ||= default_client
+        positions = get_positions(account_number, client: client)
+        wrapped = positions.map { |pos| wrap_position(pos, client) }
+        quotes = fetch_position_quotes(wrapped.map(&:symbol).compact.uniq, client)
+
+        positions.zip(wrapped).map do |original, position|
+          live_position(original, position, quotes[position.symbol.to_s])
+        end
+      end
+
+      # Get concentration and beta for an account's positions
+      #
+      # Weights are shares of gross exposure, computed from Schwab's reported market values. Sectors
+      # and betas are not available from Schwab; pass them as maps keyed by symbol to get
+      # +sector_weights+ and +beta+. See {Risk.metrics} for how each figure is computed.
+      #
+      # @param account_number [String] The account number
+      # @param betas [Hash{String => Numeric}] Beta by symbol (optional)
+      # @param sectors [Hash{String => String}] Sector by symbol (optional)
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Risk::RiskMetrics] The metrics; zeros for an account without positions
+      # @example Check concentration and beta
+      #   risk = Schwab::Accounts.get_risk_metrics("123456", betas: { "AAPL" => 1.2, "SPY" => 1.0 })
+      #   risk.largest_position_weight # => 0.35
+      #   risk.beta                    # => 1.12
+      def get_risk_metrics(account_number, betas: {}, sectors: {}, client: nil)
+        client ||= default_client
+        Risk.metrics(get_positions(account_number, client: client), betas: betas, sectors: sectors)
       end
 
       # Get transactions for a specific account
       #
+      # Ranges wider than +window_days+ are split into consecutive windows that Schwab accepts,
+      # fetched oldest first, and concatenated. Adjacent windows share their boundary day so no
+      # transactions are missed; duplicates from the overlap are removed by activity ID.
+      #
       # @param account_number [String] The account number
-      # @param types [String, Array<String>] Transaction types to filter (REQUIRED). Valid values:
-      #   TRADE, RECEIVE_AND_DELIVER, DIVIDEND_OR_INTEREST, ACH_RECEIPT, ACH_DISBURSEMENT,
-      #   CASH_RECEIPT, CASH_DISBURSEMENT, ELECTRONIC_FUND, WIRE_OUT, WIRE_IN, JOURNAL,
-      #   MEMORANDUM, MARGIN_CALL, MONEY_MARKET, SMA_ADJUSTMENT
+      # @param types [String, Array<String>, Set<String>] Transaction types to filter (REQUIRED); see
+      #   TransactionType::ALL for valid values
       # @param start_date [Date, Time, String] Start date for transactions (ISO-8601 format, REQUIRED)
       # @param end_date [Date, Time, String] End date for transactions (ISO-8601 format, REQUIRED)
       # @param symbol [String, nil] Filter by symbol
+      # @param window_days [Integer] Maximum days per request (default: TRANSACTION_WINDOW_DAYS)
+      # @param resume_from [Date, Time, String, nil] Checkpoint from +on_chunk+ to continue an interrupted
+      #   export from; earlier windows are skipped
+      # @param on_chunk [#call, nil] Called as +call(checkpoint, transactions)+ after each request succeeds,
+      #   with the last date it covered and the transactions it added; persist the checkpoint to resume later
+      # @param prefetch [Integer] Windows to fetch ahead while +on_chunk+ handles the current one
+      #   (default: 0, one request at a time). Chunks are still handled in date order; after a failed
+      #   request no further windows are started and the error is raised when its turn comes.
       # @param client [Schwab::Client, nil] Optional client instance
       # @return [Array<Hash>, Array<Resources::Transaction>] List of transactions
+      # @raise [ArgumentError] if a transaction type is unknown or prefetch is negative
       # @example Get all trade transactions
       #   Schwab::Accounts.get_transactions("123456",
       #     types: "TRADE",
@@ -90,17 +478,214 @@ module Schwab
       #     start_date: "2024-01-01",
       #     end_date: "2024-01-31"
       #   )
-      def get_transactions(account_number, types: nil, start_date: nil, end_date: nil, symbol: nil, client: nil)
+      # @example Export several years of transactions
+      #   Schwab::Accounts.get_transactions("123456",
+      #     types: "TRADE",
+      #     start_date: Date.new(2021, 1, 1),
+      #     end_date: Date.new(2024, 12, 31)
+      #   )
+      # @example Resume a long export after a failure
+      #   Schwab::Accounts.get_transactions("123456",
+      #     types: "TRADE",
+      #     start_date: Date.new(2015, 1, 1),
+      #     end_date: Date.new(2024, 12, 31),
+      #     resume_from: store.checkpoint,
+      #     on_chunk: ->(checkpoint, chunk) { store.append(chunk, checkpoint: checkpoint) }
+      #   )
+      def get_transactions(account_number, types: nil, start_date: nil, end_date: nil, symbol: nil,
+        window_days: TRANSACTION_WINDOW_DAYS, resume_from: nil, on_chunk: nil, prefetch: 0, client: nil)
+        raise ArgumentError, "window_days must be positive" unless window_days.to_i.positive?
+        raise ArgumentError, "prefetch must be a non-negative integer" unless prefetch.is_a?(Integer) && prefetch >= 0
+
         client ||= default_client
         path = "/trader/v1/accounts/#{encode_account_number(account_number, client)}/transactions"
 
         params = {}
         params[:types] = normalize_transaction_types(types) if types
-        params[:startDate] = format_date(start_date) if start_date
-        params[:endDate] = format_date(end_date) if end_date
         params[:symbol] = symbol.upcase if symbol
 
-        client.get(path, params, Resources::Transaction)
+        start_date = resume_start(start_date, end_date, resume_from) if resume_from
+        windows = transaction_windows(start_date, end_date, window_days.to_i)
+        unless windows
+          transactions = fetch_transactions(client, path, params, start_date, end_date)
+          on_chunk&.call(end_date && to_date(end_date), transactions)
+          return transactions
+        end
+
+        seen = {}
+        transactions = []
+        fetch_window = lambda do |window_start, window_end|
+          fetch_transactions(client, path, params, window_start, window_end)
+        end
+        each_window(windows, prefetch, fetch_window) do |(_, window_end), fetched|
+          chunk = fetched.reject do |transaction|
+            id = transaction_id(transaction)
+            next true if id && seen[id]
+
+            seen[id] = true if id
+            false
+          end
+          transactions.concat(chunk)
+          on_chunk&.call(window_end, chunk)
+        end
+        transactions
+      end
+
+      # Get daily cash balances for a date range
+      #
+      # Schwab has no balance history endpoint, so balances are reconstructed: starting from the
+      # current cash balance, the net amount of every transaction after each day is backed out.
+      # Only cash can be derived this way; market value and liquidation value need historical
+      # prices and are not included. History is only as complete as the transactions Schwab returns.
+      #
+      # @param account_number [String] The account number
+      # @param start_date [Date, Time, String] First day of the history
+      # @param end_date [Date, Time, String] Last day of the history (today at the latest)
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Array<BalanceSnapshot>] One snapshot per calendar day, oldest first
+      # @raise [ArgumentError] if start_date is after end_date or end_date is in the future
+      # @example Chart the last 30 days
+      #   Schwab::Accounts.get_balance_history("123456", start_date: Date.today - 30, end_date: Date.today)
+      def get_balance_history(account_number, start_date:, end_date:, client: nil)
+        first = to_date(start_date)
+        last = to_date(end_date)
+        raise ArgumentError, "start_date must be on or before end_date" if first > last
+        raise ArgumentError, "end_date cannot be in the future" if last > Date.today
+
+        client ||= default_client
+        balance = current_cash_balance(get_account(account_number, client: client))
+        transactions = get_transactions(
+          account_number,
+          types: TransactionType::ALL,
+          start_date: first,
+          end_date: Date.today,
+          client: client,
+        )
+
+        net_by_date = Hash.new(0.0)
+        transactions.each do |transaction|
+          date = transaction_date(transaction)
+          net_by_date[date] += (transaction[:netAmount] || transaction["netAmount"]).to_f if date
+        end
+
+        balance -= net_by_date.sum { |date, amount| date > last ? amount : 0.0 }
+        last.downto(first).map do |date|
+          snapshot = BalanceSnapshot.new(date: date, cash_balance: balance.round(2), net_change: net_by_date[date].round(2))
+          balance -= net_by_date[date]
+          snapshot
+        end.reverse
+      end
+
+      # Get realized gains and losses per closed lot for a tax year
+      #
+      # Schwab has no closed lots endpoint, so lots are matched from TRADE transactions with
+      # {RealizedGains}. Trades are fetched from +since+ so that lots closed during the year can
+      # be matched to purchases in earlier years; lots opened before +since+ have no cost basis.
+      #
+      # @param account_number [String] The account number
+      # @param year [Integer] The tax year
+      # @param method [Symbol] Lot matching method: :fifo, :lifo, or :hifo (default: :fifo)
+      # @param since [Date, Time, String] First day of trades to match against (default: five years before the year)
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Array<RealizedGains::RealizedLot>] Lots closed during the year, in closing order
+      # @raise [ArgumentError] if the method is unknown or the year is in the future
+      # @example Total 2024 long-term gains
+      #   lots = Schwab::Accounts.get_realized_gains("123456", year: 2024)
+      #   lots.select(&:long_term?).filter_map(&:gain).sum
+      def get_realized_gains(account_number, year:, method: :fifo, since: Date.new(year - 5, 1, 1), client: nil)
+        raise ArgumentError, "Unknown cost basis method: #{method.inspect}" unless RealizedGains::METHODS.include?(method)
+        raise ArgumentError, "year cannot be in the future" if year > Date.today.year
+
+        transactions = get_transactions(
+          account_number,
+          types: "TRADE",
+          start_date: to_date(since),
+          end_date: [Date.new(year, 12, 31), Date.today].min,
+          client: client,
+        )
+        RealizedGains.compute(transactions, year: year, method: method)
+      end
+
+      # Get the open tax lots of a position
+      #
+      # Schwab has no lot detail endpoint, so lots are rebuilt from the symbol's TRADE transactions
+      # with {RealizedGains.open_lots}. Lots opened before +since+, transferred in, or changed by
+      # corporate actions are not known: when the lots add up to less than the position's quantity
+      # (see {get_position}), the rest is only visible on schwab.com. Orders cannot name a lot; use
+      # {RealizedGains.tax_lot_method} to pick the +taxLotMethod+ that sells a given lot.
+      #
+      # @param account_number [String] The account number
+      # @param symbol [String] The position's symbol
+      # @param method [Symbol] How past sells were matched to lots: :fifo, :lifo, or :hifo (default: :fifo)
+      # @param since [Date, Time, String] First day of trades to rebuild from (default: ten years ago)
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Array<RealizedGains::OpenLot>] Open lots, oldest first
+      # @raise [ArgumentError] if the method is unknown
+      # @example Sell a specific lot
+      #   lots = Schwab::Accounts.get_lots("123456", "AAPL")
+      #   lot = lots.max_by(&:unit_cost)
+      #   order[:taxLotMethod] = Schwab::RealizedGains.tax_lot_method(lots, lot.id, quantity: 10)
+      def get_lots(account_number, symbol, method: :fifo, since: Date.today.prev_year(10), client: nil)
+        symbol = Identifiers.symbol!(symbol).upcase
+        transactions = get_transactions(
+          account_number,
+          types: "TRADE",
+          symbol: symbol,
+          start_date: to_date(since),
+          end_date: Date.today,
+          client: client,
+        )
+        RealizedGains.open_lots(transactions, method: method).select { |lot| lot.symbol.to_s.upcase == symbol }
+      end
+
+      # Get time-weighted and money-weighted returns for a date range
+      #
+      # Schwab has no historical account values, so the value at +start_date+ (and optionally at
+      # dates in between) must be supplied, for example from saved statements. Deposits and
+      # withdrawals are taken from EXTERNAL_FLOW_TRANSACTION_TYPES transactions and the returns are
+      # computed with {Performance.returns}, which documents the methodology.
+      #
+      # @param account_number [String] The account number
+      # @param start_date [Date, Time, String] First day of the range
+      # @param start_value [Numeric] Account value at the end of start_date
+      # @param end_date [Date, Time, String] Last day of the range (default: today)
+      # @param end_value [Numeric, nil] Account value at the end of end_date; defaults to the current
+      #   liquidation value when end_date is today
+      # @param valuations [Hash{Date, String => Numeric}] Values at dates in between, which refine the TWR
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Performance::ReturnsReport] The returns
+      # @raise [ArgumentError] if the range is empty or in the future, or end_value is missing for a past end_date
+      # @example Year-to-date returns
+      #   report = Schwab::Accounts.get_returns("123456", start_date: Date.new(2024, 12, 31), start_value: 50_000)
+      #   report.time_weighted_return # => 0.0642
+      def get_returns(account_number, start_date:, start_value:, end_date: Date.today, end_value: nil, valuations: {},
+        client: nil)
+        first = to_date(start_date)
+        last = to_date(end_date)
+        raise ArgumentError, "start_date must be before end_date" if first >= last
+        raise ArgumentError, "end_date cannot be in the future" if last > Date.today
+
+        client ||= default_client
+        if end_value.nil?
+          raise ArgumentError, "end_value is required when end_date is not today" unless last == Date.today
+
+          end_value = securities_account(get_account(account_number, client: client)).account_value
+        end
+
+        transactions = get_transactions(
+          account_number,
+          types: EXTERNAL_FLOW_TRANSACTION_TYPES,
+          start_date: first,
+          end_date: last,
+          client: client,
+        )
+        flows = transactions.filter_map do |transaction|
+          date = transaction_date(transaction)
+          amount = transaction[:netAmount] || transaction["netAmount"]
+          Performance::CashFlow.new(date: date, amount: amount.to_f) if date && amount
+        end
+
+        Performance.returns({ first => start_value, **valuations, last => end_value }, flows)
       end
 
       # Get a specific transaction
@@ -120,10 +705,15 @@ module Schwab
 
       # Get orders for a specific account
       #
+      # Schwab filters by a single status server-side. When several statuses are given,
+      # orders are fetched unfiltered and narrowed client-side, so +max_results+ applies
+      # before the status filter.
+      #
       # @param account_number [String] The account number
       # @param from_entered_time [Time, DateTime, String, nil] Start time for orders (ISO-8601 format required)
       # @param to_entered_time [Time, DateTime, String, nil] End time for orders (ISO-8601 format required)
-      # @param status [String, Array<String>, nil] Order status filter. Valid values:
+      # @param status [String, Array<String>, nil] Order status filter, or a set such as
+      #   Resources::Order::OPEN_STATUSES. Valid values:
       #   AWAITING_PARENT_ORDER, AWAITING_CONDITION, AWAITING_STOP_CONDITION, AWAITING_MANUAL_REVIEW,
       #   ACCEPTED, AWAITING_UR_OUT, PENDING_ACTIVATION, QUEUED, WORKING, REJECTED, PENDING_CANCEL,
       #   CANCELED, PENDING_REPLACE, REPLACED, FILLED, EXPIRED, NEW, AWAITING_RELEASE_TIME,
@@ -138,24 +728,20 @@ module Schwab
       #   )
       # @example Get working orders
       #   Schwab::Accounts.get_orders("123456", status: "WORKING")
+      # @example Get every non-terminal order
+      #   Schwab::Accounts.get_orders("123456", status: Schwab::Resources::Order::OPEN_STATUSES)
       def get_orders(account_number, from_entered_time: nil, to_entered_time: nil, status: nil, max_results: nil, client: nil)
         client ||= default_client
         path = "/trader/v1/accounts/#{encode_account_number(account_number, client)}/orders"
 
-        params = {}
-        params[:fromEnteredTime] = format_datetime(from_entered_time) if from_entered_time
-        params[:toEnteredTime] = format_datetime(to_entered_time) if to_entered_time
-        params[:status] = normalize_order_status(status) if status
-        params[:maxResults] = max_results if max_results
-
-        client.get(path, params, Resources::Order)
+        fetch_orders(client, path, from_entered_time, to_entered_time, status, max_results)
       end
 
       # Get all orders for all accounts
       #
       # @param from_entered_time [Time, DateTime, String, nil] Start time for orders
       # @param to_entered_time [Time, DateTime, String, nil] End time for orders
-      # @param status [String, Array<String>, nil] Order status filter
+      # @param status [String, Array<String>, nil] Order status filter (see #get_orders)
       # @param max_results [Integer, nil] Maximum number of results
       # @param client [Schwab::Client, nil] Optional client instance
       # @return [Array<Hash>, Array<Resources::Order>] List of orders
@@ -168,15 +754,8 @@ module Schwab
       #   )
       def get_all_orders(from_entered_time: nil, to_entered_time: nil, status: nil, max_results: nil, client: nil)
         client ||= default_client
-        path = "/trader/v1/orders"
-
-        params = {}
-        params[:fromEnteredTime] = format_datetime(from_entered_time) if from_entered_time
-        params[:toEnteredTime] = format_datetime(to_entered_time) if to_entered_time
-        params[:status] = normalize_order_status(status) if status
-        params[:maxResults] = max_results if max_results
 
-        client.get(path, params, Resources::Order)
+        fetch_orders(client, "/trader/v1/orders", from_entered_time, to_entered_time, status, max_results)
       end
 
       # Get a specific order
@@ -218,9 +797,13 @@ module Schwab
       end
 
       # Preview an order before placing it
+      # Leg quantities are rounded first when +config.quantity_rounding+ is set (see {Quantity.round}),
+      # and prices are formatted when +config.price_precision+ is set (see {Price.format})
       #
       # @param account_number [String] The account number
       # @param order_data [Hash] Order details to preview
+      # @param normalize [Boolean] Normalize leg symbols for their asset type with {Symbols.normalize}
+      #   before sending (default: false)
       # @param client [Schwab::Client, nil] Optional client instance
       # @return [Hash] Order preview with estimated costs, commissions, and margin requirements
       # @example Preview a buy order
@@ -238,11 +821,14 @@ module Schwab
       #       }
       #     }]
       #   })
-      def preview_order(account_number, order_data, client: nil)
+      def preview_order(account_number, order_data, normalize: false, client: nil)
+        order_data = Symbols.normalize_order(order_data) if normalize
+
         client ||= default_client
+        order_data = Symbols.upcase_order(order_data) if client.config.normalize_symbol_case
         path = "/trader/v1/accounts/#{encode_account_number(account_number, client)}/previewOrder"
 
-        client.post(path, order_data)
+        client.post(path, apply_price_precision(apply_quantity_rounding(order_data, client), client))
       end
 
       private
@@ -251,9 +837,19 @@ module Schwab
         Schwab.client || raise(Error, "No client configured. Set Schwab.client or pass a client instance.")
       end
 
+      def apply_quantity_rounding(order_data, client)
+        mode = client.config.quantity_rounding
+        mode ? Quantity.round_order(order_data, mode, fractional: client.config.fractional_symbols) : order_data
+      end
+
+      def apply_price_precision(order_data, client)
+        precision = client.config.price_precision
+        precision ? Price.format_order(order_data, precision) : order_data
+      end
+
       def encode_account_number(account_number, client = nil)
         client ||= default_client
-        encrypted_number = client.resolve_account_number(account_number)
+        encrypted_number = client.resolve_account_number(Identifiers.account_number!(account_number))
         URI.encode_www_form_component(encrypted_number)
       end
 
@@ -261,6 +857,109 @@ module Schwab
         URI.encode_www_form_component(symbol.to_s.upcase)
       end
 
+      def fetch_positions(account_number, client)
+        account_data = get_account(account_number, fields: "positions", client: client)
+
+        # Positions are nested under securitiesAccount
+        securities_account = account_data.respond_to?(:key?) &&
+          (account_data["securitiesAccount"] || account_data[:securitiesAccount])
+        unless securities_account
+          raise UnexpectedResponseError, "Account response has no securitiesAccount; positions could not be read"
+        end
+
+        return securities_account.positions if securities_account.is_a?(Resources::Account)
+
+        securities_account["positions"] || securities_account[:positions] || []
+      end
+
+      def position_asset_type(position)
+        return position.asset_type if position.is_a?(Resources::Position)
+
+        instrument = position[:instrument] || position["instrument"] || {}
+        instrument[:assetType] || instrument["assetType"]
+      end
+
+      def position_symbol(position)
+        return position.symbol if position.is_a?(Resources::Position)
+
+        instrument = position[:instrument] || position["instrument"]
+        if instrument
+          instrument[:symbol] || instrument["symbol"]
+        else
+          position[:symbol] || position["symbol"]
+        end
+      end
+
+      def wrap_position(position, client)
+        position.is_a?(Resources::Position) ? position : Resources::Position.new(position, client)
+      end
+
+      def fetch_position_quotes(symbols, client)
+        return {} if symbols.empty?
+
+        response = MarketData.get_quotes(symbols, fields: "quote", client: client)
+        response.to_h.each_with_object({}) { |(symbol, data), quotes| quotes[symbol.to_s] = data }
+      end
+
+      def position_pnl_figures(position, quote_data)
+        quote = quote_data && (quote_data[:quote] || quote_data["quote"])
+        last_price = quote && (quote[:lastPrice] || quote["lastPrice"])
+        prior_close = quote && (quote[:closePrice] || quote["closePrice"])
+
+        day_change = if last_price && prior_close
+          multiplier = position.asset_type.to_s.upcase == "OPTION" ? Symbols::OPTION_MULTIPLIER : 1
+          ((last_price.to_f - prior_close.to_f) * position.quantity * multiplier).round(2)
+        else
+          position.day_pnl.to_f
+        end
+
+        {
+          market_value: position.market_value,
+          cost_basis: position.cost_basis,
+          unrealized_pnl: position.unrealized_pnl,
+          day_change: day_change,
+          position_count: 1,
+        }
+      end
+
+      def live_position(original, position, quote_data)
+        quote = quote_data && (quote_data[:quote] || quote_data["quote"])
+        last_price = quote && (quote[:lastPrice] || quote["lastPrice"])
+
+        live = {}
+        if last_price
+          multiplier = position.asset_type.to_s.upcase == "OPTION" ? Symbols::OPTION_MULTIPLIER : 1
+          quantity = position.quantity
+          average = position.average_price.to_f
+          cost = (average * quantity.abs * multiplier).round(2)
+          live_pnl = ((last_price.to_f - average) * quantity * multiplier).round(2)
+
+          live[:live_market_value] = (last_price.to_f * quantity * multiplier).round(2)
+          live[:live_unrealized_pnl] = live_pnl
+          live[:live_unrealized_pnl_percentage] = cost.zero? ? nil : ((live_pnl / cost) * 100).round(2)
+        end
+
+        LivePosition.new(
+          position: original,
+          symbol: position.symbol,
+          quantity: position.quantity,
+          last_price: last_price&.to_f,
+          market_value: position.market_value,
+          unrealized_pnl: position.unrealized_pnl,
+          unrealized_pnl_percentage: position.unrealized_pnl_percentage,
+          **live,
+        )
+      end
+
+      def empty_pnl_totals
+        { market_value: 0.0, cost_basis: 0.0, unrealized_pnl: 0.0, day_change: 0.0, position_count: 0 }
+      end
+
+      def add_pnl_figures(totals, figures)
+        figures.each { |key, value| totals[key] = (totals[key] + value).round(2) }
+        totals
+      end
+
       def normalize_fields(fields)
         case fields
         when Array
@@ -272,28 +971,119 @@ module Schwab
         end
       end
 
-      def normalize_transaction_types(types)
-        case types
-        when Array
-          types.map(&:to_s).map(&:upcase).join(",")
-        when String
-          types.upcase
-        else
-          types.to_s.upcase
+      def fetch_transactions(client, path, params, start_date, end_date)
+        params = params.dup
+        params[:startDate] = format_date(start_date) if start_date
+        params[:endDate] = format_date(end_date) if end_date
+        client.get(path, params, Resources::Transaction)
+      end
+
+      # Yield each window with its fetched transactions, in order. With +prefetch+ above zero, up to
+      # that many later windows are fetched on background threads while the current one is handled.
+      # A failed fetch raises from Thread#value when its window comes up, which stops any more
+      # windows from starting; fetches already in flight finish on their own.
+      def each_window(windows, prefetch, fetch)
+        return windows.each { |window| yield(window, fetch.call(*window)) } if prefetch.zero?
+
+        in_flight = []
+        started = 0
+        windows.each do |window|
+          while started < windows.size && in_flight.size <= prefetch
+            thread = Schwab.context_thread(windows[started]) { |ahead| fetch.call(*ahead) }
+            thread.report_on_exception = false
+            in_flight << thread
+            started += 1
+          end
+          yield(window, in_flight.shift.value)
         end
       end
 
-      def normalize_order_status(status)
-        case status
-        when Array
-          status.map(&:to_s).map(&:upcase).join(",")
-        when String
-          status.upcase
-        else
-          status.to_s.upcase
+      # Start of the range still to fetch when resuming from a checkpoint
+      # Windows share their boundary day, so the checkpoint day is fetched again; transactions on it
+      # may repeat ones saved before the interruption and can be dropped by activityId.
+      def resume_start(start_date, end_date, resume_from)
+        checkpoint = to_date(resume_from)
+        raise ArgumentError, "resume_from is after end_date" if end_date && checkpoint > to_date(end_date)
+
+        start_date && to_date(start_date) > checkpoint ? start_date : checkpoint
+      end
+
+      # Split a date range into windows of at most window_days, or nil if no split is needed
+      def transaction_windows(start_date, end_date, window_days)
+        return unless start_date && end_date
+
+        first = to_date(start_date)
+        last = to_date(end_date)
+        return if last - first <= window_days
+
+        windows = []
+        window_start = first
+        while window_start < last
+          window_end = [window_start + window_days, last].min
+          windows << [window_start, window_end]
+          window_start = window_end
+        end
+        windows
+      end
+
+      def securities_account(response)
+        data = response.to_h
+        Resources::Account.new(data[:securitiesAccount] || data["securitiesAccount"] || data)
+      end
+
+      def current_cash_balance(account)
+        account = account[:securitiesAccount] || account["securitiesAccount"] || account
+        balances = account[:currentBalances] || account["currentBalances"] || {}
+        (balances[:cashBalance] || balances["cashBalance"]).to_f
+      end
+
+      def transaction_date(transaction)
+        date = transaction[:time] || transaction["time"] || transaction[:tradeDate] || transaction["tradeDate"]
+        to_date(date) if date
+      end
+
+      def transaction_id(transaction)
+        transaction[:activityId] || transaction["activityId"] ||
+          transaction[:transactionId] || transaction["transactionId"]
+      end
+
+      def to_date(date)
+        case date
+        when Time, DateTime then date.to_date
+        when Date then date
+        else Date.parse(date.to_s)
         end
       end
 
+      def normalize_transaction_types(types)
+        TransactionType.normalize(types).join(",")
+      end
+
+      def fetch_orders(client, path, from_entered_time, to_entered_time, status, max_results)
+        statuses = normalize_order_statuses(status) if status
+
+        params = {}
+        params[:fromEnteredTime] = format_datetime(from_entered_time) if from_entered_time
+        params[:toEnteredTime] = format_datetime(to_entered_time) if to_entered_time
+        params[:status] = statuses.first if statuses&.size == 1
+        params[:maxResults] = max_results if max_results
+
+        orders = client.get(path, params, Resources::Order)
+        return orders unless statuses && statuses.size > 1 && orders.is_a?(Array)
+
+        orders.select { |order| statuses.include?(order_status(order).to_s.upcase) }
+      end
+
+      def normalize_order_statuses(status)
+        Array(status).flat_map { |value| value.to_s.split(",") }.map { |value| value.strip.upcase }.uniq
+      end
+
+      def order_status(order)
+        return order.status if order.is_a?(Resources::Order)
+
+        order[:status] || order["status"]
+      end
+
       def format_date(date)
         case date
         when Date
@@ -321,7 +1111,7 @@ module Schwab
         when Date
           datetime.to_time.iso8601
         when String
-          Time.parse(datetime).iso8601
+          Timestamps.parse(datetime).iso8601
         else
           datetime.to_s
         end
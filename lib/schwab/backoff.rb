@@ -0,0 +1,112 @@
+# frozen_string_literal: true
+
+require "time"
+
+module Schwab
+  # Retry delay strategies for Middleware::RateLimit
+  #
+  # A strategy is any object responding to +next_delay(attempt, response)+ and returning the
+  # seconds to wait before retry number +attempt+ (starting at 1). +response+ is the
+  # Faraday::Response that triggered the retry, or nil after a network error. Set one with
+  # +config.backoff+; custom curves such as decorrelated jitter only need that one method.
+  #
+  # @example Retry every two seconds
+  #   config.backoff = Schwab::Backoff::Constant.new(2)
+  # @example Decorrelated jitter
+  #   class DecorrelatedJitter
+  #     def initialize
+  #       @last = 1.0
+  #     end
+  #
+  #     def next_delay(_attempt, _response)
+  #       @last = [30.0, rand(1.0..(@last * 3))].min
+  #     end
+  #   end
+  #   config.backoff = DecorrelatedJitter.new
+  module Backoff
+    # The same delay before every retry
+    class Constant
+      attr_reader :delay
+
+      # @param delay [Numeric] Seconds to wait
+      def initialize(delay)
+        @delay = delay
+      end
+
+      # @return [Numeric] Seconds to wait
+      def next_delay(_attempt, _response)
+        delay
+      end
+    end
+
+    # Exponential growth with full jitter: a random delay between zero and
+    # +base * factor**(attempt - 1)+, capped at +max+
+    class ExponentialJitter
+      attr_reader :base, :factor, :max
+
+      # @param base [Numeric] Upper bound of the first delay in seconds (default: 1)
+      # @param factor [Numeric] Growth per attempt (default: 2)
+      # @param max [Numeric] Largest delay in seconds (default: 30)
+      # @param random [Random] Source of randomness (for testing)
+      def initialize(base: 1, factor: 2, max: 30, random: Random.new)
+        @base = base
+        @factor = factor
+        @max = max
+        @random = random
+      end
+
+      # @param attempt [Integer] The retry number, starting at 1
+      # @return [Float] Seconds to wait
+      def next_delay(attempt, _response)
+        ceiling = [base * (factor**(attempt - 1)), max].min.to_f
+        @random.rand * ceiling
+      end
+    end
+
+    # Waits as long as the Retry-After header asks, deferring to another strategy when the
+    # response has none (or after a network error)
+    class RetryAfter
+      attr_reader :fallback
+
+      # @param fallback [#next_delay] Strategy used without a Retry-After header (default: ExponentialJitter)
+      # @param max [Numeric, nil] Largest delay to honor, in seconds (default: nil for no cap)
+      def initialize(fallback: ExponentialJitter.new, max: nil)
+        @fallback = fallback
+        @max = max
+      end
+
+      # @param attempt [Integer] The retry number, starting at 1
+      # @param response [Faraday::Response, nil] The response being retried
+      # @return [Numeric] Seconds to wait
+      def next_delay(attempt, response)
+        delay = response && parse(response.headers["Retry-After"])
+        return fallback.next_delay(attempt, response) unless delay
+
+        @max ? [delay, @max].min : delay
+      end
+
+      private
+
+      # Retry-After is either a number of seconds or an HTTP date
+      def parse(value)
+        return if value.nil?
+        return value.to_i if value.to_s.match?(/\A\d+\z/)
+
+        [Time.httpdate(value.to_s) - Time.now, 0].max
+      rescue ArgumentError
+        nil
+      end
+    end
+
+    class << self
+      # The strategy used when none is configured: exponential jitter, deferring to Retry-After
+      #
+      # @param base [Numeric] Upper bound of the first delay in seconds (default: 1)
+      # @param factor [Numeric] Growth per attempt (default: 2)
+      # @return [RetryAfter] The strategy
+      def default(base: 1, factor: 2)
+        RetryAfter.new(fallback: ExponentialJitter.new(base: base, factor: factor))
+      end
+    end
+  end
+end
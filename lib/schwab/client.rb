@@ -1,24 +1,128 @@
 # frozen_string_literal: true
 
+require "json"
 require_relative "connection"
 require_relative "middleware/authentication"
 require_relative "middleware/rate_limit"
 require_relative "account_number_resolver"
+require_relative "response"
+require_relative "token_expiry"
 require_relative "resources/base"
 require_relative "resources/account"
 require_relative "resources/position"
 require_relative "resources/transaction"
 require_relative "resources/order"
 require_relative "resources/strategy"
+require_relative "resources/quote"
+require_relative "resources/pagination"
+require_relative "events"
 
 module Schwab
   # Main client for interacting with the Schwab API
   class Client
+    # Environment variables read by {.from_env}, keyed by credentials file field
+    ENV_VARIABLES = {
+      client_id: "SCHWAB_CLIENT_ID",
+      client_secret: "SCHWAB_CLIENT_SECRET",
+      refresh_token: "SCHWAB_REFRESH_TOKEN",
+      access_token: "SCHWAB_ACCESS_TOKEN",
+    }.freeze
+
+    # Credentials file fields {.from_env} checks before trusting a saved access token
+    TOKEN_EXPIRY_FIELDS = ["expires_at", "expires_in", "issued_at"].freeze
+
+    # Credentials {.from_env} cannot build a client without
+    REQUIRED_CREDENTIALS = [:client_id, :client_secret].freeze
+
     attr_reader :access_token, :refresh_token, :auto_refresh, :config
 
+    class << self
+      # Build a client from environment variables and an optional JSON credentials file
+      #
+      # Reads SCHWAB_CLIENT_ID and SCHWAB_CLIENT_SECRET (required) and SCHWAB_REFRESH_TOKEN and
+      # SCHWAB_ACCESS_TOKEN (optional). Values missing from the environment are looked up in the
+      # credentials file (+credentials_file+ or SCHWAB_CREDENTIALS_FILE), whose keys are
+      # client_id, client_secret, refresh_token, and access_token. With a refresh token the client
+      # refreshes automatically, and fetches an access token before its first request if none was given.
+      # A file saved from +on_token_refresh+ data may also hold expires_at, expires_in, and issued_at;
+      # its access token is then used only if {TokenExpiry.expired?} says it is still valid.
+      #
+      # @param env [Hash] Environment to read from (default: ENV)
+      # @param credentials_file [String, nil] Path to a JSON credentials file
+      # @param config [Configuration, nil] Base configuration (uses global if not provided); it is copied, not modified
+      # @param on_token_refresh [Proc, nil] Callback when token is refreshed
+      # @return [Client] The configured client
+      # @raise [MissingCredentialsError] listing every required variable that is missing
+      # @example Build a client in a twelve-factor app
+      #   client = Schwab::Client.from_env(on_token_refresh: ->(token) { store.save(token) })
+      def from_env(env: ENV, credentials_file: nil, config: nil, on_token_refresh: nil)
+        file = read_credentials_file(credentials_file || env["SCHWAB_CREDENTIALS_FILE"])
+        credentials = ENV_VARIABLES.to_h do |field, name|
+          value = env[name].to_s.empty? ? file[field.to_s] : env[name]
+          [field, value.to_s.empty? ? nil : value]
+        end
+
+        missing = REQUIRED_CREDENTIALS.reject { |field| credentials[field] }
+        raise MissingCredentialsError, missing.map { |field| ENV_VARIABLES[field] } unless missing.empty?
+
+        config = (config || Schwab.configuration || Configuration.new).dup
+        config.client_id = credentials[:client_id]
+        config.client_secret = credentials[:client_secret]
+        credentials[:access_token] = nil if stale_saved_token?(credentials, env, file, config)
+
+        new(
+          access_token: credentials[:access_token],
+          refresh_token: credentials[:refresh_token],
+          auto_refresh: !credentials[:refresh_token].nil?,
+          on_token_refresh: on_token_refresh,
+          config: config,
+        )
+      end
+
+      # Build a client, rejecting unusable configuration up front
+      # {#initialize} accepts blank credentials and only fails when it first authenticates;
+      # this raises immediately instead, listing every problem found by Configuration#client_errors.
+      #
+      # @param options [Hash] The options accepted by {#initialize}
+      # @return [Client] The configured client
+      # @raise [InvalidConfigurationError] if the client ID or secret is blank or the base URL is malformed
+      # @example
+      #   client = Schwab::Client.validated(access_token: token, refresh_token: refresh, auto_refresh: true)
+      def validated(**options)
+        config = options[:config] || Schwab.configuration || Configuration.new
+        errors = config.client_errors
+        raise InvalidConfigurationError, errors unless errors.empty?
+
+        new(**options)
+      end
+
+      private
+
+      # A saved access token is skipped when the file's expiry fields say it may have expired,
+      # so the client refreshes first; tokens given in the environment carry no expiry to check
+      def stale_saved_token?(credentials, env, file, config)
+        return false unless credentials[:access_token] && credentials[:refresh_token]
+        return false unless env[ENV_VARIABLES[:access_token]].to_s.empty?
+        return false unless TOKEN_EXPIRY_FIELDS.any? { |field| file.key?(field) }
+
+        TokenExpiry.expired?(file, max_clock_drift: config.max_clock_drift)
+      end
+
+      def read_credentials_file(path)
+        return {} if path.to_s.empty?
+
+        JSON.parse(File.read(path))
+      rescue Errno::ENOENT
+        raise Error, "Credentials file not found: #{path}"
+      rescue JSON::ParserError => e
+        raise Error, "Invalid credentials file #{path}: #{e.message}"
+      end
+    end
+
     # Initialize a new Schwab API client
     #
-    # @param access_token [String] OAuth access token
+    # @param access_token [String, nil] OAuth access token (when nil and a refresh token is given,
+    #   one is fetched before the first request)
     # @param refresh_token [String, nil] OAuth refresh token for auto-refresh
     # @param auto_refresh [Boolean] Whether to automatically refresh expired tokens
     # @param on_token_refresh [Proc, nil] Callback when token is refreshed
@@ -31,14 +135,23 @@ module Schwab
       @config = config || Schwab.configuration || Configuration.new
       @connection = nil
       @account_resolver = nil
+      @events = nil
+      @closed = false
+      @auth_threads = []
+      @auth_mutex = Mutex.new
+      @reauthenticate = access_token.nil? && !refresh_token.nil?
       @mutex = Mutex.new
+      warn_blank_credentials
     end
 
     # Get the Faraday connection (lazily initialized)
     #
     # @return [Faraday::Connection] The configured HTTP connection
     def connection
+      raise ClientClosedError, "Client is closed" if @closed
+
       @mutex.synchronize do
+        reauthenticate! if @reauthenticate
         @connection ||= build_connection
       end
     end
@@ -93,6 +206,49 @@ module Schwab
       request(:patch, path, body, resource_class)
     end
 
+    # Make a request and return the raw Faraday response
+    # Useful when response headers matter, such as the Location of a newly placed order.
+    # Errors are raised the same way as for #get and friends.
+    #
+    # @param method [Symbol] The HTTP method (:get, :post, :put, :patch, :delete)
+    # @param path [String] The API endpoint path
+    # @param params_or_body [Hash] Query parameters or request body
+    # @return [Faraday::Response] The response
+    def raw_request(method, path, params_or_body = {})
+      # Remove the leading slash so the path resolves beneath the base URL, including any
+      # gateway path prefix; Faraday would otherwise treat it as absolute and drop the prefix
+      path = path.sub(%r{^/}, "")
+
+      unless [:get, :delete, :post, :put, :patch].include?(method)
+        raise ArgumentError, "Unsupported HTTP method: #{method}"
+      end
+
+      Response.record(connection.send(method, path, params_or_body))
+    rescue Faraday::Error => e
+      handle_error(e)
+    end
+
+    # Call an endpoint and get the response body as unparsed JSON
+    # An escape hatch for endpoints the SDK does not model yet: authentication, headers, and
+    # error classification are the same as for #get and friends, but the body is returned exactly
+    # as Schwab sent it.
+    #
+    # @param method [Symbol] The HTTP method (:get, :post, :put, :patch, :delete)
+    # @param path [String] The API endpoint path
+    # @param params_or_body [Hash] Query parameters or request body
+    # @return [Array(String, Faraday::Response)] The raw JSON body (nil when empty) and the response
+    # @raise [ApiError] subclasses for error responses, as for #get
+    # @example Call a new endpoint
+    #   json, response = client.raw_json_request(:get, "/trader/v1/newEndpoint", { fields: "all" })
+    #   data = JSON.parse(json) if json
+    def raw_json_request(method, path, params_or_body = {})
+      response = raw_request(method, path, params_or_body)
+      body = response.env[:raw_body] || response.body
+      body = @config.codec.encode(body) unless body.nil? || body.is_a?(String)
+
+      [body.nil? || body.empty? ? nil : body, response]
+    end
+
     # Update the access token (useful after manual refresh)
     #
     # @param new_token [String] The new access token
@@ -115,6 +271,85 @@ module Schwab
       end
     end
 
+    # Update the OAuth client credentials at runtime (e.g., after secret rotation)
+    #
+    # The pair is swapped atomically on a copy of the configuration, so concurrent requests
+    # never see a half-updated pair and other clients sharing the global configuration are
+    # unaffected. The cached connection is discarded, and when a refresh token is available
+    # the access token is refreshed with the new credentials before the next request.
+    #
+    # @param client_id [String] The new OAuth client ID
+    # @param client_secret [String] The new OAuth client secret
+    # @raise [ArgumentError] if either credential is blank
+    def update_credentials(client_id:, client_secret:)
+      if client_id.to_s.empty? || client_secret.to_s.empty?
+        raise ArgumentError, "client_id and client_secret are required"
+      end
+
+      config = @config.dup
+      config.client_id = client_id
+      config.client_secret = client_secret
+
+      @mutex.synchronize do
+        @config = config
+        @connection = nil
+        @reauthenticate = !@refresh_token.nil?
+      end
+    end
+
+    # Close the client
+    #
+    # Token requests still in flight are cancelled with ClientClosedError, so shutdown never
+    # waits on a hanging authentication. Later requests raise ClientClosedError. Order watchers
+    # started on {#events} are stopped.
+    #
+    # @return [void]
+    def close
+      @closed = true
+      threads = @auth_mutex.synchronize { @auth_threads.dup }
+      threads.each { |thread| thread.raise(ClientClosedError, "Client closed during authentication") }
+      @events&.stop_watchers
+      @connection = nil
+    end
+
+    # Check if the client has been closed
+    #
+    # @return [Boolean] True after #close
+    def closed?
+      @closed
+    end
+
+    # Check if a token request is in flight
+    #
+    # @return [Boolean] True while authenticating
+    def authenticating?
+      @auth_mutex.synchronize { !@auth_threads.empty? }
+    end
+
+    # Get the event bus for order and fill notifications (lazily initialized)
+    #
+    # @return [Events::Bus] The client's event bus
+    # @example Watch an account and subscribe to its events
+    #   client.events.watch_orders("123456", interval: 5)
+    #   subscription = client.events.subscribe
+    #   subscription.each { |event| puts event }
+    def events
+      @mutex.synchronize do
+        @events ||= Events::Bus.new(self)
+      end
+    end
+
+    # Group accounts for consolidated reporting
+    #
+    # @param account_numbers [Array<String>] The member account numbers
+    # @param concurrency [Integer] Maximum requests in flight per query (default: 4)
+    # @return [Household] A household that queries through this client
+    # @example
+    #   client.household(["123456", "789012"]).positions.items
+    def household(account_numbers, concurrency: Accounts::GET_MANY_CONCURRENCY)
+      Household.new(account_numbers, client: self, concurrency: concurrency)
+    end
+
     # Get the account number resolver (lazily initialized)
     #
     # @return [AccountNumberResolver] The account number resolver
@@ -145,6 +380,14 @@ module Schwab
 
     private
 
+    # Blank credentials only fail once a token is refreshed, so point at the cause early
+    def warn_blank_credentials
+      blank = [:client_id, :client_secret].select { |field| @config.public_send(field).to_s.strip.empty? }
+      return if blank.empty?
+
+      @config.logger&.warn("Schwab client created with blank #{blank.join(" and ")}; token refresh will fail")
+    end
+
     def build_connection
       if @auto_refresh && @refresh_token
         # Build connection with automatic token refresh
@@ -153,6 +396,7 @@ module Schwab
           refresh_token: @refresh_token,
           on_token_refresh: method(:handle_token_refresh),
           config: @config,
+          track_auth: method(:track_auth),
         )
       else
         # Build standard connection
@@ -163,6 +407,36 @@ module Schwab
       end
     end
 
+    # Refresh the access token with the current credentials; caller must hold @mutex
+    def reauthenticate!
+      token_data = track_auth do
+        OAuth.refresh_token(
+          refresh_token: @refresh_token,
+          client_id: @config.client_id,
+          client_secret: @config.client_secret,
+          config: @config,
+        )
+      end
+      @reauthenticate = false
+      handle_token_refresh(token_data)
+    rescue ClientClosedError
+      raise
+    rescue => e
+      raise Schwab::TokenExpiredError, "Failed to refresh access token: #{e.message}"
+    end
+
+    # Run a token request so #close can cancel it
+    def track_auth
+      raise ClientClosedError, "Client is closed" if @closed
+
+      @auth_mutex.synchronize { @auth_threads << Thread.current }
+      begin
+        yield
+      ensure
+        @auth_mutex.synchronize { @auth_threads.delete(Thread.current) }
+      end
+    end
+
     def handle_token_refresh(token_data)
       # Update our tokens
       @access_token = token_data[:access_token]
@@ -173,21 +447,24 @@ module Schwab
     end
 
     def request(method, path, params_or_body = {}, resource_class = nil)
-      # Remove leading slash if present to work with Faraday's URL joining
-      path = path.sub(%r{^/}, "")
-
-      response = case method
-      when :get, :delete
-        connection.send(method, path, params_or_body)
-      when :post, :put, :patch
-        connection.send(method, path, params_or_body)
-      else
-        raise ArgumentError, "Unsupported HTTP method: #{method}"
-      end
+      response = raw_request(method, path, params_or_body)
 
+      report_unmapped_keys(path.sub(%r{^/}, ""), response.body, resource_class)
       wrap_response(response.body, resource_class)
-    rescue Faraday::Error => e
-      handle_error(e)
+    end
+
+    # Report response keys the target resource class does not declare
+    #
+    # @param path [String] The request path
+    # @param data [Hash, Array] The response data
+    # @param resource_class [Class, nil] The resource class the response maps to
+    def report_unmapped_keys(path, data, resource_class)
+      callback = @config.on_unmapped_keys
+      return unless callback && resource_class
+
+      items = data.is_a?(Array) ? data : [data]
+      keys = items.flat_map { |item| resource_class.unmapped_keys(item) }.uniq
+      callback.call("/#{path}", keys) unless keys.empty?
     end
 
     # Wrap response data based on configured format
@@ -240,27 +517,54 @@ module Schwab
     end
 
     def handle_error(error)
+      classified = classify_error(error)
+      raise classified if classified
+
       case error
       when Faraday::TimeoutError, Faraday::ConnectionFailed
         raise Schwab::Error, "Request timeout: #{error.message}"
       when Faraday::UnauthorizedError
-        raise Schwab::AuthenticationError, "Authentication failed: #{error.message}"
+        raise Schwab::AuthenticationError.new("Authentication failed: #{error.message}", **error_details(error))
       when Faraday::ForbiddenError
-        raise Schwab::AuthorizationError, "Access forbidden: #{error.message}"
+        raise Schwab::AuthorizationError.new("Access forbidden: #{error.message}", **error_details(error))
       when Faraday::ResourceNotFound
-        raise Schwab::NotFoundError, "Resource not found: #{error.message}"
+        raise Schwab::NotFoundError.new("Resource not found: #{error.message}", **error_details(error))
       when Faraday::TooManyRequestsError
-        raise Schwab::RateLimitError, "Rate limit exceeded: #{error.message}"
+        raise Schwab::RateLimitError.new("Rate limit exceeded: #{error.message}", **error_details(error))
       when Faraday::BadRequestError
         # Preserve the response body for BadRequestError so we can parse JSON error details
-        bad_request_error = Schwab::BadRequestError.new("Bad request: #{error.message}")
-        bad_request_error.response_body = error.response[:body] if error.response && error.response[:body]
-        raise bad_request_error
+        details = error_details(error)
+        if MarketClosedError.rejection?(details[:response_body])
+          raise Schwab::MarketClosedError.new("Market closed: #{error.message}", **details)
+        end
+
+        raise Schwab::BadRequestError.new("Bad request: #{error.message}", **details)
+      when Faraday::ConflictError
+        raise Schwab::ConflictError.new("Conflict: #{error.message}", **error_details(error))
       when Faraday::ServerError
-        raise Schwab::ServerError, "Server error: #{error.message}"
+        raise Schwab::ServerError.new("Server error: #{error.message}", **error_details(error))
       else
         raise Schwab::Error, "Request failed: #{error.message}"
       end
     end
+
+    # The error chosen by config.error_classifier for a failed response, or nil
+    def classify_error(error)
+      classifier = @config.error_classifier
+      return unless classifier && error.response
+
+      details = error_details(error)
+      result = classifier.call(details[:status], details[:response_body], details[:response_headers])
+      return result unless result.is_a?(Class)
+
+      message = "Request failed: #{error.message}"
+      result <= ApiError ? result.new(message, **details) : result.new(message)
+    end
+
+    # Status, body, and headers of a failed response, for ApiError
+    def error_details(error)
+      response = error.response || {}
+      { status: response[:status], response_body: response[:body], response_headers: response[:headers] }
+    end
   end
 end
@@ -0,0 +1,54 @@
+# frozen_string_literal: true
+
+require "json"
+
+module Schwab
+  # JSON encoding and decoding of API request and response bodies
+  #
+  # A codec is any object that responds to +encode(object)+, returning a JSON string, and
+  # +decode(string)+, returning the parsed data with string keys. Set one with +config.codec+
+  # to parse large order and transaction lists with a faster library; {Stdlib} is the default.
+  # Whatever a codec raises while decoding is reported as a Schwab::Error naming the request
+  # and the codec, so failures read the same whichever library is in use.
+  #
+  # @example Decode with Oj
+  #   module OjCodec
+  #     def self.encode(object)
+  #       Oj.dump(object, mode: :compat)
+  #     end
+  #
+  #     def self.decode(json)
+  #       Oj.load(json, mode: :compat)
+  #     end
+  #   end
+  #
+  #   Schwab.configure { |config| config.codec = OjCodec }
+  module Codec
+    # Ruby's json library
+    module Stdlib
+      class << self
+        # @param object [Object] Data to encode
+        # @return [String] The JSON text
+        def encode(object)
+          ::JSON.generate(object)
+        end
+
+        # @param json [String] JSON text
+        # @return [Object] The parsed data, with string keys
+        def decode(json)
+          ::JSON.parse(json)
+        end
+      end
+    end
+
+    class << self
+      # Check whether an object can be used as a codec
+      #
+      # @param codec [Object] The candidate
+      # @return [Boolean] True if it responds to #encode and #decode
+      def valid?(codec)
+        codec.respond_to?(:encode) && codec.respond_to?(:decode)
+      end
+    end
+  end
+end
@@ -1,5 +1,22 @@
 # frozen_string_literal: true
 
+require "uri"
+require_relative "quantity"
+require_relative "price"
+require_relative "etag_cache"
+require_relative "quote_cache"
+require_relative "quote_snapshot"
+require_relative "endpoint_limiter"
+require_relative "request_semaphore"
+require_relative "duplicate_order_guard"
+require_relative "latency_alert"
+require_relative "middleware/follow_redirects"
+require_relative "middleware/recorder"
+require_relative "timestamps"
+require_relative "backoff"
+require_relative "token_expiry"
+require_relative "codec"
+
 module Schwab
   # Configuration storage for Schwab SDK
   #
@@ -18,7 +35,10 @@ module Schwab
     # @!attribute redirect_uri
     #   @return [String] OAuth callback URL configured in Schwab developer portal
     # @!attribute api_base_url
-    #   @return [String] Base URL for Schwab API (default: https://api.schwabapi.com)
+    #   @return [String] Base URL for Schwab API (default: https://api.schwabapi.com). An absolute
+    #     http(s) URL with no query or fragment. It may include a path prefix for a gateway that
+    #     mounts Schwab under one, such as https://gw.example.com/external/schwab/; request paths
+    #     are resolved beneath the prefix, with or without a trailing slash.
     # @!attribute api_version
     #   @return [String] API version to use (default: v1)
     # @!attribute logger
@@ -27,16 +47,109 @@ module Schwab
     #   @return [Integer] Request timeout in seconds (default: 30)
     # @!attribute open_timeout
     #   @return [Integer] Connection open timeout in seconds (default: 30)
+    # @!attribute auth_timeout
+    #   @return [Integer] Maximum seconds a single token request may take, independent of +timeout+ (default: 10)
     # @!attribute faraday_adapter
     #   @return [Symbol] Faraday adapter to use (default: Faraday.default_adapter)
     # @!attribute max_retries
     #   @return [Integer] Maximum number of retries for failed requests (default: 3)
     # @!attribute retry_delay
     #   @return [Integer] Delay in seconds between retries (default: 1)
+    # @!attribute [r] backoff
+    #   @return [Boolean, #next_delay, nil] Retry 429/503 responses and network errors up to +max_retries+
+    #     times: true for exponential jitter from +retry_delay+ that honors Retry-After, or a
+    #     strategy from {Backoff}; nil disables automatic retries (default: nil)
     # @!attribute response_format
     #   @return [Symbol] Response format (:hash or :resource, default: :hash)
     #     - :hash returns plain Ruby hashes (default, backward compatible)
     #     - :resource returns Sawyer::Resource-like objects with method access
+    # @!attribute on_unmapped_keys
+    #   @return [Proc, nil] Debug callback invoked as +call(endpoint, keys)+ with response keys
+    #     that the target resource class does not declare (default: nil)
+    # @!attribute recorder_dir
+    #   @return [String, nil] Directory holding recorded HTTP interactions (default: nil)
+    # @!attribute recorder_mode
+    #   @return [Symbol, nil] HTTP recorder mode (:record, :replay, or nil to disable, default: nil)
+    # @!attribute quantity_rounding
+    #   @return [Symbol, nil] Round order leg quantities before sending (:down, :nearest, :up,
+    #     or nil to send quantities unchanged, default: nil). Equities round to whole shares
+    #     unless listed in +fractional_symbols+. See {Quantity.round_order}
+    # @!attribute [r] fractional_symbols
+    #   @return [Array<String>, #call] Fractional-enabled equities whose legs keep their decimals under
+    #     +quantity_rounding+, or a callable given a symbol that returns whether it is (default: empty)
+    # @!attribute [r] price_precision
+    #   @return [Symbol, Integer, nil] Send order prices as fixed-point decimal strings (:auto for
+    #     2 places, or 4 for sub-dollar and forex prices; an Integer for fixed places; nil to send
+    #     prices unchanged, default: nil). See {Price.format}
+    # @!attribute [r] display_precision
+    #   @return [Hash{String => Integer}] Decimal places for displayed prices by asset type, over
+    #     {Price::DISPLAY_PRECISION} (default: empty). See {Price.display}
+    # @!attribute on_request
+    #   @return [Proc, nil] Instrumentation callback invoked as +call(event)+ after every request,
+    #     with :method, :endpoint, :operation, :status, :duration (seconds), :error, and :labels
+    #     (default: nil). See {Schwab.with_operation} and {Schwab.with_labels} for labeling requests
+    # @!attribute [r] etag_cache
+    #   @return [ETagCache, nil] Cache for conditional GET requests, or nil when disabled (default: nil)
+    # @!attribute [r] quote_snapshot
+    #   @return [QuoteSnapshot::Writer, nil] Writer that MarketData.get_quotes results are appended to (default: nil)
+    # @!attribute [r] endpoint_limiter
+    #   @return [EndpointLimiter, nil] Per-endpoint-group rate limits, or nil when none are set (default: nil).
+    #     See {#endpoint_limit}
+    # @!attribute [r] max_concurrent_requests
+    #   @return [Integer, nil] Most API requests in flight at once across every client sharing this
+    #     configuration; further requests wait up to +timeout+ seconds for a slot (default: nil for no cap)
+    # @!attribute [r] request_semaphore
+    #   @return [RequestSemaphore, nil] The semaphore enforcing +max_concurrent_requests+
+    # @!attribute [r] default_timezone
+    #   @return [String, Object] Timezone for response datetimes sent without an offset: a zone name
+    #     ("America/New_York", "UTC"), a fixed offset ("+09:00"), or a TZInfo::Timezone
+    #     (default: "America/New_York"). See {Timestamps.parse}
+    # @!attribute [r] request_signer
+    #   @return [#call, nil] Called as +call(env)+ with each API request's Faraday::Env just before it is
+    #     sent, to attach headers such as a gateway HMAC signature (default: nil). Runs after every other
+    #     middleware (JSON encoding, authorization, retries, the recorder), so it sees the final body
+    #     and headers, and again on each retry. Token requests to the OAuth endpoint are not signed.
+    # @!attribute [r] max_clock_drift
+    #   @return [Numeric] Seconds a saved token's timestamps may be off between machines: a token
+    #     expiring within this many seconds counts as expired, and one issued more than this far in
+    #     the future is distrusted (default: 60). See {TokenExpiry.expired?}
+    # @!attribute [r] codec
+    #   @return [#encode, #decode] Encodes request bodies and decodes JSON responses (default: {Codec::Stdlib}).
+    #     See {Codec}
+    # @!attribute [r] normalize_symbol_case
+    #   @return [Boolean] Upper-case symbols sent to quote requests and in order legs, since Schwab
+    #     returns nothing for lower-case symbols (default: true). See {Symbols.upcase}
+    # @!attribute [r] error_classifier
+    #   @return [#call, nil] Called as +call(status, body, headers)+ for every failed response before the
+    #     default status-code mapping; returns the error to raise (an exception or an Error class), or
+    #     nil for the default handling (default: nil). See {#error_classifier=}
+    # @!attribute [r] duplicate_order_window
+    #   @return [Numeric, nil] Seconds during which Trading.place_order rejects a repeat of an order it
+    #     just placed, or nil to allow repeats (default: nil). See {DuplicateOrderGuard}
+    # @!attribute [r] duplicate_order_guard
+    #   @return [DuplicateOrderGuard, nil] The guard enforcing +duplicate_order_window+
+    # @!attribute [r] audit_sink
+    #   @return [#write, nil] Receives one line of JSON per API request attempt with the method, path,
+    #     status, and bodies, credentials redacted (default: nil). See {Middleware::AuditLog}
+    # @!attribute [r] max_order_notional
+    #   @return [Numeric, nil] Largest estimated notional value (price x quantity x multiplier) Trading.place_order
+    #     accepts for a single order, or nil for no cap (default: nil)
+    # @!attribute [r] quote_order_notional
+    #   @return [Boolean] Quote orders without a price (such as market orders) to estimate their notional
+    #     value against +max_order_notional+; when false they are not checked (default: true)
+    # @!attribute [r] latency_alert
+    #   @return [LatencyAlert, nil] Calls back for requests slower than its threshold (default: nil).
+    #     See {#on_slow_request}
+    # @!attribute [r] quote_cache
+    #   @return [#read, #write, nil] Cache MarketData.get_quotes and get_quote answer fresh quotes from,
+    #     shareable between clients, or nil when disabled (default: nil). See {QuoteCache}
+    # @!attribute [r] max_redirects
+    #   @return [Integer] Redirects followed in a row before TooManyRedirectsError is raised, or 0 to
+    #     return redirect responses without following them (default: 5). A redirect to another host
+    #     drops the Authorization header first. See {Middleware::FollowRedirects}
+    # @!attribute validate_only
+    #   @return [Boolean] Dry-run mode: Trading.place_order and replace_order validate orders locally
+    #     and return them marked VALIDATED_DRY_RUN without any network calls (default: false)
     attr_accessor :client_id,
       :client_secret,
       :redirect_uri,
@@ -45,22 +158,61 @@ module Schwab
       :logger,
       :timeout,
       :open_timeout,
+      :auth_timeout,
       :faraday_adapter,
       :max_retries,
-      :retry_delay
+      :retry_delay,
+      :on_unmapped_keys,
+      :recorder_dir,
+      :validate_only,
+      :on_request
 
-    attr_reader :response_format
+    attr_reader :backoff, :response_format, :recorder_mode, :quantity_rounding, :price_precision, :display_precision, :etag_cache, :quote_snapshot, :endpoint_limiter,
+      :max_concurrent_requests, :request_semaphore, :default_timezone, :request_signer, :max_clock_drift, :codec,
+      :normalize_symbol_case, :error_classifier, :duplicate_order_window, :duplicate_order_guard, :audit_sink,
+      :max_order_notional, :quote_order_notional, :latency_alert, :quote_cache, :max_redirects,
+      :fractional_symbols
 
     def initialize
       @api_base_url = "https://api.schwabapi.com"
       @api_version = "v1"
       @timeout = 30
       @open_timeout = 30
+      @auth_timeout = 10
       @faraday_adapter = Faraday.default_adapter
       @max_retries = 3
       @retry_delay = 1
+      @backoff = nil
       @logger = nil
       @response_format = :hash
+      @on_unmapped_keys = nil
+      @recorder_dir = nil
+      @recorder_mode = nil
+      @quantity_rounding = nil
+      @fractional_symbols = [].freeze
+      @price_precision = nil
+      @display_precision = {}
+      @validate_only = false
+      @on_request = nil
+      @etag_cache = nil
+      @quote_snapshot = nil
+      @endpoint_limiter = nil
+      @max_concurrent_requests = nil
+      @request_semaphore = nil
+      @default_timezone = Timestamps::DEFAULT_TIMEZONE
+      @request_signer = nil
+      @max_clock_drift = TokenExpiry::DEFAULT_MAX_CLOCK_DRIFT
+      @codec = Codec::Stdlib
+      @normalize_symbol_case = true
+      @error_classifier = nil
+      @duplicate_order_window = nil
+      @duplicate_order_guard = nil
+      @audit_sink = nil
+      @max_order_notional = nil
+      @quote_order_notional = true
+      @latency_alert = nil
+      @quote_cache = nil
+      @max_redirects = Middleware::FollowRedirects::DEFAULT_MAX_REDIRECTS
     end
 
     # Set response format with validation
@@ -78,21 +230,372 @@ module Schwab
       @response_format = format
     end
 
+    # Set HTTP recorder mode with validation
+    #
+    # @param mode [Symbol, nil] :record to save live responses, :replay to serve saved ones, nil to disable
+    # @raise [ArgumentError] if mode is not :record, :replay, or nil
+    # @example Replay recorded responses in tests
+    #   config.recorder_dir = "spec/fixtures/recordings"
+    #   config.recorder_mode = :replay
+    def recorder_mode=(mode)
+      unless mode.nil? || Middleware::Recorder::MODES.include?(mode)
+        raise ArgumentError, "Invalid recorder_mode: #{mode}. Must be :record, :replay, or nil"
+      end
+
+      @recorder_mode = mode
+    end
+
+    # Set order quantity rounding with validation
+    #
+    # @param mode [Symbol, nil] :down, :nearest, :up, or nil to disable
+    # @raise [ArgumentError] if mode is not a supported rounding mode or nil
+    # @example Round fractional sizing down to whole shares
+    #   config.quantity_rounding = :down
+    def quantity_rounding=(mode)
+      unless mode.nil? || Quantity::ROUNDING_MODES.include?(mode)
+        raise ArgumentError, "Invalid quantity_rounding: #{mode}. Must be :down, :nearest, :up, or nil"
+      end
+
+      @quantity_rounding = mode
+    end
+
+    # Set the fractional-enabled equities with validation
+    #
+    # @param symbols [Array<String>, #call] Symbols whose equity legs keep their decimals, or a
+    #   callable given a symbol that returns true for fractional-enabled instruments
+    # @raise [ArgumentError] if symbols is neither an Array of Strings nor callable
+    # @example Keep fractional share quantities for two ETFs
+    #   config.quantity_rounding = :down
+    #   config.fractional_symbols = ["VTI", "VOO"]
+    def fractional_symbols=(symbols)
+      if symbols.respond_to?(:call)
+        @fractional_symbols = symbols
+      elsif symbols.is_a?(Array) && symbols.all? { |symbol| symbol.is_a?(String) }
+        @fractional_symbols = symbols.map(&:upcase).freeze
+      else
+        raise ArgumentError, "Invalid fractional_symbols: #{symbols.inspect}. Must be an Array of Strings or callable"
+      end
+    end
+
+    # Set symbol case normalization with validation
+    #
+    # @param enabled [Boolean] true to upper-case symbols before sending them, false to send them as given
+    # @raise [ArgumentError] if enabled is not true or false
+    # @example Send symbols exactly as typed
+    #   config.normalize_symbol_case = false
+    def normalize_symbol_case=(enabled)
+      unless [true, false].include?(enabled)
+        raise ArgumentError, "Invalid normalize_symbol_case: #{enabled.inspect}. Must be true or false"
+      end
+
+      @normalize_symbol_case = enabled
+    end
+
+    # Enable automatic retries with a backoff strategy
+    #
+    # @param strategy [Boolean, #next_delay, nil] true for the default strategy, a strategy object,
+    #   or false/nil to disable retries
+    # @raise [ArgumentError] if strategy does not respond to #next_delay
+    # @example Retry with a constant delay
+    #   config.backoff = Schwab::Backoff::Constant.new(2)
+    def backoff=(strategy)
+      unless [true, false, nil].include?(strategy) || strategy.respond_to?(:next_delay)
+        raise ArgumentError, "Invalid backoff: #{strategy.inspect}. Must be true, false, nil, or respond to #next_delay"
+      end
+
+      @backoff = strategy || nil
+    end
+
+    # Set order price formatting with validation
+    #
+    # @param precision [Symbol, Integer, nil] :auto, a non-negative number of decimal places, or nil to disable
+    # @raise [ArgumentError] if precision is not :auto, a non-negative Integer, or nil
+    # @example Always send four decimal places
+    #   config.price_precision = 4
+    def price_precision=(precision)
+      unless precision.nil? || precision == :auto || (precision.is_a?(Integer) && !precision.negative?)
+        raise ArgumentError, "Invalid price_precision: #{precision.inspect}. Must be :auto, a non-negative Integer, or nil"
+      end
+
+      @price_precision = precision
+    end
+
+    # Set the decimal places used to display prices of each asset type
+    #
+    # @param precision [Hash{String, Symbol => Integer}] Places by asset type; unlisted types keep their defaults
+    # @raise [ArgumentError] if any value is not a non-negative Integer
+    # @example Show option prices to four places and forex to five
+    #   config.display_precision = { option: 4, forex: 5 }
+    def display_precision=(precision)
+      places = precision.to_h.to_h { |type, value| [type.to_s.upcase, value] }
+      invalid = places.reject { |_, value| value.is_a?(Integer) && !value.negative? }
+      unless invalid.empty?
+        raise ArgumentError, "Invalid display_precision: #{invalid.inspect}. Places must be non-negative Integers"
+      end
+
+      @display_precision = places.freeze
+    end
+
+    # Enable or disable ETag caching of GET responses
+    #
+    # @param cache [Boolean, ETagCache, nil] true for a new cache, an ETagCache to share one, or false/nil to disable
+    # @raise [ArgumentError] if cache is not a boolean, ETagCache, or nil
+    # @example Cache instrument lookups
+    #   config.etag_cache = true
+    def etag_cache=(cache)
+      @etag_cache = case cache
+      when true then ETagCache.new
+      when false, nil then nil
+      when ETagCache then cache
+      else
+        raise ArgumentError, "Invalid etag_cache: #{cache.inspect}. Must be true, false, nil, or a Schwab::ETagCache"
+      end
+    end
+
+    # Enable or disable the quote cache
+    #
+    # @param cache [Boolean, #read, nil] true for a new QuoteCache, a cache responding to #read and
+    #   #write to share one, or false/nil to disable
+    # @raise [ArgumentError] if cache is not a boolean, nil, or an object responding to #read and #write
+    # @example Share a cache between two clients
+    #   cache = Schwab::QuoteCache.new(ttl: 2)
+    #   first.config.quote_cache = cache
+    #   second.config.quote_cache = cache
+    def quote_cache=(cache)
+      @quote_cache = case cache
+      when true then QuoteCache.new
+      when false, nil then nil
+      else
+        unless cache.respond_to?(:read) && cache.respond_to?(:write)
+          raise ArgumentError, "Invalid quote_cache: #{cache.inspect}. Must be true, false, nil, or respond to #read and #write"
+        end
+
+        cache
+      end
+    end
+
+    # Capture quote responses for later replay
+    #
+    # @param target [IO, QuoteSnapshot::Writer, nil] Destination for snapshots, or nil to disable
+    # @raise [ArgumentError] if target cannot be written to
+    # @example Append quote snapshots to a file
+    #   config.quote_snapshot = File.open("quotes.jsonl", "a")
+    def quote_snapshot=(target)
+      @quote_snapshot = case target
+      when nil, QuoteSnapshot::Writer then target
+      else
+        raise ArgumentError, "Invalid quote_snapshot: #{target.inspect}. Must respond to #write" unless target.respond_to?(:write)
+
+        QuoteSnapshot::Writer.new(target)
+      end
+    end
+
+    # Limit the request rate for an endpoint group (trading, marketdata, or account)
+    # Limits are enforced per configuration, across every client sharing it.
+    #
+    # @param group [String, Symbol] The endpoint group (see EndpointLimiter::GROUPS)
+    # @param rps [Numeric, nil] Sustained requests per second (default from EndpointLimiter::DEFAULT_LIMITS)
+    # @param burst [Integer, nil] Requests allowed at once before throttling
+    # @raise [ArgumentError] if the group is unknown or the limits are not positive
+    # @return [EndpointLimiter] The limiter
+    # @example Reserve capacity for order submissions
+    #   config.endpoint_limit(:marketdata, rps: 1.5, burst: 5)
+    #   config.endpoint_limit(:trading)
+    def endpoint_limit(group, rps: nil, burst: nil)
+      (@endpoint_limiter ||= EndpointLimiter.new).limit(group, rps: rps, burst: burst)
+    end
+
+    # Cap the number of API requests in flight at once
+    #
+    # @param max [Integer, nil] Requests allowed at once, or nil to remove the cap
+    # @raise [ArgumentError] if max is not a positive Integer or nil
+    # @example Allow at most four outstanding requests
+    #   config.max_concurrent_requests = 4
+    def max_concurrent_requests=(max)
+      @request_semaphore = max.nil? ? nil : RequestSemaphore.new(max)
+      @max_concurrent_requests = max
+    end
+
+    # Call a block whenever a request takes longer than a threshold
+    # Only time waiting on Schwab counts, and the block runs on a background thread (see {LatencyAlert}).
+    #
+    # @param threshold [Numeric, nil] Seconds a request may take, or nil to stop alerting
+    # @yieldparam endpoint [String] The request path
+    # @yieldparam duration [Float] How long the request took, in seconds
+    # @raise [ArgumentError] if threshold is not a positive number or nil, or no block is given
+    # @return [LatencyAlert, nil] The alert
+    # @example Page when Schwab takes over two seconds
+    #   config.on_slow_request(2) { |endpoint, duration| Pager.notify("#{endpoint} took #{duration.round(1)}s") }
+    def on_slow_request(threshold, &callback)
+      @latency_alert = threshold.nil? ? nil : LatencyAlert.new(threshold, &callback)
+    end
+
+    # Reject repeats of recently placed orders
+    #
+    # @param window [Numeric, nil] Seconds an order is remembered, or nil to stop checking
+    # @raise [ArgumentError] if window is not a positive number or nil
+    # @example Catch double submissions within ten seconds
+    #   config.duplicate_order_window = 10
+    def duplicate_order_window=(window)
+      @duplicate_order_guard = window.nil? ? nil : DuplicateOrderGuard.new(window)
+      @duplicate_order_window = window
+    end
+
+    # Set where audit records of every API request are written
+    #
+    # @param sink [#write, nil] An IO or anything responding to #write, or nil to stop auditing
+    # @raise [ArgumentError] if the sink does not respond to #write
+    # @example Append audit records to a file
+    #   config.audit_sink = File.open("schwab-audit.jsonl", "a").tap { |file| file.sync = true }
+    def audit_sink=(sink)
+      unless sink.nil? || sink.respond_to?(:write)
+        raise ArgumentError, "Invalid audit_sink: #{sink.inspect}. Must respond to #write or be nil"
+      end
+
+      @audit_sink = sink
+    end
+
+    # Cap the estimated notional value of any single order
+    #
+    # @param amount [Numeric, nil] The largest notional value allowed, or nil to remove the cap
+    # @raise [ArgumentError] if amount is not a positive number or nil
+    # @example Refuse orders worth more than $50,000
+    #   config.max_order_notional = 50_000
+    def max_order_notional=(amount)
+      unless amount.nil? || (amount.is_a?(Numeric) && amount.positive?)
+        raise ArgumentError, "Invalid max_order_notional: #{amount.inspect}. Must be a positive number or nil"
+      end
+
+      @max_order_notional = amount
+    end
+
+    # Set whether orders without a price are quoted to check them against max_order_notional
+    #
+    # @param enabled [Boolean] true to quote them, false to skip the check and the quote request
+    # @raise [ArgumentError] if enabled is not true or false
+    # @example Only cap orders that carry a price
+    #   config.quote_order_notional = false
+    def quote_order_notional=(enabled)
+      unless [true, false].include?(enabled)
+        raise ArgumentError, "Invalid quote_order_notional: #{enabled.inspect}. Must be true or false"
+      end
+
+      @quote_order_notional = enabled
+    end
+
+    # Set the timezone for datetimes Schwab sends without an offset
+    #
+    # @param timezone [String, Object] A zone name, a fixed offset, or a TZInfo::Timezone
+    # @raise [ArgumentError] if the timezone is not supported
+    # @example Read bare datetimes as UTC
+    #   config.default_timezone = "UTC"
+    def default_timezone=(timezone)
+      Timestamps.zone(timezone)
+      @default_timezone = timezone
+    end
+
+    # Set a hook that signs each API request before it is sent
+    #
+    # @param signer [#call, nil] Called with the request's Faraday::Env; set headers on
+    #   +env.request_headers+. The body (+env.body+) is the final JSON string, or nil.
+    #   Raise to abort the request.
+    # @raise [ArgumentError] if the signer does not respond to #call
+    # @example Sign requests for an internal gateway
+    #   config.request_signer = lambda do |env|
+    #     payload = [env.method.to_s.upcase, env.url.request_uri, env.body.to_s].join("\n")
+    #     env.request_headers["X-Gateway-Signature"] = OpenSSL::HMAC.hexdigest("SHA256", secret, payload)
+    #   end
+    def request_signer=(signer)
+      unless signer.nil? || signer.respond_to?(:call)
+        raise ArgumentError, "Invalid request_signer: #{signer.inspect}. Must respond to #call or be nil"
+      end
+
+      @request_signer = signer
+    end
+
+    # Set a classifier for failed responses, for gateways that use non-standard statuses
+    #
+    # The classifier runs before the default status-code mapping. Return an Error subclass to have
+    # it built with the response's status, body, and headers (like every ApiError), an exception
+    # instance to raise it as is, or nil to fall through to the default handling. Classification
+    # only changes the error raised: retries still follow the real status.
+    #
+    # @param classifier [#call, nil] Called as +call(status, body, headers)+ with the raw response body
+    # @raise [ArgumentError] if the classifier does not respond to #call
+    # @example Treat a gateway's 409 as a rate limit
+    #   config.error_classifier = ->(status, _body, _headers) { Schwab::RateLimitError if status == 409 }
+    def error_classifier=(classifier)
+      unless classifier.nil? || classifier.respond_to?(:call)
+        raise ArgumentError, "Invalid error_classifier: #{classifier.inspect}. Must respond to #call or be nil"
+      end
+
+      @error_classifier = classifier
+    end
+
+    # Set the clock drift tolerated when checking a saved token's expiry
+    #
+    # @param seconds [Numeric] Non-negative number of seconds
+    # @raise [ArgumentError] if seconds is not a non-negative number
+    # @example Allow for hosts up to five minutes apart
+    #   config.max_clock_drift = 300
+    def max_clock_drift=(seconds)
+      unless seconds.is_a?(Numeric) && !seconds.negative?
+        raise ArgumentError, "Invalid max_clock_drift: #{seconds.inspect}. Must be a non-negative number of seconds"
+      end
+
+      @max_clock_drift = seconds
+    end
+
+    # Set how many redirects a request may follow in a row
+    #
+    # @param count [Integer] The limit; 0 returns redirect responses without following them
+    # @raise [ArgumentError] if count is not a non-negative Integer
+    # @example Never follow redirects
+    #   config.max_redirects = 0
+    def max_redirects=(count)
+      unless count.is_a?(Integer) && !count.negative?
+        raise ArgumentError, "Invalid max_redirects: #{count.inspect}. Must be a non-negative Integer"
+      end
+
+      @max_redirects = count
+    end
+
+    # Set the codec used for JSON request and response bodies
+    #
+    # @param codec [#encode, #decode] The codec, or nil for {Codec::Stdlib}
+    # @raise [ArgumentError] if the codec does not respond to #encode and #decode
+    # @example Decode responses with Oj
+    #   config.codec = OjCodec
+    def codec=(codec)
+      codec ||= Codec::Stdlib
+      raise ArgumentError, "Invalid codec: #{codec.inspect}. Must respond to #encode and #decode" unless Codec.valid?(codec)
+
+      @codec = codec
+    end
+
     # Get the full API endpoint URL with version
     def api_endpoint
-      "#{api_base_url}/#{api_version}"
+      "#{api_base_url.to_s.chomp("/")}/#{api_version}"
+    end
+
+    # Get the path prefix of the base URL, for gateways that mount Schwab under one
+    # @return [String] The prefix without a trailing slash (e.g., "/external/schwab"), or "" for none
+    def api_base_path
+      URI.parse(api_base_url.to_s).path.to_s.chomp("/")
+    rescue URI::InvalidURIError
+      ""
     end
 
     # OAuth-specific endpoints
     # @return [String] The OAuth authorization URL
     def oauth_authorize_url
-      "#{api_base_url}/v1/oauth/authorize"
+      "#{api_base_url.to_s.chomp("/")}/v1/oauth/authorize"
     end
 
     # Get the OAuth token endpoint URL
     # @return [String] The OAuth token URL
     def oauth_token_url
-      "#{api_base_url}/v1/oauth/token"
+      "#{api_base_url.to_s.chomp("/")}/v1/oauth/token"
     end
 
     # Validate that required OAuth parameters are present
@@ -115,6 +618,23 @@ module Schwab
       true
     end
 
+    # List the problems that stop a client from authenticating
+    # Unlike {#validate!}, the redirect URI is not required, since clients built from an
+    # existing token never go through the authorization step.
+    #
+    # @return [Array<String>] Error messages (empty when the client configuration is usable)
+    def client_errors
+      errors = []
+      errors << "client_id is blank" if client_id.to_s.strip.empty?
+      errors << "client_secret is blank" if client_secret.to_s.strip.empty?
+      if !valid_base_url?
+        errors << "api_base_url is not an absolute http(s) URL: #{api_base_url.inspect}"
+      elsif base_url_has_query?
+        errors << "api_base_url must not include a query or fragment: #{api_base_url.inspect}"
+      end
+      errors
+    end
+
     # Check if OAuth credentials are configured
     def oauth_configured?
       !client_id.nil? && !client_secret.nil? && !redirect_uri.nil?
@@ -129,12 +649,54 @@ module Schwab
         api_base_url: api_base_url,
         timeout: timeout,
         open_timeout: open_timeout,
+        auth_timeout: auth_timeout,
         faraday_adapter: faraday_adapter,
         max_retries: max_retries,
         retry_delay: retry_delay,
+        backoff: backoff,
         logger: logger,
         response_format: response_format,
+        on_unmapped_keys: on_unmapped_keys,
+        recorder_dir: recorder_dir,
+        recorder_mode: recorder_mode,
+        quantity_rounding: quantity_rounding,
+        fractional_symbols: fractional_symbols,
+        price_precision: price_precision,
+        display_precision: display_precision,
+        validate_only: validate_only,
+        on_request: on_request,
+        etag_cache: etag_cache,
+        quote_snapshot: quote_snapshot,
+        endpoint_limiter: endpoint_limiter,
+        max_concurrent_requests: max_concurrent_requests,
+        default_timezone: default_timezone,
+        request_signer: request_signer,
+        max_clock_drift: max_clock_drift,
+        codec: codec,
+        normalize_symbol_case: normalize_symbol_case,
+        error_classifier: error_classifier,
+        duplicate_order_window: duplicate_order_window,
+        audit_sink: audit_sink,
+        max_order_notional: max_order_notional,
+        quote_order_notional: quote_order_notional,
+        latency_alert: latency_alert,
+        quote_cache: quote_cache,
+        max_redirects: max_redirects,
       }
     end
+
+    private
+
+    def valid_base_url?
+      uri = URI.parse(api_base_url.to_s)
+      uri.is_a?(URI::HTTP) && !uri.host.to_s.empty?
+    rescue URI::InvalidURIError
+      false
+    end
+
+    def base_url_has_query?
+      uri = URI.parse(api_base_url.to_s)
+      !uri.query.nil? || !uri.fragment.nil?
+    end
   end
 end
@@ -3,6 +3,17 @@
 require "faraday"
 require "faraday/middleware"
 require_relative "middleware/authentication"
+require_relative "middleware/recorder"
+require_relative "middleware/rate_limit"
+require_relative "middleware/instrumentation"
+require_relative "middleware/etag_cache"
+require_relative "middleware/endpoint_limit"
+require_relative "middleware/concurrency_limit"
+require_relative "middleware/request_signer"
+require_relative "middleware/codec"
+require_relative "middleware/audit_log"
+require_relative "middleware/latency_monitor"
+require_relative "middleware/follow_redirects"
 
 module Schwab
   # HTTP connection builder for Schwab API
@@ -17,15 +28,42 @@ module Schwab
         config ||= Schwab.configuration || Configuration.new
 
         Faraday.new(url: config.api_base_url) do |conn|
+          # Outermost so timings include everything below
+          use_instrumentation(conn, config)
+          use_endpoint_limit(conn, config)
+          use_concurrency_limit(conn, config)
+
+          # Encode request bodies and parse JSON responses (keeping the raw body) with config.codec
+          use_codec(conn, config)
+
           # Request middleware (executed in order)
-          conn.request(:json) # Encode request bodies as JSON
           conn.request(:authorization, "Bearer", access_token) if access_token
 
           # Response middleware (executed in reverse order)
-          conn.response(:json, content_type: /\bjson$/) # Parse JSON responses
           conn.response(:raise_error) # Raise exceptions for 4xx/5xx responses
           conn.response(:logger, config.logger, { headers: false, bodies: false }) if config.logger
 
+          # Retries below error raising so they see 429/503 responses
+          use_retry(conn, config)
+
+          # Conditional GETs below JSON parsing so cached bodies are parsed too
+          use_etag_cache(conn, config)
+
+          # Follow redirects below authorization, so each hop keeps (or drops) the headers it was sent with
+          use_redirects(conn, config)
+
+          # Record or replay raw responses just above the adapter
+          use_recorder(conn, config)
+
+          # Audit every attempt with the bodies as sent and received
+          use_audit_log(conn, config)
+
+          # Time each attempt against config.on_slow_request
+          use_latency_monitor(conn, config)
+
+          # Sign last, over the final body and headers
+          use_request_signer(conn, config)
+
           # Adapter (must be last)
           conn.adapter(config.faraday_adapter)
 
@@ -41,13 +79,19 @@ module Schwab
       # @param refresh_token [String, nil] Refresh token for automatic refresh
       # @param on_token_refresh [Proc, nil] Callback when token is refreshed
       # @param config [Configuration] Configuration object
+      # @param track_auth [Proc, nil] Wraps each token refresh so the owner can cancel it (see Client#close)
       # @return [Faraday::Connection] Configured connection with refresh capability
-      def build_with_refresh(access_token:, refresh_token: nil, on_token_refresh: nil, config: nil)
+      def build_with_refresh(access_token:, refresh_token: nil, on_token_refresh: nil, config: nil, track_auth: nil)
         config ||= Schwab.configuration || Configuration.new
 
         Faraday.new(url: config.api_base_url) do |conn|
-          # Request middleware
-          conn.request(:json)
+          # Outermost so timings include token refresh retries
+          use_instrumentation(conn, config)
+          use_endpoint_limit(conn, config)
+          use_concurrency_limit(conn, config)
+
+          # Request encoding and response parsing
+          use_codec(conn, config)
 
           # Custom middleware for token refresh will be added here
           if refresh_token
@@ -58,15 +102,23 @@ module Schwab
               client_id: config.client_id,
               client_secret: config.client_secret,
               on_token_refresh: on_token_refresh,
+              config: config,
+              track_auth: track_auth,
             )
           else
             conn.request(:authorization, "Bearer", access_token)
           end
 
           # Response middleware
-          conn.response(:json, content_type: /\bjson$/)
           conn.response(:raise_error)
           conn.response(:logger, config.logger, { headers: false, bodies: false }) if config.logger
+          use_retry(conn, config)
+          use_etag_cache(conn, config)
+          use_redirects(conn, config)
+          use_recorder(conn, config)
+          use_audit_log(conn, config)
+          use_latency_monitor(conn, config)
+          use_request_signer(conn, config)
 
           # Adapter
           conn.adapter(config.faraday_adapter)
@@ -76,6 +128,77 @@ module Schwab
           conn.options.open_timeout = config.open_timeout
         end
       end
+
+      private
+
+      def use_instrumentation(conn, config)
+        return unless config.on_request || config.logger
+
+        conn.use(Middleware::Instrumentation, on_request: config.on_request, logger: config.logger)
+      end
+
+      def use_endpoint_limit(conn, config)
+        return unless config.endpoint_limiter
+
+        conn.use(
+          Middleware::EndpointLimit,
+          limiter: config.endpoint_limiter,
+          logger: config.logger,
+          prefix: config.api_base_path,
+        )
+      end
+
+      def use_concurrency_limit(conn, config)
+        return unless config.request_semaphore
+
+        conn.use(Middleware::ConcurrencyLimit, semaphore: config.request_semaphore, timeout: config.timeout)
+      end
+
+      def use_codec(conn, config)
+        conn.use(Middleware::Codec, codec: config.codec)
+      end
+
+      def use_retry(conn, config)
+        return unless config.backoff
+
+        conn.use(
+          Middleware::RateLimit,
+          max_retries: config.max_retries,
+          retry_delay: config.retry_delay,
+          backoff: config.backoff == true ? nil : config.backoff,
+          logger: config.logger,
+        )
+      end
+
+      def use_etag_cache(conn, config)
+        conn.use(Middleware::ETagCache, cache: config.etag_cache) if config.etag_cache
+      end
+
+      def use_audit_log(conn, config)
+        conn.use(Middleware::AuditLog, sink: config.audit_sink, logger: config.logger) if config.audit_sink
+      end
+
+      def use_latency_monitor(conn, config)
+        conn.use(Middleware::LatencyMonitor, alert: config.latency_alert) if config.latency_alert
+      end
+
+      def use_request_signer(conn, config)
+        conn.use(Middleware::RequestSigner, signer: config.request_signer) if config.request_signer
+      end
+
+      def use_redirects(conn, config)
+        return unless config.max_redirects.positive?
+
+        conn.use(Middleware::FollowRedirects, max_redirects: config.max_redirects)
+      end
+
+      def use_recorder(conn, config)
+        return unless config.recorder_mode
+
+        raise Error, "recorder_dir must be set when recorder_mode is enabled" unless config.recorder_dir
+
+        conn.use(Middleware::Recorder, dir: config.recorder_dir, mode: config.recorder_mode)
+      end
     end
   end
 end
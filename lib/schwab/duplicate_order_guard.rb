@@ -0,0 +1,112 @@
+# frozen_string_literal: true
+
+module Schwab
+  # Remembers recently placed orders to catch accidental resubmissions
+  #
+  # Two orders look like duplicates when they share an account, order type, price, stop price,
+  # and every leg's instruction, quantity, and symbol. Set it with +config.duplicate_order_window+;
+  # Trading.place_order then raises DuplicateOrderError for a repeat within the window unless
+  # called with +allow_duplicate: true+. Fingerprints are kept in memory and evicted once they
+  # are older than the window, so the guard only covers orders placed by this process.
+  class DuplicateOrderGuard
+    # Order fields that make up a fingerprint, besides the legs
+    ORDER_FIELDS = [:orderType, :price, :stopPrice].freeze
+
+    attr_reader :window
+
+    class << self
+      # Fingerprint an order for duplicate detection
+      #
+      # Prices and quantities are compared by value, so 150, 150.0, and "150.00" match.
+      #
+      # @param account_number [String] The account the order is placed in
+      # @param order [Hash, Resources::Order] The order payload
+      # @return [Array] The fingerprint
+      def fingerprint(account_number, order)
+        data = order.to_h
+        fields = ORDER_FIELDS.map { |field| value(read(data, field)) }
+        legs = Array(read(data, :orderLegCollection)).map do |leg|
+          instrument = read(leg, :instrument) || {}
+          [read(leg, :instruction).to_s.upcase, value(read(leg, :quantity)), read(instrument, :symbol).to_s.upcase]
+        end
+        [account_number.to_s, *fields, legs.sort]
+      end
+
+      private
+
+      def value(amount)
+        amount.nil? ? nil : amount.to_s.to_r
+      rescue ArgumentError
+        amount.to_s
+      end
+
+      def read(data, key)
+        data[key] || data[key.to_s]
+      end
+    end
+
+    # @param window [Numeric] Seconds during which a repeated order counts as a duplicate
+    # @raise [ArgumentError] if window is not a positive number
+    def initialize(window)
+      raise ArgumentError, "window must be a positive number, got #{window.inspect}" unless window.is_a?(Numeric) && window.positive?
+
+      @window = window
+      @placed = {}
+      @mutex = Mutex.new
+    end
+
+    # Record an order, raising if it repeats one recorded within the window
+    #
+    # @param fingerprint [Array] The order's fingerprint (see {fingerprint})
+    # @return [void]
+    # @raise [DuplicateOrderError] if the same fingerprint was recorded within the window
+    def check!(fingerprint)
+      @mutex.synchronize do
+        now = clock
+        evict(now)
+        placed_at = @placed[fingerprint]
+        raise DuplicateOrderError.new(fingerprint, age: now - placed_at) if placed_at
+
+        @placed[fingerprint] = now
+      end
+    end
+
+    # Record an order without checking it, restarting its window
+    #
+    # @param fingerprint [Array] The order's fingerprint
+    # @return [void]
+    def record(fingerprint)
+      @mutex.synchronize do
+        @placed.delete(fingerprint)
+        @placed[fingerprint] = clock
+      end
+    end
+
+    # Forget an order, as when Schwab rejected it
+    #
+    # @param fingerprint [Array] The order's fingerprint
+    # @return [void]
+    def forget(fingerprint)
+      @mutex.synchronize { @placed.delete(fingerprint) }
+    end
+
+    # @return [Integer] Orders remembered within the window
+    def size
+      @mutex.synchronize do
+        evict(clock)
+        @placed.size
+      end
+    end
+
+    private
+
+    # Drop fingerprints older than the window; the hash is in recording order
+    def evict(now)
+      @placed.shift while @placed.any? && now - @placed.first.last >= @window
+    end
+
+    def clock
+      Process.clock_gettime(Process::CLOCK_MONOTONIC)
+    end
+  end
+end
@@ -0,0 +1,118 @@
+# frozen_string_literal: true
+
+module Schwab
+  # Client-side rate limits per endpoint group, so one kind of traffic cannot starve another
+  #
+  # Requests are sorted into groups by path: +trading+ (placing, replacing, canceling, and
+  # previewing orders), +marketdata+ (everything under /marketdata), and +account+ (every other
+  # Trader API request, including order lookups). Each configured group is a token bucket that
+  # refills at +rps+ requests per second and holds up to +burst+ requests; a request that finds
+  # the bucket empty waits for its turn. Groups without a limit are not throttled.
+  #
+  # @example Keep quote polling from crowding out order submissions
+  #   Schwab.configure do |config|
+  #     config.endpoint_limit(:marketdata, rps: 1.5, burst: 5)
+  #     config.endpoint_limit(:trading, rps: 0.5, burst: 2)
+  #   end
+  class EndpointLimiter
+    # Supported endpoint groups
+    GROUPS = ["trading", "marketdata", "account"].freeze
+
+    # Limits used when a group is configured without an explicit rate or burst. Schwab allows
+    # 120 requests per minute per endpoint family.
+    DEFAULT_LIMITS = {
+      "trading" => { rps: 2.0, burst: 2 },
+      "marketdata" => { rps: 2.0, burst: 10 },
+      "account" => { rps: 2.0, burst: 5 },
+    }.freeze
+
+    Bucket = Struct.new(:rps, :burst, :tokens, :updated_at)
+
+    class << self
+      # Get the endpoint group for a request
+      #
+      # @param method [Symbol, String] The HTTP method
+      # @param path [String] The request path
+      # @return [String, nil] The group, or nil for paths outside the API
+      def group_for(method, path)
+        path = "/#{path.to_s.sub(%r{^/}, "")}"
+        return "marketdata" if path.start_with?("/marketdata/")
+        return unless path.start_with?("/trader/")
+
+        order_write = path.match?(%r{/orders(/[^/]+)?$}) && method.to_s.downcase != "get"
+        (order_write || path.end_with?("/previewOrder")) ? "trading" : "account"
+      end
+    end
+
+    # @param clock [#call] Monotonic clock returning seconds (for testing)
+    # @param sleeper [#call] Called with the seconds to wait (for testing)
+    def initialize(clock: -> { Process.clock_gettime(Process::CLOCK_MONOTONIC) }, sleeper: ->(seconds) { sleep(seconds) })
+      @clock = clock
+      @sleeper = sleeper
+      @buckets = {}
+      @mutex = Mutex.new
+    end
+
+    # Set the limit for an endpoint group
+    #
+    # @param group [String, Symbol] One of GROUPS
+    # @param rps [Numeric, nil] Sustained requests per second (default from DEFAULT_LIMITS)
+    # @param burst [Integer, nil] Requests allowed at once before throttling (default from DEFAULT_LIMITS)
+    # @raise [ArgumentError] if the group is unknown or the limits are not positive
+    # @return [EndpointLimiter] self
+    def limit(group, rps: nil, burst: nil)
+      group = group.to_s
+      raise ArgumentError, "Unknown endpoint group: #{group}. Must be one of #{GROUPS.join(", ")}" unless GROUPS.include?(group)
+
+      rps ||= DEFAULT_LIMITS[group][:rps]
+      burst ||= DEFAULT_LIMITS[group][:burst]
+      raise ArgumentError, "rps must be positive, got #{rps}" unless rps.is_a?(Numeric) && rps.positive?
+      raise ArgumentError, "burst must be a positive integer, got #{burst}" unless burst.is_a?(Integer) && burst.positive?
+
+      @mutex.synchronize { @buckets[group] = Bucket.new(rps.to_f, burst, burst.to_f, @clock.call) }
+      self
+    end
+
+    # Remove the limit for a group
+    #
+    # @param group [String, Symbol] The group
+    def remove(group)
+      @mutex.synchronize { @buckets.delete(group.to_s) }
+      nil
+    end
+
+    # Get the configured limits
+    #
+    # @return [Hash{String => Hash}] Rate and burst per limited group
+    def limits
+      @mutex.synchronize { @buckets.transform_values { |bucket| { rps: bucket.rps, burst: bucket.burst } } }
+    end
+
+    # Wait until a request may be sent
+    # Each request reserves a slot immediately, so waiting requests go out in arrival order.
+    #
+    # @param method [Symbol, String] The HTTP method
+    # @param path [String] The request path
+    # @return [Float] Seconds spent waiting
+    def acquire(method, path)
+      wait = reserve(self.class.group_for(method, path))
+      @sleeper.call(wait) if wait.positive?
+      wait
+    end
+
+    private
+
+    def reserve(group)
+      @mutex.synchronize do
+        bucket = @buckets[group]
+        return 0.0 unless bucket
+
+        now = @clock.call
+        bucket.tokens = [bucket.tokens + ((now - bucket.updated_at) * bucket.rps), bucket.burst.to_f].min
+        bucket.updated_at = now
+        bucket.tokens -= 1
+        bucket.tokens.negative? ? -bucket.tokens / bucket.rps : 0.0
+      end
+    end
+  end
+end
@@ -1,12 +1,21 @@
 # frozen_string_literal: true
 
+require "json"
+
 module Schwab
   # Base error class for all Schwab SDK errors
   class Error < StandardError; end
 
   # Base class for all API-related errors
+  #
+  # Error details are read from the response body. Responses sent as RFC 7807 problem+json
+  # supply +title+, +detail+, +instance+, and +status+ directly; other responses supply them
+  # through the first entry of their +errors+ array, alongside the plain +message+ and +code+.
   class ApiError < Error
-    attr_reader :status, :response_body, :response_headers
+    # Content type of RFC 7807 problem details responses
+    PROBLEM_CONTENT_TYPE = "application/problem+json"
+
+    attr_reader :response_body, :response_headers
 
     def initialize(message = nil, status: nil, response_body: nil, response_headers: nil)
       super(message)
@@ -14,6 +23,68 @@ module Schwab
       @response_body = response_body
       @response_headers = response_headers
     end
+
+    # Get the HTTP status, falling back to the one reported in the problem details
+    #
+    # @return [Integer, nil] The status code
+    def status
+      @status || problem_details["status"]&.to_i
+    end
+
+    # Check if the response was sent as problem+json
+    #
+    # @return [Boolean] True for application/problem+json responses
+    def problem?
+      headers = response_headers || {}
+      content_type = headers["Content-Type"] || headers["content-type"]
+      content_type.to_s.downcase.start_with?(PROBLEM_CONTENT_TYPE)
+    end
+
+    # @return [String, nil] Short summary of the problem
+    def title
+      problem_details["title"]
+    end
+
+    # @return [String, nil] Explanation specific to this occurrence of the problem
+    def detail
+      problem_details["detail"]
+    end
+
+    # @return [String, nil] URI identifying this occurrence of the problem
+    def instance
+      problem_details["instance"]
+    end
+
+    # @return [String, nil] The +message+ field of a non-problem error body
+    def error_message
+      parsed_body["message"]
+    end
+
+    # @return [String, Integer, nil] The +code+ field of a non-problem error body
+    def error_code
+      parsed_body["code"]
+    end
+
+    # @return [Array] Entries of the body's +errors+ array (messages or problem detail hashes)
+    def errors
+      Array(parsed_body["errors"])
+    end
+
+    private
+
+    def problem_details
+      return parsed_body if problem?
+
+      first = errors.first
+      first.is_a?(Hash) ? first.transform_keys(&:to_s) : {}
+    end
+
+    def parsed_body
+      body = response_body.is_a?(String) ? JSON.parse(response_body) : response_body
+      body.is_a?(Hash) ? body.transform_keys(&:to_s) : {}
+    rescue JSON::ParserError
+      {}
+    end
   end
 
   # Raised when API returns 401 Unauthorized
@@ -46,6 +117,269 @@ module Schwab
     attr_accessor :response_body
   end
 
+  # Raised when Schwab rejects an order because its market is closed
+  #
+  # Schwab reports this as a 400 Bad Request; the rejection is recognized by its error code
+  # or message. Use Trading.next_market_open to find when to resubmit.
+  #
+  # @example Queue the order for the next open
+  #   begin
+  #     Schwab::Trading.place_order(account, order: order)
+  #   rescue Schwab::MarketClosedError
+  #     queue.schedule(order, at: Schwab::Trading.next_market_open)
+  #   end
+  class MarketClosedError < BadRequestError
+    # Error codes Schwab uses for market-closed rejections
+    CODES = ["MARKET_CLOSED", "MARKET_NOT_OPEN"].freeze
+
+    # Messages of market-closed rejections, also used by Resources::Order#reject_reason
+    MESSAGE_PATTERN = Regexp.union(
+      /\bmarkets? (?:is |are )?(?:currently |now )?closed\b/i,
+      /outside (?:of )?(?:regular )?(?:market |trading )+hours/i,
+      /\bsession (?:is )?(?:closed|not open)\b/i,
+      /\bnot open for trading\b/i,
+    )
+
+    class << self
+      # Check if an error response body is a market-closed rejection
+      #
+      # @param response_body [String, Hash, nil] The response body
+      # @return [Boolean] True if the code or any message says the market is closed
+      def rejection?(response_body)
+        error = BadRequestError.new(response_body: response_body)
+        return true if CODES.include?(error.error_code.to_s.upcase)
+
+        messages = [error.error_message, error.title, error.detail] + error.errors.map do |entry|
+          entry.is_a?(Hash) ? entry.values_at("message", "detail", :message, :detail).compact.join(" ") : entry
+        end
+        messages.compact.any? { |message| MESSAGE_PATTERN.match?(message.to_s) }
+      end
+    end
+  end
+
+  # Raised when API returns 409 Conflict
+  class ConflictError < ApiError; end
+
+  # Raised by Trading.cancel_order when Schwab refuses a cancel because of the order's state,
+  # such as an order in the middle of filling
+  #
+  # The order is fetched after the conflict so the caller can decide: retry the cancel shortly
+  # while the order is still open ({#retryable?}), or accept the fill once it is final.
+  #
+  # @example Retry until the order is canceled or filled
+  #   begin
+  #     Schwab::Trading.cancel_order(account, order_id)
+  #   rescue Schwab::OrderStateConflictError => e
+  #     raise unless e.retryable?
+  #
+  #     sleep(0.5)
+  #     retry
+  #   end
+  class OrderStateConflictError < ConflictError
+    # @return [String, Integer] The ID of the order that could not be canceled
+    attr_reader :order_id
+
+    # @return [String, nil] The order's status after the conflict, or nil if it could not be fetched
+    attr_reader :order_status
+
+    # @return [Hash, Resources::Order, nil] The order as fetched after the conflict
+    attr_reader :order
+
+    def initialize(message = nil, order_id: nil, order_status: nil, order: nil, **options)
+      super(message, **options)
+      @order_id = order_id
+      @order_status = order_status
+      @order = order
+    end
+
+    # Check if retrying the cancel can still succeed
+    #
+    # @return [Boolean] True unless the order has reached a terminal status such as FILLED
+    def retryable?
+      !Resources::Order::TERMINAL_STATUSES.include?(order_status.to_s.upcase)
+    end
+  end
+
   # Raised when API returns an unexpected status code
   class UnexpectedResponseError < ApiError; end
+
+  # Failures from a batch operation, one per failed item
+  #
+  # Batch methods such as Accounts.get_many keep going when an item fails. Their results
+  # offer the failures as one MultiError when you only need to know whether anything failed,
+  # or which kinds of failure occurred. +cause+ is the first failure.
+  #
+  # @example Retry a batch that was rate limited
+  #   error = Schwab::Accounts.get_many(numbers).error
+  #   retry_later if error&.include?(Schwab::RateLimitError)
+  class MultiError < Error
+    # @return [Hash{Object => Error}] Each failure keyed by the item it belongs to, in item order
+    attr_reader :errors_by_key
+
+    # @param errors_by_key [Hash{Object => Error}] Failures keyed by item (e.g., account number)
+    def initialize(errors_by_key)
+      @errors_by_key = errors_by_key
+      details = errors_by_key.first(3).map { |key, error| "#{key}: #{error.message}" }
+      details << "..." if errors_by_key.size > 3
+      count = errors_by_key.size
+      super("#{count} batch #{count == 1 ? "item" : "items"} failed (#{details.join("; ")})")
+    end
+
+    # @return [Array<Error>] Every failure
+    def errors
+      errors_by_key.values
+    end
+
+    # Check whether any failure is of a given class, like +rescue+ would match it
+    #
+    # @param error_class [Class] The error class (e.g., RateLimitError)
+    # @return [Boolean] True if any failure is an instance of the class or a subclass
+    def include?(error_class)
+      errors.any? { |error| error.is_a?(error_class) }
+    end
+
+    # @return [Error, nil] The first failure
+    def cause
+      errors.first
+    end
+  end
+
+  # Raised when a request fails client-side validation before it is sent
+  class InvalidRequestError < Error
+    attr_reader :errors
+
+    def initialize(message = nil, errors: [])
+      super(message || errors.join("; "))
+      @errors = errors
+    end
+  end
+
+  # Raised by Trading.place_order_checked when a limit price is too far from the market
+  class PriceDeviationError < InvalidRequestError
+    # @return [Resources::Quote] The quote the limit price was checked against
+    attr_reader :quote
+
+    # @return [Float, nil] The market price used (mid, falling back to last), or nil if the quote had none
+    attr_reader :reference_price
+
+    # @return [Float, nil] How far the limit price is from the reference price, in percent
+    attr_reader :deviation_percent
+
+    def initialize(message, quote:, reference_price: nil, deviation_percent: nil)
+      super(message, errors: [message])
+      @quote = quote
+      @reference_price = reference_price
+      @deviation_percent = deviation_percent
+    end
+  end
+
+  # Raised by Trading.place_order when an order's estimated notional value exceeds config.max_order_notional
+  class OrderNotionalError < InvalidRequestError
+    # @return [Float] The estimated notional value of the order
+    attr_reader :notional
+
+    # @return [Numeric] The configured cap
+    attr_reader :max_notional
+
+    def initialize(message, notional:, max_notional:)
+      super(message, errors: [message])
+      @notional = notional
+      @max_notional = max_notional
+    end
+  end
+
+  # Raised by Client.validated when credentials are blank or the base URL is malformed
+  class InvalidConfigurationError < Error
+    # @return [Array<String>] Every configuration problem found
+    attr_reader :errors
+
+    def initialize(errors)
+      @errors = errors
+      super("Invalid Schwab configuration: #{errors.join("; ")}")
+    end
+  end
+
+  # Raised when required credentials are not found in the environment or credentials file
+  class MissingCredentialsError < Error
+    # @return [Array<String>] Names of the missing environment variables
+    attr_reader :missing
+
+    def initialize(missing)
+      @missing = missing
+      super("Missing Schwab credentials: #{missing.join(", ")}")
+    end
+  end
+
+  # Raised by Accounts.wait_until_ready when an account can never become tradeable
+  class AccountNotTradeableError < Error
+    attr_reader :account_number, :account_status
+
+    def initialize(account_number, account_status)
+      @account_number = account_number
+      @account_status = account_status
+      super("Account #{account_number} cannot trade (status: #{account_status})")
+    end
+  end
+
+  # Raised by Accounts.wait_until_ready when an account is still pending at the timeout
+  class AccountNotReadyError < Error
+    attr_reader :account_number, :account_status
+
+    def initialize(account_number, account_status)
+      @account_number = account_number
+      @account_status = account_status
+      super("Account #{account_number} was not ready for trading before the timeout (status: #{account_status})")
+    end
+  end
+
+  # Raised by Trading.place_order when an order repeats one placed moments ago
+  # (see {DuplicateOrderGuard}); pass +allow_duplicate: true+ to place it anyway
+  class DuplicateOrderError < Error
+    # @return [Array] The order's fingerprint
+    attr_reader :fingerprint
+
+    # @return [Float] Seconds since the matching order was placed
+    attr_reader :age
+
+    def initialize(fingerprint, age:)
+      @fingerprint = fingerprint
+      @age = age
+      super("Order looks like a duplicate of one placed #{age.round(1)}s ago; pass allow_duplicate: true to place it anyway")
+    end
+  end
+
+  # Raised when a request is redirected more than config.max_redirects times in a row
+  class TooManyRedirectsError < Error
+    # @return [Integer] The configured limit
+    attr_reader :max_redirects
+
+    # @return [String, nil] The Location of the redirect that was not followed
+    attr_reader :location
+
+    def initialize(max_redirects, location)
+      @max_redirects = max_redirects
+      @location = location
+      super("Stopped after #{max_redirects} redirects; not following the redirect to #{location}")
+    end
+  end
+
+  # Raised when a client is used after #close, including authentication cut short by #close
+  class ClientClosedError < Error; end
+
+  # Raised for streaming connection, login, and subscription failures
+  class StreamError < Error; end
+
+  # Reported to stream error handlers when a streamer gives up reconnecting and closes
+  class StreamTerminatedError < StreamError; end
+
+  # Raised when a quote is older than the caller's freshness threshold
+  class StaleQuoteError < Error
+    attr_reader :symbol, :age
+
+    def initialize(message = nil, symbol: nil, age: nil)
+      super(message)
+      @symbol = symbol
+      @age = age
+    end
+  end
 end
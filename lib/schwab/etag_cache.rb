@@ -0,0 +1,71 @@
+# frozen_string_literal: true
+
+module Schwab
+  # Thread-safe store of ETags and response bodies for conditional GET requests
+  #
+  # Enable it with +config.etag_cache = true+ (or pass your own instance). GET responses
+  # carrying an ETag are stored per URL; later requests send If-None-Match, and a
+  # 304 Not Modified is answered with the stored body. Mostly useful for slow-changing
+  # data such as instruments and fundamentals.
+  #
+  # @example Inspect and clear the cache
+  #   cache = client.config.etag_cache
+  #   cache.size # => 3
+  #   cache.etag("https://api.schwabapi.com/marketdata/v1/instruments?symbol=AAPL&projection=fundamental")
+  #   cache.clear
+  class ETagCache
+    # A cached response
+    Entry = Struct.new(:etag, :status, :headers, :body, keyword_init: true)
+
+    def initialize
+      @entries = {}
+      @mutex = Mutex.new
+    end
+
+    # Get the cached entry for a URL
+    #
+    # @param url [String] The full request URL, including the query string
+    # @return [Entry, nil] The entry, if any
+    def [](url)
+      @mutex.synchronize { @entries[url] }
+    end
+
+    # Store a response for a URL
+    #
+    # @param url [String] The full request URL
+    # @param entry [Entry] The response to cache
+    def []=(url, entry)
+      @mutex.synchronize { @entries[url] = entry }
+    end
+
+    # Get the cached ETag for a URL
+    #
+    # @param url [String] The full request URL
+    # @return [String, nil] The ETag, if cached
+    def etag(url)
+      self[url]&.etag
+    end
+
+    # Get the cached URLs
+    #
+    # @return [Array<String>] URLs with a cached response
+    def urls
+      @mutex.synchronize { @entries.keys }
+    end
+
+    # Get the number of cached responses
+    #
+    # @return [Integer] The number of entries
+    def size
+      @mutex.synchronize { @entries.size }
+    end
+
+    # Remove one URL, or every entry when no URL is given
+    #
+    # @param url [String, nil] The URL to remove
+    def clear(url = nil)
+      @mutex.synchronize { url ? @entries.delete(url) : @entries.clear }
+      nil
+    end
+  end
+end
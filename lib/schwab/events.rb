@@ -0,0 +1,41 @@
+# frozen_string_literal: true
+
+require_relative "events/bus"
+require_relative "events/order_watcher"
+
+module Schwab
+  # Order and fill notifications fanned out to any number of subscribers
+  #
+  # Each client has one {Events::Bus} ({Client#events}). An {Events::OrderWatcher} started with
+  # {Events::Bus#watch_orders} polls an account's orders and publishes what changed; other
+  # sources can publish to the same bus with {Events::Bus#publish}.
+  #
+  # @example React to fills in two places
+  #   client.events.watch_orders(account_number, interval: 5) { |error| warn(error.message) }
+  #   fills = client.events.subscribe
+  #   Thread.new { fills.each { |event| record(event) if event.is_a?(Schwab::Events::FillEvent) } }
+  module Events
+    # An order was seen for the first time or changed status
+    OrderEvent = Struct.new(:account_number, :order_id, :status, :previous_status, :order, keyword_init: true)
+
+    # More of an order was filled; +quantity+ is the newly filled amount
+    # +execution+ is the execution leg that triggered the event (with its price, quantity, and
+    # time), or nil when Schwab did not report one. Each cumulative fill level is published once.
+    FillEvent = Struct.new(
+      :account_number,
+      :order_id,
+      :quantity,
+      :filled_quantity,
+      :remaining_quantity,
+      :price,
+      :execution,
+      :order,
+      keyword_init: true,
+    ) do
+      # @return [Boolean] True while part of the order is still unfilled
+      def partial?
+        remaining_quantity.to_f.positive?
+      end
+    end
+  end
+end
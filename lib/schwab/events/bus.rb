@@ -0,0 +1,168 @@
+# frozen_string_literal: true
+
+module Schwab
+  module Events
+    # Fan-out of events to independent subscribers
+    #
+    # Every subscriber gets every event in its own bounded buffer, so a slow consumer never
+    # delays the publisher or other subscribers. Backpressure policy: when a subscriber's buffer
+    # is full, its oldest queued event is discarded to make room and counted in
+    # {Subscription#dropped}. Nothing is dropped while a subscriber keeps up within its buffer.
+    class Bus
+      # Events buffered per subscriber before the oldest is dropped
+      DEFAULT_BUFFER = 100
+
+      # @param client [Schwab::Client, nil] Client used by {#watch_orders}
+      def initialize(client = nil)
+        @client = client
+        @subscriptions = []
+        @watchers = []
+        @mutex = Mutex.new
+      end
+
+      # Register a subscriber
+      #
+      # @param buffer [Integer] Events to buffer before dropping the oldest (default: DEFAULT_BUFFER)
+      # @return [Subscription] The subscription; call #cancel to unsubscribe
+      # @raise [ArgumentError] if buffer is not positive
+      def subscribe(buffer: DEFAULT_BUFFER)
+        raise ArgumentError, "buffer must be positive" unless buffer.to_i.positive?
+
+        subscription = Subscription.new(self, buffer.to_i)
+        @mutex.synchronize { @subscriptions << subscription }
+        subscription
+      end
+
+      # Deliver an event to every subscriber
+      #
+      # @param event [Object] The event (usually an OrderEvent or FillEvent)
+      def publish(event)
+        subscriptions = @mutex.synchronize { @subscriptions.dup }
+        subscriptions.each { |subscription| subscription.push(event) }
+      end
+
+      # Get the number of active subscribers
+      #
+      # @return [Integer] The subscriber count
+      def subscriber_count
+        @mutex.synchronize { @subscriptions.size }
+      end
+
+      # Poll an account's orders and publish order and fill events
+      #
+      # @param account_number [String] The account number
+      # @param interval [Numeric] Seconds between polls (default: 5)
+      # @param lookback [Numeric] Seconds of order history to watch (default: one day)
+      # @param client [Schwab::Client, nil] Client to poll with (default: the bus's client)
+      # @yieldparam error [StandardError] A failed poll; polling continues on the next tick
+      # @return [OrderWatcher] The running watcher; call #stop to end it
+      def watch_orders(account_number, interval: 5, lookback: OrderWatcher::DEFAULT_LOOKBACK, client: nil, &on_error)
+        watcher = OrderWatcher.new(
+          account_number,
+          bus: self,
+          client: client || @client,
+          interval: interval,
+          lookback: lookback,
+          &on_error
+        )
+        @mutex.synchronize { @watchers << watcher }
+        watcher.start
+      end
+
+      # Stop every watcher started with {#watch_orders}
+      def stop_watchers
+        watchers = @mutex.synchronize { @watchers.slice!(0..) }
+        watchers.each(&:stop)
+      end
+
+      # @api private
+      def unsubscribe(subscription)
+        @mutex.synchronize { @subscriptions.delete(subscription) }
+      end
+    end
+
+    # One subscriber's buffered view of a {Bus}
+    class Subscription
+      # @return [Integer] Events discarded because the buffer was full
+      attr_reader :dropped
+
+      # @return [Integer] Maximum buffered events
+      attr_reader :buffer
+
+      def initialize(bus, buffer)
+        @bus = bus
+        @buffer = buffer
+        @events = []
+        @dropped = 0
+        @cancelled = false
+        @mutex = Mutex.new
+        @available = ConditionVariable.new
+      end
+
+      # Take the next event, waiting for one if the buffer is empty
+      #
+      # @param timeout [Numeric, nil] Seconds to wait, or nil to wait until an event arrives or the
+      #   subscription is cancelled
+      # @return [Object, nil] The event, or nil on timeout or cancellation
+      def pop(timeout: nil)
+        deadline = Process.clock_gettime(Process::CLOCK_MONOTONIC) + timeout if timeout
+        @mutex.synchronize do
+          while @events.empty? && !@cancelled
+            remaining = deadline && deadline - Process.clock_gettime(Process::CLOCK_MONOTONIC)
+            return if remaining && remaining <= 0
+
+            @available.wait(@mutex, remaining)
+          end
+          @events.shift
+        end
+      end
+
+      # Yield events as they arrive until the subscription is cancelled
+      #
+      # @yieldparam event [Object] Each event
+      def each
+        while (event = pop)
+          yield event
+        end
+      end
+
+      # Stop receiving events; pending #pop calls return nil
+      def cancel
+        @bus.unsubscribe(self)
+        @mutex.synchronize do
+          @cancelled = true
+          @events.clear
+          @available.broadcast
+        end
+      end
+
+      # Check if the subscription was cancelled
+      #
+      # @return [Boolean] True if cancelled
+      def cancelled?
+        @cancelled
+      end
+
+      # Get the number of buffered events
+      #
+      # @return [Integer] The buffered event count
+      def size
+        @mutex.synchronize { @events.size }
+      end
+
+      # @api private
+      def push(event)
+        @mutex.synchronize do
+          return if @cancelled
+
+          if @events.size >= @buffer
+            @events.shift
+            @dropped += 1
+          end
+          @events << event
+          @available.signal
+        end
+      end
+    end
+  end
+end
@@ -0,0 +1,104 @@
+# frozen_string_literal: true
+
+require_relative "../poller"
+
+module Schwab
+  module Events
+    # Polls an account's orders and publishes {OrderEvent} and {FillEvent} to a {Bus}
+    #
+    # The first poll records the current state without publishing, so starting a watcher
+    # does not replay existing orders. Afterwards a new order or status change publishes an
+    # OrderEvent, and any increase in filled quantity publishes a FillEvent carrying the
+    # increment. Fill levels only move up: a poll reporting a lower filled quantity than one
+    # already seen (a stale read) publishes nothing, so no fill level is reported twice.
+    class OrderWatcher
+      include Poller
+
+      # Seconds of order history each poll requests
+      DEFAULT_LOOKBACK = 86_400
+
+      attr_reader :account_number
+
+      # @param account_number [String] The account number
+      # @param bus [Bus] The bus to publish to
+      # @param client [Schwab::Client] The client to poll with
+      # @param interval [Numeric] Seconds between polls
+      # @param lookback [Numeric] Seconds of order history to watch
+      # @yieldparam error [StandardError] A failed poll; polling continues on the next tick
+      # @raise [ArgumentError] if interval is not positive
+      def initialize(account_number, bus:, client:, interval: 5, lookback: DEFAULT_LOOKBACK, &on_error)
+        init_poller(interval)
+        @account_number = account_number
+        @bus = bus
+        @client = client
+        @lookback = lookback
+        @on_error = on_error
+        @known = nil
+      end
+
+      # Poll once and publish what changed since the previous poll
+      #
+      # @return [void]
+      def poll
+        now = Time.now
+        orders = Accounts.get_orders(
+          @account_number,
+          from_entered_time: now - @lookback,
+          to_entered_time: now,
+          client: @client,
+        )
+        orders = Array(orders).map { |order| order.is_a?(Resources::Order) ? order : Resources::Order.new(order.to_h, @client) }
+
+        baseline = @known.nil?
+        @known ||= {}
+        orders.each do |order|
+          previous = @known[order.order_id]
+          filled = [order.filled_quantity, previous ? previous[:filled_quantity] : 0.0].max
+          @known[order.order_id] = { status: order.status, filled_quantity: filled }
+          publish_changes(order, previous) unless baseline
+        end
+      end
+
+      private
+
+      def tick
+        poll
+      rescue StandardError => e
+        @on_error&.call(e)
+      end
+
+      def publish_changes(order, previous)
+        if previous.nil? || previous[:status] != order.status
+          @bus.publish(OrderEvent.new(
+            account_number: @account_number,
+            order_id: order.order_id,
+            status: order.status,
+            previous_status: previous&.dig(:status),
+            order: order,
+          ))
+        end
+
+        filled = order.filled_quantity - (previous ? previous[:filled_quantity] : 0.0)
+        return unless filled.positive?
+
+        execution = last_execution(order)
+        @bus.publish(FillEvent.new(
+          account_number: @account_number,
+          order_id: order.order_id,
+          quantity: filled,
+          filled_quantity: order.filled_quantity,
+          remaining_quantity: [order.remaining_quantity, 0.0].max,
+          price: (execution && execution[:price]) || order.price,
+          execution: execution,
+          order: order,
+        ))
+      end
+
+      # The most recent execution leg, which produced the latest fill
+      def last_execution(order)
+        activity = Array(order[:orderActivityCollection]).last
+        activity && Array(activity[:executionLegs]).last
+      end
+    end
+  end
+end
@@ -0,0 +1,89 @@
+# frozen_string_literal: true
+
+require_relative "accounts"
+
+module Schwab
+  # Several related accounts viewed together for consolidated reporting
+  #
+  # Each query runs the matching per-account {Accounts} call for every member account
+  # concurrently (see {Accounts.fetch_many}). A failed account does not stop the others: its
+  # error is kept in the result, and everything returned is tagged with its account number.
+  #
+  # @example Report a family's holdings
+  #   household = Schwab::Household.new(["123456", "789012"], client: client)
+  #   household.positions.items.each { |item| puts "#{item.account_number}: #{item.value[:instrument][:symbol]}" }
+  #   household.total_balances[:liquidationValue] # => 182400.0
+  class Household
+    # A value from one member account
+    #
+    # @!attribute account_number
+    #   @return [String] The member account it came from
+    # @!attribute value
+    #   @return [Object] The position, order, or balances
+    Item = Struct.new(:account_number, :value, keyword_init: true)
+
+    # Results by member account, with the failures kept per account number
+    class Result < Accounts::ManyResult
+      # @return [Array<Item>] Every value tagged with its account, in member order
+      def items
+        accounts.flat_map do |number, values|
+          (values.is_a?(Array) ? values : [values]).map { |value| Item.new(account_number: number, value: value) }
+        end
+      end
+    end
+
+    # @return [Array<String>] The member account numbers
+    attr_reader :account_numbers
+
+    # @param account_numbers [Array<String>] The member account numbers
+    # @param client [Schwab::Client, nil] Client for every request (uses Schwab.client if not provided)
+    # @param concurrency [Integer] Maximum requests in flight per query (default: 4)
+    # @raise [ArgumentError] if no account numbers are given
+    def initialize(account_numbers, client: nil, concurrency: Accounts::GET_MANY_CONCURRENCY)
+      @account_numbers = Array(account_numbers).map(&:to_s).uniq.freeze
+      raise ArgumentError, "A household needs at least one account number" if @account_numbers.empty?
+
+      @client = client
+      @concurrency = concurrency
+    end
+
+    # Get the positions of every member account
+    #
+    # @param asset_type [String, Symbol, Array, nil] Keep only positions of these asset types
+    # @return [Result] Positions by account number
+    def positions(asset_type: nil)
+      query { |number| Accounts.get_positions(number, asset_type: asset_type, client: @client) }
+    end
+
+    # Get the current balances of every member account
+    #
+    # @return [Result] currentBalances by account number
+    def balances
+      query { |number| Accounts.get_balances(number, client: @client) }
+    end
+
+    # Get the orders of every member account
+    #
+    # @param filters [Hash] Filters accepted by {Accounts.get_orders} (e.g., status:, from_entered_time:)
+    # @return [Result] Orders by account number
+    def orders(**filters)
+      query { |number| Accounts.get_orders(number, **filters, client: @client) }
+    end
+
+    # Sum the numeric balance fields across member accounts
+    #
+    # @param result [Result, nil] Balances already fetched with {#balances} (fetched if not given)
+    # @return [Hash{Symbol => Float}] Each numeric currentBalances field, summed over the accounts that were fetched
+    def total_balances(result = balances)
+      result.accounts.values.each_with_object(Hash.new(0.0)) do |account_balances, totals|
+        account_balances.each { |field, value| totals[field.to_sym] += value if value.is_a?(Numeric) }
+      end.transform_values { |total| total.round(2) }
+    end
+
+    private
+
+    def query(&block)
+      Result.new(**Accounts.fetch_many(@account_numbers, concurrency: @concurrency, &block).to_h)
+    end
+  end
+end
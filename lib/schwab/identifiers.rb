@@ -0,0 +1,144 @@
+# frozen_string_literal: true
+
+require "json"
+require_relative "error"
+
+module Schwab
+  # Typed wrappers that keep account numbers and instrument symbols apart
+  #
+  # Both are plain strings on the wire, so mixing them up usually fails only at the server.
+  # Wrapping values in AccountNumber and InstrumentSymbol makes the service methods reject
+  # the wrong kind up front: market data calls raise on an AccountNumber, and account and
+  # trading calls raise on an InstrumentSymbol. Plain strings are still accepted everywhere.
+  #
+  # @example
+  #   account = Schwab::AccountNumber("123456789")
+  #   symbol = Schwab::InstrumentSymbol("AAPL")
+  #   Schwab::MarketData.get_quote(symbol)
+  #   Schwab::MarketData.get_quote(account) # => ArgumentError
+  module Identifiers
+    # Account numbers and encrypted account hashes are letters and digits only
+    ACCOUNT_NUMBER_PATTERN = /\A[A-Za-z0-9]+\z/
+
+    # Base class for string identifiers; compares equal to other identifiers of the same kind
+    # with the same value, and serializes to JSON as a plain string
+    class Identifier
+      include Comparable
+
+      attr_reader :value
+
+      # @param value [String, #to_s] The identifier
+      # @raise [ArgumentError] if the value is blank
+      def initialize(value)
+        @value = value.to_s.strip.freeze
+        raise ArgumentError, "#{self.class.name.split("::").last} cannot be blank" if @value.empty?
+
+        freeze
+      end
+
+      # @return [String] The identifier
+      def to_s
+        value
+      end
+
+      # @return [Integer, nil] Ordering by value among identifiers of the same kind
+      def <=>(other)
+        value <=> other.value if other.instance_of?(self.class)
+      end
+
+      def eql?(other)
+        other.instance_of?(self.class) && value == other.value
+      end
+
+      def hash
+        [self.class, value].hash
+      end
+
+      def inspect
+        "#<#{self.class.name} #{value}>"
+      end
+
+      # @return [String] The identifier as a JSON string
+      def to_json(*args)
+        value.to_json(*args)
+      end
+    end
+
+    class << self
+      # Convert an account number argument to a string, rejecting instrument symbols
+      # Every service validates account numbers here before putting them in a URL path, so a
+      # malformed one fails locally instead of as a confusing 404.
+      #
+      # @param value [String, Integer, AccountNumber] The account number or encrypted account hash
+      # @return [String] The account number, with surrounding whitespace removed
+      # @raise [ArgumentError] if an InstrumentSymbol was passed
+      # @raise [InvalidRequestError] if the account number is blank or has characters other than letters and digits
+      def account_number!(value)
+        raise ArgumentError, "Expected an account number, got symbol #{value}" if value.is_a?(InstrumentSymbol)
+
+        account_number = value.to_s.strip
+        raise InvalidRequestError.new(errors: ["Account number is blank"]) if account_number.empty?
+        unless ACCOUNT_NUMBER_PATTERN.match?(account_number)
+          raise InvalidRequestError.new(errors: ["Invalid account number: #{value.to_s.inspect}"])
+        end
+
+        account_number
+      end
+
+      # Check if a value is a well-formed account number or encrypted account hash
+      #
+      # @param value [String, Integer, AccountNumber] The account number
+      # @return [Boolean] True if {account_number!} would accept it
+      def valid_account_number?(value)
+        account_number!(value)
+        true
+      rescue InvalidRequestError, ArgumentError
+        false
+      end
+
+      # Convert a symbol argument to a string, rejecting account numbers
+      #
+      # @param value [String, InstrumentSymbol] The symbol
+      # @return [String] The symbol
+      # @raise [ArgumentError] if an AccountNumber was passed
+      def symbol!(value)
+        raise ArgumentError, "Expected a symbol, got account number #{value}" if value.is_a?(AccountNumber)
+
+        value.to_s
+      end
+
+      # Convert symbol arguments to strings, rejecting account numbers
+      #
+      # @param values [String, InstrumentSymbol, Array] The symbols
+      # @return [Array<String>] The symbols
+      # @raise [ArgumentError] if any AccountNumber was passed
+      def symbols!(values)
+        Array(values).map { |value| symbol!(value) }
+      end
+    end
+  end
+
+  # A plain-text account number or encrypted account hash
+  class AccountNumber < Identifiers::Identifier; end
+
+  # An instrument symbol, such as "AAPL" or an OCC option symbol
+  class InstrumentSymbol < Identifiers::Identifier; end
+
+  class << self
+    # Wrap a string as an AccountNumber
+    #
+    # @param value [String, AccountNumber] The account number
+    # @return [AccountNumber] The typed account number
+    def AccountNumber(value) # rubocop:disable Naming/MethodName
+      value.is_a?(AccountNumber) ? value : AccountNumber.new(value)
+    end
+
+    # Wrap a string as an InstrumentSymbol
+    #
+    # @param value [String, InstrumentSymbol] The symbol
+    # @return [InstrumentSymbol] The typed symbol
+    def InstrumentSymbol(value) # rubocop:disable Naming/MethodName
+      value.is_a?(InstrumentSymbol) ? value : InstrumentSymbol.new(value)
+    end
+  end
+end
@@ -0,0 +1,145 @@
+# frozen_string_literal: true
+
+require "set"
+require_relative "market_data"
+
+module Schwab
+  # Trading characteristics of instruments
+  #
+  # Schwab does not publish per-instrument trading rules, so capabilities are derived from the
+  # instrument's asset type (read from its quote), plus the shortable flag Schwab reports in the
+  # quote reference data. Use them to hide order options an instrument cannot accept, or check an
+  # order with {Resources::Order#validate_for_instrument}.
+  module Instruments
+    # What an instrument can trade. +order_types+ and +sessions+ are nil when the asset type is
+    # unknown, in which case every order type and session is reported as supported.
+    TradingCapabilities = Struct.new(:symbol, :asset_type, :order_types, :sessions, :fractional, :shortable,
+      keyword_init: true) do
+      # @param order_type [String, Symbol] The order type (e.g., "LIMIT")
+      # @return [Boolean] True if the order type is allowed
+      def supports_order_type?(order_type)
+        order_types.nil? || order_types.include?(order_type.to_s.upcase)
+      end
+
+      # @param session [String, Symbol] The session (e.g., "AM")
+      # @return [Boolean] True if the session is allowed
+      def supports_session?(session)
+        sessions.nil? || sessions.include?(session.to_s.upcase)
+      end
+
+      # @return [Boolean] True if the instrument trades in fractional quantities
+      def fractional?
+        !!fractional
+      end
+
+      # @return [Boolean, nil] True if Schwab reports the instrument as shortable, nil when unknown
+      def shortable?
+        shortable
+      end
+    end
+
+    # Order types, sessions, and fractional support by asset type
+    ASSET_TYPE_CAPABILITIES = {
+      "EQUITY" => {
+        order_types: [
+          "MARKET",
+          "LIMIT",
+          "STOP",
+          "STOP_LIMIT",
+          "TRAILING_STOP",
+          "TRAILING_STOP_LIMIT",
+          "MARKET_ON_CLOSE",
+          "LIMIT_ON_CLOSE",
+        ],
+        sessions: ["NORMAL", "AM", "PM", "SEAMLESS"],
+        fractional: false,
+      },
+      "OPTION" => {
+        order_types: ["MARKET", "LIMIT", "STOP", "STOP_LIMIT", "NET_DEBIT", "NET_CREDIT", "NET_ZERO"],
+        sessions: ["NORMAL"],
+        fractional: false,
+      },
+      "MUTUAL_FUND" => { order_types: ["MARKET"], sessions: ["NORMAL"], fractional: true },
+      "FIXED_INCOME" => { order_types: ["LIMIT"], sessions: ["NORMAL"], fractional: false },
+      "FUTURE" => { order_types: ["MARKET", "LIMIT", "STOP", "STOP_LIMIT"], sessions: ["NORMAL"], fractional: false },
+      "INDEX" => { order_types: [], sessions: [], fractional: false },
+    }.freeze
+
+    # Symbols looked up per instruments request by {.exists}
+    EXISTS_BATCH_SIZE = 100
+
+    class << self
+      # Get the trading capabilities of an instrument
+      # Looks up the instrument's asset type with a single quote request.
+      #
+      # @param symbol [String] The symbol
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [TradingCapabilities] The capabilities
+      # @example Offer only supported order types
+      #   caps = Schwab::Instruments.trading_capabilities("AAPL")
+      #   caps.order_types # => ["MARKET", "LIMIT", "STOP", ...]
+      #   caps.supports_session?("PM") # => true
+      def trading_capabilities(symbol, client: nil)
+        response = MarketData.get_quote(symbol, fields: "quote,reference", client: client).to_h
+        data = response[symbol] || response[symbol.to_sym] || response.values.find { |value| value.respond_to?(:key?) } || {}
+        quote = Resources::Quote.new(data.to_h)
+        reference = quote[:reference]
+        shortable = reference[:isShortable] if reference
+
+        capabilities_for(quote[:assetSubType] == "ETF" ? "ETF" : quote.asset_type, symbol: symbol, shortable: shortable)
+      end
+
+      # Check which symbols Schwab knows about
+      # Looks symbols up in batches with the instruments search, so each batch is one request
+      # through the client's marketdata rate limit. Symbols are upper-cased like quote requests
+      # unless config.normalize_symbol_case is off.
+      #
+      # @param symbols [String, InstrumentSymbol, Array] The symbols to check
+      # @param batch_size [Integer] Symbols per request
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Hash{String => Boolean}] Existence keyed by each symbol as given
+      # @raise [ArgumentError] If batch_size is not a positive integer
+      # @example Drop unknown symbols before subscribing
+      #   known = Schwab::Instruments.exists(watchlist)
+      #   watchlist.select! { |symbol| known[symbol] }
+      def exists(symbols, batch_size: EXISTS_BATCH_SIZE, client: nil)
+        unless batch_size.is_a?(Integer) && batch_size.positive?
+          raise ArgumentError, "Invalid batch size: #{batch_size.inspect}. Must be a positive integer"
+        end
+
+        symbols = Identifiers.symbols!(symbols)
+        client ||= MarketData.default_client
+        lookups = symbols.to_h { |symbol| [symbol, Symbols.for_request(symbol, client.config)] }
+
+        found = lookups.values.uniq.each_slice(batch_size).flat_map do |batch|
+          response = client.get("/marketdata/v1/instruments", { symbol: batch.join(","), projection: "symbol-search" })
+          instruments = response.to_h[:instruments] || response.to_h["instruments"] || []
+          instruments.map { |instrument| instrument[:symbol] || instrument["symbol"] }
+        end.to_set
+
+        lookups.transform_values { |symbol| found.include?(symbol) }
+      end
+
+      # Get the trading capabilities for an asset type without a network call
+      #
+      # @param asset_type [String, Symbol, nil] The asset type (e.g., "EQUITY", "OPTION")
+      # @param symbol [String, nil] The symbol to record on the result
+      # @param shortable [Boolean, nil] Whether the instrument can be sold short, when known
+      # @return [TradingCapabilities] The capabilities (unrestricted for unknown asset types)
+      def capabilities_for(asset_type, symbol: nil, shortable: nil)
+        type = asset_type.to_s.upcase
+        type = Symbols::EQUITY if Symbols::EQUITY_LIKE_ASSET_TYPES.include?(type)
+        rules = ASSET_TYPE_CAPABILITIES.fetch(type, { order_types: nil, sessions: nil, fractional: false })
+
+        TradingCapabilities.new(
+          symbol: symbol,
+          asset_type: asset_type&.to_s&.upcase,
+          order_types: rules[:order_types],
+          sessions: rules[:sessions],
+          fractional: rules[:fractional],
+          shortable: shortable,
+        )
+      end
+    end
+  end
+end
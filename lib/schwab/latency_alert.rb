@@ -0,0 +1,77 @@
+# frozen_string_literal: true
+
+module Schwab
+  # Calls back when an API request takes longer than a threshold
+  #
+  # A lighter alternative to +on_request+ for simple latency alerting. Each attempt is timed on
+  # its own, from just before it is sent until its response or network error arrives, so client
+  # side waits (rate limits, the concurrency cap, retry backoff) never count against Schwab.
+  # Slow requests are queued and the callback runs on a background thread, so a slow or failing
+  # callback cannot stall requests. When more than MAX_PENDING alerts are waiting, further ones are
+  # dropped and counted in {#dropped}. Set it with +config.on_slow_request+.
+  class LatencyAlert
+    # Alerts queued for the callback before new ones are dropped
+    MAX_PENDING = 100
+
+    attr_reader :threshold
+
+    # @param threshold [Numeric] Seconds a request may take before the callback fires
+    # @yieldparam endpoint [String] The request path
+    # @yieldparam duration [Float] How long the request took, in seconds
+    # @raise [ArgumentError] if threshold is not a positive number or no block is given
+    def initialize(threshold, &callback)
+      unless threshold.is_a?(Numeric) && threshold.positive?
+        raise ArgumentError, "threshold must be a positive number, got #{threshold.inspect}"
+      end
+      raise ArgumentError, "A callback block is required" unless callback
+
+      @threshold = threshold
+      @callback = callback
+      @queue = Queue.new
+      @dropped = 0
+      @mutex = Mutex.new
+      @thread = nil
+    end
+
+    # Record a request's duration, queueing an alert when it is over the threshold
+    #
+    # @param endpoint [String] The request path
+    # @param duration [Float] How long the request took, in seconds
+    # @return [Boolean] True if an alert was queued
+    def observe(endpoint, duration)
+      return false unless duration > threshold
+
+      @mutex.synchronize do
+        if @queue.size >= MAX_PENDING
+          @dropped += 1
+          return false
+        end
+
+        @queue << [endpoint, duration]
+        @thread = Thread.new { run } unless @thread&.alive?
+      end
+      true
+    end
+
+    # Get the number of alerts dropped because the callback fell behind
+    #
+    # @return [Integer] The dropped count
+    def dropped
+      @mutex.synchronize { @dropped }
+    end
+
+    private
+
+    def run
+      loop do
+        endpoint, duration = @queue.pop
+        begin
+          @callback.call(endpoint, duration)
+        rescue StandardError
+          # An alerting failure must not stop later alerts
+          nil
+        end
+      end
+    end
+  end
+end
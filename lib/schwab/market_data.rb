@@ -1,6 +1,11 @@
 # frozen_string_literal: true
 
 require "uri"
+require_relative "symbols"
+require_relative "identifiers"
+require_relative "quote_poller"
+require_relative "quote_cache"
+require_relative "timestamps"
 
 module Schwab
   # Market Data API endpoints for retrieving quotes, price history, and market information
@@ -8,41 +13,152 @@ module Schwab
     class << self
       # Get quotes for one or more symbols
       #
-      # @param symbols [String, Array<String>] Symbol(s) to get quotes for
+      # Whether a quote is real-time or delayed is decided by the account's market data
+      # entitlements, not the request. Each quote reports it via +realtime+ and +quoteType+
+      # (see Resources::Quote#delayed?).
+      #
+      # @param symbols [String, InstrumentSymbol, Array] Symbol(s) to get quotes for
       # @param fields [String, Array<String>, nil] Quote fields to include (e.g., "quote", "fundamental")
-      # @param indicative [Boolean] Whether to include indicative quotes
-      # @param client [Schwab::Client, nil] Optional client instance (uses default if not provided)
-      # @return [Hash] Quote data for the requested symbols
+      # @param indicative [Boolean] Whether to include indicative quotes (e.g., ETF intraday values)
+      # @param normalize [Boolean] Normalize symbols with {Symbols.normalize} before sending (default: false)
+      # @param cached [Boolean] Answer from +config.quote_cache+ when set; false requests every symbol
+      #   and refreshes the cache (default: true)
+      # @param client [Schwab::Client, QuoteSnapshot::Replay, nil] Optional client instance (uses default if not provided)
+      # @return [Hash] Quote data for the requested symbols (also appended to +config.quote_snapshot+ when set).
+      #   With +config.quote_cache+ set, cached quotes are returned and only the other symbols requested.
+      # @raise [ArgumentError] If indicative is not true or false, or an AccountNumber is passed as a symbol
+      # @raise [InvalidRequestError] If normalize is set and a symbol is malformed
       # @example Get quotes for multiple symbols
       #   Schwab::MarketData.get_quotes(["AAPL", "MSFT"])
       # @example Get quotes with specific fields
       #   Schwab::MarketData.get_quotes("AAPL", fields: ["quote", "fundamental"])
-      def get_quotes(symbols, fields: nil, indicative: false, client: nil)
+      def get_quotes(symbols, fields: nil, indicative: false, normalize: false, cached: true, client: nil)
+        unless [true, false].include?(indicative)
+          raise ArgumentError, "Invalid indicative flag: #{indicative.inspect}. Must be true or false"
+        end
+
+        symbols = Identifiers.symbols!(symbols)
+        symbols = symbols.map { |symbol| Symbols.normalize(symbol) } if normalize
+
         client ||= default_client
+        symbols = symbols.map { |symbol| Symbols.for_request(symbol, client.config) }
+        fields = normalize_fields(fields) if fields
+        cache = client.config.quote_cache
+        hits = cache && cached ? cached_quotes(cache, symbols, fields, indicative) : {}
+        requested = cache ? symbols.uniq - hits.keys : symbols
+        return hits if cache && requested.empty? && !symbols.empty?
+
         params = {
-          symbols: normalize_symbols(symbols),
+          symbols: normalize_symbols(requested),
           indicative: indicative,
         }
-        params[:fields] = normalize_fields(fields) if fields
+        params[:fields] = fields if fields
+
+        response = client.get("/marketdata/v1/quotes", params)
+        client.config.quote_snapshot&.write(params[:symbols].split(","), response)
+        return response unless cache
+
+        cache_quotes(cache, requested, response, fields, indicative)
+        hits.merge(response.to_h)
+      end
+
+      # Get quotes and verify none are older than a maximum age
+      #
+      # Stale quotes are re-fetched once; if any are still stale afterwards a
+      # StaleQuoteError is raised for the first offending symbol. Quotes without
+      # a timestamp are treated as stale.
+      #
+      # @param symbols [String, Array<String>] Symbol(s) to get quotes for
+      # @param max_age [Numeric] Maximum acceptable quote age in seconds
+      # @param fields [String, Array<String>, nil] Quote fields to include
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Hash<String, Resources::Quote>] Quotes keyed by symbol
+      # @raise [StaleQuoteError] If a quote is still stale after re-fetching
+      # @example Guard a trading decision against stale data
+      #   quotes = Schwab::MarketData.get_fresh_quotes(["AAPL", "$SPX"], max_age: 15)
+      #   quotes["AAPL"].last_price
+      def get_fresh_quotes(symbols, max_age:, fields: nil, client: nil)
+        client ||= default_client
+        quotes = build_quotes(get_quotes(symbols, fields: fields, client: client), client)
+
+        stale_symbols = quotes.select { |_, quote| quote.stale?(max_age) }.keys
+        unless stale_symbols.empty?
+          quotes.merge!(build_quotes(get_quotes(stale_symbols, fields: fields, cached: false, client: client), client))
+        end
+
+        symbol, stale = quotes.find { |_, quote| quote.stale?(max_age) }
+        if stale
+          age = stale.age
+          raise StaleQuoteError.new(
+            "Quote for #{symbol} is stale (age: #{age ? "#{age.round(1)}s" : "unknown"}, max: #{max_age}s)",
+            symbol: symbol,
+            age: age,
+          )
+        end
+
+        quotes
+      end
+
+      # Get quotes in the order the symbols were given
+      #
+      # Useful when rendering a fixed-order table. Each symbol is requested once; a symbol listed
+      # more than once gets the same quote at every position. Symbols Schwab does not return
+      # (unknown or invalid symbols, reported under "errors") are nil at their position rather
+      # than raising. Response symbols are matched case-insensitively, and with +normalize+ each
+      # input is matched by its normalized form.
+      #
+      # @param symbols [String, InstrumentSymbol, Array] Symbol(s) to get quotes for
+      # @param fields [String, Array<String>, nil] Quote fields to include
+      # @param normalize [Boolean] Normalize symbols with {Symbols.normalize} before sending (default: false)
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Array<Resources::Quote, nil>] One entry per input symbol, nil where no quote came back
+      # @example Render a watchlist in its own order
+      #   symbols = ["MSFT", "AAPL", "NOPE", "MSFT"]
+      #   quotes = Schwab::MarketData.get_quotes_ordered(symbols)
+      #   symbols.zip(quotes).each { |symbol, quote| puts "#{symbol} #{quote&.last_price || "n/a"}" }
+      def get_quotes_ordered(symbols, fields: nil, normalize: false, client: nil)
+        requested = Identifiers.symbols!(symbols)
+        requested = requested.map { |symbol| Symbols.normalize(symbol) } if normalize
+        return [] if requested.empty?
+
+        client ||= default_client
+        response = get_quotes(requested.uniq, fields: fields, client: client)
+        quotes = build_quotes(response, client).transform_keys(&:upcase)
 
-        client.get("/marketdata/v1/quotes", params)
+        requested.map { |symbol| quotes[symbol.upcase] }
       end
 
       # Get detailed quote for a single symbol
       #
       # @param symbol [String] The symbol to get a quote for
       # @param fields [String, Array<String>, nil] Quote fields to include
+      # @param normalize [Boolean] Normalize the symbol with {Symbols.normalize} before sending (default: false)
       # @param client [Schwab::Client, nil] Optional client instance
       # @return [Hash] Detailed quote data for the symbol
+      # @raise [InvalidRequestError] If normalize is set and the symbol is malformed
       # @example Get a single quote
       #   Schwab::MarketData.get_quote("AAPL")
-      def get_quote(symbol, fields: nil, client: nil)
+      # @example Get an index quote from a legacy symbol
+      #   Schwab::MarketData.get_quote("$SPX.X", normalize: true)
+      def get_quote(symbol, fields: nil, normalize: false, client: nil)
+        symbol = Identifiers.symbol!(symbol)
+        symbol = Symbols.normalize(symbol) if normalize
+
         client ||= default_client
+        symbol = Symbols.for_request(symbol, client.config)
         path = "/marketdata/v1/#{URI.encode_www_form_component(symbol)}/quotes"
         params = {}
         params[:fields] = normalize_fields(fields) if fields
 
-        client.get(path, params)
+        cache = client.config.quote_cache
+        return client.get(path, params) unless cache
+
+        cached = cached_quotes(cache, [symbol], params[:fields], false)
+        return cached unless cached.empty?
+
+        response = client.get(path, params)
+        cache_quotes(cache, [symbol], response, params[:fields], false)
+        response
       end
 
       # Get price history for a symbol
@@ -66,7 +182,7 @@ module Schwab
         client ||= default_client
         path = "/marketdata/v1/pricehistory"
 
-        params = { symbol: symbol }
+        params = { symbol: Symbols.for_request(Identifiers.symbol!(symbol), client.config) }
         params[:periodType] = period_type if period_type
         params[:period] = period if period
         params[:frequencyType] = frequency_type if frequency_type
@@ -124,8 +240,59 @@ module Schwab
         get_market_hours(market_id, date: date, client: client)
       end
 
-      private
+      # Stream level-2 order book data for one or more symbols
+      #
+      # @param symbols [String, Array<String>] Symbol(s) to stream
+      # @param services [Array<String>] Book services (NASDAQ_BOOK, NYSE_BOOK, OPTIONS_BOOK)
+      # @param streamer [Streaming::Streamer, nil] Shared streamer to multiplex over (optional)
+      # @param client [Schwab::Client, nil] Optional client instance (uses Schwab.client if not provided)
+      # @yieldparam update [Streaming::BookUpdate] Merged book after each message
+      # @return [Streaming::BookStream] The running stream; call #close to stop it
+      # @example Watch the inside market
+      #   stream = Schwab::MarketData.stream_book("AAPL") do |update|
+      #     puts "#{update.best_bid&.price} x #{update.best_ask&.price}"
+      #   end
+      def stream_book(symbols, services: ["NASDAQ_BOOK", "NYSE_BOOK"], streamer: nil, client: nil, &block)
+        Streaming::BookStream.new(symbols, services: services, streamer: streamer, client: client, &block).start
+      end
 
+      # Stream live OHLCV bars for one or more symbols
+      #
+      # @param symbols [String, Array<String>] Symbol(s) to stream (futures start with "/")
+      # @param frequency [Integer] Bar length in seconds; a multiple of 60 (default: 60)
+      # @param streamer [Streaming::Streamer, nil] Shared streamer to multiplex over (optional)
+      # @param client [Schwab::Client, nil] Optional client instance (uses Schwab.client if not provided)
+      # @yieldparam bar [Streaming::Bar] Each completed bar, including gap bars for intervals without trades
+      # @return [Streaming::BarStream] The running stream; call #current_bar for the partial bar and #close to stop
+      # @example Five-minute bars
+      #   Schwab::MarketData.stream_bars("AAPL", frequency: 300) { |bar| chart.add(bar) }
+      def stream_bars(symbols, frequency: 60, streamer: nil, client: nil, &block)
+        Streaming::BarStream.new(symbols, frequency: frequency, streamer: streamer, client: client, &block).start
+      end
+
+      # Poll quotes for a watchlist on an interval
+      # A REST alternative to streaming; see {QuotePoller}.
+      #
+      # @param symbols [String, Array<String>] Symbol(s) to poll
+      # @param interval [Numeric] Seconds between polls
+      # @param fields [String, Array<String>, nil] Quote fields to include
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @yieldparam quotes [Hash<String, Resources::Quote>, nil] Quotes keyed by symbol, or nil on error
+      # @yieldparam error [StandardError, nil] The error if the poll failed
+      # @return [QuotePoller] The running poller; use #add and #remove to change symbols and #stop to end it
+      # @example Refresh quotes every 10 seconds
+      #   poller = Schwab::MarketData.poll_quotes(["AAPL", "MSFT"], interval: 10) do |quotes, error|
+      #     update_dashboard(quotes) unless error
+      #   end
+      def poll_quotes(symbols, interval:, fields: nil, client: nil, &block)
+        QuotePoller.new(symbols, interval: interval, fields: fields, client: client, &block).start
+      end
+
+      # Get the client market data requests use when none is passed
+      # Built once from the global configuration's tokens; {Instruments} shares it.
+      #
+      # @return [Schwab::Client] The client
+      # @raise [Error] if there is no global configuration
       def default_client
         raise Error, "No client provided and no global configuration available" unless Schwab.configuration
 
@@ -135,6 +302,34 @@ module Schwab
         )
       end
 
+      private
+
+      # Wrap a quotes response in Quote resources keyed by symbol
+      def build_quotes(response, client)
+        response.to_h.each_with_object({}) do |(symbol, data), quotes|
+          next if symbol.to_s == "errors" || !data.respond_to?(:key?)
+
+          quotes[symbol.to_s] = Resources::Quote.new(data.to_h, client)
+        end
+      end
+
+      # Quotes for the symbols found in the quote cache, keyed by symbol
+      def cached_quotes(cache, symbols, fields, indicative)
+        symbols.uniq.each_with_object({}) do |symbol, quotes|
+          quote = cache.read(QuoteCache.key(symbol, fields: fields, indicative: indicative))
+          quotes[symbol] = quote if quote
+        end
+      end
+
+      # Store the quote for each requested symbol present in a quotes response
+      def cache_quotes(cache, symbols, response, fields, indicative)
+        data = response.to_h
+        symbols.each do |symbol|
+          quote = data[symbol] || data[symbol.to_sym]
+          cache.write(QuoteCache.key(symbol, fields: fields, indicative: indicative), quote) if quote.respond_to?(:key?)
+        end
+      end
+
       def normalize_symbols(symbols)
         Array(symbols).join(",")
       end
@@ -156,7 +351,7 @@ module Schwab
         when Integer
           time
         when String
-          (Time.parse(time).to_f * 1000).to_i
+          (Timestamps.parse(time).to_f * 1000).to_i
         else
           raise ArgumentError, "Invalid timestamp format: #{time.class}"
         end
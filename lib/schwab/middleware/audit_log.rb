@@ -0,0 +1,96 @@
+# frozen_string_literal: true
+
+require "faraday"
+require "json"
+require "time"
+require "uri"
+
+module Schwab
+  module Middleware
+    # Faraday middleware that writes every request and response to +config.audit_sink+
+    #
+    # Each attempt (retries and token-refresh replays included) is written as one line of JSON:
+    #
+    #   {"timestamp":"2024-03-15T13:30:00.123Z","method":"POST","path":"/trader/v1/accounts/ABC/orders",
+    #    "status":201,"request_body":"{...}","response_body":""}
+    #
+    # Bodies are written as sent and received, except that values of request body fields and
+    # query parameters named like credentials (see REDACTED_KEYS) are replaced with "[REDACTED]".
+    # Headers, including Authorization, are never written. A request that gets no response has a
+    # nil status and an +error+ field. Writing runs after the response arrives and a failed write
+    # is logged, never raised, so the sink cannot break a request.
+    class AuditLog < Faraday::Middleware
+      # Field and parameter names whose values are redacted
+      REDACTED_KEYS = /token|secret|password|authorization|api_?key|client_id/i
+
+      # Replacement for redacted values
+      REDACTED = "[REDACTED]"
+
+      def initialize(app, options = {})
+        super(app)
+        @sink = options[:sink]
+        @logger = options[:logger]
+      end
+
+      # Send the request and write its audit record
+      # @param env [Faraday::Env] The request environment
+      # @return [Faraday::Response] The response
+      def call(env)
+        request_body = env.body
+        response = @app.call(env)
+        write(env, request_body, response.status, response.body)
+        response
+      rescue Faraday::Error => e
+        response = e.response || {}
+        write(env, request_body, response[:status], response[:body], e)
+        raise
+      end
+
+      private
+
+      def write(env, request_body, status, response_body, error = nil)
+        record = {
+          timestamp: Time.now.utc.iso8601(3),
+          method: env.method.to_s.upcase,
+          path: redact_path(env.url),
+          status: status,
+          request_body: redact_body(request_body),
+          response_body: response_body.is_a?(String) ? response_body : response_body&.to_json,
+        }
+        record[:error] = "#{error.class}: #{error.message}" if error && status.nil?
+        @sink.write("#{JSON.generate(record)}\n")
+      rescue StandardError => e
+        @logger&.warn("Schwab audit sink write failed: #{e.class}: #{e.message}")
+      end
+
+      def redact_path(url)
+        return url.path unless url.query
+
+        params = URI.decode_www_form(url.query).map { |key, value| [key, REDACTED_KEYS.match?(key) ? REDACTED : value] }
+        "#{url.path}?#{URI.encode_www_form(params)}"
+      end
+
+      def redact_body(body)
+        return if body.nil?
+        return JSON.generate(redact(body)) unless body.is_a?(String)
+
+        parsed = JSON.parse(body)
+        redacted = redact(parsed)
+        redacted == parsed ? body : JSON.generate(redacted)
+      rescue JSON::ParserError
+        body.gsub(/([\w.-]*(?:token|secret|password|api_?key|client_id)[\w.-]*=)[^&\s]*/i, "\\1#{REDACTED}")
+      end
+
+      def redact(value)
+        case value
+        when Hash
+          value.to_h { |key, item| [key, REDACTED_KEYS.match?(key.to_s) ? REDACTED : redact(item)] }
+        when Array
+          value.map { |item| redact(item) }
+        else
+          value
+        end
+      end
+    end
+  end
+end
@@ -13,59 +13,59 @@ module Schwab
         @client_id = options[:client_id]
         @client_secret = options[:client_secret]
         @on_token_refresh = options[:on_token_refresh]
+        @config = options[:config]
+        @track_auth = options[:track_auth] || ->(&block) { block.call }
         @mutex = Mutex.new
       end
 
       # Process the request with automatic token refresh on 401
+      #
+      # A 401 (for example from a token revoked server-side before it expired) triggers one
+      # token refresh and exactly one retry. A 401 on the retry is surfaced to the caller
+      # rather than refreshing again.
+      #
       # @param env [Faraday::Env] The request environment
       # @return [Faraday::Response] The response
       def call(env)
-        # Add the current access token to the request
-        env[:request_headers]["Authorization"] = "Bearer #{@access_token}"
-
-        # Make the request
-        response = @app.call(env)
-
-        # Check if token expired (401 Unauthorized)
-        if response.status == 401 && @refresh_token
-          # Thread-safe token refresh
-          @mutex.synchronize do
-            # Double-check in case another thread already refreshed
-            if response.status == 401
-              refresh_access_token!
+        # The adapter replaces env[:body] with the response body, so keep the request body for the retry
+        request_body = env[:body]
+        token = @access_token
+        env[:request_headers]["Authorization"] = "Bearer #{token}"
 
-              # Retry the request with new token
-              env[:request_headers]["Authorization"] = "Bearer #{@access_token}"
-              response = @app.call(env)
-            end
-          end
+        begin
+          response = @app.call(env)
+          return response unless response.status == 401 && @refresh_token
+        rescue Faraday::UnauthorizedError
+          raise unless @refresh_token
         end
 
-        response
-      rescue Faraday::UnauthorizedError => e
-        # If we get an unauthorized error and have a refresh token, try refreshing
-        if @refresh_token
-          @mutex.synchronize do
-            refresh_access_token!
-
-            # Retry the request with new token
-            env[:request_headers]["Authorization"] = "Bearer #{@access_token}"
-            @app.call(env)
-          end
-        else
-          raise e
-        end
+        # Outside the rescue so a 401 on the retry propagates instead of looping
+        retry_with_fresh_token(env, request_body, token)
       end
 
       private
 
+      def retry_with_fresh_token(env, request_body, rejected_token)
+        @mutex.synchronize do
+          # Skip the refresh if another thread already replaced the rejected token
+          refresh_access_token! if @access_token == rejected_token
+        end
+
+        env[:body] = request_body
+        env[:request_headers]["Authorization"] = "Bearer #{@access_token}"
+        @app.call(env)
+      end
+
       def refresh_access_token!
         # Use the OAuth module to refresh the token
-        result = Schwab::OAuth.refresh_token(
-          refresh_token: @refresh_token,
-          client_id: @client_id,
-          client_secret: @client_secret,
-        )
+        result = @track_auth.call do
+          Schwab::OAuth.refresh_token(
+            refresh_token: @refresh_token,
+            client_id: @client_id,
+            client_secret: @client_secret,
+            config: @config,
+          )
+        end
 
         # Update our tokens
         @access_token = result[:access_token]
@@ -75,6 +75,8 @@ module Schwab
         @on_token_refresh&.call(result)
 
         result
+      rescue Schwab::ClientClosedError
+        raise
       rescue => e
         # If refresh fails, wrap the error with more context
         raise Schwab::TokenExpiredError, "Failed to refresh access token: #{e.message}"
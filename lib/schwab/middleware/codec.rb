@@ -0,0 +1,68 @@
+# frozen_string_literal: true
+
+require "faraday"
+
+module Schwab
+  module Middleware
+    # Faraday middleware that encodes request bodies and decodes JSON responses with +config.codec+
+    #
+    # Requests whose body is not already a string are encoded and sent as application/json.
+    # Responses with a JSON content type are decoded, and the undecoded body is kept in
+    # +env[:raw_body]+ for Client#raw_json_request. A decoding failure raises Faraday::ParsingError
+    # with the request, the codec, and the codec's own error in its message.
+    class Codec < Faraday::Middleware
+      # Content types treated as JSON (matched against the media type, without parameters)
+      JSON_CONTENT_TYPE = /\bjson$/
+
+      def initialize(app, options = {})
+        super(app)
+        @codec = options[:codec]
+      end
+
+      # Encode the request body, then decode the response body
+      # @param env [Faraday::Env] The request environment
+      # @return [Faraday::Response] The response
+      def call(env)
+        encode_request(env)
+        method = env[:method].to_s.upcase
+        path = env[:url]&.path
+        @app.call(env).on_complete { |response_env| decode_response(response_env, "#{method} #{path}") }
+      end
+
+      private
+
+      def encode_request(env)
+        body = env[:body]
+        return if body.nil? || body.respond_to?(:to_str)
+
+        content_type = env[:request_headers]["Content-Type"].to_s
+        return unless content_type.empty? || JSON_CONTENT_TYPE.match?(media_type(content_type))
+
+        env[:request_headers]["Content-Type"] = "application/json" if content_type.empty?
+        env[:body] = @codec.encode(body)
+      end
+
+      def decode_response(env, request)
+        body = env[:body]
+        return unless body.respond_to?(:to_str)
+        return unless JSON_CONTENT_TYPE.match?(media_type(env[:response_headers]&.[]("Content-Type").to_s))
+
+        env[:raw_body] = body
+        env[:body] = body.strip.empty? ? nil : @codec.decode(body)
+      rescue StandardError => e
+        raise Faraday::ParsingError.new(
+          "Could not decode the #{request} response with #{codec_name}: #{e.class}: #{e.message}",
+          env[:response],
+        )
+      end
+
+      def media_type(content_type)
+        content_type.split(";").first.to_s.strip
+      end
+
+      def codec_name
+        @codec.is_a?(Module) ? @codec.name : @codec.class.name
+      end
+    end
+  end
+end
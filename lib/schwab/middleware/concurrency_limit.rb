@@ -0,0 +1,33 @@
+# frozen_string_literal: true
+
+require "faraday"
+
+module Schwab
+  module Middleware
+    # Faraday middleware that holds each request until a {Schwab::RequestSemaphore} slot is
+    # free and releases the slot when the response (or error) comes back
+    class ConcurrencyLimit < Faraday::Middleware
+      def initialize(app, options = {})
+        super(app)
+        @semaphore = options[:semaphore]
+        @timeout = options[:timeout]
+      end
+
+      # Take a slot, send the request, and give the slot back
+      # @param env [Faraday::Env] The request environment
+      # @return [Faraday::Response] The response
+      # @raise [Faraday::TimeoutError] if no slot frees up within the timeout
+      def call(env)
+        unless @semaphore.acquire(timeout: @timeout)
+          raise Faraday::TimeoutError, "No request slot free after #{@timeout}s (max #{@semaphore.max} in flight)"
+        end
+
+        begin
+          @app.call(env)
+        ensure
+          @semaphore.release
+        end
+      end
+    end
+  end
+end
@@ -0,0 +1,40 @@
+# frozen_string_literal: true
+
+require "faraday"
+
+module Schwab
+  module Middleware
+    # Faraday middleware that holds each request until its endpoint group has capacity
+    # (see {Schwab::EndpointLimiter})
+    #
+    # When the base URL has a path prefix (see Configuration#api_base_url), it is removed before
+    # the request is grouped, so gateway-mounted requests are limited like direct ones.
+    class EndpointLimit < Faraday::Middleware
+      def initialize(app, options = {})
+        super(app)
+        @limiter = options[:limiter]
+        @logger = options[:logger]
+        @prefix = options[:prefix].to_s
+      end
+
+      # Wait for the request's endpoint group, then send it
+      # @param env [Faraday::Env] The request environment
+      # @return [Faraday::Response] The response
+      def call(env)
+        path = endpoint_path(env[:url].path)
+        waited = @limiter.acquire(env[:method], path)
+        @logger&.debug("[EndpointLimit] Waited #{waited.round(3)}s for #{path}") if waited.positive?
+
+        @app.call(env)
+      end
+
+      private
+
+      def endpoint_path(path)
+        return path if @prefix.empty? || !path.start_with?("#{@prefix}/")
+
+        path.delete_prefix(@prefix)
+      end
+    end
+  end
+end
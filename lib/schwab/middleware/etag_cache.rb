@@ -0,0 +1,46 @@
+# frozen_string_literal: true
+
+require "faraday"
+require_relative "../etag_cache"
+
+module Schwab
+  module Middleware
+    # Faraday middleware that makes GET requests conditional using a {Schwab::ETagCache}
+    #
+    # Sends If-None-Match with the last ETag seen for the URL and, on 304 Not Modified,
+    # returns the cached response instead. Sits below JSON parsing so cached bodies are
+    # parsed like fresh ones.
+    class ETagCache < Faraday::Middleware
+      def initialize(app, options = {})
+        super(app)
+        @cache = options.fetch(:cache)
+      end
+
+      # Add If-None-Match to GET requests and serve cached bodies on 304
+      # @param env [Faraday::Env] The request environment
+      # @return [Faraday::Response] The response
+      def call(env)
+        return @app.call(env) unless env[:method] == :get
+
+        url = env[:url].to_s
+        cached = @cache[url]
+        env[:request_headers]["If-None-Match"] = cached.etag if cached
+
+        @app.call(env).on_complete do |response_env|
+          if response_env[:status] == 304 && cached
+            response_env[:status] = cached.status
+            response_env[:response_headers] = Faraday::Utils::Headers.new(cached.headers)
+            response_env[:body] = cached.body
+          elsif response_env[:status] == 200 && response_env[:response_headers]["ETag"]
+            @cache[url] = Schwab::ETagCache::Entry.new(
+              etag: response_env[:response_headers]["ETag"],
+              status: response_env[:status],
+              headers: response_env[:response_headers].to_h,
+              body: response_env[:body],
+            )
+          end
+        end
+      end
+    end
+  end
+end
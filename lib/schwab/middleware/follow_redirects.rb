@@ -0,0 +1,81 @@
+# frozen_string_literal: true
+
+require "faraday"
+require_relative "../error"
+
+module Schwab
+  module Middleware
+    # Faraday middleware that follows redirects, up to +config.max_redirects+ in a row
+    #
+    # It sits below authorization, so a redirected request keeps the headers it was sent with
+    # rather than being authorized again. When a redirect leads to a different origin (scheme,
+    # host, or port), SENSITIVE_HEADERS are removed first, so the bearer token is never sent to
+    # another host; they stay removed for the rest of the chain. 303 responses, and 301 or 302
+    # responses to anything but GET or HEAD, are followed with a GET and no body; 307 and 308
+    # repeat the method and body. The request signer, audit log, and recorder below see each hop.
+    class FollowRedirects < Faraday::Middleware
+      # Redirects followed in a row by default
+      DEFAULT_MAX_REDIRECTS = 5
+
+      # Statuses that are followed when they carry a Location header
+      REDIRECT_STATUSES = [301, 302, 303, 307, 308].freeze
+
+      # Headers removed before following a redirect to another origin
+      SENSITIVE_HEADERS = ["Authorization", "Proxy-Authorization", "Cookie"].freeze
+
+      def initialize(app, options = {})
+        super(app)
+        @max_redirects = options.fetch(:max_redirects, DEFAULT_MAX_REDIRECTS)
+      end
+
+      # Send the request, following any redirects
+      # @param env [Faraday::Env] The request environment
+      # @return [Faraday::Response] The final response
+      # @raise [TooManyRedirectsError] if more than max_redirects redirects are followed in a row
+      def call(env)
+        body = env[:body]
+        response = @app.call(env)
+        redirects = 0
+        while redirect?(response)
+          location = response.headers["Location"]
+          raise TooManyRedirectsError.new(@max_redirects, location) if redirects >= @max_redirects
+
+          redirects += 1
+          env = redirected_env(response.env.dup, response.status, location, body)
+          body = env[:body]
+          response = @app.call(env)
+        end
+        response
+      end
+
+      private
+
+      def redirect?(response)
+        REDIRECT_STATUSES.include?(response.status) && !response.headers["Location"].to_s.empty?
+      end
+
+      def redirected_env(env, status, location, body)
+        from = env[:url]
+        env[:url] = from + location
+        env[:request_headers] = env[:request_headers].dup
+        [:status, :response, :response_headers, :reason_phrase].each { |key| env[key] = nil }
+
+        if status == 303 || ([301, 302].include?(status) && ![:get, :head].include?(env[:method]))
+          env[:method] = :get
+          env[:body] = nil
+          env[:request_headers].delete("Content-Type")
+        else
+          env[:body] = body
+        end
+        unless same_origin?(from, env[:url])
+          SENSITIVE_HEADERS.each { |header| env[:request_headers].delete(header) }
+        end
+        env
+      end
+
+      def same_origin?(from, to)
+        from.scheme == to.scheme && from.host.to_s.casecmp?(to.host.to_s) && from.port == to.port
+      end
+    end
+  end
+end
@@ -0,0 +1,57 @@
+# frozen_string_literal: true
+
+require "faraday"
+
+module Schwab
+  module Middleware
+    # Faraday middleware that reports each request to the +on_request+ callback and logger
+    #
+    # Every event carries the endpoint path and an operation label: the name set with
+    # {Schwab.with_operation} around the call, or the endpoint path when none is set, plus any
+    # labels set with {Schwab.with_labels}.
+    class Instrumentation < Faraday::Middleware
+      def initialize(app, options = {})
+        super(app)
+        @callback = options[:on_request]
+        @logger = options[:logger]
+      end
+
+      # Time the request and report it, including failed requests
+      # @param env [Faraday::Env] The request environment
+      # @return [Faraday::Response] The response
+      def call(env)
+        started = Process.clock_gettime(Process::CLOCK_MONOTONIC)
+        response = @app.call(env)
+        report(env, response.status, started)
+        response
+      rescue Faraday::Error => e
+        report(env, e.response && e.response[:status], started, e)
+        raise
+      end
+
+      private
+
+      def report(env, status, started, error = nil)
+        endpoint = env[:url].path
+        event = {
+          method: env[:method].to_s.upcase,
+          endpoint: endpoint,
+          operation: Schwab.current_operation || endpoint,
+          status: status,
+          duration: Process.clock_gettime(Process::CLOCK_MONOTONIC) - started,
+          error: error,
+          labels: Schwab.current_labels,
+        }
+
+        @logger&.debug(format_event(event))
+        @callback&.call(event)
+      end
+
+      def format_event(event)
+        "Schwab #{event[:method]} #{event[:endpoint]} operation=#{event[:operation]} " \
+          "status=#{event[:status] || "error"} duration=#{(event[:duration] * 1000).round(1)}ms" +
+          event[:labels].map { |key, value| " #{key}=#{value.to_s.inspect}" }.join
+      end
+    end
+  end
+end
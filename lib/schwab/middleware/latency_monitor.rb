@@ -0,0 +1,30 @@
+# frozen_string_literal: true
+
+require "faraday"
+
+module Schwab
+  module Middleware
+    # Faraday middleware that times each attempt and passes it to a {Schwab::LatencyAlert}
+    #
+    # It sits just above the adapter, so only time spent waiting on Schwab is measured. Requests
+    # that fail without a response, such as timeouts, are timed too.
+    class LatencyMonitor < Faraday::Middleware
+      def initialize(app, options = {})
+        super(app)
+        @alert = options[:alert]
+      end
+
+      # Time the request
+      # @param env [Faraday::Env] The request environment
+      # @return [Faraday::Response] The response
+      def call(env)
+        started = Process.clock_gettime(Process::CLOCK_MONOTONIC)
+        begin
+          @app.call(env)
+        ensure
+          @alert.observe(env.url.path, Process.clock_gettime(Process::CLOCK_MONOTONIC) - started)
+        end
+      end
+    end
+  end
+end
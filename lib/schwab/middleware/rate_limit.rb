@@ -1,11 +1,21 @@
 # frozen_string_literal: true
 
 require "faraday"
+require_relative "../backoff"
 
 module Schwab
   # Middleware components for the HTTP client
   module Middleware
-    # Faraday middleware for handling rate limits with exponential backoff
+    # Faraday middleware for handling rate limits by retrying with backoff
+    #
+    # Delays come from the +backoff+ strategy (see {Schwab::Backoff}); the default is
+    # exponential jitter built from +retry_delay+ and +backoff_factor+, deferring to the
+    # Retry-After header when the server sends one.
+    #
+    # A 429 means the request was refused before it was processed, so it is retried for every
+    # method. Timeouts, connection failures, and 503s leave it unknown whether the request took
+    # effect, so they are retried only for IDEMPOTENT_METHODS: a POST that places an order is
+    # never sent twice.
     class RateLimit < Faraday::Middleware
       # Default maximum number of retries for rate-limited requests
       DEFAULT_MAX_RETRIES = 3
@@ -14,12 +24,17 @@ module Schwab
       # Default exponential backoff factor for retries
       DEFAULT_BACKOFF_FACTOR = 2
       RETRY_STATUSES = [429, 503].freeze # Rate limited and Service Unavailable
+      # Statuses retried for every method, since the request was not processed
+      NON_IDEMPOTENT_RETRY_STATUSES = [429].freeze
+      # Methods that are safe to repeat when the outcome of an attempt is unknown
+      IDEMPOTENT_METHODS = [:get, :head, :put, :delete].freeze
 
       def initialize(app, options = {})
         super(app)
         @max_retries = options[:max_retries] || DEFAULT_MAX_RETRIES
         @retry_delay = options[:retry_delay] || DEFAULT_RETRY_DELAY
         @backoff_factor = options[:backoff_factor] || DEFAULT_BACKOFF_FACTOR
+        @backoff = options[:backoff] || Backoff.default(base: @retry_delay, factor: @backoff_factor)
         @logger = options[:logger]
       end
 
@@ -28,41 +43,37 @@ module Schwab
       # @return [Faraday::Response] The response
       def call(env)
         retries = 0
-        delay = @retry_delay
+        # The adapter replaces env[:body] with the response body, so keep the request body for retries
+        request_body = env[:body]
 
         begin
+          env[:body] = request_body
           response = @app.call(env)
 
           # Check if we should retry this response
-          if should_retry?(response) && retries < @max_retries
+          if should_retry?(env, response) && retries < @max_retries
             retries += 1
-
-            # Check for Retry-After header
-            retry_after = response.headers["retry-after"]
-            wait_time = retry_after ? parse_retry_after(retry_after) : delay
+            wait_time = @backoff.next_delay(retries, response)
 
             log_retry(env, response, retries, wait_time)
 
             # Wait before retrying
             sleep(wait_time)
 
-            # Exponential backoff for next retry
-            delay *= @backoff_factor
-
             # Retry the request by raising a custom error
             raise Faraday::RetriableResponse.new(nil, response)
           end
 
           response
         rescue Faraday::TimeoutError, Faraday::ConnectionFailed => e
-          # Retry on network errors
-          if retries < @max_retries
+          # Retry on network errors, unless the request may have taken effect
+          if idempotent?(env) && retries < @max_retries
             retries += 1
+            wait_time = @backoff.next_delay(retries, nil)
 
-            log_retry_error(env, e, retries, delay)
+            log_retry_error(env, e, retries, wait_time)
 
-            sleep(delay)
-            delay *= @backoff_factor
+            sleep(wait_time)
 
             retry
           else
@@ -76,23 +87,14 @@ module Schwab
 
       private
 
-      def should_retry?(response)
-        RETRY_STATUSES.include?(response.status)
+      def should_retry?(env, response)
+        return false unless RETRY_STATUSES.include?(response.status)
+
+        NON_IDEMPOTENT_RETRY_STATUSES.include?(response.status) || idempotent?(env)
       end
 
-      def parse_retry_after(value)
-        # Retry-After can be in seconds (integer) or HTTP date
-        if value.match?(/^\d+$/)
-          value.to_i
-        else
-          # Parse HTTP date and calculate seconds to wait
-          retry_time = Time.httpdate(value)
-          wait_seconds = retry_time - Time.now
-          wait_seconds > 0 ? wait_seconds : @retry_delay
-        end
-      rescue ArgumentError
-        # If we can't parse it, use default delay
-        @retry_delay
+      def idempotent?(env)
+        IDEMPOTENT_METHODS.include?(env[:method])
       end
 
       def log_retry(env, response, attempt, wait_time)
@@ -101,7 +103,7 @@ module Schwab
         @logger.info(
           "[RateLimit] Retrying request to #{env[:url].path} " \
             "(attempt #{attempt}/#{@max_retries}, status: #{response.status}, " \
-            "waiting: #{wait_time}s)",
+            "waiting: #{wait_time.round(2)}s)",
         )
       end
 
@@ -111,7 +113,7 @@ module Schwab
         @logger.info(
           "[RateLimit] Retrying request to #{env[:url].path} after error " \
             "(attempt #{attempt}/#{@max_retries}, error: #{error.class}, " \
-            "waiting: #{wait_time}s)",
+            "waiting: #{wait_time.round(2)}s)",
         )
       end
     end
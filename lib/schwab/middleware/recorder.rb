@@ -0,0 +1,93 @@
+# frozen_string_literal: true
+
+require "faraday"
+require "fileutils"
+require "json"
+
+module Schwab
+  module Middleware
+    # Faraday middleware that records API interactions to disk and replays them offline
+    #
+    # Interactions are keyed by HTTP method and path (query strings are ignored), one
+    # JSON file per key. In :record mode requests go to the network and each response
+    # is saved, overwriting any previous recording. In :replay mode saved responses are
+    # served without network access. Authorization headers are redacted before writing.
+    #
+    # @example Record once, replay in tests
+    #   Schwab.configure do |config|
+    #     config.recorder_dir = "spec/fixtures/recordings"
+    #     config.recorder_mode = ENV["RECORD"] ? :record : :replay
+    #   end
+    class Recorder < Faraday::Middleware
+      # Supported recorder modes
+      MODES = [:record, :replay].freeze
+      # Headers never written to recordings
+      REDACTED_HEADERS = ["authorization", "cookie", "set-cookie"].freeze
+
+      def initialize(app, options = {})
+        super(app)
+        @dir = options.fetch(:dir)
+        @mode = options.fetch(:mode)
+      end
+
+      # Record or replay the request
+      # @param env [Faraday::Env] The request environment
+      # @return [Faraday::Response] The live or replayed response
+      def call(env)
+        return replay(env) if @mode == :replay
+
+        @app.call(env).on_complete { |response_env| record(env, response_env) }
+      end
+
+      # Path of the recording file for a request
+      #
+      # @param method [Symbol, String] The HTTP method
+      # @param path [String] The request path
+      # @return [String] The recording file path
+      def recording_path(method, path)
+        slug = path.to_s.gsub(%r{\A/+}, "").gsub(/[^A-Za-z0-9.-]+/, "_")
+        File.join(@dir, "#{method.to_s.downcase}_#{slug}.json")
+      end
+
+      private
+
+      def replay(env)
+        file = recording_path(env[:method], env[:url].path)
+        unless File.exist?(file)
+          raise Schwab::Error, "No recording for #{env[:method].to_s.upcase} #{env[:url].path} (expected #{file})"
+        end
+
+        recorded = JSON.parse(File.read(file))["response"]
+        env.status = recorded["status"]
+        env.response_headers = Faraday::Utils::Headers.new(recorded["headers"] || {})
+        env.body = recorded["body"]
+        env.response = Faraday::Response.new(env)
+      end
+
+      def record(env, response_env)
+        interaction = {
+          request: {
+            method: env[:method].to_s.upcase,
+            url: env[:url].to_s,
+            headers: redact(env[:request_headers]),
+          },
+          response: {
+            status: response_env.status,
+            headers: redact(response_env.response_headers),
+            body: response_env.body,
+          },
+        }
+
+        file = recording_path(env[:method], env[:url].path)
+        FileUtils.mkdir_p(File.dirname(file))
+        File.write(file, JSON.pretty_generate(interaction))
+      end
+
+      def redact(headers)
+        (headers || {}).to_h.each_with_object({}) do |(name, value), redacted|
+          redacted[name] = REDACTED_HEADERS.include?(name.to_s.downcase) ? "<REDACTED>" : value
+        end
+      end
+    end
+  end
+end
@@ -0,0 +1,28 @@
+# frozen_string_literal: true
+
+require "faraday"
+
+module Schwab
+  module Middleware
+    # Faraday middleware that hands each outgoing request to +config.request_signer+
+    #
+    # Sits directly above the adapter, so the signer sees the request exactly as it will be sent:
+    # the body is already encoded as JSON, and the Authorization, conditional GET, and every other
+    # header set by the middleware above are in place. Each retry or token-refresh replay passes
+    # through again and is signed afresh.
+    class RequestSigner < Faraday::Middleware
+      def initialize(app, options = {})
+        super(app)
+        @signer = options[:signer]
+      end
+
+      # Sign the request, then send it
+      # @param env [Faraday::Env] The request environment
+      # @return [Faraday::Response] The response
+      def call(env)
+        @signer.call(env)
+        @app.call(env)
+      end
+    end
+  end
+end
@@ -0,0 +1,108 @@
+# frozen_string_literal: true
+
+require_relative "price"
+
+module Schwab
+  # The net limit price of a multi-leg order
+  #
+  # Spreads and other complex option orders are not priced per leg: the order's +price+ is the
+  # net of all legs, and the order type says which way the money moves. Schwab expects the
+  # price as a positive amount with an +orderType+ of NET_DEBIT (you pay), NET_CREDIT (you
+  # receive), or NET_ZERO (even money). A signed amount follows the usual convention that a
+  # debit is positive and a credit negative.
+  #
+  # @example Price a vertical spread at a $1.25 debit
+  #   net = Schwab::NetPrice.debit(1.25)
+  #   order = {
+  #     orderStrategyType: "SINGLE",
+  #     complexOrderStrategyType: "VERTICAL",
+  #     session: "NORMAL",
+  #     duration: "DAY",
+  #     orderLegCollection: legs,
+  #   }.merge(net.order_fields) # => orderType: "NET_DEBIT", price: "1.25"
+  #
+  # @!attribute amount
+  #   @return [Rational] The net price, never negative
+  # @!attribute price_type
+  #   @return [String] NET_DEBIT, NET_CREDIT, or NET_ZERO
+  NetPrice = Struct.new(:amount, :price_type) do
+    # Order types that carry a net price
+    self::TYPES = ["NET_DEBIT", "NET_CREDIT", "NET_ZERO"].freeze
+
+    class << self
+      # @param amount [Numeric, String] The net amount paid
+      # @return [NetPrice]
+      def debit(amount)
+        new(amount, "NET_DEBIT")
+      end
+
+      # @param amount [Numeric, String] The net amount received
+      # @return [NetPrice]
+      def credit(amount)
+        new(amount, "NET_CREDIT")
+      end
+
+      # @return [NetPrice] An even-money net price
+      def zero
+        new(0, "NET_ZERO")
+      end
+
+      # Build a net price from a signed amount
+      #
+      # @param amount [Numeric, String] Positive for a debit, negative for a credit
+      # @return [NetPrice]
+      def from_signed(amount)
+        value = amount.to_s.to_r
+        return zero if value.zero?
+
+        value.positive? ? debit(value) : credit(-value)
+      end
+    end
+
+    # @param amount [Numeric, String] The net price, not negative
+    # @param price_type [String, Symbol] NET_DEBIT, NET_CREDIT, or NET_ZERO
+    # @raise [ArgumentError] if the type is unknown or the amount is negative or not a number
+    def initialize(amount, price_type)
+      type = price_type.to_s.upcase
+      raise ArgumentError, "Unknown net price type: #{price_type.inspect}" unless self.class::TYPES.include?(type)
+
+      value = begin
+        Rational(amount.to_s)
+      rescue ArgumentError, ZeroDivisionError
+        raise ArgumentError, "Invalid net price: #{amount.inspect}"
+      end
+      raise ArgumentError, "Net price cannot be negative: #{amount.inspect}; use a NET_CREDIT price instead" if value.negative?
+      raise ArgumentError, "A NET_ZERO price must be 0" if type == "NET_ZERO" && !value.zero?
+
+      super(value, type)
+    end
+
+    # @return [Boolean] True for a NET_DEBIT price
+    def debit?
+      price_type == "NET_DEBIT"
+    end
+
+    # @return [Boolean] True for a NET_CREDIT price
+    def credit?
+      price_type == "NET_CREDIT"
+    end
+
+    # @return [Rational] The amount, positive for a debit and negative for a credit
+    def signed_amount
+      credit? ? -amount : amount
+    end
+
+    # The orderType and price fields Schwab expects, with the price as a decimal string
+    #
+    # @param precision [Integer, nil] Decimal places, or nil to choose from the price
+    # @return [Hash{Symbol => String}] orderType and price
+    def order_fields(precision: nil)
+      { orderType: price_type, price: Price.format(amount, precision: precision) }
+    end
+
+    # @return [String] e.g., "1.25 NET_DEBIT"
+    def to_s
+      "#{Price.format(amount)} #{price_type}"
+    end
+  end
+end
@@ -38,7 +38,7 @@ module Schwab
       # @param client_secret [String] Your Schwab application's client secret
       # @param redirect_uri [String] The redirect URI used in the authorization request
       # @param config [Configuration, nil] Optional configuration object (uses global config if not provided)
-      # @return [Hash] Token response with :access_token, :refresh_token, :expires_in, :expires_at
+      # @return [Hash] Token response with :access_token, :refresh_token, :expires_in, :expires_at, :issued_at
       def get_token(code:, client_id:, client_secret:, redirect_uri:, config: nil)
         config ||= Schwab.configuration || Configuration.new
         client = oauth2_client(client_id: client_id, client_secret: client_secret, config: config)
@@ -58,7 +58,7 @@ module Schwab
       # @param client_id [String] Your Schwab application's client ID
       # @param client_secret [String] Your Schwab application's client secret
       # @param config [Configuration, nil] Optional configuration object (uses global config if not provided)
-      # @return [Hash] Token response with :access_token, :refresh_token, :expires_in, :expires_at
+      # @return [Hash] Token response with :access_token, :refresh_token, :expires_in, :expires_at, :issued_at
       def refresh_token(refresh_token:, client_id:, client_secret:, config: nil)
         config ||= Schwab.configuration || Configuration.new
         client = oauth2_client(client_id: client_id, client_secret: client_secret, config: config)
@@ -78,6 +78,7 @@ module Schwab
           site: config.api_base_url,
           authorize_url: config.oauth_authorize_url,
           token_url: config.oauth_token_url,
+          connection_opts: { request: { timeout: config.auth_timeout, open_timeout: config.auth_timeout } },
         )
       end
 
@@ -87,6 +88,7 @@ module Schwab
           refresh_token: token.refresh_token,
           expires_in: token.expires_in,
           expires_at: token.expires_at ? Time.at(token.expires_at) : nil,
+          issued_at: Time.now,
           token_type: token.params["token_type"] || "Bearer",
         }
       end
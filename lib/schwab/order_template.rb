@@ -0,0 +1,162 @@
+# frozen_string_literal: true
+
+require_relative "identifiers"
+require_relative "price"
+require_relative "resources/order"
+
+module Schwab
+  # The fixed shape of a single-leg order, filled in with a symbol and quantity for each trade
+  #
+  # A strategy that keeps placing the same kind of order (say, a DAY limit buy a few cents under
+  # the bid) describes it once and builds each order from the template. Prices are either fixed
+  # or an offset from a quote passed to {#build}: +price_reference+ picks the quote price to
+  # start from ("last", "bid", "ask", "mid", or "mark"), and the offset is added to it, so a
+  # negative offset prices below the market. Built orders are validated like any order passed to
+  # Trading.place_order.
+  #
+  # @example Buy 5 cents under the bid
+  #   template = Schwab::OrderTemplate.new(instruction: "BUY", price_offset: -0.05, price_reference: "bid")
+  #   quote = Schwab::MarketData.get_quote("AAPL")
+  #   Schwab::Trading.place_order("123456", order: template.build("AAPL", 10, quote: quote))
+  class OrderTemplate
+    # Quote prices an offset can start from
+    PRICE_REFERENCES = ["last", "bid", "ask", "mid", "mark"].freeze
+
+    # Order types that need a limit price
+    LIMIT_ORDER_TYPES = ["LIMIT", "STOP_LIMIT"].freeze
+
+    # Order types that need a stop price
+    STOP_ORDER_TYPES = ["STOP", "STOP_LIMIT"].freeze
+
+    attr_reader :order_type,
+      :instruction,
+      :duration,
+      :session,
+      :asset_type,
+      :price,
+      :price_offset,
+      :stop_price,
+      :stop_offset,
+      :price_reference
+
+    # @param instruction [String, Symbol] The leg instruction (e.g., "BUY", "SELL_SHORT")
+    # @param order_type [String, Symbol] The order type (default: "LIMIT")
+    # @param duration [String, Symbol] Time in force (default: "DAY")
+    # @param session [String, Symbol] Trading session (default: "NORMAL")
+    # @param asset_type [String, Symbol] The instrument's asset type (default: "EQUITY")
+    # @param price [Numeric, String, nil] A fixed limit price
+    # @param price_offset [Numeric, nil] Limit price as an offset from the quote's reference price
+    # @param stop_price [Numeric, String, nil] A fixed stop price
+    # @param stop_offset [Numeric, nil] Stop price as an offset from the quote's reference price
+    # @param price_reference [String, Symbol] The quote price offsets start from (default: "last")
+    # @raise [ArgumentError] if a price is given both fixed and as an offset, a price the order
+    #   type needs is missing, or the price reference is unknown
+    def initialize(instruction:, order_type: "LIMIT", duration: "DAY", session: "NORMAL", asset_type: "EQUITY",
+      price: nil, price_offset: nil, stop_price: nil, stop_offset: nil, price_reference: "last")
+      @instruction = instruction.to_s.upcase
+      @order_type = order_type.to_s.upcase
+      @duration = duration.to_s.upcase
+      @session = session.to_s.upcase
+      @asset_type = asset_type.to_s.upcase
+      @price_reference = price_reference.to_s.downcase
+      unless PRICE_REFERENCES.include?(@price_reference)
+        raise ArgumentError,
+          "Unknown price reference: #{price_reference.inspect}. Must be one of #{PRICE_REFERENCES.join(", ")}"
+      end
+
+      @price, @price_offset = price_pair("price", price, price_offset, LIMIT_ORDER_TYPES)
+      @stop_price, @stop_offset = price_pair("stop price", stop_price, stop_offset, STOP_ORDER_TYPES)
+    end
+
+    # Check if building needs a quote
+    #
+    # @return [Boolean] True if the limit or stop price is an offset
+    def quote_required?
+      !price_offset.nil? || !stop_offset.nil?
+    end
+
+    # Build an order for a symbol and quantity
+    #
+    # @param symbol [String] The symbol to trade
+    # @param quantity [Numeric] The quantity, positive
+    # @param quote [Resources::Quote, Hash, Numeric, nil] The quote offsets start from (a quote, or
+    #   a MarketData.get_quote response), or the reference price itself; required when {#quote_required?}
+    # @return [Hash] The order payload
+    # @raise [ArgumentError] if the quantity is not positive, or a quote is needed but missing
+    #   or lacks the reference price
+    # @raise [InvalidRequestError] if the built order is invalid
+    def build(symbol, quantity, quote: nil)
+      symbol = Identifiers.symbol!(symbol)
+      unless quantity.is_a?(Numeric) && quantity.positive?
+        raise ArgumentError, "quantity must be a positive number, got #{quantity.inspect}"
+      end
+
+      reference = reference_price(quote, symbol) if quote_required?
+      order = {
+        orderType: order_type,
+        session: session,
+        duration: duration,
+        orderStrategyType: "SINGLE",
+        orderLegCollection: [{
+          instruction: instruction,
+          quantity: quantity,
+          instrument: { symbol: symbol, assetType: asset_type },
+        }],
+      }
+      limit = price || offset_price(reference, price_offset, symbol)
+      stop = stop_price || offset_price(reference, stop_offset, symbol)
+      order[:price] = Price.format(limit, asset_type: asset_type) if limit
+      order[:stopPrice] = Price.format(stop, asset_type: asset_type) if stop
+
+      Resources::Order.new(order).validate!
+      order
+    end
+
+    private
+
+    def price_pair(name, fixed, offset, order_types)
+      raise ArgumentError, "Give a fixed #{name} or an offset, not both" if fixed && offset
+      unless offset.nil? || offset.is_a?(Numeric)
+        raise ArgumentError, "#{name} offset must be a number, got #{offset.inspect}"
+      end
+
+      needed = order_types.include?(order_type)
+      raise ArgumentError, "#{order_type} orders need a #{name} or an offset" if needed && fixed.nil? && offset.nil?
+      raise ArgumentError, "#{order_type} orders do not take a #{name}" if !needed && (fixed || offset)
+
+      [fixed, offset]
+    end
+
+    def offset_price(reference, offset, symbol)
+      return if offset.nil?
+
+      value = reference + offset.to_s.to_r
+      unless value.positive?
+        raise ArgumentError, "Offset #{offset} from #{symbol} at #{Price.format(reference)} is not a positive price"
+      end
+
+      value
+    end
+
+    def reference_price(quote, symbol)
+      raise ArgumentError, "A quote is required to price #{symbol} from an offset" if quote.nil?
+      return quote.to_s.to_r if quote.is_a?(Numeric)
+
+      unless quote.is_a?(Resources::Quote)
+        # MarketData.get_quote responses are keyed by symbol
+        data = quote.to_h
+        quote = Resources::Quote.new((data[symbol] || data[symbol.to_sym] || data).to_h)
+      end
+      value = case price_reference
+      when "bid" then quote.bid_price
+      when "ask" then quote.ask_price
+      when "mid" then quote.mid_price
+      when "mark" then quote.mark
+      else quote.last_price
+      end
+      raise ArgumentError, "The #{symbol} quote has no #{price_reference} price to offset from" unless value
+
+      value.to_s.to_r
+    end
+  end
+end
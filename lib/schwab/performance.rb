@@ -0,0 +1,170 @@
+# frozen_string_literal: true
+
+require "date"
+
+module Schwab
+  # Time-weighted and money-weighted returns from account valuations and external cash flows
+  #
+  # Cash flows are money moved into (positive) or out of (negative) the account from outside:
+  # deposits, withdrawals, and journals. Dividends, interest, and trades are part of the
+  # account's performance, not flows.
+  #
+  # The time-weighted return (TWR) chain-links the return of each period between valuations,
+  # so it measures the investments regardless of when money was added. A valuation is taken at
+  # the end of its day and includes that day's flows; within a period, flows are assumed to
+  # earn nothing until the next valuation. TWR is exact when there is a valuation on every flow
+  # date and an approximation otherwise. Periods that start at a zero or negative value have no
+  # defined return and are skipped (reported in +skipped_periods+).
+  #
+  # The money-weighted return (MWR) is the internal rate of return of the starting value, the
+  # flows, and the ending value, compounded daily on an actual/365 basis (like a spreadsheet's
+  # XIRR), so it reflects the timing and size of the flows. It is nil when no rate solves, as
+  # when the account starts empty and never receives money.
+  #
+  # @example A deposit made before a rally
+  #   report = Schwab::Performance.returns(
+  #     { Date.new(2024, 1, 1) => 1000.0, Date.new(2024, 7, 1) => 1600.0, Date.new(2024, 12, 31) => 2400.0 },
+  #     [Schwab::Performance::CashFlow.new(date: Date.new(2024, 7, 1), amount: 1000.0)],
+  #   )
+  #   report.time_weighted_return  # => -0.1
+  #   report.money_weighted_return # => 0.27186
+  module Performance
+    # Days per year used to compound the money-weighted return
+    DAYS_PER_YEAR = 365.0
+
+    # Largest annual rate searched for the money-weighted return (1,000,000%)
+    MAX_RATE = 10_000.0
+
+    # Bisection steps used to solve the money-weighted return
+    IRR_ITERATIONS = 200
+
+    # Money moved into (positive amount) or out of (negative amount) the account on a date
+    CashFlow = Struct.new(:date, :amount, keyword_init: true)
+
+    # Returns over a date range, as computed by {Performance.returns}
+    #
+    # @!attribute start_date
+    #   @return [Date] First valuation date
+    # @!attribute end_date
+    #   @return [Date] Last valuation date
+    # @!attribute start_value
+    #   @return [Float] Account value at the end of start_date
+    # @!attribute end_value
+    #   @return [Float] Account value at the end of end_date
+    # @!attribute net_cash_flow
+    #   @return [Float] Deposits minus withdrawals after start_date through end_date
+    # @!attribute time_weighted_return
+    #   @return [Float, nil] TWR over the range as a fraction (0.05 is 5%); nil if every period was skipped
+    # @!attribute money_weighted_return
+    #   @return [Float, nil] MWR over the range as a fraction; nil when it cannot be solved
+    # @!attribute annualized_money_weighted_return
+    #   @return [Float, nil] MWR as an annual rate
+    # @!attribute skipped_periods
+    #   @return [Array<Array(Date, Date)>] Periods left out of the TWR because they started at a value of zero or less
+    ReturnsReport = Struct.new(
+      :start_date,
+      :end_date,
+      :start_value,
+      :end_value,
+      :net_cash_flow,
+      :time_weighted_return,
+      :money_weighted_return,
+      :annualized_money_weighted_return,
+      :skipped_periods,
+      keyword_init: true,
+    ) do
+      # @return [Float] Change in value not explained by cash flows
+      def gain
+        (end_value - start_value - net_cash_flow).round(2)
+      end
+    end
+
+    class << self
+      # Compute time-weighted and money-weighted returns
+      #
+      # @param valuations [Hash{Date, String => Numeric}] Account values by date; the earliest and
+      #   latest bound the range, and any in between refine the TWR
+      # @param cash_flows [Array<CashFlow, Hash>] External flows (+:date+ and +:amount+); flows
+      #   outside the range are ignored
+      # @return [ReturnsReport] The returns
+      # @raise [ArgumentError] if fewer than two distinct valuation dates are given
+      def returns(valuations, cash_flows = [])
+        values = valuations.to_h { |date, value| [to_date(date), value.to_f] }.sort.to_h
+        raise ArgumentError, "At least two valuation dates are needed" if values.size < 2
+
+        start_date, start_value = values.first
+        end_date, end_value = values.to_a.last
+        flows = cash_flows.map { |flow| CashFlow.new(date: to_date(read(flow, :date)), amount: read(flow, :amount).to_f) }
+          .select { |flow| flow.date > start_date && flow.date <= end_date }
+
+        twr, skipped = time_weighted(values, flows)
+        rate = internal_rate(start_date, start_value, end_date, end_value, flows)
+        years = (end_date - start_date) / DAYS_PER_YEAR
+
+        ReturnsReport.new(
+          start_date: start_date,
+          end_date: end_date,
+          start_value: start_value,
+          end_value: end_value,
+          net_cash_flow: flows.sum(&:amount).round(2),
+          time_weighted_return: twr&.round(6),
+          money_weighted_return: rate && (((1 + rate)**years) - 1).round(6),
+          annualized_money_weighted_return: rate&.round(6),
+          skipped_periods: skipped,
+        )
+      end
+
+      private
+
+      def time_weighted(values, flows)
+        growth = nil
+        skipped = []
+        values.each_cons(2) do |(from, start_value), (to, end_value)|
+          if start_value <= 0
+            skipped << [from, to]
+            next
+          end
+
+          period_flows = flows.select { |flow| flow.date > from && flow.date <= to }.sum(&:amount)
+          growth = (growth || 1.0) * ((end_value - period_flows) / start_value)
+        end
+        [growth && growth - 1, skipped]
+      end
+
+      # Annual rate at which the discounted flows net to zero, by bisection
+      def internal_rate(start_date, start_value, end_date, end_value, flows)
+        amounts = [[start_date, -start_value]] + flows.map { |flow| [flow.date, -flow.amount] } + [[end_date, end_value]]
+        npv = lambda do |rate|
+          amounts.sum { |date, amount| amount / ((1 + rate)**((date - start_date) / DAYS_PER_YEAR)) }
+        end
+
+        low = -1 + 1e-9
+        high = MAX_RATE
+        low_sign = npv.call(low).positive?
+        return if npv.call(high).positive? == low_sign
+
+        IRR_ITERATIONS.times do
+          mid = (low + high) / 2
+          if npv.call(mid).positive? == low_sign
+            low = mid
+          else
+            high = mid
+          end
+        end
+        (low + high) / 2
+      end
+
+      def to_date(value)
+        case value
+        when Date then value
+        when Time then value.to_date
+        else Date.parse(value.to_s)
+        end
+      end
+
+      def read(data, key)
+        data.respond_to?(key) ? data.public_send(key) : data[key] || data[key.to_s]
+      end
+    end
+  end
+end
@@ -0,0 +1,69 @@
+# frozen_string_literal: true
+
+module Schwab
+  # Background polling loop shared by {QuotePoller} and {Events::OrderWatcher}
+  #
+  # Including classes call #init_poller from their constructor and define a private #tick,
+  # which runs once per interval on the poller thread, the first time as soon as polling
+  # starts. Ticks start +interval+ seconds apart; a tick that runs longer than that is followed
+  # by the next one straight away. #stop wakes the thread rather than waiting out the interval.
+  module Poller
+    attr_reader :interval
+
+    # Start polling on a background thread
+    #
+    # @return [self]
+    def start
+      @mutex.synchronize do
+        return self if @thread&.alive?
+
+        @stopped = false
+        @thread = Thread.new { run }
+      end
+      self
+    end
+
+    # Stop polling
+    #
+    # @param timeout [Numeric] Seconds to wait for an in-flight poll to finish (default: 5)
+    def stop(timeout: 5)
+      @mutex.synchronize do
+        @stopped = true
+        @wakeup.signal
+      end
+      @thread&.join(timeout) unless Thread.current == @thread
+      @thread = nil
+    end
+
+    # Check if the poller is running
+    #
+    # @return [Boolean] True if polling
+    def running?
+      !@stopped && !@thread.nil? && @thread.alive?
+    end
+
+    private
+
+    def init_poller(interval)
+      raise ArgumentError, "interval must be positive" unless interval.to_f.positive?
+
+      @interval = interval
+      @mutex = Mutex.new
+      @wakeup = ConditionVariable.new
+      @stopped = true
+      @thread = nil
+    end
+
+    def run
+      until @stopped
+        started = Process.clock_gettime(Process::CLOCK_MONOTONIC)
+        tick
+
+        elapsed = Process.clock_gettime(Process::CLOCK_MONOTONIC) - started
+        @mutex.synchronize do
+          @wakeup.wait(@mutex, [@interval - elapsed, 0].max) unless @stopped
+        end
+      end
+    end
+  end
+end
@@ -0,0 +1,123 @@
+# frozen_string_literal: true
+
+module Schwab
+  # Fixed-point formatting for order prices
+  #
+  # Ruby serializes small floats in scientific notation (+1.0e-05+) and keeps whatever
+  # precision a calculation produced (+150.10000000000002+). Formatted prices are decimal
+  # strings, as in Schwab's order examples, with two places for prices of a dollar or more and
+  # four for sub-dollar and forex prices. Rounding is half-up on the decimal value.
+  #
+  # @example Format prices before building an order
+  #   Schwab::Price.format(150.1) # => "150.10"
+  #   Schwab::Price.format(0.0001) # => "0.0001"
+  #   Schwab::Price.format(1.23456, precision: 3) # => "1.235"
+  module Price
+    # Decimal places for prices of a dollar or more
+    DEFAULT_PRECISION = 2
+
+    # Decimal places for sub-dollar and forex prices
+    FINE_PRECISION = 4
+
+    # Asset types always priced with FINE_PRECISION
+    FINE_PRECISION_ASSET_TYPES = ["FOREX"].freeze
+
+    # Decimal places used by {display} for each asset type; others use DEFAULT_PRECISION.
+    # Override per asset type with +config.display_precision+.
+    DISPLAY_PRECISION = { "FOREX" => 5 }.freeze
+
+    # Order fields holding prices
+    PRICE_FIELDS = ["price", "stopPrice", "activationPrice"].freeze
+
+    class << self
+      # Format a price as a fixed-point decimal string
+      #
+      # @param value [Numeric, String] The price
+      # @param asset_type [String, Symbol, nil] The instrument asset type (e.g., "EQUITY", "FOREX")
+      # @param precision [Integer, nil] Decimal places, or nil to choose from the price and asset type
+      # @return [String] The formatted price, never in scientific notation
+      # @raise [ArgumentError] if the value is not a number
+      def format(value, asset_type: nil, precision: nil)
+        decimal = to_rational(value)
+        places = precision || precision_for(decimal, asset_type)
+        scaled = (decimal * (10**places)).round(half: :up)
+        whole, fraction = scaled.abs.divmod(10**places)
+        sign = scaled.negative? ? "-" : ""
+
+        places.zero? ? "#{sign}#{whole}" : "#{sign}#{whole}.#{fraction.to_s.rjust(places, "0")}"
+      end
+
+      # Get the decimal places used for a price
+      #
+      # @param value [Numeric, String] The price
+      # @param asset_type [String, Symbol, nil] The instrument asset type
+      # @return [Integer] FINE_PRECISION for sub-dollar and forex prices, otherwise DEFAULT_PRECISION
+      def precision_for(value, asset_type = nil)
+        fine = FINE_PRECISION_ASSET_TYPES.include?(asset_type.to_s.upcase) || to_rational(value).abs < 1
+        fine ? FINE_PRECISION : DEFAULT_PRECISION
+      end
+
+      # Format a price for display with the decimal places of its asset type
+      #
+      # Unlike {format}, which shapes prices for order payloads, the places depend only on the
+      # asset type, so a column of prices for one instrument type lines up.
+      #
+      # @param value [Numeric, String] The price
+      # @param asset_type [String, Symbol, nil] The instrument asset type (e.g., "EQUITY", "OPTION", "FOREX")
+      # @return [String] The formatted price
+      # @raise [ArgumentError] if the value is not a number
+      # @example
+      #   Schwab::Price.display(1.084215, "FOREX") # => "1.08422"
+      #   Schwab::Price.display(150.1, "EQUITY") # => "150.10"
+      def display(value, asset_type = nil)
+        format(value, precision: display_precision(asset_type))
+      end
+
+      # Get the decimal places {display} uses for an asset type
+      #
+      # @param asset_type [String, Symbol, nil] The instrument asset type
+      # @return [Integer] The configured places, then DISPLAY_PRECISION, then DEFAULT_PRECISION
+      def display_precision(asset_type)
+        type = asset_type.to_s.upcase
+        configured = Schwab.respond_to?(:configuration) ? Schwab.configuration.display_precision : {}
+        configured.fetch(type) { DISPLAY_PRECISION.fetch(type, DEFAULT_PRECISION) }
+      end
+
+      # Format the price fields of an order payload, including child orders
+      #
+      # @param order_data [Hash] Order payload
+      # @param precision [Symbol, Integer] :auto to choose places per price, or a fixed number of places
+      # @return [Hash] A copy of the payload with formatted prices
+      def format_order(order_data, precision = :auto)
+        places = precision == :auto ? nil : precision
+        asset_type = leg_asset_type(order_data)
+
+        order_data.to_h do |key, value|
+          if PRICE_FIELDS.include?(key.to_s) && !value.nil?
+            [key, format(value, asset_type: asset_type, precision: places)]
+          elsif key.to_s == "childOrderStrategies" && value.is_a?(Array)
+            [key, value.map { |child| format_order(child, precision) }]
+          else
+            [key, value]
+          end
+        end
+      end
+
+      private
+
+      def to_rational(value)
+        raise ArgumentError, "Invalid price: #{value.inspect}" unless value.is_a?(Numeric) || value.is_a?(String)
+
+        value.is_a?(Rational) || value.is_a?(Integer) ? value.to_r : Rational(value.to_s)
+      rescue ZeroDivisionError, TypeError
+        raise ArgumentError, "Invalid price: #{value.inspect}"
+      end
+
+      def leg_asset_type(order_data)
+        legs = order_data[:orderLegCollection] || order_data["orderLegCollection"] || []
+        instrument = legs.first && (legs.first[:instrument] || legs.first["instrument"]) || {}
+        instrument[:assetType] || instrument["assetType"]
+      end
+    end
+  end
+end
@@ -0,0 +1,107 @@
+# frozen_string_literal: true
+
+require_relative "error"
+
+module Schwab
+  # Quantity rounding rules for order legs
+  #
+  # Schwab rejects fractional quantities for options and for equities outside the fractional
+  # share program. Options, futures, and equities round to whole units; mutual funds and
+  # equities flagged as fractional-enabled keep their decimals.
+  #
+  # @example Round position-sizing output before building an order
+  #   Schwab::Quantity.round(12.7, "EQUITY") # => 12
+  #   Schwab::Quantity.round(12.7, "EQUITY", mode: :nearest) # => 13
+  #   Schwab::Quantity.round(12.7, "EQUITY", fractional: true) # => 12.7
+  module Quantity
+    # Supported rounding modes
+    ROUNDING_MODES = [:down, :nearest, :up].freeze
+
+    # Asset types that always trade in fractional quantities
+    FRACTIONAL_ASSET_TYPES = ["MUTUAL_FUND"].freeze
+
+    # Asset types that may trade fractionally when the instrument is fractional-enabled
+    FRACTIONAL_ELIGIBLE_ASSET_TYPES = ["EQUITY", "COLLECTIVE_INVESTMENT"].freeze
+
+    # Number of decimals kept for fractional quantities
+    FRACTIONAL_PRECISION = 6
+
+    class << self
+      # Round a quantity according to the rules for an asset type
+      #
+      # @param quantity [Numeric] The quantity to round
+      # @param asset_type [String, Symbol, nil] The instrument asset type (e.g., "EQUITY", "OPTION")
+      # @param mode [Symbol] Rounding mode (:down, :nearest, or :up, default: :down)
+      # @param fractional [Boolean] Whether the instrument is fractional-enabled
+      # @return [Integer, Float] Whole units as an Integer, or the fractional quantity as a Float
+      # @raise [ArgumentError] if mode is not supported
+      def round(quantity, asset_type, mode: :down, fractional: false)
+        unless ROUNDING_MODES.include?(mode)
+          raise ArgumentError, "Invalid rounding mode: #{mode}. Must be one of: #{ROUNDING_MODES.join(", ")}"
+        end
+
+        return quantity.to_f.round(FRACTIONAL_PRECISION) if fractional?(asset_type, fractional)
+
+        case mode
+        when :down then quantity.floor
+        when :nearest then quantity.round
+        when :up then quantity.ceil
+        end
+      end
+
+      # Round the quantity of every leg in an order payload
+      #
+      # Equity legs round to whole shares unless +fractional+ marks their instrument as
+      # fractional-enabled, in which case they keep their decimals.
+      #
+      # @param order_data [Hash] Order payload with an orderLegCollection
+      # @param mode [Symbol] Rounding mode (:down, :nearest, or :up)
+      # @param fractional [Boolean, Array<String>, Hash{String => Boolean}, #call] Whether each leg's
+      #   instrument is fractional-enabled: one answer for every leg, the fractional-enabled symbols,
+      #   a lookup by symbol, or a callable given the symbol (default: false)
+      # @return [Hash] A copy of the payload with rounded leg quantities
+      # @raise [InvalidRequestError] if a leg with a quantity would round to 0
+      def round_order(order_data, mode, fractional: false)
+        legs_key = [:orderLegCollection, "orderLegCollection"].find { |key| order_data.key?(key) }
+        return order_data unless legs_key
+
+        legs = order_data[legs_key].map do |leg|
+          quantity_key = [:quantity, "quantity"].find { |key| leg.key?(key) }
+          next leg unless quantity_key
+
+          instrument = leg[:instrument] || leg["instrument"] || {}
+          asset_type = instrument[:assetType] || instrument["assetType"]
+          symbol = instrument[:symbol] || instrument["symbol"]
+          quantity = leg[quantity_key]
+          rounded = round(quantity, asset_type, mode: mode, fractional: fractional_for(fractional, symbol))
+          if rounded.zero? && !quantity.zero?
+            raise InvalidRequestError, "#{symbol || "Leg"} quantity #{quantity} rounds to 0 with #{mode} rounding"
+          end
+
+          leg.merge(quantity_key => rounded)
+        end
+
+        order_data.merge(legs_key => legs)
+      end
+
+      private
+
+      def fractional_for(fractional, symbol)
+        if fractional.is_a?(Array)
+          fractional.any? { |candidate| candidate.to_s.casecmp?(symbol.to_s) }
+        elsif fractional.is_a?(Hash)
+          fractional.key?(symbol) ? fractional[symbol] : fractional[symbol.to_s.to_sym]
+        elsif fractional.respond_to?(:call)
+          fractional.call(symbol)
+        else
+          fractional
+        end
+      end
+
+      def fractional?(asset_type, fractional)
+        type = asset_type.to_s.upcase
+        FRACTIONAL_ASSET_TYPES.include?(type) || (fractional && FRACTIONAL_ELIGIBLE_ASSET_TYPES.include?(type))
+      end
+    end
+  end
+end
@@ -0,0 +1,104 @@
+# frozen_string_literal: true
+
+module Schwab
+  # Thread-safe in-memory cache of quotes, evicting the least recently used entry when full
+  #
+  # Enable it with +config.quote_cache = true+, or set the same instance on several
+  # configurations so every client shares it. MarketData.get_quotes and get_quote then answer
+  # symbols cached within +ttl+ seconds from the cache and request only the rest.
+  #
+  # Entries are keyed by {.key}: the symbol as sent (after case normalization), the quote
+  # fields requested ("all" when none are), and whether indicative quotes were asked for, so
+  # a quote fetched with fewer fields never answers a request for more.
+  #
+  # Any object responding to +read(key)+ (returning the cached quote data, or nil on a miss)
+  # and +write(key, quote)+ can be used instead, such as one backed by a shared store. It must
+  # be safe to call from several threads at once.
+  #
+  # @example Share one cache between tenants
+  #   cache = Schwab::QuoteCache.new(ttl: 2, max_size: 5_000)
+  #   tenants.each { |tenant| tenant.client.config.quote_cache = cache }
+  class QuoteCache
+    # Seconds a quote is served from the cache
+    DEFAULT_TTL = 5
+
+    # Quotes kept before the least recently used is evicted
+    DEFAULT_MAX_SIZE = 1_000
+
+    attr_reader :ttl, :max_size
+
+    class << self
+      # Build the cache key for a quote
+      #
+      # @param symbol [String] The symbol as sent to Schwab
+      # @param fields [String, Array<String>, nil] The quote fields requested
+      # @param indicative [Boolean] Whether indicative quotes were requested
+      # @return [String] The key, e.g. "AAPL|quote,fundamental|regular"
+      def key(symbol, fields: nil, indicative: false)
+        fields = Array(fields).join(",")
+        "#{symbol}|#{fields.empty? ? "all" : fields}|#{indicative ? "indicative" : "regular"}"
+      end
+    end
+
+    # @param ttl [Numeric] Seconds a quote is served from the cache
+    # @param max_size [Integer] Quotes kept before the least recently used is evicted
+    # @raise [ArgumentError] if ttl or max_size is not positive
+    def initialize(ttl: DEFAULT_TTL, max_size: DEFAULT_MAX_SIZE)
+      raise ArgumentError, "ttl must be a positive number, got #{ttl.inspect}" unless ttl.is_a?(Numeric) && ttl.positive?
+      unless max_size.is_a?(Integer) && max_size.positive?
+        raise ArgumentError, "max_size must be a positive Integer, got #{max_size.inspect}"
+      end
+
+      @ttl = ttl
+      @max_size = max_size
+      @entries = {}
+      @mutex = Mutex.new
+    end
+
+    # Get a cached quote
+    #
+    # @param key [String] The cache key (see {.key})
+    # @return [Hash, nil] The quote data, or nil if missing or older than ttl
+    def read(key)
+      @mutex.synchronize do
+        quote, expires_at = @entries.delete(key)
+        next if quote.nil? || expires_at <= now
+
+        @entries[key] = [quote, expires_at]
+        quote
+      end
+    end
+
+    # Store a quote
+    #
+    # @param key [String] The cache key (see {.key})
+    # @param quote [Hash] The quote data
+    def write(key, quote)
+      @mutex.synchronize do
+        @entries.delete(key)
+        @entries[key] = [quote, now + ttl]
+        @entries.shift while @entries.size > max_size
+      end
+      nil
+    end
+
+    # Get the number of cached quotes, expired ones included until they are evicted
+    #
+    # @return [Integer] The number of entries
+    def size
+      @mutex.synchronize { @entries.size }
+    end
+
+    # Remove every cached quote
+    def clear
+      @mutex.synchronize { @entries.clear }
+      nil
+    end
+
+    private
+
+    def now
+      Process.clock_gettime(Process::CLOCK_MONOTONIC)
+    end
+  end
+end
@@ -0,0 +1,95 @@
+# frozen_string_literal: true
+
+require_relative "poller"
+
+module Schwab
+  # Polls quotes for a changing set of symbols on a fixed interval
+  #
+  # A REST alternative to streaming for apps that cannot hold a WebSocket open. Each tick
+  # fetches every watched symbol in one request through the same client, so polling shares
+  # the client's connection, token refresh, and retry handling with the rest of the app.
+  # The callback runs on the poller thread.
+  #
+  # @example Keep a watchlist fresh
+  #   poller = Schwab::MarketData.poll_quotes(["AAPL", "MSFT"], interval: 5) do |quotes, error|
+  #     next warn(error.message) if error
+  #
+  #     quotes.each { |symbol, quote| puts "#{symbol} #{quote.last_price}" }
+  #   end
+  #   poller.add("NVDA")
+  #   poller.remove("MSFT")
+  #   poller.stop
+  class QuotePoller
+    include Poller
+
+    # @param symbols [String, Array<String>] Symbols to poll
+    # @param interval [Numeric] Seconds between polls
+    # @param fields [String, Array<String>, nil] Quote fields to include
+    # @param client [Schwab::Client, nil] Optional client instance
+    # @yieldparam quotes [Hash<String, Resources::Quote>, nil] Quotes keyed by symbol, or nil on error
+    # @yieldparam error [StandardError, nil] The error if the poll failed
+    # @raise [ArgumentError] if interval is not positive or no block is given
+    def initialize(symbols, interval:, fields: nil, client: nil, &block)
+      init_poller(interval)
+      raise ArgumentError, "A callback block is required" unless block
+
+      @symbols = normalize(symbols)
+      @fields = fields
+      @client = client
+      @callback = block
+    end
+
+    # Add symbols to the watchlist; they are included from the next poll
+    #
+    # @param symbols [Array<String>] Symbols to add
+    # @return [QuotePoller] self
+    def add(*symbols)
+      @mutex.synchronize { @symbols |= normalize(symbols) }
+      self
+    end
+
+    # Remove symbols from the watchlist
+    #
+    # @param symbols [Array<String>] Symbols to remove
+    # @return [QuotePoller] self
+    def remove(*symbols)
+      @mutex.synchronize { @symbols -= normalize(symbols) }
+      self
+    end
+
+    # Get the watched symbols
+    #
+    # @return [Array<String>] The symbols
+    def symbols
+      @mutex.synchronize { @symbols.dup }
+    end
+
+    private
+
+    def tick
+      symbols = self.symbols
+      return if symbols.empty?
+
+      begin
+        response = MarketData.get_quotes(symbols, fields: @fields, client: @client)
+      rescue StandardError => e
+        @callback.call(nil, e) unless @stopped
+        return
+      end
+
+      @callback.call(build_quotes(response), nil) unless @stopped
+    end
+
+    def build_quotes(response)
+      response.to_h.each_with_object({}) do |(symbol, data), quotes|
+        next if symbol.to_s == "errors" || !data.respond_to?(:key?)
+
+        quotes[symbol.to_s] = Resources::Quote.new(data.to_h, @client)
+      end
+    end
+
+    def normalize(symbols)
+      Array(symbols).flatten.map { |symbol| symbol.to_s.strip.upcase }.reject(&:empty?).uniq
+    end
+  end
+end
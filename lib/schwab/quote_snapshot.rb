@@ -0,0 +1,148 @@
+# frozen_string_literal: true
+
+require "json"
+require "time"
+
+module Schwab
+  # Capture live quote responses to JSON Lines and replay them offline
+  #
+  # Set +config.quote_snapshot+ to an IO (or a {Writer}) and every MarketData.get_quotes
+  # result is appended as one timestamped record. Later, a {Replay} built from the file can be
+  # passed as the +client:+ of MarketData calls to serve those quotes back in order, which
+  # makes backtests reproducible.
+  #
+  # @example Capture during a live session
+  #   Schwab.configure { |config| config.quote_snapshot = File.open("quotes.jsonl", "a") }
+  #
+  # @example Replay in a backtest
+  #   replay = Schwab::QuoteSnapshot::Replay.open("quotes.jsonl")
+  #   Schwab::MarketData.get_quotes(["AAPL"], client: replay) # => quotes from the first record
+  module QuoteSnapshot
+    # One captured get_quotes result
+    Record = Struct.new(:timestamp, :symbols, :quotes, keyword_init: true)
+
+    # Appends timestamped quote records to an IO as JSON Lines
+    class Writer
+      attr_reader :io
+
+      # @param io [IO, #write] Destination, e.g. a File opened for appending
+      def initialize(io)
+        @io = io
+        @mutex = Mutex.new
+      end
+
+      # Append a record
+      #
+      # @param symbols [Array<String>] The requested symbols
+      # @param quotes [Hash] The quotes response
+      # @param timestamp [Time] When the quotes were fetched (default: now)
+      def write(symbols, quotes, timestamp: Time.now)
+        line = JSON.generate({ timestamp: timestamp.utc.iso8601(3), symbols: Array(symbols), quotes: quotes.to_h })
+        @mutex.synchronize do
+          @io.write("#{line}\n")
+          @io.flush if @io.respond_to?(:flush)
+        end
+      end
+    end
+
+    # Reads records written by {Writer}
+    class Reader
+      include Enumerable
+
+      # @param io [IO, #each_line] Source of JSON Lines
+      def initialize(io)
+        @io = io
+      end
+
+      # Yield each record in file order
+      #
+      # @yieldparam record [Record] The record
+      def each
+        return enum_for(:each) unless block_given?
+
+        @io.each_line do |line|
+          next if line.strip.empty?
+
+          data = JSON.parse(line)
+          yield Record.new(
+            timestamp: Time.iso8601(data["timestamp"]),
+            symbols: data["symbols"],
+            quotes: data["quotes"],
+          )
+        end
+      end
+    end
+
+    # Stand-in client that serves recorded quotes to MarketData quote calls
+    #
+    # Each quotes request returns the next record, limited to the requested symbols.
+    # Other endpoints are not recorded and raise {Error}.
+    class Replay
+      QUOTES_PATH = "/marketdata/v1/quotes"
+
+      attr_reader :config
+
+      class << self
+        # Build a replay from a snapshot file
+        #
+        # @param path [String] Path to a JSON Lines snapshot file
+        # @param repeat [Boolean] Start over after the last record (default: false)
+        # @return [Replay] The replay
+        def open(path, repeat: false)
+          new(File.open(path) { |file| Reader.new(file).to_a }, repeat: repeat)
+        end
+      end
+
+      # @param records [Enumerable<Record>] Records to serve, in order
+      # @param repeat [Boolean] Start over after the last record (default: false)
+      def initialize(records, repeat: false)
+        @records = records.to_a
+        @repeat = repeat
+        @position = 0
+        @config = Configuration.new
+        @mutex = Mutex.new
+      end
+
+      # Serve the next recorded quotes response
+      #
+      # @param path [String] The request path (only the quotes endpoint is supported)
+      # @param params [Hash] Query parameters; :symbols limits the returned quotes
+      # @return [Hash] Quotes keyed by symbol
+      # @raise [Error] for other endpoints or once the records are exhausted
+      def get(path, params = {}, _resource_class = nil)
+        raise Error, "Quote snapshot replay only serves #{QUOTES_PATH}, not #{path}" unless path == QUOTES_PATH
+
+        record = next_record
+        symbols = params[:symbols].to_s.split(",")
+        return record.quotes if symbols.empty?
+
+        record.quotes.slice(*symbols)
+      end
+
+      # Get the number of records not yet served
+      #
+      # @return [Integer] Remaining records
+      def remaining
+        @mutex.synchronize { @records.size - @position }
+      end
+
+      # Start serving from the first record again
+      def rewind
+        @mutex.synchronize { @position = 0 }
+      end
+
+      private
+
+      def next_record
+        @mutex.synchronize do
+          @position = 0 if @repeat && @position >= @records.size
+          record = @records[@position]
+          raise Error, "Quote snapshot replay exhausted after #{@records.size} records" unless record
+
+          @position += 1
+          record
+        end
+      end
+    end
+  end
+end
@@ -0,0 +1,306 @@
+# frozen_string_literal: true
+
+require "date"
+require_relative "symbols"
+
+module Schwab
+  # Realized gains and losses per closed lot, matched from trade transactions
+  #
+  # Schwab's Trader API has no realized-gains (closed lots) endpoint, so lots are rebuilt from
+  # TRADE transactions: every buy opens a lot, and every sell closes open lots of the same symbol
+  # in the order of the cost-basis method (FIFO by default). A sell with nothing to close opens a
+  # short lot that a later buy closes, unless Schwab marked it CLOSING. Amounts come from each
+  # transaction's +netAmount+, so commissions and fees are included in cost basis and proceeds.
+  #
+  # This is basic matching for reporting, not a substitute for the 1099-B: wash sales, corporate
+  # actions, transferred-in lots, and lots opened before the transactions you pass in are not
+  # known. A CLOSING trade without a matching open is reported with a nil term and a nil cost
+  # basis (or nil proceeds, for a buy to cover).
+  #
+  # @example Realized gains for 2024 with FIFO matching
+  #   transactions = Schwab::Accounts.get_transactions("123456", types: "TRADE",
+  #     start_date: Date.new(2019, 1, 1), end_date: Date.new(2024, 12, 31))
+  #   Schwab::RealizedGains.compute(transactions, year: 2024).filter_map(&:gain).sum
+  module RealizedGains
+    # Lot matching methods: first in first out, last in first out, highest cost first
+    METHODS = [:fifo, :lifo, :hifo].freeze
+
+    # Quantities smaller than this are treated as fully matched
+    EPSILON = 1e-9
+
+    # Schwab tax lot methods (an order's +taxLotMethod+) that {tax_lot_method} can choose, with the
+    # lot attribute each one sorts by and whether it sells the lowest or highest value first
+    TAX_LOT_METHODS = {
+      "FIFO" => [:open_date, :min],
+      "LIFO" => [:open_date, :max],
+      "HIGH_COST" => [:unit_cost, :max],
+      "LOW_COST" => [:unit_cost, :min],
+    }.freeze
+
+    # One closed lot (or part of one)
+    #
+    # @!attribute symbol
+    #   @return [String] The instrument symbol
+    # @!attribute quantity
+    #   @return [Float] Shares or contracts closed
+    # @!attribute open_date
+    #   @return [Date, nil] When the lot was opened, or nil if no opening trade was found
+    # @!attribute close_date
+    #   @return [Date] When the lot was closed
+    # @!attribute proceeds
+    #   @return [Float, nil] Amount received (the sale, or the short sale for short lots); nil when unknown
+    # @!attribute cost_basis
+    #   @return [Float, nil] Amount paid (the purchase, or the covering buy for short lots)
+    # @!attribute term
+    #   @return [Symbol, nil] :long when held more than a year, otherwise :short; nil when unknown
+    # @!attribute short_sale
+    #   @return [Boolean] True when the lot was opened by selling short
+    RealizedLot = Struct.new(
+      :symbol,
+      :quantity,
+      :open_date,
+      :close_date,
+      :proceeds,
+      :cost_basis,
+      :term,
+      :short_sale,
+      keyword_init: true,
+    ) do
+      # @return [Float, nil] Proceeds minus cost basis, or nil when either is unknown
+      def gain
+        (proceeds - cost_basis).round(2) if proceeds && cost_basis
+      end
+
+      # @return [Boolean] True for long-term lots
+      def long_term?
+        term == :long
+      end
+    end
+
+    # One lot still open after matching
+    #
+    # @!attribute id
+    #   @return [String, nil] ID of the transaction that opened the lot
+    # @!attribute symbol
+    #   @return [String] The instrument symbol
+    # @!attribute quantity
+    #   @return [Float] Shares or contracts still open (negative for short lots)
+    # @!attribute open_date
+    #   @return [Date] When the lot was opened
+    # @!attribute cost_basis
+    #   @return [Float] Amount paid for the open quantity, fees included (received, for short lots)
+    OpenLot = Struct.new(:id, :symbol, :quantity, :open_date, :cost_basis, keyword_init: true) do
+      # @return [Float] Cost basis per share or contract
+      def unit_cost
+        cost_basis / quantity.abs
+      end
+
+      # @param as_of [Date] The date to measure to (default: today)
+      # @return [Symbol] :long once held more than a year (never for short lots), otherwise :short
+      def holding_period(as_of = Date.today)
+        quantity.positive? && as_of > open_date.next_year ? :long : :short
+      end
+    end
+
+    class << self
+      # Match trades into closed lots
+      #
+      # @param transactions [Array<Hash, Resources::Transaction>] Transactions in any order; non-trades are ignored
+      # @param year [Integer, nil] Only return lots closed in this calendar year (default: all)
+      # @param method [Symbol] :fifo, :lifo, or :hifo (default: :fifo)
+      # @return [Array<RealizedLot>] Closed lots, in closing order
+      # @raise [ArgumentError] if the method is unknown
+      def compute(transactions, year: nil, method: :fifo)
+        unless METHODS.include?(method)
+          raise ArgumentError, "Unknown cost basis method: #{method.inspect}. Use one of #{METHODS.join(", ")}"
+        end
+
+        _open_lots, closed = replay(transactions, method)
+        year ? closed.select { |lot| lot.close_date.year == year } : closed
+      end
+
+      # Match trades and return the lots left open
+      #
+      # Lots opened before the transactions you pass in are not known, so the open quantity can
+      # fall short of the position Schwab reports; compare the two before relying on the lots.
+      #
+      # @param transactions [Array<Hash, Resources::Transaction>] Transactions in any order; non-trades are ignored
+      # @param method [Symbol] :fifo, :lifo, or :hifo, used to match past sells (default: :fifo)
+      # @return [Array<OpenLot>] Open lots, oldest first
+      # @raise [ArgumentError] if the method is unknown
+      def open_lots(transactions, method: :fifo)
+        unless METHODS.include?(method)
+          raise ArgumentError, "Unknown cost basis method: #{method.inspect}. Use one of #{METHODS.join(", ")}"
+        end
+
+        lots_by_symbol, _closed = replay(transactions, method)
+        lots_by_symbol.flat_map do |symbol, lots|
+          lots.map do |lot|
+            OpenLot.new(
+              id: lot[:id],
+              symbol: symbol,
+              quantity: lot[:quantity],
+              open_date: lot[:date],
+              cost_basis: (lot[:unit_amount] * lot[:quantity].abs).round(2),
+            )
+          end
+        end.sort_by(&:open_date)
+      end
+
+      # Choose the order +taxLotMethod+ that sells a given lot first
+      #
+      # Schwab's orders cannot name a lot, only a method for choosing lots. This finds a method
+      # under which the sale is taken entirely from the requested lot.
+      #
+      # @param lots [Array<OpenLot>] The position's open lots (see {open_lots})
+      # @param lot_id [String] ID of the lot to sell
+      # @param quantity [Numeric, nil] Quantity to sell (default: the whole lot)
+      # @return [String, nil] "FIFO", "LIFO", "HIGH_COST", or "LOW_COST"; nil when every method would
+      #   take from another lot first
+      # @raise [ArgumentError] if the lot is not among the lots or holds less than the quantity
+      def tax_lot_method(lots, lot_id, quantity: nil)
+        lot = lots.find { |candidate| candidate.id.to_s == lot_id.to_s }
+        raise ArgumentError, "Lot #{lot_id} is not an open lot of this position" unless lot
+        if quantity && quantity > lot.quantity.abs
+          raise ArgumentError, "Lot #{lot_id} holds #{lot.quantity.abs}, less than #{quantity}"
+        end
+
+        candidates = lots.select do |candidate|
+          candidate.symbol == lot.symbol && candidate.quantity.positive? == lot.quantity.positive?
+        end
+        TAX_LOT_METHODS.find do |_method, (attribute, first)|
+          values = candidates.map(&attribute)
+          best = values.public_send(first)
+          lot.public_send(attribute) == best && values.count(best) == 1
+        end&.first
+      end
+
+      private
+
+      # Match every trade, returning the open lots by symbol and the closed lots
+      def replay(transactions, method)
+        open_lots = Hash.new { |lots, symbol| lots[symbol] = [] }
+        closed = []
+        trades(transactions).each do |trade|
+          match(trade, open_lots[trade[:symbol]], method, closed)
+        end
+        [open_lots, closed]
+      end
+
+      # Trades with a security leg, oldest first; Schwab lists transactions newest first, so the
+      # full timestamp orders trades within a day, and the input order breaks ties
+      def trades(transactions)
+        transactions.filter_map { |transaction| parse_trade(transaction.to_h) }
+          .each_with_index.sort_by { |trade, index| [trade[:time], index] }
+          .map(&:first)
+      end
+
+      def parse_trade(data)
+        return unless read(data, :type).to_s.upcase == "TRADE"
+
+        items = Array(read(data, :transferItems)).select do |item|
+          instrument = read(item, :instrument) || {}
+          !["CURRENCY", "CASH_EQUIVALENT"].include?(read(instrument, :assetType).to_s.upcase) && read(item, :feeType).nil?
+        end
+        return unless items.size == 1
+
+        item = items.first
+        instrument = read(item, :instrument)
+        quantity = read(item, :amount).to_f
+        return if quantity.zero?
+
+        date = read(data, :tradeDate) || read(data, :time)
+        {
+          id: (read(data, :activityId) || read(data, :transactionId))&.to_s,
+          symbol: read(instrument, :symbol),
+          quantity: quantity,
+          date: date.is_a?(Date) ? date.to_date : Date.parse(date.to_s),
+          time: timestamp(read(data, :time) || date),
+          unit_amount: unit_amount(data, item, instrument, quantity),
+          position_effect: read(item, :positionEffect).to_s.upcase,
+        }
+      end
+
+      def timestamp(value)
+        case value
+        when Time, Date then value.to_datetime
+        else DateTime.parse(value.to_s)
+        end
+      end
+
+      # Cash per share or contract, fees included: paid for buys, received for sells
+      def unit_amount(data, item, instrument, quantity)
+        net = read(data, :netAmount)
+        return net.to_f.abs / quantity.abs if net
+
+        multiplier = read(instrument, :assetType).to_s.upcase == "OPTION" ? Symbols::OPTION_MULTIPLIER : 1
+        read(item, :price).to_f * multiplier
+      end
+
+      def match(trade, lots, method, closed)
+        remaining = trade[:quantity].abs
+        buying = trade[:quantity].positive?
+
+        while remaining > EPSILON && lots.any? && lots.first[:quantity].positive? != buying
+          lot = next_lot(lots, method)
+          quantity = [remaining, lot[:quantity].abs].min
+          closed << closed_lot(trade, lot, quantity)
+          lot[:quantity] += buying ? quantity : -quantity
+          lots.delete(lot) if lot[:quantity].abs < EPSILON
+          remaining -= quantity
+        end
+        return if remaining <= EPSILON
+
+        if trade[:position_effect] == "CLOSING"
+          closed << unmatched_lot(trade, remaining, buying)
+        else
+          lots << { id: trade[:id], quantity: buying ? remaining : -remaining, date: trade[:date], unit_amount: trade[:unit_amount] }
+        end
+      end
+
+      def next_lot(lots, method)
+        case method
+        when :fifo then lots.first
+        when :lifo then lots.last
+        when :hifo then lots.max_by { |lot| lot[:unit_amount] }
+        end
+      end
+
+      def closed_lot(trade, lot, quantity)
+        short = lot[:quantity].negative?
+        opened = (lot[:unit_amount] * quantity).round(2)
+        closing = (trade[:unit_amount] * quantity).round(2)
+
+        RealizedLot.new(
+          symbol: trade[:symbol],
+          quantity: quantity,
+          open_date: lot[:date],
+          close_date: trade[:date],
+          proceeds: short ? opened : closing,
+          cost_basis: short ? closing : opened,
+          term: short || trade[:date] <= lot[:date].next_year ? :short : :long, # short sales are always short-term
+          short_sale: short,
+        )
+      end
+
+      # A close whose opening trade is not in the transactions (e.g., opened before the range fetched)
+      def unmatched_lot(trade, quantity, covering)
+        amount = (trade[:unit_amount] * quantity).round(2)
+        RealizedLot.new(
+          symbol: trade[:symbol],
+          quantity: quantity,
+          open_date: nil,
+          close_date: trade[:date],
+          proceeds: covering ? nil : amount,
+          cost_basis: covering ? amount : nil,
+          term: nil,
+          short_sale: covering,
+        )
+      end
+
+      def read(data, key)
+        data[key] || data[key.to_s]
+      end
+    end
+  end
+end
@@ -0,0 +1,47 @@
+# frozen_string_literal: true
+
+require "json"
+require "uri"
+
+module Schwab
+  # An API request built but not yet sent
+  #
+  # Service builders such as {Trading.build_place_order_request} return one of these so the
+  # effective method, path, and body can be inspected (or logged, or asserted on in tests)
+  # before anything goes over the wire. The matching service method calls the same builder,
+  # so what you inspect is exactly what would be sent. Authorization and other headers added
+  # by the connection middleware are not included.
+  #
+  # @example Inspect an order before placing it
+  #   request = Schwab::Trading.build_place_order_request("123456", order: order)
+  #   request.http_method # => :post
+  #   request.path        # => "/trader/v1/accounts/ABC123/orders"
+  #   request.body        # => { orderType: "LIMIT", session: "NORMAL", ... }
+  #   request.perform(client)
+  Request = Struct.new(:http_method, :path, :body, :params, keyword_init: true) do
+    # @return [Hash] Headers the SDK sets for this request, before middleware
+    def headers
+      body ? { "Content-Type" => "application/json", "Accept" => "application/json" } : { "Accept" => "application/json" }
+    end
+
+    # @param base_url [String] The API base URL (e.g., config.api_base_url)
+    # @return [String] The full URL, including any query parameters
+    def url(base_url)
+      url = "#{base_url.to_s.chomp("/")}/#{path.to_s.sub(%r{^/}, "")}"
+      params && !params.empty? ? "#{url}?#{URI.encode_www_form(params)}" : url
+    end
+
+    # @return [String, nil] The body as it will be serialized
+    def body_json
+      body && JSON.generate(body)
+    end
+
+    # Send the request
+    #
+    # @param client [Schwab::Client] The client to send it with
+    # @return [Faraday::Response] The raw response
+    def perform(client)
+      client.raw_request(http_method, path, body || params || {})
+    end
+  end
+end
@@ -0,0 +1,56 @@
+# frozen_string_literal: true
+
+module Schwab
+  # Caps the number of API requests in flight at once
+  #
+  # Unlike {EndpointLimiter}, which bounds throughput, this bounds concurrency: however many
+  # threads make requests, at most +max+ are outstanding and the rest wait for a slot. Set it
+  # with +config.max_concurrent_requests+; every client sharing the configuration shares the cap.
+  class RequestSemaphore
+    attr_reader :max
+
+    # @param max [Integer] Requests allowed in flight at once
+    # @raise [ArgumentError] if max is not a positive Integer
+    def initialize(max)
+      raise ArgumentError, "max must be a positive Integer, got #{max.inspect}" unless max.is_a?(Integer) && max.positive?
+
+      @max = max
+      @in_flight = 0
+      @mutex = Mutex.new
+      @available = ConditionVariable.new
+    end
+
+    # Wait for a free slot and take it
+    #
+    # @param timeout [Numeric, nil] Most seconds to wait, or nil to wait indefinitely
+    # @return [Boolean] True once a slot is taken, false if the timeout expired first
+    def acquire(timeout: nil)
+      deadline = timeout && Process.clock_gettime(Process::CLOCK_MONOTONIC) + timeout
+
+      @mutex.synchronize do
+        while @in_flight >= max
+          remaining = deadline && deadline - Process.clock_gettime(Process::CLOCK_MONOTONIC)
+          return false if remaining && remaining <= 0
+
+          @available.wait(@mutex, remaining)
+        end
+        @in_flight += 1
+        true
+      end
+    end
+
+    # Give back a slot taken with {#acquire}
+    # @return [void]
+    def release
+      @mutex.synchronize do
+        @in_flight -= 1 if @in_flight.positive?
+        @available.signal
+      end
+    end
+
+    # @return [Integer] Requests currently in flight
+    def in_flight
+      @mutex.synchronize { @in_flight }
+    end
+  end
+end
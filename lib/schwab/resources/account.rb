@@ -1,12 +1,28 @@
 # frozen_string_literal: true
 
 require_relative "base"
+require_relative "order"
+require_relative "quote"
+require_relative "../quantity"
+require_relative "../symbols"
 
 module Schwab
   module Resources
     # Resource wrapper for account objects
     # Provides account-specific helper methods and type coercions
     class Account < Base
+      # Minimum equity for a pattern day trader to keep day trading (FINRA Rule 4210)
+      PDT_MINIMUM_EQUITY = 25_000
+
+      # Leg instructions that open or add to a position
+      OPENING_INSTRUCTIONS = ["BUY", "BUY_TO_OPEN", "SELL_SHORT", "SELL_SHORT_EXEMPT", "SELL_TO_OPEN"].freeze
+
+      # Leg instructions that sell short
+      SHORT_SALE_INSTRUCTIONS = ["SELL_SHORT", "SELL_SHORT_EXEMPT"].freeze
+
+      # An account holder and their role on the account, from {#owners}
+      Owner = Struct.new(:name, :relationship, keyword_init: true)
+
       # Set up field type coercions for account fields
       set_field_type :created_time, :datetime
       set_field_type :opened_date, :date
@@ -16,6 +32,14 @@ module Schwab
       set_field_type :closing_only_restricted, :boolean
       set_field_type :pdt_flag, :boolean
       set_field_type :round_trips, :integer
+      set_field_type :is_day_trader, :boolean
+      set_field_type :is_closing_only_restricted, :boolean
+
+      # Response keys interpreted by this resource
+      known_fields :accountNumber, :hashValue, :type, :accountType, :status, :accountStatus,
+        :currentBalances, :initialBalances, :projectedBalances, :positions, :roundTrips,
+        :isDayTrader, :isClosingOnlyRestricted, :pfcbFlag, :securitiesAccount, :aggregatedBalance,
+        :owners, :accountOwners, :registrationType, :nickName, :displayAcctId, :primaryAccount
 
       # Get the account number/ID (plain text)
       #
@@ -69,6 +93,33 @@ module Schwab
         self[:status] || self[:accountStatus]
       end
 
+      # Get the registration type (e.g., individual, joint, IRA)
+      # Only present on accounts fetched with Accounts.get_details, and only when Schwab reports it.
+      #
+      # @return [String, nil] The registration type
+      def registration_type
+        self[:registrationType] || self[:registration_type] || self[:accountRegistrationType]
+      end
+
+      # Get the account holders
+      # Only present on accounts fetched with Accounts.get_details, and only when Schwab reports them.
+      #
+      # @return [Array<Owner>] The owners (empty when not reported)
+      def owners
+        entries = self[:owners] || self[:accountOwners] || self[:account_owners] || []
+        entries.map do |owner|
+          name = owner[:name] || [owner[:firstName], owner[:lastName]].compact.join(" ")
+          Owner.new(name: name.to_s.empty? ? nil : name, relationship: owner[:relationship] || owner[:ownerType])
+        end
+      end
+
+      # Get the nickname set in Schwab's account preferences
+      #
+      # @return [String, nil] The nickname
+      def nickname
+        self[:nickName] || self[:nickname]
+      end
+
       # Check if account is active
       #
       # @return [Boolean] True if account is active
@@ -76,6 +127,85 @@ module Schwab
         status == "ACTIVE"
       end
 
+      # Check if the account is flagged as a pattern day trader
+      #
+      # @return [Boolean] True if flagged
+      def day_trader?
+        !!(self[:isDayTrader] || self[:day_trader])
+      end
+
+      # Check if the account may only close positions
+      #
+      # @return [Boolean] True if restricted to closing transactions
+      def closing_only_restricted?
+        !!(self[:isClosingOnlyRestricted] || self[:closing_only_restricted])
+      end
+
+      # Get the number of day trades in the rolling five-day window
+      #
+      # @return [Integer] The round trip count
+      def round_trips
+        (self[:roundTrips] || self[:round_trips]).to_i
+      end
+
+      # Check if day trading is blocked by the pattern day trader rule
+      # A flagged account needs at least PDT_MINIMUM_EQUITY of equity to day trade.
+      #
+      # @return [Boolean] True if day trades would be rejected
+      def pdt_restricted?
+        day_trader? && (equity || 0).to_f < PDT_MINIMUM_EQUITY
+      end
+
+      # List the reasons this account cannot place an order
+      # Checks account status, closing-only restrictions, cash account limits (no options, no
+      # short sales), and pattern day trader restrictions. Schwab does not report option approval
+      # levels, so option permissions beyond the account type are not checked.
+      #
+      # @param order [Order, Hash] The order to check
+      # @param day_trade [Boolean] Whether the order would complete a day trade (default: false)
+      # @return [Array<String>] Violations (empty when the order is allowed)
+      def trading_violations(order, day_trade: false)
+        order = order.is_a?(Order) ? order : Order.new(order.to_h)
+        instructions = order.order_legs.map { |leg| leg[:instruction].to_s.upcase }
+        asset_types = order.order_legs.map { |leg| leg[:instrument] && leg[:instrument][:assetType].to_s.upcase }
+
+        errors = []
+        errors << "Account is not active (status: #{status})" if status && !active?
+        if closing_only_restricted? && instructions.intersect?(OPENING_INSTRUCTIONS)
+          errors << "Account is restricted to closing transactions"
+        end
+        if cash_account?
+          errors << "Options cannot be traded in a cash account" if asset_types.include?("OPTION")
+          errors << "Short sales require a margin account" if instructions.intersect?(SHORT_SALE_INSTRUCTIONS)
+        end
+        if day_trade && pdt_restricted?
+          errors << "Day trades are restricted: pattern day trader with equity below #{PDT_MINIMUM_EQUITY}"
+        end
+        errors
+      end
+
+      # Check if this account can place an order
+      #
+      # @param order [Order, Hash] The order to check
+      # @param day_trade [Boolean] Whether the order would complete a day trade
+      # @return [Boolean] True if allowed
+      def can_trade?(order, day_trade: false)
+        trading_violations(order, day_trade: day_trade).empty?
+      end
+
+      # Check if this account can place an order, raising on violations
+      #
+      # @param order [Order, Hash] The order to check
+      # @param day_trade [Boolean] Whether the order would complete a day trade
+      # @return [Account] self
+      # @raise [InvalidRequestError] with every violation in #errors
+      def can_trade!(order, day_trade: false)
+        errors = trading_violations(order, day_trade: day_trade)
+        raise InvalidRequestError.new(errors: errors) unless errors.empty?
+
+        self
+      end
+
       # Get the current balances
       #
       # @return [Schwab::Resources::Base] The current balances object
@@ -159,6 +289,48 @@ module Schwab
           current_balances[:day_trading_buying_power]
       end
 
+      # Get the buying power that applies to buying an asset type
+      # Options use option buying power, cash accounts the cash available for trading, and
+      # margin accounts their (margin-inclusive) buying power.
+      #
+      # @param asset_type [String, Symbol] The asset type (e.g., "EQUITY", "OPTION")
+      # @return [Float, nil] The buying power, or nil when balances are not present
+      def buying_power_for(asset_type)
+        return unless current_balances
+
+        option_power = current_balances[:optionBuyingPower] || current_balances[:option_buying_power]
+        return option_power if asset_type.to_s.upcase == "OPTION" && option_power
+
+        if cash_account?
+          current_balances[:cashAvailableForTrading] || current_balances[:cash_available_for_trading] || buying_power
+        else
+          buying_power
+        end
+      end
+
+      # Calculate the largest quantity buying power allows at the ask price
+      # Whole units are returned except for mutual funds; option prices are per share, so each
+      # contract costs 100 times the ask.
+      #
+      # @param quote [Quote, Hash] The instrument quote
+      # @param asset_type [String, Symbol, nil] The asset type (default: the quote's asset type)
+      # @return [Integer, Float] The quantity, or zero when there is no buying power
+      # @raise [ArgumentError] if buying power is available but the quote has no positive ask price
+      # @example Size a market buy
+      #   account.max_buy_quantity(Schwab::MarketData.get_quote("AAPL")["AAPL"]) # => 57
+      def max_buy_quantity(quote, asset_type = nil)
+        quote = Quote.new(quote.to_h) unless quote.is_a?(Quote)
+        type = (asset_type || quote.asset_type || "EQUITY").to_s.upcase
+        power = buying_power_for(type).to_f
+        return 0 unless power.positive?
+
+        ask = quote.ask_price.to_f
+        raise ArgumentError, "Quote has no ask price" unless ask.positive?
+
+        multiplier = type == "OPTION" ? Symbols::OPTION_MULTIPLIER : 1
+        Quantity.round((power / (ask * multiplier)).floor(Quantity::FRACTIONAL_PRECISION), type)
+      end
+
       # Get maintenance requirement
       #
       # @return [Float, nil] The maintenance requirement
@@ -247,6 +419,20 @@ module Schwab
         positions_by_type(:option)
       end
 
+      # Group positions by category (see {Position#category})
+      #
+      # @return [Hash{String => Array<Schwab::Resources::Position>}] Positions by category
+      def positions_by_category
+        positions.group_by(&:category)
+      end
+
+      # Calculate net exposure per category, with short positions counted negative
+      #
+      # @return [Hash{String => Float}] Net exposure by category
+      def net_exposure_by_category
+        positions_by_category.transform_values { |group| group.sum(&:exposure).round(2) }
+      end
+
       # Calculate total market value of positions
       #
       # @return [Float] Total market value
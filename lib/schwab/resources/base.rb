@@ -2,6 +2,7 @@
 
 require "time"
 require "date"
+require_relative "../timestamps"
 
 module Schwab
   # Resource objects for wrapping API responses with convenient access patterns
@@ -16,6 +17,9 @@ module Schwab
     #   resource[:age] # => 30
     #   resource.address.city # => "NYC"
     class Base
+      # Field types applied to camelCase keys through their snake_case declarations
+      NUMERIC_FIELD_TYPES = [:integer, :float, :decimal, "Integer", "Float", "BigDecimal"].freeze
+
       class << self
         # Define fields that should be coerced to specific types
         # Subclasses can override this to specify their field types
@@ -27,6 +31,56 @@ module Schwab
         def set_field_type(field, type)
           field_types[field.to_sym] = type
         end
+
+        # Look up the coercion type for a field
+        # camelCase keys from the API match snake_case numeric declarations (averagePrice ->
+        # :average_price); other types apply only to the key they were declared with
+        #
+        # @param field [Symbol, String] The field name
+        # @return [Symbol, Class, nil] The declared type
+        def field_type_for(field)
+          type = field_types[field.to_sym]
+          return type if type
+
+          type = field_types[underscore_field_name(field).to_sym]
+          type if NUMERIC_FIELD_TYPES.include?(type.is_a?(Module) ? type.name : type)
+        end
+
+        # Declare the response keys this resource knows how to interpret
+        # Called without arguments, returns the declared keys
+        #
+        # @param fields [Array<Symbol, String>] Response keys (camelCase or snake_case)
+        # @return [Array<String>] All declared keys
+        def known_fields(*fields)
+          @known_fields ||= []
+          @known_fields.concat(fields.map(&:to_s))
+        end
+
+        # List top-level keys in response data that this resource does not declare
+        # Resources that declare no fields (like Base) accept every key
+        #
+        # @param data [Hash] The raw response data
+        # @return [Array<String>] Keys not covered by known_fields or field_types
+        def unmapped_keys(data)
+          known = known_fields + field_types.keys.map(&:to_s)
+          return [] if known.empty? || !data.is_a?(Hash)
+
+          # Pagination metadata is shared by all list responses (see #pagination)
+          normalized = (known + ["metadata"]).map { |field| normalize_field_name(field) }
+          data.keys.map(&:to_s).reject { |key| normalized.include?(normalize_field_name(key)) }
+        end
+
+        private
+
+        # Normalize camelCase and snake_case keys to a comparable form
+        def normalize_field_name(name)
+          name.to_s.delete("_").downcase
+        end
+
+        # Convert a camelCase key to snake_case
+        def underscore_field_name(name)
+          name.to_s.gsub(/([a-z\d])([A-Z])/, '\1_\2').downcase
+        end
       end
 
       # Initialize a new resource with data
@@ -114,6 +168,14 @@ module Schwab
         @data.keys
       end
 
+      # Get pagination details for list responses
+      #
+      # @return [Pagination, nil] Pagination read from the +metadata+ object, or nil if absent
+      def pagination
+        metadata = @data[:metadata] || @data["metadata"]
+        Pagination.new(metadata.to_h) if metadata
+      end
+
       # Convert to hash
       #
       # @return [Hash] The underlying data hash
@@ -186,9 +248,8 @@ module Schwab
       # @return [Object] The wrapped and coerced value
       def wrap_value(value, field_name = nil)
         # First apply type coercion if field type is defined
-        if field_name && self.class.field_types[field_name.to_sym]
-          value = coerce_value(value, self.class.field_types[field_name.to_sym])
-        end
+        field_type = self.class.field_type_for(field_name) if field_name
+        value = coerce_value(value, field_type) if field_type
 
         case value
         when Hash
@@ -217,12 +278,12 @@ module Schwab
         when :datetime, DateTime
           coerce_to_datetime(value)
         when :integer, Integer
-          value.to_i
+          coerce_to_number(value)&.to_i
         when :float, Float
-          value.to_f
+          coerce_to_number(value)&.to_f
         when :decimal, BigDecimal
           require "bigdecimal"
-          BigDecimal(value.to_s)
+          BigDecimal(value.to_s) unless coerce_to_number(value).nil?
         when :boolean
           coerce_to_boolean(value)
         when :symbol, Symbol
@@ -234,6 +295,27 @@ module Schwab
         value # Return original value if coercion fails
       end
 
+      # Coerce a JSON number or numeric string (e.g. "150.25") to a number
+      # Schwab occasionally encodes numbers as strings; blank strings become nil
+      # and non-numeric values raise so the original value is preserved
+      def coerce_to_number(value)
+        case value
+        when Numeric
+          value
+        when String
+          stripped = value.strip
+          Float(stripped) unless stripped.empty?
+        else
+          raise ArgumentError, "Cannot coerce #{value.class} to a number"
+        end
+      end
+
+      # Timezone for datetimes without an offset, from the client's configuration
+      # (nil falls back to the global configuration)
+      def default_timezone
+        @client.config.default_timezone if @client.respond_to?(:config)
+      end
+
       # Coerce to Time
       def coerce_to_time(value)
         case value
@@ -242,7 +324,7 @@ module Schwab
         when Date, DateTime
           value.to_time
         when String
-          Time.parse(value)
+          Timestamps.parse(value, default_timezone)
         when Integer, Float
           # Assume milliseconds timestamp if large number
           value > 9999999999 ? Time.at(value / 1000.0) : Time.at(value)
@@ -273,7 +355,7 @@ module Schwab
         when Date
           value.to_time
         when String
-          Time.parse(value)
+          Timestamps.parse(value, default_timezone)
         when Integer, Float
           # Assume milliseconds timestamp if large number
           value > 9999999999 ? Time.at(value / 1000.0) : Time.at(value)
@@ -1,12 +1,154 @@
 # frozen_string_literal: true
 
+require "json"
 require_relative "base"
+require_relative "quote"
+require_relative "../error"
+require_relative "../net_price"
 
 module Schwab
   module Resources
     # Resource wrapper for order objects
     # Provides order-specific helper methods and status checking
     class Order < Base
+      # Statuses for orders that can still fill, be canceled, or be replaced
+      OPEN_STATUSES = [
+        "AWAITING_PARENT_ORDER",
+        "AWAITING_CONDITION",
+        "AWAITING_STOP_CONDITION",
+        "AWAITING_MANUAL_REVIEW",
+        "ACCEPTED",
+        "AWAITING_UR_OUT",
+        "PENDING_ACTIVATION",
+        "QUEUED",
+        "WORKING",
+        "PENDING_CANCEL",
+        "PENDING_REPLACE",
+        "NEW",
+        "AWAITING_RELEASE_TIME",
+        "PENDING_ACKNOWLEDGEMENT",
+        "PENDING_RECALL",
+      ].freeze
+
+      # Statuses for orders that will not change again
+      TERMINAL_STATUSES = ["REJECTED", "CANCELED", "REPLACED", "FILLED", "EXPIRED"].freeze
+
+      # Routing destinations accepted as requestedDestination
+      DESTINATIONS = [
+        "INET",
+        "ECN_ARCA",
+        "CBOE",
+        "AMEX",
+        "PHLX",
+        "ISE",
+        "BOX",
+        "NYSE",
+        "NASDAQ",
+        "BATS",
+        "C2",
+        "AUTO",
+      ].freeze
+
+      # Special handling instructions accepted as specialInstruction
+      SPECIAL_INSTRUCTIONS = ["ALL_OR_NONE", "DO_NOT_REDUCE", "ALL_OR_NONE_DO_NOT_REDUCE"].freeze
+
+      # Durations that cannot be combined with an all-or-none instruction
+      ALL_OR_NONE_INCOMPATIBLE_DURATIONS = ["FILL_OR_KILL", "FOK", "IMMEDIATE_OR_CANCEL", "IOC"].freeze
+
+      # Trading sessions accepted as session: regular hours, pre-market, after-hours, or all three
+      SESSIONS = ["NORMAL", "AM", "PM", "SEAMLESS"].freeze
+
+      # Session used when an order does not set one
+      DEFAULT_SESSION = "NORMAL"
+
+      # Order types Schwab accepts outside regular hours
+      EXTENDED_HOURS_ORDER_TYPES = ["LIMIT"].freeze
+
+      # Order types that accept an activationPrice, which arms the order once the market reaches it
+      ACTIVATION_PRICE_ORDER_TYPES = ["STOP", "STOP_LIMIT", "TRAILING_STOP", "TRAILING_STOP_LIMIT"].freeze
+
+      # complexOrderStrategyType values for orders that are not a multi-leg strategy
+      SIMPLE_STRATEGY_TYPES = ["NONE"].freeze
+
+      # Largest gap, as a fraction of the stop price, between a stop-limit's stop and limit
+      # before {#validate_with_quote} flags the limit
+      STOP_LIMIT_MAX_GAP = 0.1
+
+      # A non-fatal concern about an order's prices, from {#validate_with_quote}
+      PriceWarning = Struct.new(:field, :message, keyword_init: true) do
+        # @return [String] The warning message
+        def to_s
+          message
+        end
+      end
+
+      # Commission and regulatory fees charged on an order; every field defaults to 0.0
+      # +other+ collects charge types without a dedicated field and is included in +total+.
+      Fees = Struct.new(:commission, :sec_fee, :taf_fee, :option_reg_fee, :other, keyword_init: true) do
+        # @return [Float] Sum of all charges
+        def total
+          (commission + sec_fee + taf_fee + option_reg_fee + other).round(4)
+        end
+      end
+
+      # Why an order was rejected, classified from Schwab's free-text +statusDescription+
+      #
+      # @!attribute code
+      #   @return [String] One of REJECT_REASONS (OTHER when no pattern matches)
+      # @!attribute text
+      #   @return [String, nil] The reason exactly as Schwab sent it
+      RejectReason = Struct.new(:code, :text, keyword_init: true) do
+        # @return [String] The code and text, e.g. "INSUFFICIENT_FUNDS: Insufficient buying power"
+        def to_s
+          text.to_s.empty? ? code : "#{code}: #{text}"
+        end
+      end
+
+      # Codes a RejectReason can have
+      REJECT_REASONS = [
+        "INSUFFICIENT_FUNDS",
+        "MARKET_CLOSED",
+        "INVALID_SYMBOL",
+        "RISK_LIMIT",
+        "DUPLICATE",
+        "OTHER",
+      ].freeze
+
+      # Rejection messages mapped to RejectReason codes, checked in order; the first match wins.
+      # Schwab does not publish its rejection messages, so these match the wording it is known to use.
+      REJECT_REASON_PATTERNS = [
+        ["DUPLICATE", /duplicate|identical order/i],
+        ["INSUFFICIENT_FUNDS", /insufficient|buying power|not enough (cash|funds)|available funds/i],
+        ["MARKET_CLOSED", MarketClosedError::MESSAGE_PATTERN],
+        [
+          "INVALID_SYMBOL",
+          Regexp.union(
+            /invalid (symbol|security)/i,
+            /symbol .*(not found|not recognized|is not valid)/i,
+            /(unknown|no such) (symbol|security)/i,
+          ),
+        ],
+        [
+          "RISK_LIMIT",
+          Regexp.union(
+            /risk/i,
+            /exceeds? .*(limit|maximum)/i,
+            /margin requirement/i,
+            /restricted/i,
+            /not (permitted|allowed) .*account/i,
+          ),
+        ],
+      ].freeze
+
+      # Schwab charge types mapped to Fees fields
+      FEE_TYPES = {
+        "COMMISSION" => :commission,
+        "BASE_CHARGE" => :commission,
+        "SEC_FEE" => :sec_fee,
+        "TAF_FEE" => :taf_fee,
+        "OPT_REG_FEE" => :option_reg_fee,
+      }.freeze
+
       # Set up field type coercions for order fields
       set_field_type :entered_time, :datetime
       set_field_type :close_time, :datetime
@@ -19,6 +161,50 @@ module Schwab
       set_field_type :activation_price, :float
       set_field_type :commission, :float
 
+      # Response keys interpreted by this resource
+      known_fields :orderId, :accountNumber, :status, :statusDescription, :orderType, :session,
+        :duration, :cancelTime, :releaseTime, :complexOrderStrategyType, :orderStrategyType,
+        :requestedDestination, :destinationLinkName, :stopPriceLinkBasis, :stopPriceLinkType,
+        :stopPriceOffset, :stopType, :priceLinkBasis, :priceLinkType, :taxLotMethod,
+        :orderLegCollection, :specialInstruction, :cancelable, :editable, :tag,
+        :orderActivityCollection, :replacingOrderCollection, :childOrderStrategies, :commissionAndFee
+
+      class << self
+        # Validate a serialized order without a client
+        # Runs {#validate} and reports every problem at once, so a pre-trade check can show
+        # them all in one pass. Malformed JSON is reported as a single error.
+        #
+        # @param json [String] The order as JSON, in the shape sent to Trading.place_order
+        # @return [Array<String>] Validation error messages (empty when valid)
+        # @example
+        #   Schwab::Resources::Order.validate_json(request.body.read).each { |error| puts error }
+        def validate_json(json)
+          parse_json(json).validate
+        rescue InvalidRequestError => e
+          e.errors
+        end
+
+        # Validate a serialized order without a client, raising on failure
+        #
+        # @param json [String] The order as JSON
+        # @return [Order] The parsed, valid order
+        # @raise [InvalidRequestError] if the JSON is malformed or the order is invalid, with every error in #errors
+        def validate_json!(json)
+          parse_json(json).validate!
+        end
+
+        private
+
+        def parse_json(json)
+          data = JSON.parse(json.to_s)
+          raise InvalidRequestError.new(errors: ["Order JSON must be an object"]) unless data.is_a?(Hash)
+
+          new(data)
+        rescue JSON::ParserError => e
+          raise InvalidRequestError.new(errors: ["Invalid order JSON: #{e.message}"])
+        end
+      end
+
       # Get order ID
       #
       # @return [String] The order ID
@@ -41,6 +227,29 @@ module Schwab
         self[:status] || self[:orderStatus] || self[:order_status]
       end
 
+      # Get Schwab's description of the status, such as the reason an order was rejected
+      #
+      # @return [String, nil] The description
+      def status_description
+        self[:statusDescription] || self[:status_description]
+      end
+
+      # Get the reason a rejected order was rejected
+      # The free-text +statusDescription+ is classified with REJECT_REASON_PATTERNS, so retry logic
+      # can branch on the code; the text is kept as Schwab sent it.
+      #
+      # @return [RejectReason, nil] The reason, or nil unless the order is {#rejected?}
+      # @example Retry only when the market was closed
+      #   reason = order.reject_reason
+      #   schedule_retry(order) if reason&.code == "MARKET_CLOSED"
+      def reject_reason
+        return unless rejected?
+
+        text = status_description
+        code = REJECT_REASON_PATTERNS.find { |_, pattern| pattern.match?(text.to_s) }&.first
+        RejectReason.new(code: code || "OTHER", text: text)
+      end
+
       # Get order type
       #
       # @return [String] The order type (MARKET, LIMIT, STOP, etc.)
@@ -50,9 +259,16 @@ module Schwab
 
       # Get session (regular or extended hours)
       #
-      # @return [String] The session (NORMAL, AM, PM, SEAMLESS)
+      # @return [String] The session (see SESSIONS), DEFAULT_SESSION when unset
       def session
-        self[:session] || self[:tradingSession] || self[:trading_session]
+        self[:session] || self[:tradingSession] || self[:trading_session] || DEFAULT_SESSION
+      end
+
+      # Set the trading session
+      #
+      # @param value [String, Symbol, nil] The session (see SESSIONS)
+      def session=(value)
+        write_field(:session, value&.to_s&.upcase)
       end
 
       # Get duration (time in force)
@@ -63,6 +279,41 @@ module Schwab
       end
       alias_method :time_in_force, :duration
 
+      # Get the requested routing destination
+      #
+      # @return [String, nil] The destination (see DESTINATIONS)
+      def destination
+        self[:requestedDestination] || self[:requested_destination] || self[:destination]
+      end
+
+      # Set the requested routing destination
+      #
+      # @param value [String, Symbol, nil] The destination (see DESTINATIONS)
+      def destination=(value)
+        write_field(:requestedDestination, value&.to_s&.upcase)
+      end
+
+      # Get the special handling instruction
+      #
+      # @return [String, nil] The special instruction (see SPECIAL_INSTRUCTIONS)
+      def special_instruction
+        self[:specialInstruction] || self[:special_instruction]
+      end
+
+      # Set the special handling instruction
+      #
+      # @param value [String, Symbol, nil] The special instruction (see SPECIAL_INSTRUCTIONS)
+      def special_instruction=(value)
+        write_field(:specialInstruction, value&.to_s&.upcase)
+      end
+
+      # Check if the order must fill completely or not at all
+      #
+      # @return [Boolean] True if an all-or-none instruction is set
+      def all_or_none?
+        special_instruction.to_s.start_with?("ALL_OR_NONE")
+      end
+
       # Get instruction (BUY, SELL, etc.)
       #
       # @return [String] The instruction
@@ -139,20 +390,91 @@ module Schwab
         self[:activationPrice] || self[:activation_price]
       end
 
+      # Set the activation price, which arms a stop or trailing stop only once the market reaches it
+      #
+      # @param value [Numeric, nil] The activation price, or nil to remove it
+      def activation_price=(value)
+        write_field(:activationPrice, value)
+      end
+
+      # Get the net price of a multi-leg order
+      #
+      # @return [NetPrice, nil] The net price, or nil unless the order type is NET_DEBIT, NET_CREDIT, or NET_ZERO
+      def net_price
+        return unless net_price?
+
+        amount = self[:price] || (order_type.to_s.upcase == "NET_ZERO" ? 0 : nil)
+        amount && NetPrice.new(amount, order_type)
+      rescue ArgumentError
+        nil
+      end
+
+      # Check if the order is priced as a net debit, net credit, or even money
+      #
+      # @return [Boolean] True for NET_DEBIT, NET_CREDIT, and NET_ZERO orders
+      def net_price?
+        NetPrice::TYPES.include?(order_type.to_s.upcase)
+      end
+
+      # Get the commission and fee breakdown
+      # Read from the commissionAndFee object Schwab includes in order previews; orders without it
+      # report zero for every charge.
+      #
+      # @return [Fees] The charges
+      def fees
+        charges = { commission: 0.0, sec_fee: 0.0, taf_fee: 0.0, option_reg_fee: 0.0, other: 0.0 }
+        details = self[:commissionAndFee] || {}
+
+        commission = details[:commission] || {}
+        fee = details[:fee] || {}
+        charge_values = Array(commission[:commissionLegs]).flat_map { |leg| Array(leg[:commissionValues]) } +
+          Array(fee[:feeLegs]).flat_map { |leg| Array(leg[:feeValues]) }
+
+        charge_values.each do |charge|
+          field = FEE_TYPES.fetch(charge[:type].to_s.upcase, :other)
+          charges[field] += charge[:value].to_f
+        end
+
+        Fees.new(**charges)
+      end
+
+      # Get the total of all commissions and fees
+      #
+      # @return [Float] The total charges (0.0 when none are reported)
+      def total_fees
+        fees.total
+      end
+
       # Get entered time
       #
-      # @return [Time, String] The time order was entered
+      # @return [Time, nil] The time order was entered
       def entered_time
         self[:enteredTime] || self[:entered_time] || self[:createdTime] || self[:created_time]
       end
 
       # Get close time (when order was filled/cancelled)
       #
-      # @return [Time, String, nil] The close time
+      # @return [Time, nil] The close time, nil while the order is open
       def close_time
         self[:closeTime] || self[:close_time] || self[:filledTime] || self[:filled_time]
       end
 
+      # Get how long the order stayed working, from entry to close
+      # The close may be a fill, a cancel, an expiry, or a replacement.
+      #
+      # @return [Float, nil] Seconds between entry and close, or nil while the order is open or
+      #   when either time is missing
+      # @example Measure fill latency
+      #   orders.select(&:filled?).filter_map(&:time_to_fill)
+      def time_to_fill
+        entered = entered_time
+        closed = close_time
+        return unless entered.respond_to?(:to_time) && closed.respond_to?(:to_time)
+        return if status && !TERMINAL_STATUSES.include?(status.upcase)
+
+        closed.to_time - entered.to_time
+      end
+
       # Get order legs
       #
       # @return [Array] Array of order legs
@@ -194,6 +516,15 @@ module Schwab
         order_legs.size > 1
       end
 
+      # Check if this is a multi-leg strategy such as a vertical, straddle, or iron condor
+      # True when complexOrderStrategyType names a strategy, or the order has several legs.
+      #
+      # @return [Boolean] True if the order is a complex strategy
+      def complex_strategy?
+        strategy = self[:complexOrderStrategyType] || self[:complex_order_strategy_type]
+        strategy ? !SIMPLE_STRATEGY_TYPES.include?(strategy.to_s.upcase) : complex?
+      end
+
       # Check if this is a single-leg order
       #
       # @return [Boolean] True if single-leg
@@ -348,6 +679,14 @@ module Schwab
         ["BUY_TO_CLOSE", "SELL_TO_CLOSE", "BUY_TO_COVER"].include?(inst)
       end
 
+      # Get the asset type of the first leg's instrument
+      #
+      # @return [String, nil] The asset type (e.g., "EQUITY", "OPTION")
+      def asset_type
+        instrument = order_legs.first && order_legs.first[:instrument]
+        instrument && instrument[:assetType]
+      end
+
       # Check if option order
       #
       # @return [Boolean] True if option order
@@ -421,6 +760,92 @@ module Schwab
         ((filled_quantity / quantity) * 100).round(2)
       end
 
+      # Validate the order before submission
+      #
+      # @return [Array<String>] Validation error messages (empty when valid)
+      def validate
+        errors = []
+        validate_routing(errors)
+        validate_session(errors)
+        validate_stop_prices(errors)
+        validate_activation_price(errors)
+        validate_net_price(errors)
+        errors
+      end
+
+      # Validate the order, then check it against an instrument's trading capabilities
+      #
+      # @param capabilities [Instruments::TradingCapabilities] The instrument's capabilities
+      #   (see Instruments.trading_capabilities)
+      # @return [Array<String>] Validation error messages (empty when valid)
+      # @example
+      #   order.validate_for_instrument(Schwab::Instruments.trading_capabilities("VFIAX"))
+      def validate_for_instrument(capabilities)
+        errors = validate
+        label = capabilities.symbol || capabilities.asset_type || "This instrument"
+        type = (order_type || "MARKET").to_s.upcase
+
+        errors << "#{label} does not support #{type} orders" unless capabilities.supports_order_type?(type)
+        errors << "#{label} cannot trade in the #{session.to_s.upcase} session" unless capabilities.supports_session?(session)
+        if !capabilities.fractional? && order_legs.any? { |leg| leg[:quantity].to_f != leg[:quantity].to_f.floor }
+          errors << "#{label} does not support fractional quantities"
+        end
+        if capabilities.shortable == false && order_legs.any? { |leg| leg[:instruction].to_s.upcase.start_with?("SELL_SHORT") }
+          errors << "#{label} is not available to sell short"
+        end
+        errors
+      end
+
+      # Check the order's stop and limit prices against the market
+      # Unlike {#validate}, which is purely structural, these checks depend on a reference price
+      # and only return warnings: a stop on the wrong side of the market triggers at once, and a
+      # stop-limit whose limit is on the wrong side of (or far from) its stop may never fill.
+      #
+      # @param quote [Quote, Hash, Numeric] A quote (last price, falling back to mark) or a reference price
+      # @return [Array<PriceWarning>] Warnings (empty when nothing looks suspicious or no price is known)
+      # @example
+      #   order.validate_with_quote(Schwab::MarketData.get_quote("AAPL")).each { |w| puts w }
+      def validate_with_quote(quote)
+        reference = reference_price(quote)
+        return [] unless reference && (stop_order? || stop_limit_order?) && stop_price && (buy? || sell?)
+
+        warnings = []
+        stop = stop_price.to_f
+        if sell? && stop >= reference
+          warnings << PriceWarning.new(
+            field: :stop_price,
+            message: "Sell stop #{format("%.2f", stop)} is at or above the market (#{format("%.2f", reference)}) " \
+              "and would trigger immediately",
+          )
+        elsif buy? && stop <= reference
+          warnings << PriceWarning.new(
+            field: :stop_price,
+            message: "Buy stop #{format("%.2f", stop)} is at or below the market (#{format("%.2f", reference)}) " \
+              "and would trigger immediately",
+          )
+        end
+        validate_stop_limit_gap(stop, warnings) if stop_limit_order?
+        warnings
+      end
+
+      # Check if the order passes validation
+      #
+      # @return [Boolean] True if valid
+      def valid?
+        validate.empty?
+      end
+
+      # Validate the order, raising on failure
+      #
+      # @return [Order] self
+      # @raise [InvalidRequestError] if the order is invalid, with all errors in #errors
+      def validate!
+        errors = validate
+        raise InvalidRequestError.new(errors: errors) unless errors.empty?
+
+        self
+      end
+
       # Get formatted display string for the order
       #
       # @return [String] Formatted order string
@@ -436,6 +861,138 @@ module Schwab
 
         parts.compact.join(" ")
       end
+
+      # Get a one-line summary of the order for logs and confirmations
+      # Multi-leg orders list each leg, separated by " / ".
+      #
+      # @return [String] The summary
+      # @example
+      #   order.summary # => "BUY 5 AAPL @ LIMIT 150.00 DAY (OPEN)"
+      #   order.summary # => "BUY 5 AAPL @ LIMIT 150.00 DAY (WORKING, filled 2/5)"
+      def summary
+        legs = order_legs.map do |leg|
+          instrument = leg[:instrument] || {}
+          [leg[:instruction], format_quantity(leg[:quantity].to_f), instrument[:symbol]].compact.join(" ")
+        end
+        legs = [[instruction, format_quantity(quantity), symbol].compact.join(" ")] if legs.empty?
+
+        parts = [legs.join(" / "), "@", order_type || "MARKET"]
+        parts << Price.display(price, asset_type) if price
+        parts << "stop #{Price.display(stop_price, asset_type)}" if stop_limit_order? && stop_price
+        parts << "activation #{Price.display(activation_price, asset_type)}" if activation_price
+        parts << duration if duration
+        parts << summary_state if status || filled_quantity.positive?
+
+        parts.join(" ")
+      end
+      alias_method :to_s, :summary
+
+      private
+
+      def summary_state
+        fills = "filled #{format_quantity(filled_quantity)}/#{format_quantity(quantity)}" if filled_quantity.positive?
+        "(#{[status, fills].compact.join(", ")})"
+      end
+
+      def format_quantity(value)
+        value == value.to_i ? value.to_i.to_s : value.to_s
+      end
+
+      # Store a payload field under a single key so serialization has no duplicates
+      def write_field(key, value)
+        @data.delete(key.to_s)
+        if value.nil?
+          @data.delete(key)
+        else
+          @data[key] = value
+        end
+      end
+
+      def reference_price(quote)
+        return quote.to_f if quote.is_a?(Numeric)
+        return if quote.nil?
+
+        quote = Quote.new(quote.to_h) unless quote.is_a?(Quote)
+        (quote.last_price || quote.mark)&.to_f
+      end
+
+      def validate_stop_limit_gap(stop, warnings)
+        limit = self[:price] || limit_price
+        return unless limit
+
+        limit = limit.to_f
+        if sell? ? limit > stop : limit < stop
+          side = sell? ? "above" : "below"
+          warnings << PriceWarning.new(
+            field: :price,
+            message: "#{sell? ? "Sell" : "Buy"} stop-limit price #{format("%.2f", limit)} is #{side} the stop " \
+              "#{format("%.2f", stop)} and may not fill once triggered",
+          )
+        elsif (limit - stop).abs > stop * STOP_LIMIT_MAX_GAP
+          warnings << PriceWarning.new(
+            field: :price,
+            message: "Stop-limit price #{format("%.2f", limit)} is more than #{(STOP_LIMIT_MAX_GAP * 100).round}% " \
+              "from the stop #{format("%.2f", stop)}",
+          )
+        end
+      end
+
+      def validate_stop_prices(errors)
+        return unless stop_limit_order?
+
+        errors << "STOP_LIMIT orders require a stop price" unless stop_price
+        errors << "STOP_LIMIT orders require a limit price" unless self[:price] || limit_price
+      end
+
+      def validate_activation_price(errors)
+        return if activation_price.nil?
+
+        type = (order_type || "MARKET").to_s.upcase
+        unless ACTIVATION_PRICE_ORDER_TYPES.include?(type)
+          errors << "#{type} orders do not accept an activation price (only #{ACTIVATION_PRICE_ORDER_TYPES.join(", ")})"
+        end
+        errors << "Activation price must be positive" unless activation_price.to_f.positive?
+      end
+
+      def validate_net_price(errors)
+        if limit_order? && complex_strategy?
+          errors << "Multi-leg LIMIT orders must be priced as NET_DEBIT, NET_CREDIT, or NET_ZERO (see Schwab::NetPrice)"
+        end
+        return unless net_price?
+
+        type = order_type.to_s.upcase
+        amount = self[:price]
+        return errors << "#{type} orders require a price" if amount.nil? && type != "NET_ZERO"
+
+        NetPrice.new(amount || 0, type)
+      rescue ArgumentError => e
+        errors << e.message
+      end
+
+      def validate_session(errors)
+        session_name = session.to_s.upcase
+        return errors << "Unknown session: #{session}" unless SESSIONS.include?(session_name)
+        return if session_name == DEFAULT_SESSION
+
+        type = (order_type || "MARKET").to_s.upcase
+        unless EXTENDED_HOURS_ORDER_TYPES.include?(type)
+          errors << "#{type} orders are not allowed in the #{session_name} session (extended hours accept LIMIT orders only)"
+        end
+      end
+
+      def validate_routing(errors)
+        if destination && !DESTINATIONS.include?(destination.to_s.upcase)
+          errors << "Unknown destination: #{destination}"
+        end
+
+        if special_instruction && !SPECIAL_INSTRUCTIONS.include?(special_instruction.to_s.upcase)
+          errors << "Unknown special instruction: #{special_instruction}"
+        end
+
+        if all_or_none? && ALL_OR_NONE_INCOMPATIBLE_DURATIONS.include?(duration&.upcase)
+          errors << "#{special_instruction} cannot be combined with #{duration} duration"
+        end
+      end
     end
   end
 end
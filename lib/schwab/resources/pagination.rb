@@ -0,0 +1,79 @@
+# frozen_string_literal: true
+
+require_relative "base"
+
+module Schwab
+  module Resources
+    # Resource wrapper for list pagination details
+    # Reads the +metadata+ object that accompanies paged list responses. Most Schwab endpoints
+    # return complete lists, in which case pagination is inferred from the list itself and
+    # there is never a next page.
+    #
+    # @example Walk pages generically
+    #   page = Schwab::Resources::Pagination.from_response(response)
+    #   page.next_page? # => false
+    class Pagination < Base
+      set_field_type :total_count, :integer
+      set_field_type :limit, :integer
+      set_field_type :offset, :integer
+
+      # Response keys interpreted by this resource
+      known_fields :totalCount, :limit, :offset, :nextPageToken
+
+      class << self
+        # Build pagination details from any list response
+        #
+        # @param response [Hash, Array, Base] A response body
+        # @return [Pagination, nil] Pagination from the response's metadata, inferred for bare
+        #   lists, or nil when the response carries no list
+        def from_response(response)
+          case response
+          when Array
+            new({ totalCount: response.size, limit: response.size, offset: 0 })
+          when Base
+            response.pagination
+          when Hash
+            metadata = response[:metadata] || response["metadata"]
+            new(metadata.to_h) if metadata
+          end
+        end
+      end
+
+      # Get the total number of items across all pages
+      #
+      # @return [Integer, nil] The total count
+      def total_count
+        self[:totalCount] || self[:total_count]
+      end
+
+      # Get the page size
+      #
+      # @return [Integer, nil] The maximum number of items per page
+      def limit
+        self[:limit]
+      end
+
+      # Get the index of the first item in this page
+      #
+      # @return [Integer] The offset (default: 0)
+      def offset
+        self[:offset] || 0
+      end
+
+      # Get the token for requesting the next page
+      #
+      # @return [String, nil] The next page token
+      def next_page_token
+        token = self[:nextPageToken] || self[:next_page_token]
+        token unless token.to_s.empty?
+      end
+
+      # Check if more items are available
+      #
+      # @return [Boolean] True if a next page token is present
+      def next_page?
+        !next_page_token.nil?
+      end
+    end
+  end
+end
@@ -21,6 +21,14 @@ module Schwab
       set_field_type :previous_session_long_quantity, :float
       set_field_type :previous_session_short_quantity, :float
 
+      # Asset types grouped into the CASH category regardless of side
+      CASH_ASSET_TYPES = ["CASH_EQUIVALENT", "CURRENCY"].freeze
+
+      # Response keys interpreted by this resource
+      known_fields :instrument, :symbol, :assetType, :cusip, :averageLongPrice, :averageShortPrice,
+        :taxLotAverageLongPrice, :taxLotAverageShortPrice, :longOpenProfitLoss, :shortOpenProfitLoss,
+        :agedQuantity, :quote, :currentPrice, :lastPrice
+
       # Get the symbol
       #
       # @return [String] The position symbol
@@ -170,6 +178,24 @@ module Schwab
         quantity < 0
       end
 
+      # Get the grouping category: "CASH" for cash-like holdings, otherwise the side and
+      # asset type (e.g., "LONG_EQUITY", "SHORT_OPTION")
+      #
+      # @return [String] The category
+      def category
+        type = asset_type.to_s.upcase
+        return "CASH" if CASH_ASSET_TYPES.include?(type)
+
+        "#{short? ? "SHORT" : "LONG"}_#{type.empty? ? "UNKNOWN" : type}"
+      end
+
+      # Get the signed market value: positive for long positions, negative for short ones
+      #
+      # @return [Float] The exposure
+      def exposure
+        short? ? -market_value.abs : market_value.abs
+      end
+
       # Check if this is an equity position
       #
       # @return [Boolean] True if equity
@@ -0,0 +1,175 @@
+# frozen_string_literal: true
+
+require_relative "base"
+
+module Schwab
+  module Resources
+    # Resource wrapper for a single symbol's quote from the quotes endpoints
+    # Provides price accessors and freshness checks based on the quote timestamp
+    class Quote < Base
+      # Response keys interpreted by this resource
+      known_fields :symbol, :assetMainType, :assetSubType, :quoteType, :realtime, :ssid,
+        :quote, :reference, :regular, :fundamental, :extended
+
+      # Get the symbol
+      #
+      # @return [String] The quoted symbol
+      def symbol
+        self[:symbol]
+      end
+
+      # Get the asset type
+      #
+      # @return [String, nil] The asset type (e.g., "EQUITY", "INDEX", "OPTION")
+      def asset_type
+        self[:assetMainType] || self[:asset_main_type]
+      end
+
+      # Get the quote type
+      #
+      # @return [String, nil] The quote type: "NBBO" for real-time, "NFL" (non-fee liable) for delayed
+      def quote_type
+        self[:quoteType] || self[:quote_type]
+      end
+
+      # Check if the quote is real-time
+      #
+      # @return [Boolean] True if Schwab marked the quote as real-time
+      def realtime?
+        return quote_type&.upcase == "NBBO" unless key?(:realtime)
+
+        self[:realtime] == true
+      end
+
+      # Check if the quote is delayed
+      # Displaying delayed data usually requires labeling it as such
+      #
+      # @return [Boolean] True if the quote is delayed
+      def delayed?
+        !realtime?
+      end
+
+      # Get the last trade price
+      #
+      # @return [Float, nil] The last price
+      def last_price
+        quote_field(:lastPrice)
+      end
+
+      # Get the bid price
+      #
+      # @return [Float, nil] The bid price
+      def bid_price
+        quote_field(:bidPrice)
+      end
+
+      # Get the ask price
+      #
+      # @return [Float, nil] The ask price
+      def ask_price
+        quote_field(:askPrice)
+      end
+
+      # Get the mark price
+      #
+      # @return [Float, nil] The mark price
+      def mark
+        quote_field(:mark)
+      end
+
+      # Check if the quote has both a positive bid and a positive ask that are not crossed
+      # Spread and mid-price helpers return nil for any other quote.
+      #
+      # @return [Boolean] True if the bid/ask can be used for spread and mid-price
+      def two_sided?
+        bid = bid_price
+        ask = ask_price
+        !bid.nil? && !ask.nil? && bid.positive? && ask.positive? && bid <= ask
+      end
+
+      # Check if the bid is above the ask
+      # Crossed markets are transient or bad data; do not price orders from them.
+      #
+      # @return [Boolean] True if both sides are present and the bid exceeds the ask
+      def crossed?
+        bid = bid_price
+        ask = ask_price
+        !bid.nil? && !ask.nil? && bid > ask
+      end
+
+      # Get the bid/ask spread
+      #
+      # @return [Float, nil] Ask minus bid, or nil unless the quote is {#two_sided?}
+      def spread
+        (ask_price - bid_price).to_f if two_sided?
+      end
+
+      # Get the bid/ask spread as a percentage of the mid-price
+      #
+      # @return [Float, nil] The spread in percent (e.g., 0.05 for five basis points), or nil unless {#two_sided?}
+      def spread_percent
+        spread / mid_price * 100 if two_sided?
+      end
+
+      # Get the midpoint between bid and ask, e.g. to price a limit order
+      #
+      # @return [Float, nil] The mid-price, or nil unless the quote is {#two_sided?}
+      # @example Price a limit buy at the mid
+      #   price = Schwab::Price.format(quote.mid_price) if quote.mid_price
+      def mid_price
+        (bid_price + ask_price) / 2.0 if two_sided?
+      end
+
+      # Get the previous session's closing price
+      #
+      # @return [Float, nil] The prior close
+      def close_price
+        quote_field(:closePrice)
+      end
+
+      # Get the net change from the prior close
+      #
+      # @return [Float, nil] The net change
+      def net_change
+        quote_field(:netChange)
+      end
+
+      # Get the time the quote was last updated
+      # Falls back to the last trade time for instruments without a quote time (e.g., indices)
+      #
+      # @return [Time, nil] The quote timestamp
+      def quote_time
+        timestamp = quote_field(:quoteTime) || quote_field(:tradeTime)
+        coerce_to_time(timestamp) if timestamp
+      end
+
+      # Get how old the quote is
+      #
+      # @param now [Time] The reference time (default: Time.now)
+      # @return [Float, nil] Age in seconds, or nil when the quote has no timestamp
+      def age(now = Time.now)
+        time = quote_time
+        now - time if time
+      end
+
+      # Check if the quote is older than the given age
+      # Quotes without a timestamp cannot be verified and are treated as stale
+      #
+      # @param max_age [Numeric] Maximum acceptable age in seconds
+      # @param now [Time] The reference time (default: Time.now)
+      # @return [Boolean] True if stale
+      def stale?(max_age, now = Time.now)
+        current_age = age(now)
+        current_age.nil? || current_age > max_age
+      end
+
+      private
+
+      # Read a field from the nested quote block
+      def quote_field(name)
+        quote = self[:quote]
+        quote[name] if quote
+      end
+    end
+  end
+end
@@ -14,6 +14,9 @@ module Schwab
       set_field_type :remaining_quantity, :float
       set_field_type :quantity, :float
 
+      # Response keys interpreted by this resource
+      known_fields :strategyType, :strategyName, :name, :status, :legs, :strategyLegs, :orderLegCollection
+
       # Get strategy type
       #
       # @return [String] The strategy type
@@ -1,6 +1,7 @@
 # frozen_string_literal: true
 
 require_relative "base"
+require_relative "../transaction_type"
 
 module Schwab
   module Resources
@@ -18,6 +19,11 @@ module Schwab
       set_field_type :amount, :float
       set_field_type :cost, :float
 
+      # Response keys interpreted by this resource
+      known_fields :activityId, :transactionId, :time, :user, :description, :accountNumber, :type,
+        :transactionType, :transactionSubType, :status, :subAccount, :tradeDate, :transactionDate,
+        :positionId, :orderId, :activityType, :transferItems, :transactionItem, :instrument
+
       # Get transaction ID
       #
       # @return [String] The transaction ID
@@ -143,6 +149,23 @@ module Schwab
         end.to_f
       end
 
+      # Check if the transaction has one of the given types
+      #
+      # @param types [Array<String, Symbol>] Transaction types (see TransactionType::ALL)
+      # @return [Boolean] True if the transaction type matches any of them
+      # @example
+      #   transaction.type_in?(Schwab::TransactionType::WIRE_IN, Schwab::TransactionType::ACH_RECEIPT)
+      def type_in?(*types)
+        types.flatten.map { |type| type.to_s.upcase }.include?(transaction_type&.upcase)
+      end
+
+      # Check if the transaction type is one Schwab documents
+      #
+      # @return [Boolean] True if the type is in TransactionType::ALL
+      def known_type?
+        TransactionType.valid?(transaction_type.to_s)
+      end
+
       # Check if this is a trade transaction
       #
       # @return [Boolean] True if trade
@@ -0,0 +1,71 @@
+# frozen_string_literal: true
+
+module Schwab
+  # A service method's result together with the HTTP responses behind it
+  #
+  # Service methods return only the decoded data. Wrap a call in {Schwab.with_response} to also
+  # get the Faraday responses it received, for headers such as Schwab's correlation ID, without
+  # dropping down to Client#raw_request. Methods that make several requests (pagination,
+  # account number resolution) report every response in order; {#http_response} is the last one.
+  #
+  # @example Read the correlation ID of a placed order
+  #   response = Schwab.with_response { Schwab::Trading.place_order("123456", order: order) }
+  #   response.data[:orderId]
+  #   response.request_id # => "8f0b2c9e-..."
+  #
+  # @!attribute data
+  #   @return [Object] The block's result
+  # @!attribute http_responses
+  #   @return [Array<Faraday::Response>] Every response received inside the block, oldest first
+  Response = Struct.new(:data, :http_responses) do
+    # Header carrying Schwab's ID for a request
+    self::REQUEST_ID_HEADER = "Schwab-Client-CorrelId"
+
+    class << self
+      # Run a block and collect the responses of the requests it makes
+      # Captures nest: an outer block also sees the responses of inner ones.
+      #
+      # @yield The block to run
+      # @return [Response] The block's result and its responses
+      def capture
+        stack = (Thread.current[:schwab_response_captures] ||= [])
+        responses = []
+        stack.push(responses)
+        begin
+          new(yield, responses)
+        ensure
+          stack.pop
+        end
+      end
+
+      # Record a response with every capture active on this thread
+      # Called by Client for each successful request.
+      #
+      # @param response [Faraday::Response] The response
+      def record(response)
+        Thread.current[:schwab_response_captures]&.each { |responses| responses << response }
+        response
+      end
+    end
+
+    # @return [Faraday::Response, nil] The last response received, or nil if nothing was sent
+    def http_response
+      http_responses.last
+    end
+
+    # @return [Hash] Headers of the last response (empty if nothing was sent)
+    def headers
+      http_response ? http_response.headers : {}
+    end
+
+    # @return [Integer, nil] HTTP status of the last response
+    def status
+      http_response&.status
+    end
+
+    # @return [String, nil] Schwab's correlation ID for the last request, when sent
+    def request_id
+      headers[self.class::REQUEST_ID_HEADER]
+    end
+  end
+end
@@ -0,0 +1,128 @@
+# frozen_string_literal: true
+
+require_relative "resources/position"
+
+module Schwab
+  # Concentration and beta of a set of positions
+  #
+  # Concentration weights are each position's share of gross exposure: the sum of absolute
+  # market values, so a large short concentrates a portfolio as much as a large long. Schwab
+  # reports no sectors or betas, so those come from the caller as maps keyed by symbol; symbols
+  # missing from the sector map are grouped under UNKNOWN_SECTOR.
+  #
+  # Beta is the sum of each covered position's market value times its beta, divided by the
+  # covered gross exposure. Shorts count negatively, so it describes the net exposure: a hedged
+  # book has a beta near zero, and an all-long book gets the usual value-weighted average.
+  # Positions missing from the beta map are left out and +beta_coverage+ reports the share of
+  # gross exposure that was covered.
+  #
+  # @example Concentration with sectors and beta
+  #   betas = { "AAPL" => 1.2, "XOM" => 0.9 }
+  #   metrics = Schwab::Risk.metrics(positions, betas: betas, sectors: { "AAPL" => "Technology" })
+  #   metrics.largest_position_weight # => 0.42
+  #   metrics.beta # => 1.08
+  module Risk
+    # Sector of positions missing from the sector map
+    UNKNOWN_SECTOR = "UNKNOWN"
+
+    # Risk figures for a set of positions, as computed by {Risk.metrics}
+    #
+    # All figures are zero (and the breakdowns empty) for a portfolio with no exposure.
+    #
+    # @!attribute holdings_count
+    #   @return [Integer] Number of positions
+    # @!attribute gross_exposure
+    #   @return [Float] Sum of absolute market values
+    # @!attribute net_exposure
+    #   @return [Float] Long market value minus short market value
+    # @!attribute largest_position
+    #   @return [String, nil] Symbol of the position with the largest absolute market value
+    # @!attribute largest_position_weight
+    #   @return [Float] That position's share of gross exposure (0.25 is 25%)
+    # @!attribute asset_type_weights
+    #   @return [Hash{String => Float}] Share of gross exposure by asset type, largest first
+    # @!attribute sector_weights
+    #   @return [Hash{String => Float}] Share of gross exposure by sector, largest first; empty without a sector map
+    # @!attribute beta
+    #   @return [Float, nil] Weighted beta of the covered positions; nil without a beta map
+    # @!attribute beta_coverage
+    #   @return [Float] Share of gross exposure with a beta
+    RiskMetrics = Struct.new(
+      :holdings_count,
+      :gross_exposure,
+      :net_exposure,
+      :largest_position,
+      :largest_position_weight,
+      :asset_type_weights,
+      :sector_weights,
+      :beta,
+      :beta_coverage,
+      keyword_init: true,
+    )
+
+    class << self
+      # Compute concentration and beta for positions
+      #
+      # @param positions [Array<Hash, Resources::Position>] The positions
+      # @param betas [Hash{String => Numeric}] Beta by symbol (optional)
+      # @param sectors [Hash{String => String}] Sector by symbol (optional)
+      # @return [RiskMetrics] The metrics
+      def metrics(positions, betas: {}, sectors: {})
+        positions = positions.map do |position|
+          position.is_a?(Resources::Position) ? position : Resources::Position.new(position.to_h)
+        end
+        betas = betas.to_h { |symbol, beta| [symbol.to_s.upcase, beta.to_f] }
+        sectors = sectors.to_h { |symbol, sector| [symbol.to_s.upcase, sector.to_s] }
+        gross = gross_exposure(positions)
+        largest = positions.max_by { |position| position.exposure.abs }
+        covered = positions.select { |position| betas.key?(key(position)) }
+
+        RiskMetrics.new(
+          holdings_count: positions.size,
+          gross_exposure: gross.round(2),
+          net_exposure: positions.sum(&:exposure).round(2),
+          largest_position: largest&.symbol,
+          largest_position_weight: weight(largest ? largest.exposure.abs : 0.0, gross),
+          asset_type_weights: weights_by(positions, gross) { |position| position.asset_type || "UNKNOWN" },
+          sector_weights: sector_weights(positions, sectors, gross),
+          beta: betas.empty? ? nil : portfolio_beta(covered, betas),
+          beta_coverage: weight(gross_exposure(covered), gross),
+        )
+      end
+
+      private
+
+      def weights_by(positions, gross, &group)
+        return {} unless gross.positive?
+
+        totals = positions.group_by(&group).transform_values { |members| gross_exposure(members) }
+        totals.sort_by { |_, value| -value }.to_h { |name, value| [name, weight(value, gross)] }
+      end
+
+      def sector_weights(positions, sectors, gross)
+        return {} if sectors.empty?
+
+        weights_by(positions, gross) { |position| sectors.fetch(key(position), UNKNOWN_SECTOR) }
+      end
+
+      def portfolio_beta(covered, betas)
+        gross = gross_exposure(covered)
+        return 0.0 unless gross.positive?
+
+        (covered.sum { |position| position.exposure * betas[key(position)] } / gross).round(4)
+      end
+
+      def gross_exposure(positions)
+        positions.sum { |position| position.exposure.abs }
+      end
+
+      def key(position)
+        position.symbol.to_s.upcase
+      end
+
+      def weight(value, gross)
+        gross.positive? ? (value / gross).round(4) : 0.0
+      end
+    end
+  end
+end
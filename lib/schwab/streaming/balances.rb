@@ -0,0 +1,186 @@
+# frozen_string_literal: true
+
+require_relative "streamer"
+
+module Schwab
+  module Streaming
+    # Account balances fetched after activity that can change them
+    #
+    # @!attribute account_number
+    #   @return [String] The account number
+    # @!attribute balances
+    #   @return [Hash] The account's currentBalances, as returned by Accounts.get_balances
+    # @!attribute message_type
+    #   @return [String, nil] The activity message type that prompted the fetch, or nil for the initial balances
+    # @!attribute time
+    #   @return [Time] When the balances were fetched
+    BalanceUpdate = Struct.new(:account_number, :balances, :message_type, :time, keyword_init: true) do
+      # Get the buying power
+      #
+      # @return [Float, nil] The buying power, or available funds for trading when Schwab sends no buying power
+      def buying_power
+        value = balances[:buyingPower] || balances["buyingPower"] ||
+          balances[:availableFundsTrade] || balances["availableFundsTrade"]
+        value&.to_f
+      end
+    end
+
+    # Live balances for one account, refreshed as orders fill
+    #
+    # Schwab's account activity stream (ACCT_ACTIVITY) reports order events but carries no balance
+    # figures, so balances are synthesized: each fill or execution message for the account triggers
+    # a fresh {Accounts.get_balances} request on a background thread. Messages that arrive while a
+    # request is in flight are coalesced into one further request, so a burst of partial fills
+    # costs at most two requests. The balances at start are fetched and yielded first.
+    #
+    # @example Watch buying power
+    #   stream = Schwab::Accounts.stream_balances("123456") do |update|
+    #     puts "#{update.message_type}: #{update.buying_power}"
+    #   end
+    #   stream.latest # => most recent BalanceUpdate
+    #   stream.close
+    class BalanceStream
+      SERVICE = "ACCT_ACTIVITY"
+
+      # Account activity fields: subscription key, account, message type, message data
+      FIELDS = [0, 1, 2, 3].freeze
+
+      # Subscription key for the account activity service
+      ACTIVITY_KEY = "Account Activity"
+
+      # Activity message types that can move balances
+      FILL_MESSAGE_TYPES = [
+        "OrderFill",
+        "OrderPartialFill",
+        "OrderFillCompleted",
+        "ExecutionCreated",
+        "ManualExecution",
+        "BrokenTrade",
+      ].freeze
+
+      attr_reader :account_number, :streamer
+
+      # @param account_number [String] The plain account number, as it appears in activity messages
+      # @param message_types [Array<String>] Activity message types that trigger a refresh (default: FILL_MESSAGE_TYPES)
+      # @param streamer [Streamer, nil] Shared streamer to use (creates and owns one if not provided)
+      # @param client [Schwab::Client, nil] Client for balance requests and a new streamer (default: the streamer's client)
+      # @yieldparam update [BalanceUpdate] The initial balances, then the balances after each refresh
+      def initialize(account_number, message_types: FILL_MESSAGE_TYPES, streamer: nil, client: nil, &block)
+        @account_number = account_number.to_s
+        @message_types = Array(message_types).map(&:to_s)
+        @owns_streamer = streamer.nil?
+        @streamer = streamer || Streamer.new(client: client)
+        @client = client || @streamer.client
+        @callback = block
+        @error_handlers = []
+        @latest = nil
+        @pending = false
+        @pending_type = nil
+        @closed = true
+        @mutex = Mutex.new
+        @wakeup = ConditionVariable.new
+        @worker = nil
+        @handler = nil
+      end
+
+      # Fetch the initial balances, subscribe to account activity, and start streaming
+      #
+      # @return [BalanceStream] self
+      def start
+        @mutex.synchronize do
+          return self if @worker&.alive?
+
+          @closed = false
+          @pending = true
+          @pending_type = nil
+          @worker = Thread.new { run }
+        end
+        @handler ||= @streamer.on_data(SERVICE) { |item, _timestamp| apply(item) }
+        @streamer.subscribe(SERVICE, ACTIVITY_KEY, fields: FIELDS)
+        @streamer.start
+        self
+      end
+
+      # Register a handler for stream errors and failed balance requests
+      #
+      # @yieldparam error [StandardError] The error
+      def on_error(&block)
+        @error_handlers << block
+        @streamer.on_error(&block)
+      end
+
+      # Get the most recent balances
+      #
+      # @return [BalanceUpdate, nil] The latest update, or nil before the first fetch completes
+      def latest
+        @mutex.synchronize { @latest }
+      end
+
+      # Stop streaming
+      # Closes the streamer if this stream created it, otherwise only unsubscribes. A balance
+      # request in flight is allowed to finish, but its result is not yielded.
+      #
+      # @param timeout [Numeric] Seconds to wait for an in-flight request (default: 5)
+      def close(timeout: 5)
+        if @handler
+          @streamer.remove_handler(SERVICE, @handler)
+          @streamer.unsubscribe(SERVICE, ACTIVITY_KEY) unless @owns_streamer
+          @handler = nil
+        end
+        @mutex.synchronize do
+          @closed = true
+          @wakeup.signal
+        end
+        @worker&.join(timeout) unless Thread.current == @worker
+        @worker = nil
+        @streamer.close if @owns_streamer
+      end
+
+      private
+
+      def apply(item)
+        message_type = item["2"].to_s
+        return unless @message_types.include?(message_type)
+
+        account = item["1"].to_s
+        return unless account.empty? || account == @account_number
+
+        @mutex.synchronize do
+          @pending = true
+          @pending_type = message_type
+          @wakeup.signal
+        end
+      end
+
+      def run
+        loop do
+          message_type = @mutex.synchronize do
+            @wakeup.wait(@mutex) until @pending || @closed
+            return if @closed
+
+            @pending = false
+            @pending_type
+          end
+          refresh(message_type)
+        end
+      end
+
+      def refresh(message_type)
+        balances = Accounts.get_balances(@account_number, client: @client)
+        update = BalanceUpdate.new(
+          account_number: @account_number,
+          balances: balances,
+          message_type: message_type,
+          time: Time.now,
+        )
+        closed = @mutex.synchronize do
+          @latest = update unless @closed
+          @closed
+        end
+        @callback&.call(update) unless closed
+      rescue StandardError => e
+        @error_handlers.each { |handler| handler.call(e) }
+      end
+    end
+  end
+end
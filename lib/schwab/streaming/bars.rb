@@ -0,0 +1,225 @@
+# frozen_string_literal: true
+
+require_relative "streamer"
+
+module Schwab
+  module Streaming
+    # Field layouts of the chart streaming services, which push one-minute bars
+    CHART_SERVICES = {
+      "CHART_EQUITY" => { fields: (0..8).to_a, time: "7", open: "1", high: "2", low: "3", close: "4", volume: "5" },
+      "CHART_FUTURES" => { fields: (0..6).to_a, time: "1", open: "2", high: "3", low: "4", close: "5", volume: "6" },
+    }.freeze
+
+    # OHLCV bar built from streamed chart data
+    #
+    # @!attribute symbol
+    #   @return [String] The symbol
+    # @!attribute time
+    #   @return [Time] Start of the bar, aligned to the bar frequency
+    # @!attribute open
+    #   @return [Float] Opening price
+    # @!attribute high
+    #   @return [Float] High price
+    # @!attribute low
+    #   @return [Float] Low price
+    # @!attribute close
+    #   @return [Float] Closing price
+    # @!attribute volume
+    #   @return [Integer] Volume
+    # @!attribute complete
+    #   @return [Boolean] False for the bar still forming
+    # @!attribute gap
+    #   @return [Boolean] True for a filler bar covering an interval with no trades
+    Bar = Struct.new(:symbol, :time, :open, :high, :low, :close, :volume, :complete, :gap, keyword_init: true) do
+      # Check if the bar is final
+      #
+      # @return [Boolean] True once the interval has ended
+      def complete?
+        complete == true
+      end
+
+      # Check if the bar fills an interval without trades
+      #
+      # @return [Boolean] True for filler bars
+      def gap?
+        gap == true
+      end
+    end
+
+    # Live bars for a set of symbols, aggregated from the one-minute chart stream
+    #
+    # Equities use CHART_EQUITY and futures (symbols starting with "/") use CHART_FUTURES.
+    # Bars are aligned to clock boundaries of +frequency+ (e.g. 5-minute bars start at :00, :05,
+    # ...). A bar is yielded once its last minute arrives, or when data for a later interval
+    # shows it has ended. Intervals with no trades are yielded as gap bars that repeat the
+    # previous close with zero volume. The bar still forming is available from {#current_bar}.
+    #
+    # @example Five-minute bars
+    #   stream = Schwab::MarketData.stream_bars("AAPL", frequency: 300) do |bar|
+    #     puts "#{bar.time.strftime("%H:%M")} #{bar.open} #{bar.high} #{bar.low} #{bar.close} #{bar.volume}"
+    #   end
+    #   stream.current_bar("AAPL") # => partial bar for the current interval
+    #   stream.close
+    class BarStream
+      MINUTE_MS = 60_000
+
+      attr_reader :symbols, :frequency, :streamer
+
+      # @param symbols [String, Array<String>] Symbols to stream
+      # @param frequency [Integer] Bar length in seconds; a positive multiple of 60 (default: 60)
+      # @param streamer [Streamer, nil] Shared streamer to use (creates and owns one if not provided)
+      # @param client [Schwab::Client, nil] Client for a new streamer
+      # @yieldparam bar [Bar] Each completed bar, in time order per symbol
+      # @raise [ArgumentError] if frequency is not a positive multiple of 60
+      def initialize(symbols, frequency: 60, streamer: nil, client: nil, &block)
+        unless frequency.is_a?(Integer) && frequency.positive? && (frequency % 60).zero?
+          raise ArgumentError, "frequency must be a positive multiple of 60 seconds"
+        end
+
+        @symbols = Array(symbols).map { |symbol| symbol.to_s.upcase }
+        @frequency = frequency
+        @frequency_ms = frequency * 1000
+        @owns_streamer = streamer.nil?
+        @streamer = streamer || Streamer.new(client: client)
+        @callback = block
+        @forming = {}
+        @last = {}
+        @mutex = Mutex.new
+        @handlers = {}
+      end
+
+      # Subscribe to the chart services and start streaming
+      #
+      # @return [BarStream] self
+      def start
+        symbols_by_service.each do |service, symbols|
+          @handlers[service] ||= @streamer.on_data(service) { |item, _timestamp| apply(service, item) }
+          @streamer.subscribe(service, symbols, fields: CHART_SERVICES[service][:fields])
+        end
+        @streamer.start
+        self
+      end
+
+      # Register a handler for stream errors
+      #
+      # @yieldparam error [StandardError] The error
+      def on_error(&block)
+        @streamer.on_error(&block)
+      end
+
+      # Get the bar still forming for a symbol
+      #
+      # @param symbol [String] The symbol
+      # @return [Bar, nil] The partial bar, or nil if no data has arrived for the current interval
+      def current_bar(symbol)
+        symbol = symbol.to_s.upcase
+        @mutex.synchronize do
+          forming = @forming[symbol]
+          build_bar(symbol, forming, complete: false) if forming
+        end
+      end
+
+      # Stop streaming
+      # Closes the streamer if this stream created it, otherwise only unsubscribes.
+      def close
+        services = symbols_by_service
+        @handlers.each do |service, handler|
+          @streamer.remove_handler(service, handler)
+          @streamer.unsubscribe(service, services[service]) unless @owns_streamer
+        end
+        @handlers.clear
+        @streamer.close if @owns_streamer
+      end
+
+      private
+
+      def symbols_by_service
+        @symbols.group_by { |symbol| symbol.start_with?("/") ? "CHART_FUTURES" : "CHART_EQUITY" }
+      end
+
+      def apply(service, item)
+        layout = CHART_SERVICES[service]
+        symbol = item["key"].to_s.upcase
+        minute = item[layout[:time]].to_i
+        start = minute - (minute % @frequency_ms)
+
+        completed = @mutex.synchronize do
+          bars = []
+          forming = @forming[symbol]
+          if forming && start > forming[:start]
+            bars << finish(symbol, forming)
+            forming = nil
+          end
+
+          # Ignore minutes that arrive after their interval was completed
+          last = @last[symbol]
+          next bars if forming ? start < forming[:start] : last && start < last[:closes_at]
+
+          bars.concat(gap_bars(symbol, start)) unless forming
+
+          forming ||= { start: start, minutes: {} }
+          forming[:minutes][minute] = {
+            open: item[layout[:open]].to_f,
+            high: item[layout[:high]].to_f,
+            low: item[layout[:low]].to_f,
+            close: item[layout[:close]].to_f,
+            volume: item[layout[:volume]].to_i,
+          }
+
+          if minute + MINUTE_MS >= start + @frequency_ms
+            bars << finish(symbol, forming)
+          else
+            @forming[symbol] = forming
+          end
+          bars
+        end
+
+        completed.each { |bar| @callback&.call(bar) }
+      end
+
+      # Complete a forming bar; caller must hold @mutex
+      def finish(symbol, forming)
+        @forming.delete(symbol)
+        bar = build_bar(symbol, forming, complete: true)
+        @last[symbol] = { closes_at: forming[:start] + @frequency_ms, close: bar.close }
+        bar
+      end
+
+      # Filler bars for intervals without trades before start; caller must hold @mutex
+      def gap_bars(symbol, start)
+        last = @last[symbol]
+        return [] unless last
+
+        (last[:closes_at]...start).step(@frequency_ms).map do |gap_start|
+          @last[symbol] = { closes_at: gap_start + @frequency_ms, close: last[:close] }
+          Bar.new(
+            symbol: symbol,
+            time: Time.at(gap_start / 1000),
+            open: last[:close],
+            high: last[:close],
+            low: last[:close],
+            close: last[:close],
+            volume: 0,
+            complete: true,
+            gap: true,
+          )
+        end
+      end
+
+      def build_bar(symbol, forming, complete:)
+        minutes = forming[:minutes].sort.map(&:last)
+        Bar.new(
+          symbol: symbol,
+          time: Time.at(forming[:start] / 1000),
+          open: minutes.first[:open],
+          high: minutes.map { |minute| minute[:high] }.max,
+          low: minutes.map { |minute| minute[:low] }.min,
+          close: minutes.last[:close],
+          volume: minutes.sum { |minute| minute[:volume] },
+          complete: complete,
+          gap: false,
+        )
+      end
+    end
+  end
+end
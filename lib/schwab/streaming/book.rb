@@ -0,0 +1,177 @@
+# frozen_string_literal: true
+
+require_relative "streamer"
+
+module Schwab
+  module Streaming
+    # Streaming services that deliver level-2 order book data
+    BOOK_SERVICES = ["NASDAQ_BOOK", "NYSE_BOOK", "OPTIONS_BOOK"].freeze
+
+    # One price level on one side of the book
+    #
+    # @!attribute price
+    #   @return [Float] The level price
+    # @!attribute size
+    #   @return [Integer] Aggregate size at the price
+    # @!attribute market_maker_count
+    #   @return [Integer] Number of market makers quoting the price
+    BookLevel = Struct.new(:price, :size, :market_maker_count, keyword_init: true)
+
+    # Order book state for a symbol after applying a streaming message
+    #
+    # @!attribute service
+    #   @return [String] The book service (e.g., "NASDAQ_BOOK")
+    # @!attribute symbol
+    #   @return [String] The symbol
+    # @!attribute time
+    #   @return [Time, nil] The book time reported by Schwab
+    # @!attribute bids
+    #   @return [Array<BookLevel>] Bid levels, best first
+    # @!attribute asks
+    #   @return [Array<BookLevel>] Ask levels, best first
+    # @!attribute snapshot
+    #   @return [Boolean] True if the message replaced the whole book rather than updating one side
+    BookUpdate = Struct.new(:service, :symbol, :time, :bids, :asks, :snapshot, keyword_init: true) do
+      # Check if this update is a full snapshot
+      #
+      # @return [Boolean] True for snapshots
+      def snapshot?
+        snapshot == true
+      end
+
+      # Get the best bid
+      #
+      # @return [BookLevel, nil] The highest bid level
+      def best_bid
+        bids.first
+      end
+
+      # Get the best ask
+      #
+      # @return [BookLevel, nil] The lowest ask level
+      def best_ask
+        asks.first
+      end
+    end
+
+    # Level-2 order book stream for a set of symbols
+    #
+    # Schwab sends the full book when a symbol is first subscribed and may then send only the
+    # side that changed. The stream keeps the latest book per service and symbol, merges partial
+    # messages into it, and yields the merged book as a {BookUpdate}. Book state is cleared on
+    # reconnect so the first update after a drop is a snapshot again.
+    #
+    # @example Stream NASDAQ and NYSE books
+    #   stream = Schwab::MarketData.stream_book(["AAPL", "IBM"]) do |update|
+    #     puts "#{update.symbol} #{update.best_bid&.price} x #{update.best_ask&.price}"
+    #   end
+    #   stream.on_error { |error| warn(error.message) }
+    #   # ...
+    #   stream.close
+    class BookStream
+      # Book fields: symbol, book time, bids, asks
+      FIELDS = [0, 1, 2, 3].freeze
+
+      attr_reader :symbols, :services, :streamer
+
+      # @param symbols [String, Array<String>] Symbols to stream
+      # @param services [Array<String>] Book services to subscribe to (default: NASDAQ_BOOK and NYSE_BOOK)
+      # @param streamer [Streamer, nil] Shared streamer to use (creates and owns one if not provided)
+      # @param client [Schwab::Client, nil] Client for a new streamer
+      # @yieldparam update [BookUpdate] Each book update
+      def initialize(symbols, services: ["NASDAQ_BOOK", "NYSE_BOOK"], streamer: nil, client: nil, &block)
+        @symbols = Array(symbols).map { |symbol| symbol.to_s.upcase }
+        @services = Array(services).map { |service| service.to_s.upcase }
+        unknown = @services - BOOK_SERVICES
+        raise ArgumentError, "Unknown book services: #{unknown.join(", ")}" unless unknown.empty?
+
+        @owns_streamer = streamer.nil?
+        @streamer = streamer || Streamer.new(client: client)
+        @callback = block
+        @books = {}
+        @mutex = Mutex.new
+        @handlers = {}
+      end
+
+      # Subscribe to the book services and start streaming
+      #
+      # @return [BookStream] self
+      def start
+        @connect_handler ||= @streamer.on_connect { @mutex.synchronize { @books.clear } }
+        @services.each do |service|
+          @handlers[service] ||= @streamer.on_data(service) { |item, _timestamp| apply(service, item) }
+          @streamer.subscribe(service, @symbols, fields: FIELDS)
+        end
+        @streamer.start
+        self
+      end
+
+      # Register a handler for stream errors
+      #
+      # @yieldparam error [StandardError] The error
+      def on_error(&block)
+        @streamer.on_error(&block)
+      end
+
+      # Get the latest book for a symbol
+      #
+      # @param symbol [String] The symbol
+      # @param service [String, nil] The book service (default: the first with data)
+      # @return [BookUpdate, nil] The latest merged book
+      def book(symbol, service: nil)
+        symbol = symbol.to_s.upcase
+        @mutex.synchronize do
+          services = service ? [service.to_s.upcase] : @services
+          services.each do |name|
+            book = @books[[name, symbol]]
+            return book if book
+          end
+          nil
+        end
+      end
+
+      # Stop streaming
+      # Closes the streamer if this stream created it, otherwise only unsubscribes.
+      def close
+        @handlers.each do |service, handler|
+          @streamer.remove_handler(service, handler)
+          @streamer.unsubscribe(service, @symbols) unless @owns_streamer
+        end
+        @handlers.clear
+        @streamer.close if @owns_streamer
+      end
+
+      private
+
+      def apply(service, item)
+        symbol = item["key"].to_s.upcase
+        update = @mutex.synchronize do
+          previous = @books[[service, symbol]]
+          @books[[service, symbol]] = BookUpdate.new(
+            service: service,
+            symbol: symbol,
+            time: item.key?("1") ? parse_time(item["1"]) : previous&.time,
+            bids: item.key?("2") ? parse_levels(item["2"]) : previous&.bids || [],
+            asks: item.key?("3") ? parse_levels(item["3"]) : previous&.asks || [],
+            snapshot: previous.nil? || (item.key?("2") && item.key?("3")),
+          )
+        end
+        @callback&.call(update)
+      end
+
+      def parse_levels(levels)
+        Array(levels).map do |level|
+          BookLevel.new(
+            price: level["0"].to_f,
+            size: level["1"].to_i,
+            market_maker_count: level["2"].to_i,
+          )
+        end
+      end
+
+      def parse_time(milliseconds)
+        Time.at(milliseconds.to_i / 1000.0) if milliseconds
+      end
+    end
+  end
+end
@@ -0,0 +1,169 @@
+# frozen_string_literal: true
+
+module Schwab
+  module Streaming
+    # Field names of the level-one and account activity services, indexed by field number
+    FIELD_NAMES = {
+      "LEVELONE_EQUITIES" => [
+        "symbol", "bidPrice", "askPrice", "lastPrice", "bidSize", "askSize", "askId", "bidId",
+        "totalVolume", "lastSize", "highPrice", "lowPrice", "closePrice", "exchangeId", "marginable",
+        "description", "lastId", "openPrice", "netChange", "52WeekHigh", "52WeekLow", "peRatio",
+        "annualDividendAmount", "dividendYield", "nav", "exchangeName", "dividendDate",
+        "regularMarketQuote", "regularMarketTrade", "regularMarketLastPrice", "regularMarketLastSize",
+        "regularMarketNetChange", "securityStatus", "mark", "quoteTime", "tradeTime",
+        "regularMarketTradeTime",
+      ].freeze,
+      "LEVELONE_OPTIONS" => [
+        "symbol", "description", "bidPrice", "askPrice", "lastPrice", "highPrice", "lowPrice",
+        "closePrice", "totalVolume", "openInterest", "volatility", "moneyIntrinsicValue",
+        "expirationYear", "multiplier", "digits", "openPrice", "bidSize", "askSize", "lastSize",
+        "netChange", "strikePrice", "contractType", "underlying", "expirationMonth", "deliverables",
+        "timeValue", "expirationDay", "daysToExpiration", "delta", "gamma", "theta", "vega", "rho",
+        "securityStatus", "theoreticalOptionValue", "underlyingPrice", "uvExpirationType", "mark",
+        "quoteTime", "tradeTime",
+      ].freeze,
+      "ACCT_ACTIVITY" => ["subscriptionKey", "account", "messageType", "messageData"].freeze,
+    }.freeze
+
+    # One streamed record with its fields named where the service layout is known
+    #
+    # @!attribute service
+    #   @return [String] The streaming service (e.g., "LEVELONE_EQUITIES")
+    # @!attribute key
+    #   @return [String] The symbol or subscription key
+    # @!attribute fields
+    #   @return [Hash{String => Object}] Field values by name, or by field number for unknown services
+    # @!attribute timestamp
+    #   @return [Time, nil] The message time reported by Schwab
+    # @!attribute sequence
+    #   @return [Integer, nil] The streamer's sequence number for the item (see {Streamer#last_sequence})
+    Message = Struct.new(:service, :key, :fields, :timestamp, :sequence, keyword_init: true) do
+      # @param name [String, Symbol, Integer] A field name, or a field number
+      # @return [Object, nil] The field value
+      def field(name)
+        names = FIELD_NAMES[service]
+        name = names[name] if name.is_a?(Integer) && names
+        fields[name.to_s]
+      end
+    end
+
+    # Messages from one service, delivered in order to a single consumer
+    #
+    # Channels share their streamer's connection, so any number of services (and channels) run
+    # over one socket. Create them with {Streamer#channel}; closing a channel unsubscribes its
+    # keys and leaves the streamer running.
+    #
+    # @example Level-one equities and options over one connection
+    #   streamer = Schwab::Streaming::Streamer.new(client: client).start
+    #   equities = streamer.channel("LEVELONE_EQUITIES", ["AAPL", "MSFT"])
+    #   options = streamer.channel("LEVELONE_OPTIONS", ["AAPL  240517C00190000"])
+    #   Thread.new { options.each { |message| puts message.field("delta") } }
+    #   equities.each { |message| puts "#{message.key} #{message.field("lastPrice")}" }
+    class Channel
+      include Enumerable
+
+      attr_reader :service, :keys
+
+      # @param streamer [Streamer] The streamer to receive messages from
+      # @param service [String, Symbol] The streaming service
+      # @param keys [String, Array<String>] Symbols or other keys to subscribe to
+      # @param fields [Array<Integer>, nil] Field numbers to receive (default: every named field)
+      def initialize(streamer, service, keys, fields: nil)
+        @streamer = streamer
+        @service = service.to_s.upcase
+        @keys = Array(keys).flat_map { |key| key.to_s.split(",") }.map { |key| key.strip.upcase }.reject(&:empty?)
+        @messages = []
+        @mutex = Mutex.new
+        @available = ConditionVariable.new
+        @closed = false
+
+        names = FIELD_NAMES[@service]
+        fields ||= names ? (0...names.size).to_a : [0]
+        @handler = streamer.on_data(@service) { |item, timestamp, sequence| push(item, timestamp, sequence) }
+        streamer.subscribe(@service, @keys, fields: fields)
+      end
+
+      # Take the next message, waiting for one to arrive
+      #
+      # @param timeout [Numeric, nil] Most seconds to wait, or nil to wait until one arrives or the channel closes
+      # @return [Message, nil] The message, or nil on timeout or once the channel is closed and drained
+      def pop(timeout: nil)
+        deadline = timeout && Process.clock_gettime(Process::CLOCK_MONOTONIC) + timeout
+
+        @mutex.synchronize do
+          while @messages.empty?
+            return if @closed
+
+            remaining = deadline && deadline - Process.clock_gettime(Process::CLOCK_MONOTONIC)
+            return if remaining && remaining <= 0
+
+            @available.wait(@mutex, remaining)
+          end
+          @messages.shift
+        end
+      end
+
+      # Yield messages as they arrive until the channel is closed
+      #
+      # @yieldparam message [Message] Each message
+      def each
+        return enum_for(:each) unless block_given?
+
+        while (message = pop)
+          yield message
+        end
+      end
+
+      # Stop receiving messages and unsubscribe the channel's keys
+      # Messages already received can still be popped.
+      def close
+        return if @closed
+
+        @streamer.remove_handler(@service, @handler)
+        @streamer.unsubscribe(@service, @keys)
+        @mutex.synchronize do
+          @closed = true
+          @available.broadcast
+        end
+      end
+
+      # @return [Boolean] True once {#close} was called
+      def closed?
+        @closed
+      end
+
+      private
+
+      def push(item, timestamp, sequence)
+        key = item["key"].to_s.upcase
+        return unless @keys.include?(key)
+
+        message = Message.new(
+          service: @service,
+          key: key,
+          fields: name_fields(item),
+          timestamp: parse_time(timestamp),
+          sequence: sequence,
+        )
+        @mutex.synchronize do
+          @messages << message
+          @available.signal
+        end
+      end
+
+      def name_fields(item)
+        names = FIELD_NAMES[@service]
+        item.each_with_object({}) do |(number, value), fields|
+          next if number == "key"
+
+          name = names && number.to_s.match?(/\A\d+\z/) ? names[number.to_i] : nil
+          fields[name || number.to_s] = value
+        end
+      end
+
+      def parse_time(milliseconds)
+        Time.at(milliseconds / 1000.0) if milliseconds
+      end
+    end
+  end
+end
@@ -0,0 +1,168 @@
+# frozen_string_literal: true
+
+require "net/http"
+require "uri"
+
+module Schwab
+  module Streaming
+    # Server-sent events transport for networks that block WebSockets
+    #
+    # Schwab's streamer only speaks WebSocket, so this transport goes through a relay you run
+    # somewhere WebSockets are allowed. The relay holds the WebSocket to Schwab and bridges it
+    # over plain HTTP:
+    #
+    # - +GET relay_url?url=<streamer URL>+ with +Accept: text/event-stream+ opens the upstream
+    #   WebSocket. The relay first sends an event named +session+ whose data is a session ID,
+    #   then one unnamed event per message received from Schwab.
+    # - +POST relay_url?session=<session ID>+ with a message as the body forwards it to Schwab.
+    # - Closing the event stream closes the upstream WebSocket.
+    #
+    # Compared with {WebSocket}, every message crosses an extra hop, and each write is a
+    # separate HTTP request, so subscription changes take a round trip longer. Data delivery
+    # is otherwise the same: {Streamer} handlers and channels see identical messages.
+    class SSE
+      attr_reader :url, :target_url, :session_id
+
+      # @param url [String] The relay's http:// or https:// URL
+      # @param target_url [String] The streamer WebSocket URL the relay should connect to
+      # @param open_timeout [Integer] Seconds to wait for the connection and the session event (default: 10)
+      def initialize(url, target_url, open_timeout: 10)
+        @url = URI.parse(url)
+        @target_url = target_url
+        @open_timeout = open_timeout
+        @mutex = Mutex.new
+        @ready = ConditionVariable.new
+        @messages = Queue.new
+        @session_id = nil
+        @error = nil
+        @finished = false
+        @reader = nil
+      end
+
+      # Open the event stream and wait for the relay's session
+      #
+      # @return [SSE] self
+      # @raise [StreamError] if the relay refuses the stream or sends no session in time
+      def connect
+        @reader = Thread.new { listen }
+        @mutex.synchronize do
+          @ready.wait(@mutex, @open_timeout) unless @session_id || @error
+        end
+        raise StreamError, "SSE connection failed: #{@error.message}" if @error
+
+        unless @session_id
+          close
+          raise StreamError, "SSE relay sent no session within #{@open_timeout} seconds"
+        end
+
+        self
+      end
+
+      # Check if the event stream is open
+      #
+      # @return [Boolean] True if connected
+      def connected?
+        !@session_id.nil? && !@reader.nil? && @reader.alive?
+      end
+
+      # Send a message through the relay
+      #
+      # @param message [String] The message to send
+      # @raise [StreamError] if the relay rejects it
+      def write(message)
+        uri = @url.dup
+        uri.query = URI.encode_www_form(session: @session_id)
+        request = Net::HTTP::Post.new(uri, "Content-Type" => "application/json")
+        request.body = message
+
+        response = http(uri, read_timeout: @open_timeout) { |connection| connection.request(request) }
+        raise StreamError, "SSE relay rejected a message: HTTP #{response.code}" unless response.is_a?(Net::HTTPSuccess)
+      end
+
+      # Read the next message
+      #
+      # @return [String, nil] The message, or nil once the stream ends
+      def read
+        return if @finished
+
+        message = @messages.pop
+        @finished = true if message.nil?
+        message
+      end
+
+      # Close the event stream
+      def close
+        @reader&.kill
+        @messages << nil
+      end
+
+      private
+
+      def listen
+        uri = @url.dup
+        uri.query = URI.encode_www_form(url: @target_url)
+        request = Net::HTTP::Get.new(uri, "Accept" => "text/event-stream", "Cache-Control" => "no-cache")
+
+        http(uri, read_timeout: nil) do |connection|
+          connection.request(request) do |response|
+            raise StreamError, "HTTP #{response.code}" unless response.is_a?(Net::HTTPSuccess)
+
+            buffer = +""
+            response.read_body do |chunk|
+              buffer << chunk.delete("\r")
+              while (boundary = buffer.index("\n\n"))
+                dispatch(buffer.slice!(0, boundary + 2))
+              end
+            end
+          end
+        end
+      rescue StandardError => e
+        signal { @error = e }
+      ensure
+        @messages << nil
+        @mutex.synchronize { @ready.broadcast }
+      end
+
+      def http(uri, read_timeout:, &block)
+        Net::HTTP.start(
+          uri.host,
+          uri.port,
+          use_ssl: uri.scheme == "https",
+          open_timeout: @open_timeout,
+          read_timeout: read_timeout,
+          &block
+        )
+      end
+
+      # Handle one event: a block of "field: value" lines
+      def dispatch(event)
+        name = "message"
+        data = []
+        event.each_line(chomp: true) do |line|
+          next if line.empty? || line.start_with?(":")
+
+          field, value = line.split(":", 2)
+          value = value.to_s.delete_prefix(" ")
+          case field
+          when "event" then name = value
+          when "data" then data << value
+          end
+        end
+        return if data.empty?
+
+        if name == "session"
+          signal { @session_id = data.join("\n") }
+        else
+          @messages << data.join("\n")
+        end
+      end
+
+      def signal
+        @mutex.synchronize do
+          yield
+          @ready.broadcast
+        end
+      end
+    end
+  end
+end
@@ -0,0 +1,508 @@
+# frozen_string_literal: true
+
+require "json"
+require_relative "websocket"
+require_relative "sse"
+require_relative "channel"
+
+module Schwab
+  module Streaming
+    # Client for Schwab's streaming API
+    #
+    # Looks up the streamer endpoint from the user preferences, logs in with the client's
+    # access token, and routes data messages to handlers registered per service. The
+    # connection runs on a background thread; when it drops, the streamer reconnects,
+    # logs in again, and resubscribes everything that was subscribed before.
+    # Reconnects back off exponentially; after +max_reconnect_attempts+ consecutive
+    # failures the streamer reports a {StreamTerminatedError} and closes.
+    #
+    # Schwab sends heartbeats between data messages. If nothing at all arrives for
+    # +heartbeat_timeout+ seconds the connection is treated as dead (a half-open TCP connection
+    # never reports an error on its own): a {StreamError} is reported and the streamer reconnects.
+    #
+    # Where WebSockets are blocked, the streamer can run over server-sent events through a relay
+    # (see {SSE}). Pass +sse_url+ and it falls back to SSE when a WebSocket connection fails,
+    # staying on SSE for later reconnects; pass +transport: :sse+ to skip the WebSocket attempt.
+    # Handlers, channels, and subscriptions work the same over either transport.
+    #
+    # Every data item gets a sequence number, one higher than the item before it across all
+    # services, so consumers can restore order after fanning items out to other threads and
+    # detect items lost on the way (compare against {#last_sequence}). Numbers are assigned as
+    # items arrive and keep counting across reconnects; they never reset for the life of the
+    # streamer. Schwab does not number its messages, so items missed while the connection was
+    # down leave no gap: each reconnect is signalled through {#on_connect} instead.
+    #
+    # Handlers run on the streamer thread, so they should return quickly. To consume messages
+    # on your own thread instead, open a {Channel} per service with {#channel}; every channel
+    # shares the one connection.
+    #
+    # @example Subscribe to NASDAQ book data
+    #   streamer = Schwab::Streaming::Streamer.new(client: client)
+    #   streamer.on_data("NASDAQ_BOOK") { |item, timestamp, sequence| puts "#{sequence} #{item["key"]}" }
+    #   streamer.subscribe("NASDAQ_BOOK", ["AAPL"], fields: [0, 1, 2, 3])
+    #   streamer.start
+    class Streamer
+      attr_reader :client
+
+      # Transports that can be selected by name
+      TRANSPORTS = [:auto, :websocket, :sse].freeze
+
+      # @return [Integer] Consecutive failed connection attempts since the last successful login
+      attr_reader :reconnect_attempts
+
+      # @return [Symbol, nil] :websocket or :sse once connected, or nil for a custom transport
+      attr_reader :active_transport
+
+      # @return [Integer] Sequence number of the last data item received, or 0 before the first
+      attr_reader :last_sequence
+
+      # @param client [Schwab::Client, nil] Client used for streamer info and the access token
+      #   (uses Schwab.client if not provided)
+      # @param transport [Symbol, #call] :auto for a WebSocket with SSE fallback when +sse_url+ is set,
+      #   :websocket, :sse, or a factory called with the socket URL that returns a connection
+      #   responding to #connect, #write, #read, and #close (default: :auto)
+      # @param sse_url [String, nil] URL of an SSE relay (see {SSE}); required for :sse
+      # @param reconnect_delay [Numeric] Seconds to wait before the first reconnect, doubled
+      #   after each further failure (default: 1)
+      # @param max_reconnect_delay [Numeric] Cap on the reconnect delay in seconds (default: 30)
+      # @param max_reconnect_attempts [Integer, nil] Consecutive failed reconnects before giving up,
+      #   or nil to retry forever (default: 10)
+      # @param heartbeat_timeout [Numeric, nil] Seconds without any message, heartbeats included, before
+      #   the connection is considered dead and replaced, or nil to wait forever (default: 60)
+      # @raise [ArgumentError] if the transport is unknown, or :sse is chosen without an sse_url
+      def initialize(client: nil, transport: :auto, sse_url: nil, reconnect_delay: 1, max_reconnect_delay: 30,
+        max_reconnect_attempts: 10, heartbeat_timeout: 60)
+        @client = client || Schwab.client || raise(Error, "No client configured. Set Schwab.client or pass a client instance.")
+        transport ||= :auto
+        unless transport.respond_to?(:call) || TRANSPORTS.include?(transport)
+          raise ArgumentError, "Unknown transport: #{transport.inspect}. Use one of #{TRANSPORTS.join(", ")} or a factory"
+        end
+        raise ArgumentError, "The :sse transport requires an sse_url" if transport == :sse && sse_url.nil?
+
+        @transport = transport
+        @sse_url = sse_url
+        @active_transport = nil
+        @reconnect_delay = reconnect_delay
+        @max_reconnect_delay = max_reconnect_delay
+        @max_reconnect_attempts = max_reconnect_attempts
+        @heartbeat_timeout = heartbeat_timeout
+        @reconnect_attempts = 0
+        @last_message_time = nil
+        @last_message_clock = nil
+        @last_sequence = 0
+        @subscriptions = {}
+        @handlers = Hash.new { |handlers, service| handlers[service] = [] }
+        @connect_handlers = []
+        @channels = []
+        @error_handlers = []
+        @mutex = Mutex.new
+        @request_id = 0
+        @socket = nil
+        @streamer_info = nil
+        @logged_in = false
+        @closed = true
+        @thread = nil
+      end
+
+      # Start streaming on a background thread
+      #
+      # @return [Streamer] self
+      def start
+        @mutex.synchronize do
+          return self if @thread&.alive?
+
+          @closed = false
+          @reconnect_attempts = 0
+          @thread = Thread.new { run }
+        end
+        self
+      end
+
+      # Stop streaming and close the connection
+      #
+      # @param timeout [Numeric] Seconds to wait for the streamer thread to finish (default: 5)
+      def close(timeout: 5)
+        @closed = true
+        logout
+        @channels.dup.each(&:close)
+        @socket&.close
+        @thread&.join(timeout) unless Thread.current == @thread
+        @thread = nil
+      end
+
+      # Get the time the last message of any kind arrived, heartbeats included
+      #
+      # @return [Time, nil] The arrival time, or nil before the first message
+      def last_message_time
+        @last_message_time
+      end
+
+      # Check if the streamer is connected and logged in
+      #
+      # @return [Boolean] True if logged in
+      def connected?
+        @logged_in
+      end
+
+      # Subscribe to keys on a service
+      # The subscription is sent immediately when connected and replayed after every reconnect.
+      #
+      # Subscriptions are reference counted so that several consumers can share overlapping keys:
+      # each call adds one reference per key, and only keys that were not yet subscribed are sent.
+      # Fields accumulate across calls, so no consumer loses fields another one asked for; when
+      # only the fields grow, they are changed with a VIEW request.
+      #
+      # @param service [String, Symbol] The streaming service (e.g., "NASDAQ_BOOK")
+      # @param keys [String, Array<String>] Symbols or other keys to subscribe to
+      # @param fields [Array<Integer, String>] Field numbers to receive
+      # @return [Streamer] self
+      def subscribe(service, keys, fields:)
+        service = service.to_s.upcase
+        keys = normalize_keys(keys)
+        fields = Array(fields).map(&:to_s)
+
+        @mutex.synchronize do
+          existing = @subscriptions[service]
+          subscription = existing || { keys: Hash.new(0), fields: [] }
+          added = keys.uniq.select { |key| subscription[:keys][key].zero? }
+          keys.uniq.each { |key| subscription[:keys][key] += 1 }
+          widened = !(fields - subscription[:fields]).empty?
+          subscription[:fields] |= fields
+          @subscriptions[service] = subscription
+          next unless @logged_in
+
+          field_list = subscription[:fields].join(",")
+          if added.any?
+            send_request(service, existing ? "ADD" : "SUBS", keys: added.join(","), fields: field_list)
+          elsif widened
+            send_request(service, "VIEW", fields: field_list)
+          end
+        end
+        self
+      end
+
+      # Release keys on a service
+      # Each call removes one reference per key; a key is unsubscribed once its last reference is released.
+      #
+      # @param service [String, Symbol] The streaming service
+      # @param keys [String, Array<String>] Keys to remove
+      # @return [Streamer] self
+      def unsubscribe(service, keys)
+        service = service.to_s.upcase
+        keys = normalize_keys(keys)
+
+        @mutex.synchronize do
+          subscription = @subscriptions[service]
+          return self unless subscription
+
+          removed = keys.uniq.select do |key|
+            next false unless subscription[:keys].key?(key)
+
+            subscription[:keys][key] -= 1
+            subscription[:keys].delete(key) if subscription[:keys][key].zero?
+            !subscription[:keys].key?(key)
+          end
+          @subscriptions.delete(service) if subscription[:keys].empty?
+          send_request(service, "UNSUBS", keys: removed.join(",")) if @logged_in && removed.any?
+        end
+        self
+      end
+
+      # Subscribe to keys on a service and receive its messages through a channel
+      #
+      # @param service [String, Symbol] The streaming service (e.g., "LEVELONE_EQUITIES")
+      # @param keys [String, Array<String>] Symbols or other keys to subscribe to
+      # @param fields [Array<Integer>, nil] Field numbers to receive (default: every field in {FIELD_NAMES})
+      # @return [Channel] The channel, closed along with the streamer
+      def channel(service, keys, fields: nil)
+        channel = Channel.new(self, service, keys, fields: fields)
+        @mutex.synchronize do
+          @channels.reject!(&:closed?)
+          @channels << channel
+        end
+        channel
+      end
+
+      # Get the current subscriptions
+      #
+      # @return [Hash{String => Array<String>}] Subscribed keys by service
+      def subscriptions
+        @mutex.synchronize { @subscriptions.transform_values { |subscription| subscription[:keys].keys } }
+      end
+
+      # Get how many consumers hold a key
+      #
+      # @param service [String, Symbol] The streaming service
+      # @param key [String] The key (e.g., a symbol)
+      # @return [Integer] Outstanding {#subscribe} calls for the key, 0 if it is not subscribed
+      def subscription_count(service, key)
+        @mutex.synchronize do
+          subscription = @subscriptions[service.to_s.upcase]
+          subscription ? subscription[:keys].fetch(normalize_keys(key).first, 0) : 0
+        end
+      end
+
+      # Register a handler for data messages from a service
+      #
+      # @param service [String, Symbol] The streaming service
+      # @yieldparam item [Hash] One content entry, keyed by field number with the symbol under "key"
+      # @yieldparam timestamp [Integer] The message timestamp in milliseconds since epoch, as sent by Schwab
+      # @yieldparam sequence [Integer] The item's sequence number (see {#last_sequence})
+      # @return [Proc] The handler, for use with {#remove_handler}
+      def on_data(service, &block)
+        @handlers[service.to_s.upcase] << block
+        block
+      end
+
+      # Remove a handler registered with {#on_data}
+      #
+      # @param service [String, Symbol] The streaming service
+      # @param handler [Proc] The handler to remove
+      def remove_handler(service, handler)
+        @handlers[service.to_s.upcase].delete(handler)
+      end
+
+      # Register a handler called after each successful login, including reconnects
+      #
+      # @return [Proc] The handler
+      def on_connect(&block)
+        @connect_handlers << block
+        block
+      end
+
+      # Register a handler for connection, login, subscription, and handler errors
+      #
+      # @yieldparam error [StandardError] The error
+      # @return [Proc] The handler
+      def on_error(&block)
+        @error_handlers << block
+        block
+      end
+
+      private
+
+      def run
+        until @closed
+          begin
+            record_message
+            connect
+            watchdog = start_heartbeat_watchdog
+            read_messages
+          rescue StandardError => e
+            notify_error(e) unless @closed
+          ensure
+            watchdog&.kill
+            disconnect
+          end
+          break if @closed
+
+          @reconnect_attempts += 1
+          if @max_reconnect_attempts && @reconnect_attempts > @max_reconnect_attempts
+            terminate
+            break
+          end
+
+          sleep(reconnect_backoff)
+        end
+      end
+
+      # Delay before the next reconnect: doubles per consecutive failure, capped at max_reconnect_delay
+      def reconnect_backoff
+        [@reconnect_delay * (2**(@reconnect_attempts - 1)), @max_reconnect_delay].min
+      end
+
+      def terminate
+        @closed = true
+        notify_error(
+          StreamTerminatedError.new("Streamer closed after #{@max_reconnect_attempts} failed reconnect attempts"),
+        )
+      end
+
+      def connect
+        @streamer_info = fetch_streamer_info
+        @socket = open_socket(@streamer_info[:url])
+
+        @mutex.synchronize do
+          send_request(
+            "ADMIN",
+            "LOGIN",
+            Authorization: @client.access_token,
+            SchwabClientChannel: @streamer_info[:channel],
+            SchwabClientFunctionId: @streamer_info[:function_id],
+          )
+        end
+        await_login
+
+        @mutex.synchronize do
+          @logged_in = true
+          @reconnect_attempts = 0
+          @subscriptions.each do |service, subscription|
+            keys = subscription[:keys].keys.join(",")
+            send_request(service, "SUBS", keys: keys, fields: subscription[:fields].join(","))
+          end
+        end
+        @connect_handlers.each { |handler| safely_call(handler) }
+      end
+
+      def open_socket(url)
+        return @transport.call(url).tap(&:connect) if @transport.respond_to?(:call)
+        return open_sse(url) if @transport == :sse || @active_transport == :sse
+
+        begin
+          socket = WebSocket.new(url).connect
+          @active_transport = :websocket
+          socket
+        rescue StreamError, IOError, SystemCallError, OpenSSL::SSL::SSLError => e
+          raise unless @transport == :auto && @sse_url
+
+          notify_error(StreamError.new("WebSocket connection failed (#{e.message}); falling back to SSE"))
+          open_sse(url)
+        end
+      end
+
+      def open_sse(url)
+        socket = SSE.new(@sse_url, url).connect
+        @active_transport = :sse
+        socket
+      end
+
+      def await_login
+        loop do
+          message = read_message
+          raise StreamError, "Streamer connection closed during login" unless message
+
+          login = Array(message["response"]).find { |response| response["command"] == "LOGIN" }
+          next unless login
+
+          content = login["content"] || {}
+          return if content["code"].to_i.zero?
+
+          raise StreamError, "Streamer login failed: #{content["msg"]}"
+        end
+      end
+
+      def read_messages
+        while (message = read_message)
+          dispatch(message)
+        end
+      end
+
+      def read_message
+        raw = @socket.read
+        return unless raw
+
+        record_message
+        JSON.parse(raw)
+      end
+
+      def record_message
+        @last_message_clock = Process.clock_gettime(Process::CLOCK_MONOTONIC)
+        @last_message_time = Time.now
+      end
+
+      # Close the socket when no message arrives within heartbeat_timeout, ending the read loop
+      # so #run reconnects
+      def start_heartbeat_watchdog
+        return unless @heartbeat_timeout
+
+        socket = @socket
+        Thread.new do
+          loop do
+            sleep([@heartbeat_timeout / 4.0, 1].min)
+            silence = Process.clock_gettime(Process::CLOCK_MONOTONIC) - @last_message_clock
+            next if silence < @heartbeat_timeout
+
+            notify_error(StreamError.new("No streamer message for #{silence.round(1)}s; reconnecting")) unless @closed
+            socket.close
+            break
+          end
+        end
+      end
+
+      def dispatch(message)
+        Array(message["data"]).each do |data|
+          handlers = @handlers[data["service"].to_s.upcase]
+          Array(data["content"]).each do |item|
+            sequence = @last_sequence += 1
+            handlers.each { |handler| safely_call(handler, *data_arguments(handler, item, data["timestamp"], sequence)) }
+          end
+        end
+
+        Array(message["response"]).each do |response|
+          content = response["content"] || {}
+          next if content["code"].to_i.zero?
+
+          notify_error(StreamError.new("#{response["service"]} #{response["command"]} failed: #{content["msg"]}"))
+        end
+      end
+
+      def logout
+        @mutex.synchronize do
+          return unless @logged_in
+
+          send_request("ADMIN", "LOGOUT")
+          @logged_in = false
+        end
+      rescue StandardError
+        # The connection is going away either way
+      end
+
+      def disconnect
+        @logged_in = false
+        @socket&.close
+      rescue StandardError
+        # Already disconnected
+      end
+
+      def send_request(service, command, parameters = {})
+        @request_id += 1
+        request = {
+          service: service,
+          command: command,
+          requestid: @request_id.to_s,
+          SchwabClientCustomerId: @streamer_info[:customer_id],
+          SchwabClientCorrelId: @streamer_info[:correl_id],
+          parameters: parameters,
+        }
+        @socket.write(JSON.generate({ requests: [request] }))
+      end
+
+      def fetch_streamer_info
+        preferences = Accounts.get_user_preferences(client: @client)
+        info = Array(read_field(preferences, :streamerInfo)).first
+        raise StreamError, "User preferences did not include streamer info" unless info
+
+        {
+          url: read_field(info, :streamerSocketUrl),
+          customer_id: read_field(info, :schwabClientCustomerId),
+          correl_id: read_field(info, :schwabClientCorrelId),
+          channel: read_field(info, :schwabClientChannel),
+          function_id: read_field(info, :schwabClientFunctionId),
+        }
+      end
+
+      def read_field(data, key)
+        data[key] || data[key.to_s]
+      end
+
+      def normalize_keys(keys)
+        Array(keys).flat_map { |key| key.to_s.split(",") }.map { |key| key.strip.upcase }.reject(&:empty?)
+      end
+
+      # Lambdas registered before sequence numbers existed take only the item and timestamp
+      def data_arguments(handler, *args)
+        handler.lambda? && handler.arity >= 0 ? args.first(handler.arity) : args
+      end
+
+      def safely_call(handler, *args)
+        handler.call(*args)
+      rescue StandardError => e
+        notify_error(e)
+      end
+
+      def notify_error(error)
+        @error_handlers.each { |handler| handler.call(error) }
+      end
+    end
+  end
+end
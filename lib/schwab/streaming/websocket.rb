@@ -0,0 +1,188 @@
+# frozen_string_literal: true
+
+require "digest"
+require "openssl"
+require "securerandom"
+require "socket"
+require "uri"
+
+module Schwab
+  module Streaming
+    # Minimal RFC 6455 WebSocket client used as the default streamer transport
+    #
+    # Supports text messages, ping/pong, and close frames, which is all the Schwab
+    # streamer uses. Any object responding to #connect, #write, #read, and #close
+    # can be passed to {Streamer} in its place.
+    class WebSocket
+      # Magic value used to derive Sec-WebSocket-Accept (RFC 6455, section 1.3)
+      GUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+      OPCODE_CONTINUATION = 0x0
+      OPCODE_TEXT = 0x1
+      OPCODE_BINARY = 0x2
+      OPCODE_CLOSE = 0x8
+      OPCODE_PING = 0x9
+      OPCODE_PONG = 0xA
+
+      attr_reader :url
+
+      # @param url [String] The ws:// or wss:// URL to connect to
+      # @param open_timeout [Integer] Seconds to wait for the TCP connection (default: 10)
+      def initialize(url, open_timeout: 10)
+        @url = URI.parse(url)
+        @open_timeout = open_timeout
+        @socket = nil
+        @write_mutex = Mutex.new
+      end
+
+      # Open the connection and perform the opening handshake
+      #
+      # @return [WebSocket] self
+      # @raise [StreamError] if the server rejects the handshake
+      def connect
+        tcp = Socket.tcp(@url.host, @url.port, connect_timeout: @open_timeout)
+        @socket = @url.scheme == "wss" ? wrap_ssl(tcp) : tcp
+        handshake
+        self
+      end
+
+      # Check if the connection is open
+      #
+      # @return [Boolean] True if connected
+      def connected?
+        !@socket.nil? && !@socket.closed?
+      end
+
+      # Send a text message
+      #
+      # @param message [String] The message to send
+      def write(message)
+        send_frame(OPCODE_TEXT, message)
+      end
+
+      # Read the next text message, answering pings along the way
+      #
+      # @return [String, nil] The message, or nil once the server closes the connection
+      def read
+        message = "".b
+        loop do
+          fin, opcode, payload = read_frame
+
+          case opcode
+          when OPCODE_TEXT, OPCODE_BINARY, OPCODE_CONTINUATION
+            message << payload
+            return message.force_encoding(Encoding::UTF_8) if fin
+          when OPCODE_PING
+            send_frame(OPCODE_PONG, payload)
+          when OPCODE_CLOSE
+            close
+            return
+          end
+        end
+      rescue IOError, SystemCallError, OpenSSL::SSL::SSLError
+        close
+        nil
+      end
+
+      # Close the connection
+      def close
+        return unless connected?
+
+        begin
+          send_frame(OPCODE_CLOSE, "")
+        rescue IOError, SystemCallError, OpenSSL::SSL::SSLError
+          # The peer may already be gone; closing the socket is all that matters
+        end
+        @socket.close
+      end
+
+      private
+
+      def wrap_ssl(tcp)
+        context = OpenSSL::SSL::SSLContext.new
+        context.set_params(verify_mode: OpenSSL::SSL::VERIFY_PEER)
+        ssl = OpenSSL::SSL::SSLSocket.new(tcp, context)
+        ssl.hostname = @url.host
+        ssl.sync_close = true
+        ssl.connect
+        ssl
+      end
+
+      def handshake
+        key = [SecureRandom.random_bytes(16)].pack("m0")
+        path = @url.request_uri.to_s.empty? ? "/" : @url.request_uri
+
+        @socket.write(
+          "GET #{path} HTTP/1.1\r\n" \
+            "Host: #{@url.host}\r\n" \
+            "Upgrade: websocket\r\n" \
+            "Connection: Upgrade\r\n" \
+            "Sec-WebSocket-Key: #{key}\r\n" \
+            "Sec-WebSocket-Version: 13\r\n\r\n",
+        )
+
+        status = @socket.gets.to_s
+        headers = read_headers
+        unless status.split[1] == "101"
+          raise StreamError, "WebSocket handshake failed: #{status.strip}"
+        end
+
+        expected = [Digest::SHA1.digest(key + GUID)].pack("m0")
+        raise StreamError, "WebSocket handshake failed: invalid accept key" unless headers["sec-websocket-accept"] == expected
+      end
+
+      def read_headers
+        headers = {}
+        while (line = @socket.gets) && line != "\r\n"
+          name, value = line.split(":", 2)
+          headers[name.strip.downcase] = value.to_s.strip
+        end
+        headers
+      end
+
+      def send_frame(opcode, payload)
+        payload = payload.b
+        frame = [0x80 | opcode].pack("C")
+        length = payload.bytesize
+        frame << if length < 126
+          [0x80 | length].pack("C")
+        elsif length < 65_536
+          [0x80 | 126, length].pack("Cn")
+        else
+          [0x80 | 127, length].pack("CQ>")
+        end
+
+        # Client frames must be masked
+        mask = SecureRandom.random_bytes(4)
+        frame << mask << apply_mask(payload, mask)
+        @write_mutex.synchronize { @socket.write(frame) }
+      end
+
+      def read_frame
+        first, second = read_bytes(2).unpack("CC")
+        fin = (first & 0x80) != 0
+        opcode = first & 0x0F
+        length = second & 0x7F
+        length = read_bytes(2).unpack1("n") if length == 126
+        length = read_bytes(8).unpack1("Q>") if length == 127
+        mask = read_bytes(4) if (second & 0x80) != 0
+        payload = read_bytes(length)
+
+        [fin, opcode, mask ? apply_mask(payload, mask) : payload]
+      end
+
+      def read_bytes(count)
+        return "".b if count.zero?
+
+        data = @socket.read(count)
+        raise IOError, "WebSocket connection closed" if data.nil? || data.bytesize < count
+
+        data
+      end
+
+      def apply_mask(payload, mask)
+        payload.bytes.each_with_index.map { |byte, index| byte ^ mask.getbyte(index % 4) }.pack("C*")
+      end
+    end
+  end
+end
@@ -0,0 +1,174 @@
+# frozen_string_literal: true
+
+module Schwab
+  # Symbol validation and normalization for the formats Schwab expects
+  #
+  # - Equities and ETFs: upper case, e.g. "BRK.B"
+  # - Indices: leading "$", e.g. "$SPX" (the legacy ".X" suffix is dropped)
+  # - Futures: leading "/", e.g. "/ES" or "/ESZ24"
+  # - Options: 21-character OSI format, root padded to six characters, e.g. "AAPL  240517C00190000"
+  #
+  # @example Normalize an index and an option
+  #   Schwab::Symbols.normalize("spx.x", "INDEX") # => "$SPX"
+  #   Schwab::Symbols.normalize("AAPL240517C00190000") # => "AAPL  240517C00190000"
+  module Symbols
+    EQUITY = "EQUITY"
+    INDEX = "INDEX"
+    FUTURE = "FUTURE"
+    OPTION = "OPTION"
+
+    # Shares controlled by one standard option contract
+    OPTION_MULTIPLIER = 100
+
+    # Asset types with symbol rules; other asset types are only upper-cased
+    ASSET_TYPES = [EQUITY, INDEX, FUTURE, OPTION].freeze
+
+    # Asset types traded, and symbolized, like equities
+    EQUITY_LIKE_ASSET_TYPES = ["ETF", "COLLECTIVE_INVESTMENT"].freeze
+
+    # Normalized symbol formats
+    EQUITY_PATTERN = %r{\A[A-Z][A-Z0-9]{0,9}(?:[./][A-Z0-9]{1,4})?\z}
+    INDEX_PATTERN = /\A\$[A-Z0-9]{1,10}\z/
+    FUTURE_PATTERN = %r{\A/[A-Z0-9]{1,6}\z}
+    OSI_PATTERN = /\A[A-Z0-9. ]{6}\d{6}[CP]\d{8}\z/
+
+    # Option symbols as users write them: root, optional padding, YYMMDD, C/P, strike x 1000
+    OPTION_PATTERN = /\A([A-Z0-9.]{1,6})\s*(\d{6})([CP])(\d{8})\z/
+
+    class << self
+      # Normalize a symbol for its asset type
+      #
+      # @param symbol [String] The symbol to normalize
+      # @param asset_type [String, Symbol, nil] The asset type (inferred from the symbol if nil)
+      # @return [String] The normalized symbol
+      # @raise [InvalidRequestError] if the symbol is malformed for the asset type
+      def normalize(symbol, asset_type = nil)
+        value = symbol.to_s.strip.upcase
+        raise InvalidRequestError.new(errors: ["Symbol is blank"]) if value.empty?
+
+        type = asset_type ? asset_type.to_s.upcase : infer_asset_type(value)
+        type = EQUITY if EQUITY_LIKE_ASSET_TYPES.include?(type)
+        return value unless ASSET_TYPES.include?(type)
+
+        normalized = case type
+        when INDEX then normalize_index(value)
+        when FUTURE then value.start_with?("/") ? value : "/#{value}"
+        when OPTION then normalize_option(value)
+        else value
+        end
+
+        unless pattern_for(type).match?(normalized)
+          raise InvalidRequestError.new(errors: ["Invalid #{type.downcase} symbol: #{symbol.inspect}"])
+        end
+
+        normalized
+      end
+
+      # Check if a symbol is well formed for its asset type
+      #
+      # @param symbol [String] The symbol to check
+      # @param asset_type [String, Symbol, nil] The asset type (inferred from the symbol if nil)
+      # @return [Boolean] True if the symbol can be normalized
+      def valid?(symbol, asset_type = nil)
+        normalize(symbol, asset_type)
+        true
+      rescue InvalidRequestError
+        false
+      end
+
+      # Normalize the instrument symbol of every leg in an order payload
+      #
+      # @param order_data [Hash] Order payload with an orderLegCollection
+      # @return [Hash] A copy of the payload with normalized leg symbols
+      # @raise [InvalidRequestError] if a leg symbol is malformed for its asset type
+      def normalize_order(order_data)
+        map_leg_symbols(order_data) { |symbol, asset_type| normalize(symbol, asset_type) }
+      end
+
+      # Upper-case a symbol without otherwise changing it
+      #
+      # Unlike {normalize}, prefixes, suffixes, and padding are kept as given, so "$spx.x"
+      # becomes "$SPX.X" and OSI option symbols keep their spacing.
+      #
+      # @param symbol [String, InstrumentSymbol] The symbol
+      # @return [String] The upper-case symbol
+      def upcase(symbol)
+        symbol.to_s.upcase
+      end
+
+      # Prepare a symbol for a request: upper-cased unless config.normalize_symbol_case is off
+      #
+      # @param symbol [String, InstrumentSymbol] The symbol
+      # @param config [Configuration] The client's configuration
+      # @return [String, InstrumentSymbol] The symbol to send
+      def for_request(symbol, config)
+        config.normalize_symbol_case ? upcase(symbol) : symbol
+      end
+
+      # Upper-case the instrument symbol of every leg in an order payload
+      #
+      # @param order_data [Hash] Order payload with an orderLegCollection
+      # @return [Hash] A copy of the payload with upper-case leg symbols
+      def upcase_order(order_data)
+        map_leg_symbols(order_data) { |symbol, _asset_type| upcase(symbol) }
+      end
+
+      # Infer the asset type from a symbol's shape
+      #
+      # @param symbol [String] The symbol
+      # @return [String] INDEX, FUTURE, OPTION, or EQUITY
+      def infer_asset_type(symbol)
+        value = symbol.to_s.strip.upcase
+        if value.start_with?("$")
+          INDEX
+        elsif value.start_with?("/")
+          FUTURE
+        elsif OPTION_PATTERN.match?(value)
+          OPTION
+        else
+          EQUITY
+        end
+      end
+
+      private
+
+      def map_leg_symbols(order_data)
+        legs_key = [:orderLegCollection, "orderLegCollection"].find { |key| order_data.key?(key) }
+        return order_data unless legs_key
+
+        legs = order_data[legs_key].map do |leg|
+          instrument_key = [:instrument, "instrument"].find { |key| leg.key?(key) }
+          instrument = instrument_key && leg[instrument_key]
+          symbol_key = instrument && [:symbol, "symbol"].find { |key| instrument.key?(key) }
+          next leg unless symbol_key
+
+          asset_type = instrument[:assetType] || instrument["assetType"]
+          leg.merge(instrument_key => instrument.merge(symbol_key => yield(instrument[symbol_key], asset_type)))
+        end
+
+        order_data.merge(legs_key => legs)
+      end
+
+      def normalize_index(value)
+        value = "$#{value}" unless value.start_with?("$")
+        value.delete_suffix(".X")
+      end
+
+      def normalize_option(value)
+        match = OPTION_PATTERN.match(value)
+        return value unless match
+
+        "#{match[1].ljust(6)}#{match[2]}#{match[3]}#{match[4]}"
+      end
+
+      def pattern_for(type)
+        case type
+        when INDEX then INDEX_PATTERN
+        when FUTURE then FUTURE_PATTERN
+        when OPTION then OSI_PATTERN
+        else EQUITY_PATTERN
+        end
+      end
+    end
+  end
+end
@@ -0,0 +1,128 @@
+# frozen_string_literal: true
+
+require "date"
+require "time"
+
+module Schwab
+  # Timestamp parsing that places bare datetimes in the exchange's timezone
+  #
+  # Schwab sometimes sends datetimes without an offset, meaning US Eastern wall-clock time.
+  # +Time.parse+ would read those in the host's zone (often UTC), shifting them by hours.
+  # Timestamps with an explicit offset or zone are always honored as sent; bare ones are read
+  # in +config.default_timezone+.
+  #
+  # @example
+  #   Schwab::Timestamps.parse("2024-03-15 09:30:00") # => 2024-03-15 09:30:00 -0400
+  #   Schwab::Timestamps.parse("2024-03-15T13:30:00Z") # => 2024-03-15 13:30:00 UTC
+  #   Schwab::Timestamps.parse("2024-03-15 09:30:00", "UTC") # => 2024-03-15 09:30:00 +0000
+  module Timestamps
+    # Timezone used for bare datetimes unless configured otherwise
+    DEFAULT_TIMEZONE = "America/New_York"
+
+    # US Eastern time: UTC-5, or UTC-4 from 2 a.m. on the second Sunday in March until 2 a.m.
+    # on the first Sunday in November. Ambiguous fall-back times read as daylight time.
+    class USEastern
+      STANDARD_OFFSET = -5 * 3600
+      DAYLIGHT_OFFSET = -4 * 3600
+
+      # @return [Integer] Seconds east of UTC for a wall-clock time
+      def utc_offset(year, month, day, hour)
+        daylight?(year, month, day, hour) ? DAYLIGHT_OFFSET : STANDARD_OFFSET
+      end
+
+      private
+
+      def daylight?(year, month, day, hour)
+        local = [month, day, hour]
+        (local <=> [3, nth_sunday(year, 3, 2), 2]) >= 0 && (local <=> [11, nth_sunday(year, 11, 1), 2]).negative?
+      end
+
+      def nth_sunday(year, month, nth)
+        first = Date.new(year, month, 1)
+        1 + ((7 - first.wday) % 7) + (7 * (nth - 1))
+      end
+    end
+
+    # A zone that is always the same offset from UTC
+    class FixedOffset
+      # @param seconds [Integer] Seconds east of UTC
+      def initialize(seconds)
+        @seconds = seconds
+      end
+
+      # @return [Integer] Seconds east of UTC
+      def utc_offset(*)
+        @seconds
+      end
+    end
+
+    # Adapts a TZInfo::Timezone (or anything responding to +local_to_utc+)
+    class LocalToUtc
+      def initialize(zone)
+        @zone = zone
+      end
+
+      # @return [Integer] Seconds east of UTC for a wall-clock time
+      def utc_offset(year, month, day, hour)
+        local = Time.utc(year, month, day, hour)
+        local.to_i - @zone.local_to_utc(local).to_i
+      end
+    end
+
+    # Zone names read as US Eastern time
+    EASTERN_NAMES = ["America/New_York", "US/Eastern", "EST5EDT"].freeze
+
+    # Explicit UTC offsets such as "+09:00" or "-0500"
+    OFFSET_PATTERN = /\A([+-])(\d{2}):?(\d{2})\z/
+
+    class << self
+      # Parse a timestamp, reading bare datetimes in a timezone
+      #
+      # @param value [String] The timestamp
+      # @param timezone [String, #local_to_utc, nil] Zone for bare datetimes (default: config.default_timezone)
+      # @return [Time] The time, keeping the wall-clock time and offset it was read with
+      # @raise [ArgumentError] if the value has no date or the timezone is not supported
+      def parse(value, timezone = nil)
+        parts = Date._parse(value.to_s)
+        raise ArgumentError, "Invalid timestamp: #{value.inspect}" unless parts[:year] && parts[:mon] && parts[:mday]
+        return Time.parse(value.to_s) if parts[:offset]
+
+        year, month, day, hour = parts.values_at(:year, :mon, :mday, :hour)
+        hour ||= 0
+        seconds = (parts[:sec] || 0) + (parts[:sec_fraction] || 0)
+        offset = zone(timezone || configured_timezone).utc_offset(year, month, day, hour)
+
+        Time.new(year, month, day, hour, parts[:min] || 0, seconds, offset)
+      end
+
+      # Resolve a timezone setting
+      #
+      # @param timezone [String, Symbol, #local_to_utc, #utc_offset] "America/New_York" (or "US/Eastern"),
+      #   "UTC", a fixed offset such as "+09:00", a TZInfo::Timezone, or a zone object
+      # @return [#utc_offset] The zone
+      # @raise [ArgumentError] if the timezone is not supported
+      def zone(timezone)
+        return timezone if timezone.respond_to?(:utc_offset) && !timezone.is_a?(Time)
+        return LocalToUtc.new(timezone) if timezone.respond_to?(:local_to_utc)
+
+        name = timezone.to_s
+        return USEastern.new if EASTERN_NAMES.include?(name)
+        return FixedOffset.new(0) if ["UTC", "Etc/UTC", "Z"].include?(name)
+
+        match = OFFSET_PATTERN.match(name)
+        unless match
+          raise ArgumentError, "Unsupported timezone: #{timezone.inspect}. Use #{EASTERN_NAMES.first}, UTC, " \
+            "a fixed offset such as \"+09:00\", or a TZInfo::Timezone"
+        end
+
+        FixedOffset.new((match[1] == "-" ? -1 : 1) * ((match[2].to_i * 3600) + (match[3].to_i * 60)))
+      end
+
+      private
+
+      def configured_timezone
+        Schwab.respond_to?(:configuration) ? Schwab.configuration.default_timezone : DEFAULT_TIMEZONE
+      end
+    end
+  end
+end
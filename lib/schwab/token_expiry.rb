@@ -0,0 +1,69 @@
+# frozen_string_literal: true
+
+require "time"
+
+module Schwab
+  # Expiry checks for saved tokens that tolerate clock drift between machines
+  #
+  # Token data from OAuth.get_token and OAuth.refresh_token (and passed to +on_token_refresh+)
+  # carries the absolute +expires_at+, the +expires_in+ lifetime, and the +issued_at+ instant it
+  # was received. A token saved on one host and loaded on another whose clock disagrees can look
+  # valid when it is not, so every available field is used and the earliest expiry wins. A token
+  # issued more than the allowed drift in the future cannot be trusted and counts as expired, as
+  # does one with no expiry information at all.
+  #
+  # @example Skip a saved access token that may have expired
+  #   token = JSON.parse(File.read("token.json"))
+  #   access_token = token["access_token"] unless Schwab::TokenExpiry.expired?(token)
+  module TokenExpiry
+    # Seconds of clock difference tolerated unless configured otherwise
+    DEFAULT_MAX_CLOCK_DRIFT = 60
+
+    class << self
+      # Check whether a saved token should be treated as expired
+      #
+      # @param token_data [Hash] Token data with :expires_at, :expires_in, and :issued_at (symbol or string keys;
+      #   times as Time, epoch seconds, or strings)
+      # @param max_clock_drift [Numeric, nil] Tolerance in seconds (default: +config.max_clock_drift+)
+      # @param now [Time] The current time
+      # @return [Boolean] True if the token is expired, expires within the drift, or looks future-dated
+      def expired?(token_data, max_clock_drift: nil, now: Time.now)
+        drift = max_clock_drift || Schwab.configuration.max_clock_drift
+        issued_at = to_time(read(token_data, :issued_at))
+        return true if issued_at && issued_at > now + drift
+
+        expiry = expires_at(token_data)
+        expiry.nil? || expiry <= now + drift
+      end
+
+      # The earliest expiry the token data supports
+      #
+      # @param token_data [Hash] Token data, as for {expired?}
+      # @return [Time, nil] The earlier of +expires_at+ and +issued_at+ + +expires_in+, or nil if neither is known
+      def expires_at(token_data)
+        issued_at = to_time(read(token_data, :issued_at))
+        expires_in = read(token_data, :expires_in)
+        candidates = [to_time(read(token_data, :expires_at))]
+        candidates << issued_at + expires_in.to_f if issued_at && expires_in
+        candidates.compact.min
+      end
+
+      private
+
+      def to_time(value)
+        case value
+        when nil then nil
+        when Time then value
+        when Numeric then Time.at(value)
+        else Time.parse(value.to_s)
+        end
+      rescue ArgumentError
+        nil
+      end
+
+      def read(data, key)
+        data[key] || data[key.to_s]
+      end
+    end
+  end
+end
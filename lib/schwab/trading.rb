@@ -0,0 +1,522 @@
+# frozen_string_literal: true
+
+require "uri"
+require_relative "quantity"
+require_relative "identifiers"
+require_relative "price"
+require_relative "symbols"
+require_relative "request"
+require_relative "timestamps"
+
+module Schwab
+  # Trading API endpoints for placing, replacing, and canceling orders
+  module Trading
+    # Status given to orders accepted in validate-only (dry-run) mode
+    DRY_RUN_STATUS = "VALIDATED_DRY_RUN"
+
+    # Order fields compared by {preview_replace_order}; quantity is read from the order legs
+    REPLACE_FIELDS = ["orderType", "session", "duration", "quantity", "price", "stopPrice", "activationPrice"].freeze
+
+    # Days of market hours {next_market_open} checks before giving up, enough to span a long weekend
+    MARKET_OPEN_LOOKAHEAD_DAYS = 7
+
+    class << self
+      # Place an order
+      #
+      # The order is validated locally before it is sent (see Resources::Order#validate).
+      # Orders without a session are sent for the regular session (NORMAL).
+      # When +config.validate_only+ is set nothing is sent: the order is validated and returned
+      # marked VALIDATED_DRY_RUN. Dry runs apply only the SDK's own rules; they do not check
+      # buying power, positions, or any other server-side rule (use Accounts.preview_order for that).
+      # When +config.duplicate_order_window+ is set, an order matching one placed within the window
+      # is refused (see {DuplicateOrderGuard}).
+      # When +config.max_order_notional+ is set, an order whose estimated notional value exceeds it
+      # is refused. The estimate uses the order's price, or for orders without one (such as market
+      # orders) a quote of each leg, unless +config.quote_order_notional+ is off or this is a dry run.
+      #
+      # @param account_number [String] The account number
+      # @param order [Hash, Resources::Order] The order payload
+      # @param validate [Boolean] Validate locally before submitting (default: true; dry runs always validate)
+      # @param allow_duplicate [Boolean] Place the order even if it repeats a recent one (default: false)
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Hash, Resources::Order] The submitted order, with orderId taken from the Location header
+      # @raise [InvalidRequestError] If the order fails local validation
+      # @raise [DuplicateOrderError] If the order repeats one placed within config.duplicate_order_window
+      # @raise [OrderNotionalError] If the order's estimated notional value exceeds config.max_order_notional
+      # @example Place a limit order
+      #   Schwab::Trading.place_order("123456", order: {
+      #     orderType: "LIMIT",
+      #     session: "NORMAL",
+      #     duration: "DAY",
+      #     price: 150.0,
+      #     orderStrategyType: "SINGLE",
+      #     orderLegCollection: [{
+      #       instruction: "BUY",
+      #       quantity: 10,
+      #       instrument: { symbol: "AAPL", assetType: "EQUITY" }
+      #     }]
+      #   })
+      def place_order(account_number, order:, validate: true, allow_duplicate: false, client: nil)
+        client ||= default_client
+        if client.config.validate_only
+          payload = prepare_order(order, validate, client)
+          check_notional(payload, client, quote: false)
+          return dry_run_order(payload, client)
+        end
+
+        request = build_place_order_request(account_number, order: order, validate: validate, client: client)
+        check_notional(request.body, client, quote: client.config.quote_order_notional)
+        guard_duplicates(account_number, request.body, allow_duplicate, client) do
+          submitted_order(request.body, request.perform(client), client)
+        end
+      end
+
+      # Build the request {place_order} would send, without sending it
+      # The order is prepared and validated exactly as {place_order} does.
+      #
+      # @param account_number [String] The account number
+      # @param order [Hash, Resources::Order] The order payload
+      # @param validate [Boolean] Validate locally (default: true)
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Request] The POST request
+      # @raise [InvalidRequestError] If the order fails local validation
+      # @example Assert on the order body in a test
+      #   request = Schwab::Trading.build_place_order_request("123456", order: order, client: client)
+      #   expect(request.body[:session]).to(eq("NORMAL"))
+      def build_place_order_request(account_number, order:, validate: true, client: nil)
+        client ||= default_client
+        Request.new(
+          http_method: :post,
+          path: "/trader/v1/accounts/#{encode_account_number(account_number, client)}/orders",
+          body: prepare_order(order, validate, client),
+        )
+      end
+
+      # Place a limit order after checking its price against the market
+      #
+      # Fat-finger protection: the order's symbol is quoted first, and the order is rejected if
+      # its limit price is more than +max_deviation_percent+ away from the market price (the
+      # bid/ask midpoint, or the last price when the quote is not two-sided). Orders without a
+      # limit price, such as market orders and multi-leg strategies, are placed unchecked.
+      # Otherwise this behaves exactly like {place_order}, including dry runs.
+      #
+      # @param account_number [String] The account number
+      # @param order [Hash, Resources::Order] The order payload
+      # @param max_deviation_percent [Numeric] Largest allowed distance from the market price, in percent
+      # @param validate [Boolean] Validate locally before submitting (default: true)
+      # @param allow_duplicate [Boolean] Place the order even if it repeats a recent one (default: false)
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Hash, Resources::Order] The submitted order
+      # @raise [PriceDeviationError] If the limit price is too far from the market, or the quote has no price
+      # @raise [ArgumentError] If max_deviation_percent is not a positive number
+      # @example Reject limit prices more than 5% from the market
+      #   Schwab::Trading.place_order_checked("123456", order: order, max_deviation_percent: 5)
+      def place_order_checked(account_number, order:, max_deviation_percent:, validate: true, allow_duplicate: false,
+        client: nil)
+        unless max_deviation_percent.is_a?(Numeric) && max_deviation_percent.positive?
+          raise ArgumentError, "max_deviation_percent must be a positive number, got #{max_deviation_percent.inspect}"
+        end
+
+        client ||= default_client
+        check_limit_price(Resources::Order.new(order.to_h, client), max_deviation_percent, client)
+        place_order(account_number, order: order, validate: validate, allow_duplicate: allow_duplicate, client: client)
+      end
+
+      # Find open orders an order would trade against
+      #
+      # A pre-trade check against self-crossing (wash trades): lists the account's open orders
+      # with a leg in the same symbol on the opposite side (a buy against a sell) at a crossing
+      # price. Limit prices cross when the buy price is at or above the sell price. An order with
+      # no limit price, such as a market or stop order, is treated as crossing any price, and so
+      # are multi-leg orders, whose net price cannot be compared with a single leg's. Orders
+      # whose status is in Resources::Order::OPEN_STATUSES are checked.
+      #
+      # @param account_number [String] The account number
+      # @param order [Hash, Resources::Order] The order about to be placed
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Array<Hash>, Array<Resources::Order>] The conflicting open orders (empty when none)
+      # @example Hold off on a sell while a buy is resting
+      #   conflicts = Schwab::Trading.self_crossing_orders("123456", order: sell_order)
+      #   conflicts.each { |conflict| puts "Would cross order #{conflict[:orderId]}" }
+      def self_crossing_orders(account_number, order:, client: nil)
+        client ||= default_client
+        order = Resources::Order.new(order.to_h, client)
+        legs = order.order_legs.map { |leg| crossing_leg(leg) }.compact
+        return [] if legs.empty?
+
+        open_orders = Accounts.get_orders(account_number, status: Resources::Order::OPEN_STATUSES, client: client)
+        open_orders.select do |open_order|
+          resting = Resources::Order.new(open_order.to_h, client)
+          resting.order_legs.any? do |resting_leg|
+            resting_leg = crossing_leg(resting_leg)
+            resting_leg && legs.any? { |leg| crosses?(order, leg, resting, resting_leg) }
+          end
+        end
+      end
+
+      # Check whether an order would trade against one of the account's open orders
+      #
+      # @param account_number [String] The account number
+      # @param order [Hash, Resources::Order] The order about to be placed
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Boolean] True if {self_crossing_orders} finds a conflict
+      def would_self_cross?(account_number, order:, client: nil)
+        !self_crossing_orders(account_number, order: order, client: client).empty?
+      end
+
+      # Replace an existing order
+      # Schwab cancels the original order and creates a new one with a new order ID.
+      # Validation and dry-run behave as in {place_order}.
+      #
+      # @param account_number [String] The account number
+      # @param order_id [String, Integer] The ID of the order to replace
+      # @param order [Hash, Resources::Order] The replacement order payload
+      # @param validate [Boolean] Validate locally before submitting (default: true)
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Hash, Resources::Order] The replacement order, with its new orderId
+      # @raise [InvalidRequestError] If the order fails local validation
+      def replace_order(account_number, order_id, order:, validate: true, client: nil)
+        client ||= default_client
+        return dry_run_order(prepare_order(order, validate, client), client) if client.config.validate_only
+
+        request = build_replace_order_request(account_number, order_id, order: order, validate: validate, client: client)
+        submitted_order(request.body, request.perform(client), client)
+      end
+
+      # Build the request {replace_order} would send, without sending it
+      #
+      # @param account_number [String] The account number
+      # @param order_id [String, Integer] The ID of the order to replace
+      # @param order [Hash, Resources::Order] The replacement order payload
+      # @param validate [Boolean] Validate locally (default: true)
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Request] The PUT request
+      # @raise [InvalidRequestError] If the order fails local validation
+      def build_replace_order_request(account_number, order_id, order:, validate: true, client: nil)
+        client ||= default_client
+        Request.new(
+          http_method: :put,
+          path: order_path(account_number, order_id, client),
+          body: prepare_order(order, validate, client),
+        )
+      end
+
+      # Preview replacing an existing order without modifying it
+      # Runs the replacement through Schwab's preview endpoint (see Accounts.preview_order) and adds
+      # the net effect of the change: +replacedOrderId+ and +changes+, mapping each order field that
+      # differs to its +from+ and +to+ values. The original order is fetched but never touched.
+      #
+      # @param account_number [String] The account number
+      # @param order_id [String, Integer] The ID of the order that would be replaced
+      # @param order [Hash, Resources::Order] The replacement order payload
+      # @param validate [Boolean] Validate locally before previewing (default: true)
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Hash, Resources::Base] The order preview with +replacedOrderId+ and +changes+
+      # @raise [InvalidRequestError] If the order fails local validation
+      # @example Preview raising a limit price
+      #   preview = Schwab::Trading.preview_replace_order("123456", "1001", order: order.merge(price: 152.0))
+      #   preview["changes"] # => { "price" => { "from" => 150.0, "to" => 152.0 } }
+      def preview_replace_order(account_number, order_id, order:, validate: true, client: nil)
+        client ||= default_client
+        payload = prepare_order(order, validate, client)
+
+        original = Resources::Order.new(Accounts.get_order(account_number, order_id, client: client).to_h)
+        preview = Accounts.preview_order(account_number, payload, client: client).to_h
+
+        result = with_field(preview, :replacedOrderId, order_id.to_s)
+        result = with_field(result, :changes, replace_changes(original, Resources::Order.new(payload), result))
+        client.config.response_format == :resource ? Resources::Base.new(result, client) : result
+      end
+
+      # Cancel an order
+      #
+      # Schwab's cancel endpoint takes no reason, so a +reason+ is recorded on the SDK side only:
+      # it is logged at info level and attached to the request's +on_request+ event as the
+      # +:cancel_reason+ label (see {Schwab.with_labels}).
+      #
+      # @param account_number [String] The account number
+      # @param order_id [String, Integer] The ID of the order to cancel
+      # @param reason [String, nil] Why the order is being canceled, for audit logs and metrics
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Boolean] True once Schwab accepts the cancellation
+      # @raise [OrderStateConflictError] If Schwab refuses the cancel because of the order's state
+      #   (409 Conflict), such as an order mid-fill; it carries the order's current status
+      # @example Cancel with an audit reason
+      #   Schwab::Trading.cancel_order("123456", "1001", reason: "stale quote")
+      def cancel_order(account_number, order_id, reason: nil, client: nil)
+        client ||= default_client
+        request = build_cancel_order_request(account_number, order_id, client: client)
+        return send_cancel(request, account_number, order_id, client) if reason.nil?
+
+        client.config.logger&.info("Schwab cancel order #{order_id} reason=#{reason.to_s.inspect}")
+        Schwab.with_labels(cancel_reason: reason.to_s) { send_cancel(request, account_number, order_id, client) }
+      end
+
+      # Build the request {cancel_order} would send, without sending it
+      #
+      # @param account_number [String] The account number
+      # @param order_id [String, Integer] The ID of the order to cancel
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Request] The DELETE request
+      def build_cancel_order_request(account_number, order_id, client: nil)
+        client ||= default_client
+        Request.new(http_method: :delete, path: order_path(account_number, order_id, client))
+      end
+
+      # Find when a market's regular session next opens
+      #
+      # Market hours are fetched one day at a time, starting with the day of +from+, until a
+      # regular session that opens after +from+ is found. Weekends and holidays have no session
+      # and are skipped. Useful for resubmitting an order rejected with MarketClosedError.
+      #
+      # @param market [String] The market, as accepted by MarketData.get_market_hours (default: "equity")
+      # @param from [Time] Find the first open after this time (default: now)
+      # @param client [Schwab::Client, nil] Optional client instance
+      # @return [Time] When the regular session opens, in the market's timezone
+      # @raise [Error] if no session opens within MARKET_OPEN_LOOKAHEAD_DAYS
+      # @example Retry a rejected order at the open
+      #   begin
+      #     Schwab::Trading.place_order("123456", order: order)
+      #   rescue Schwab::MarketClosedError
+      #     scheduler.at(Schwab::Trading.next_market_open) { Schwab::Trading.place_order("123456", order: order) }
+      #   end
+      def next_market_open(market: "equity", from: Time.now, client: nil)
+        client ||= default_client
+        MARKET_OPEN_LOOKAHEAD_DAYS.times do |offset|
+          hours = MarketData.get_market_hours(market, date: from.to_date + offset, client: client)
+          open = regular_session_starts(hours).select { |start| start > from }.min
+          return open if open
+        end
+
+        raise Error, "No #{market} market open found in the #{MARKET_OPEN_LOOKAHEAD_DAYS} days from #{from}"
+      end
+
+      private
+
+      def default_client
+        Schwab.client || raise(Error, "No client configured. Set Schwab.client or pass a client instance.")
+      end
+
+      def encode_account_number(account_number, client)
+        URI.encode_www_form_component(client.resolve_account_number(Identifiers.account_number!(account_number)))
+      end
+
+      def send_cancel(request, account_number, order_id, client)
+        client.delete(request.path)
+        true
+      rescue ConflictError => e
+        raise order_state_conflict(e, account_number, order_id, client)
+      end
+
+      # Fetch the order Schwab refused to cancel so the error can carry its current status
+      def order_state_conflict(error, account_number, order_id, client)
+        order = begin
+          Accounts.get_order(account_number, order_id, client: client)
+        rescue Error
+          nil
+        end
+        status = order && (order[:status] || order["status"])
+
+        OrderStateConflictError.new(
+          "Order #{order_id} cannot be canceled in its current state#{" (#{status})" if status}: #{error.message}",
+          order_id: order_id,
+          order_status: status,
+          order: order,
+          status: error.status,
+          response_body: error.response_body,
+          response_headers: error.response_headers,
+        )
+      end
+
+      def order_path(account_number, order_id, client)
+        "/trader/v1/accounts/#{encode_account_number(account_number, client)}/orders/#{order_id}"
+      end
+
+      def check_limit_price(order, max_deviation_percent, client)
+        return unless (order.limit_order? || order.stop_limit_order?) && order.single_leg?
+
+        limit = (order[:price] || order.limit_price)&.to_f
+        return unless limit && order.symbol
+
+        quote = fetch_quote(order.symbol, client)
+        reference = quote.mid_price || quote.last_price
+        unless reference&.positive?
+          raise PriceDeviationError.new("No market price for #{order.symbol} to check the limit price against", quote: quote)
+        end
+
+        deviation = ((limit - reference).abs / reference * 100).round(2)
+        return if deviation <= max_deviation_percent
+
+        raise PriceDeviationError.new(
+          "Limit price #{Price.format(limit)} for #{order.symbol} is #{deviation}% from the market " \
+            "(#{Price.format(reference)}); the maximum is #{max_deviation_percent}%",
+          quote: quote,
+          reference_price: reference,
+          deviation_percent: deviation,
+        )
+      end
+
+      # Refuse an order whose estimated notional value exceeds config.max_order_notional. Orders
+      # with a price are valued at it; others are valued at each leg's quote when +quote+ is set,
+      # and left unchecked otherwise.
+      def check_notional(payload, client, quote:)
+        max = client.config.max_order_notional
+        return unless max
+
+        order = Resources::Order.new(payload.to_h, client)
+        legs = order.order_legs
+        return if legs.empty?
+
+        notional = if order.price
+          order.price.to_f.abs * legs.first[:quantity].to_f * order_multiplier(legs)
+        elsif quote
+          quoted_notional(legs, client)
+        end
+        return unless notional && notional > max
+
+        raise OrderNotionalError.new(
+          "Order notional #{Price.format(notional, precision: 2)} exceeds the maximum of #{Price.format(max, precision: 2)}",
+          notional: notional.round(2),
+          max_notional: max,
+        )
+      end
+
+      # Value each leg at its quote (bid/ask midpoint, or last price)
+      def quoted_notional(legs, client)
+        symbols = legs.map { |leg| leg_symbol(leg) }
+        raise InvalidRequestError, "Order legs need symbols to estimate the order notional" if symbols.include?(nil)
+
+        quotes = MarketData.get_quotes(symbols.uniq, client: client).to_h
+        legs.zip(symbols).sum do |leg, symbol|
+          quote = Resources::Quote.new((quotes[symbol] || quotes[symbol.to_sym]).to_h, client)
+          price = quote.mid_price || quote.last_price
+          raise InvalidRequestError, "No market price for #{symbol} to estimate the order notional" unless price
+
+          price.to_f * leg[:quantity].to_f * order_multiplier([leg])
+        end
+      end
+
+      def order_multiplier(legs)
+        option = legs.any? { |leg| leg[:instrument] && leg[:instrument][:assetType].to_s.upcase == "OPTION" }
+        option ? Symbols::OPTION_MULTIPLIER : 1
+      end
+
+      def leg_symbol(leg)
+        leg[:instrument][:symbol] if leg[:instrument]
+      end
+
+      # Symbol and side (:buy or :sell) of an order leg, or nil for legs with neither
+      def crossing_leg(leg)
+        instruction = leg[:instruction].to_s.upcase
+        side = if instruction.start_with?("BUY")
+          :buy
+        elsif instruction.start_with?("SELL")
+          :sell
+        end
+        symbol = leg_symbol(leg)
+        [symbol.to_s.upcase, side] if side && symbol
+      end
+
+      def crosses?(order, leg, resting, resting_leg)
+        symbol, side = leg
+        resting_symbol, resting_side = resting_leg
+        return false unless symbol == resting_symbol && side != resting_side
+
+        price = crossing_price(order)
+        resting_price = crossing_price(resting)
+        return true unless price && resting_price
+
+        side == :buy ? price >= resting_price : price <= resting_price
+      end
+
+      # Limit price of a single-leg order; nil when it can trade at any price
+      def crossing_price(order)
+        return unless order.single_leg? && (order.limit_order? || order.stop_limit_order?)
+
+        (order[:price] || order.limit_price)&.to_s&.to_r
+      end
+
+      # Start times of the regular sessions in a market hours response
+      # ({ "equity" => { "EQ" => { "sessionHours" => { "regularMarket" => [{ "start" => ... }] } } } })
+      def regular_session_starts(hours)
+        products = hours.to_h.values.select { |market| market.respond_to?(:key?) }.flat_map { |market| market.to_h.values }
+        products.select { |product| product.respond_to?(:key?) }.flat_map do |product|
+          sessions = product[:sessionHours] || product["sessionHours"] || {}
+          Array(sessions[:regularMarket] || sessions["regularMarket"]).filter_map do |session|
+            start = session[:start] || session["start"]
+            Timestamps.parse(start) if start
+          end
+        end
+      end
+
+      def fetch_quote(symbol, client)
+        response = MarketData.get_quote(symbol, client: client).to_h
+        data = response[symbol] || response[symbol.to_sym] || response.values.find { |value| value.respond_to?(:key?) }
+        Resources::Quote.new(data.to_h, client)
+      end
+
+      def prepare_order(order, validate, client)
+        payload = order.to_h
+        unless payload.key?(:session) || payload.key?("session")
+          payload = with_field(payload, :session, Resources::Order::DEFAULT_SESSION)
+        end
+        payload = Symbols.upcase_order(payload) if client.config.normalize_symbol_case
+        rounding = client.config.quantity_rounding
+        payload = Quantity.round_order(payload, rounding, fractional: client.config.fractional_symbols) if rounding
+        precision = client.config.price_precision
+        payload = Price.format_order(payload, precision) if precision
+        Resources::Order.new(payload).validate! if validate || client.config.validate_only
+        payload
+      end
+
+      # Record the order with the duplicate guard around submitting it. An order Schwab rejects is
+      # forgotten; one whose outcome is unknown (a timeout or server error) is kept, since it may
+      # have been placed.
+      def guard_duplicates(account_number, payload, allow_duplicate, client)
+        guard = client.config.duplicate_order_guard
+        return yield unless guard
+
+        fingerprint = DuplicateOrderGuard.fingerprint(Identifiers.account_number!(account_number), payload)
+        allow_duplicate ? guard.record(fingerprint) : guard.check!(fingerprint)
+        begin
+          yield
+        rescue ApiError => e
+          guard.forget(fingerprint) if e.status && e.status < 500
+          raise
+        end
+      end
+
+      def dry_run_order(payload, client)
+        wrap_order(with_field(payload, :status, DRY_RUN_STATUS), client)
+      end
+
+      def submitted_order(payload, response, client)
+        location = response.headers["Location"]
+        order_id = location.to_s.split("/").last if location
+        wrap_order(order_id ? with_field(payload, :orderId, order_id) : payload, client)
+      end
+
+      def replace_changes(original, replacement, payload)
+        string_keys = payload.keys.first.is_a?(String)
+        REPLACE_FIELDS.each_with_object({}) do |field, changes|
+          from, to = [original, replacement].map { |o| field == "quantity" ? o.quantity : o[field.to_sym] }
+          next if from == to || (Price::PRICE_FIELDS.include?(field) && from && to && from.to_s.to_r == to.to_s.to_r)
+
+          change = { from: from, to: to }
+          change = change.transform_keys(&:to_s) if string_keys
+          changes[string_keys ? field : field.to_sym] = change
+        end
+      end
+
+      # Add a field using the same key style (symbol or string) as the payload
+      def with_field(payload, key, value)
+        key = key.to_s if payload.keys.first.is_a?(String)
+        payload.merge(key => value)
+      end
+
+      def wrap_order(data, client)
+        client.config.response_format == :resource ? Resources::Order.new(data, client) : data
+      end
+    end
+  end
+end
@@ -0,0 +1,72 @@
+# frozen_string_literal: true
+
+require "set"
+
+module Schwab
+  # Schwab's transaction types, as returned in a transaction's +type+ and accepted by
+  # Accounts.get_transactions
+  #
+  # @example Filter transactions by several types
+  #   types = Set[Schwab::TransactionType::TRADE, Schwab::TransactionType::DIVIDEND_OR_INTEREST]
+  #   Schwab::Accounts.get_transactions("123456", types: types, start_date: "2024-01-01", end_date: "2024-01-31")
+  module TransactionType
+    TRADE = "TRADE"
+    RECEIVE_AND_DELIVER = "RECEIVE_AND_DELIVER"
+    DIVIDEND_OR_INTEREST = "DIVIDEND_OR_INTEREST"
+    ACH_RECEIPT = "ACH_RECEIPT"
+    ACH_DISBURSEMENT = "ACH_DISBURSEMENT"
+    CASH_RECEIPT = "CASH_RECEIPT"
+    CASH_DISBURSEMENT = "CASH_DISBURSEMENT"
+    ELECTRONIC_FUND = "ELECTRONIC_FUND"
+    WIRE_OUT = "WIRE_OUT"
+    WIRE_IN = "WIRE_IN"
+    JOURNAL = "JOURNAL"
+    MEMORANDUM = "MEMORANDUM"
+    MARGIN_CALL = "MARGIN_CALL"
+    MONEY_MARKET = "MONEY_MARKET"
+    SMA_ADJUSTMENT = "SMA_ADJUSTMENT"
+
+    # Every transaction type, in Schwab's documented order
+    ALL = [
+      TRADE,
+      RECEIVE_AND_DELIVER,
+      DIVIDEND_OR_INTEREST,
+      ACH_RECEIPT,
+      ACH_DISBURSEMENT,
+      CASH_RECEIPT,
+      CASH_DISBURSEMENT,
+      ELECTRONIC_FUND,
+      WIRE_OUT,
+      WIRE_IN,
+      JOURNAL,
+      MEMORANDUM,
+      MARGIN_CALL,
+      MONEY_MARKET,
+      SMA_ADJUSTMENT,
+    ].freeze
+
+    class << self
+      # Check if a value is a known transaction type
+      #
+      # @param type [String, Symbol] The transaction type (case-insensitive)
+      # @return [Boolean] True if Schwab defines the type
+      def valid?(type)
+        ALL.include?(type.to_s.upcase)
+      end
+
+      # Normalize one or more transaction types
+      #
+      # @param types [String, Symbol, Array, Set] A type, a comma-separated list, or a collection of types
+      # @return [Array<String>] Upper-case types without duplicates, in the order given
+      # @raise [ArgumentError] if any type is unknown
+      def normalize(types)
+        values = types.is_a?(Enumerable) ? types.to_a : types.to_s.split(",")
+        values = values.map { |type| type.to_s.strip.upcase }.reject(&:empty?).uniq
+        unknown = values.reject { |type| valid?(type) }
+        raise ArgumentError, "Unknown transaction type(s): #{unknown.join(", ")}" unless unknown.empty?
+
+        values
+      end
+    end
+  end
+end
@@ -4,7 +4,8 @@ require "spec_helper"
 require "schwab/accounts"
 
 RSpec.describe(Schwab::Accounts) do
-  let(:client) { instance_double("Schwab::Client") }
+  let(:config) { Schwab::Configuration.new }
+  let(:client) { instance_double("Schwab::Client", config: config) }
   let(:account_number) { "123456789" }
   let(:encrypted_account) { "ABC123XYZ" }
 
@@ -92,6 +93,245 @@ RSpec.describe(Schwab::Accounts) do
     end
   end
 
+  describe ".get_details" do
+    let(:account_response) do
+      {
+        "securitiesAccount" => {
+          "accountNumber" => account_number,
+          "type" => "MARGIN",
+          "registrationType" => "JOINT",
+          "owners" => [
+            { "name" => "Jane Doe", "relationship" => "PRIMARY" },
+            { "firstName" => "John", "lastName" => "Doe", "relationship" => "JOINT_TENANT" },
+          ],
+        },
+      }
+    end
+    let(:preferences) do
+      {
+        "accounts" => [
+          { "accountNumber" => "987654321", "nickName" => "Other" },
+          { "accountNumber" => account_number, "nickName" => "Family", "primaryAccount" => true },
+        ],
+      }
+    end
+
+    before do
+      allow(client).to(receive(:get)
+        .with("/trader/v1/accounts/#{encrypted_account}", {}, Schwab::Resources::Account)
+        .and_return(account_response))
+      allow(client).to(receive(:get).with("/trader/v1/userPreference").and_return(preferences))
+    end
+
+    it "returns the account with registration, owners, and preferences" do
+      details = described_class.get_details(account_number)
+
+      expect(details).to(be_a(Schwab::Resources::Account))
+      expect(details.account_type).to(eq("MARGIN"))
+      expect(details.registration_type).to(eq("JOINT"))
+      expect(details.owners).to(eq([
+        Schwab::Resources::Account::Owner.new(name: "Jane Doe", relationship: "PRIMARY"),
+        Schwab::Resources::Account::Owner.new(name: "John Doe", relationship: "JOINT_TENANT"),
+      ]))
+      expect(details.nickname).to(eq("Family"))
+    end
+
+    it "leaves details empty when Schwab does not report them" do
+      account_response["securitiesAccount"].delete("registrationType")
+      account_response["securitiesAccount"].delete("owners")
+
+      details = described_class.get_details(account_number)
+
+      expect(details.registration_type).to(be_nil)
+      expect(details.owners).to(eq([]))
+    end
+  end
+
+  describe ".get_balances" do
+    it "returns the current balances of the account" do
+      allow(client).to(receive(:get)
+        .with("/trader/v1/accounts/#{encrypted_account}", {}, Schwab::Resources::Account)
+        .and_return({ "securitiesAccount" => { "currentBalances" => { "cashBalance" => 1250.0 } } }))
+
+      expect(described_class.get_balances(account_number, client: client)).to(eq({ "cashBalance" => 1250.0 }))
+    end
+  end
+
+  describe ".get_fields" do
+    let(:path) { "/trader/v1/accounts/#{encrypted_account}" }
+    let(:account) do
+      {
+        "securitiesAccount" => {
+          "accountNumber" => account_number,
+          "type" => "MARGIN",
+          "roundTrips" => 0,
+          "currentBalances" => { "buyingPower" => 5000.0 },
+          "initialBalances" => { "cashBalance" => 1000.0 },
+          "positions" => [{ "instrument" => { "symbol" => "AAPL" } }],
+        },
+        "aggregatedBalance" => { "liquidationValue" => 12_000.0 },
+      }
+    end
+
+    it "returns only the selected balance blocks without requesting positions" do
+      expect(client).to(receive(:get).with(path, {}, Schwab::Resources::Account).and_return(account))
+
+      expect(described_class.get_fields(account_number, ["currentBalances", :aggregatedBalance], client: client)).to(eq(
+        "accountNumber" => account_number,
+        "type" => "MARGIN",
+        "currentBalances" => { "buyingPower" => 5000.0 },
+        "aggregatedBalance" => { "liquidationValue" => 12_000.0 },
+      ))
+    end
+
+    it "requests positions only when they are selected" do
+      expect(client).to(receive(:get).with(path, { fields: "positions" }, Schwab::Resources::Account).and_return(account))
+
+      result = described_class.get_fields(account_number, "positions", client: client)
+
+      expect(result.keys).to(eq(["accountNumber", "type", "positions"]))
+    end
+
+    it "leaves out blocks Schwab did not send" do
+      allow(client).to(receive(:get).and_return({ "securitiesAccount" => { "accountNumber" => account_number } }))
+
+      expect(described_class.get_fields(account_number, ["projectedBalances"], client: client))
+        .to(eq("accountNumber" => account_number))
+    end
+
+    it "rejects unknown fields before making a request" do
+      expect(client).not_to(receive(:get))
+
+      expect { described_class.get_fields(account_number, ["currentBalances", "orders"], client: client) }
+        .to(raise_error(Schwab::InvalidRequestError, /Unknown account fields: orders/) do |error|
+          expect(error.errors).to(eq(["Unknown account field: orders"]))
+        end)
+      expect { described_class.get_fields(account_number, [], client: client) }
+        .to(raise_error(Schwab::InvalidRequestError, /At least one/))
+    end
+  end
+
+  describe ".get_many" do
+    let(:other_account) { "987654321" }
+
+    before do
+      allow(client).to(receive(:resolve_account_number).with(other_account).and_return("DEF456"))
+      allow(client).to(receive(:resolve_account_number).with("missing").and_return("missing"))
+    end
+
+    it "fetches every account and keeps failures per account" do
+      allow(client).to(receive(:get)
+        .with("/trader/v1/accounts/#{encrypted_account}", { fields: "positions" }, Schwab::Resources::Account)
+        .and_return({ accountNumber: account_number }))
+      allow(client).to(receive(:get)
+        .with("/trader/v1/accounts/DEF456", { fields: "positions" }, Schwab::Resources::Account)
+        .and_return({ accountNumber: other_account }))
+      allow(client).to(receive(:get)
+        .with("/trader/v1/accounts/missing", { fields: "positions" }, Schwab::Resources::Account)
+        .and_raise(Schwab::NotFoundError, "Resource not found"))
+
+      result = described_class.get_many([account_number, "missing", other_account], fields: "positions")
+
+      expect(result.accounts.keys).to(eq([account_number, other_account]))
+      expect(result.accounts[other_account]).to(eq({ accountNumber: other_account }))
+      expect(result.errors.keys).to(eq(["missing"]))
+      expect(result.errors["missing"]).to(be_a(Schwab::NotFoundError))
+      expect(result).not_to(be_success)
+      expect(result.error).to(be_a(Schwab::MultiError))
+      expect(result.error).to(include(Schwab::ApiError))
+      expect(result.error).not_to(include(Schwab::RateLimitError))
+      expect(result.error.message).to(eq("1 batch item failed (missing: Resource not found)"))
+      expect { result.accounts! }.to(raise_error(Schwab::MultiError) { |error| expect(error.cause).to(be_a(Schwab::NotFoundError)) })
+    end
+
+    it "limits the number of requests in flight" do
+      in_flight = 0
+      peak = 0
+      lock = Mutex.new
+      allow(client).to(receive(:resolve_account_number) { |number| number })
+      allow(client).to(receive(:get)) do
+        lock.synchronize { peak = [peak, in_flight += 1].max }
+        sleep(0.01)
+        lock.synchronize { in_flight -= 1 }
+        {}
+      end
+
+      result = described_class.get_many(Array.new(6) { |i| "acct#{i}" }, concurrency: 2)
+
+      expect(result.accounts.size).to(eq(6))
+      expect(result).to(be_success)
+      expect(result.error).to(be_nil)
+      expect(result.accounts!).to(eq(result.accounts))
+      expect(peak).to(be <= 2)
+    end
+
+    it "keeps the caller's operation and labels on worker threads" do
+      seen = Queue.new
+      allow(client).to(receive(:resolve_account_number) { |number| number })
+      allow(client).to(receive(:get)) do
+        seen << [Schwab.current_operation, Schwab.current_labels]
+        {}
+      end
+
+      Schwab.with_operation("nightly") do
+        Schwab.with_labels(job: "sync") { described_class.get_many(["acct1", "acct2"], concurrency: 2) }
+      end
+
+      expect(Array.new(2) { seen.pop }).to(all(eq(["nightly", { job: "sync" }])))
+    end
+
+    it "rejects a non-positive concurrency" do
+      expect { described_class.get_many([account_number], concurrency: 0) }.to(raise_error(ArgumentError))
+    end
+  end
+
+  describe ".wait_until_ready" do
+    let(:path) { "/trader/v1/accounts/#{encrypted_account}" }
+
+    def account_with(status, **fields)
+      { securitiesAccount: { accountNumber: account_number, status: status, **fields } }
+    end
+
+    before { allow(described_class).to(receive(:sleep)) }
+
+    it "polls until the account is active" do
+      expect(client).to(receive(:get).with(path, {}, Schwab::Resources::Account)
+        .and_return(account_with("PENDING"), account_with("PENDING"), account_with("ACTIVE")))
+
+      result = described_class.wait_until_ready(account_number, interval: 2)
+      expect(result).to(eq(account_with("ACTIVE")))
+      expect(described_class).to(have_received(:sleep).with(2).twice)
+    end
+
+    it "treats an account without a status as ready" do
+      allow(client).to(receive(:get).and_return({ securitiesAccount: { accountNumber: account_number } }))
+
+      described_class.wait_until_ready(account_number)
+      expect(described_class).not_to(have_received(:sleep))
+    end
+
+    it "raises when the account reaches a terminal status" do
+      allow(client).to(receive(:get).and_return(account_with("PENDING"), account_with("CLOSED")))
+
+      expect { described_class.wait_until_ready(account_number) }
+        .to(raise_error(Schwab::AccountNotTradeableError) { |e| expect(e.account_status).to(eq("CLOSED")) })
+    end
+
+    it "raises when the account is restricted to closing transactions" do
+      allow(client).to(receive(:get).and_return(account_with("ACTIVE", isClosingOnlyRestricted: true)))
+
+      expect { described_class.wait_until_ready(account_number) }
+        .to(raise_error(Schwab::AccountNotTradeableError, /CLOSING_ONLY/))
+    end
+
+    it "raises when the timeout expires" do
+      allow(client).to(receive(:get).and_return(account_with("PENDING")))
+
+      expect { described_class.wait_until_ready(account_number, timeout: 0) }
+        .to(raise_error(Schwab::AccountNotReadyError) { |e| expect(e.account_status).to(eq("PENDING")) })
+    end
+  end
+
   describe ".get_positions" do
     let(:positions_response) do
       [
@@ -119,7 +359,7 @@ RSpec.describe(Schwab::Accounts) do
     end
 
     it "returns empty array when no positions" do
-      account_no_positions = { accountNumber: account_number }
+      account_no_positions = { accountNumber: account_number, securitiesAccount: { accountNumber: account_number } }
       expect(described_class).to(receive(:get_account)
         .with(account_number, { fields: "positions", client: client })
         .and_return(account_no_positions))
@@ -127,6 +367,238 @@ RSpec.describe(Schwab::Accounts) do
       result = described_class.get_positions(account_number, client: client)
       expect(result).to(eq([]))
     end
+
+    context "when filtering by asset type" do
+      let(:positions_response) do
+        [
+          { longQuantity: 100, instrument: { symbol: "AAPL", assetType: "EQUITY" } },
+          { longQuantity: 2, instrument: { symbol: "AAPL  240517C00190000", assetType: "OPTION" } },
+          { longQuantity: 50, instrument: { symbol: "MSFT", assetType: "EQUITY" } },
+        ]
+      end
+
+      before do
+        allow(described_class).to(receive(:get_account).and_return(account_with_positions))
+      end
+
+      it "keeps only equity positions" do
+        result = described_class.get_positions(account_number, asset_type: "EQUITY", client: client)
+
+        expect(result.map { |position| position[:instrument][:symbol] }).to(eq(["AAPL", "MSFT"]))
+      end
+
+      it "keeps only option positions" do
+        result = described_class.get_positions(account_number, asset_type: :option, client: client)
+
+        expect(result.size).to(eq(1))
+        expect(result.first[:instrument][:symbol]).to(eq("AAPL  240517C00190000"))
+      end
+
+      it "accepts several asset types and filters position resources" do
+        allow(described_class).to(receive(:get_account)
+          .and_return(Schwab::Resources::Account.new(account_with_positions)))
+
+        result = described_class.get_positions(account_number, asset_type: ["OPTION", "MUTUAL_FUND"], client: client)
+
+        expect(result.map(&:symbol)).to(eq(["AAPL  240517C00190000"]))
+      end
+    end
+
+    it "normalizes null positions to an empty array" do
+      allow(described_class).to(receive(:get_account)
+        .and_return({ "securitiesAccount" => { "positions" => nil } }))
+
+      expect(described_class.get_positions(account_number, client: client)).to(eq([]))
+    end
+
+    it "returns an empty array for an empty positions list" do
+      allow(described_class).to(receive(:get_account)
+        .and_return({ "securitiesAccount" => { "positions" => [] } }))
+
+      expect(described_class.get_positions(account_number, client: client)).to(eq([]))
+    end
+
+    it "returns Position resources when the account is a resource" do
+      account = Schwab::Resources::Account.new({ "securitiesAccount" => { "positions" => [{ "longQuantity" => 5 }] } })
+      allow(described_class).to(receive(:get_account).and_return(account))
+
+      positions = described_class.get_positions(account_number, client: client)
+      expect(positions.map(&:class)).to(eq([Schwab::Resources::Position]))
+    end
+
+    it "raises instead of returning empty when the response cannot be read" do
+      allow(described_class).to(receive(:get_account).and_return({ accountNumber: account_number }))
+
+      expect { described_class.get_positions(account_number, client: client) }
+        .to(raise_error(Schwab::UnexpectedResponseError))
+    end
+  end
+
+  describe ".get_position" do
+    let(:positions_response) do
+      [
+        { instrument: { symbol: "AAPL", assetType: "EQUITY" }, longQuantity: 100 },
+        { instrument: { symbol: "GOOGL", assetType: "EQUITY" }, longQuantity: 50 },
+      ]
+    end
+
+    before do
+      allow(described_class).to(receive(:get_positions)
+        .with(account_number, client: client)
+        .and_return(positions_response))
+    end
+
+    it "returns the position matching the symbol" do
+      result = described_class.get_position(account_number, "googl", client: client)
+      expect(result).to(eq(positions_response[1]))
+    end
+
+    it "raises NotFoundError when the symbol is not held" do
+      expect { described_class.get_position(account_number, "MSFT", client: client) }
+        .to(raise_error(Schwab::NotFoundError, /MSFT/))
+    end
+  end
+
+  describe ".get_positions_summary" do
+    let(:positions_response) do
+      [
+        {
+          instrument: { symbol: "AAPL", assetType: "EQUITY" },
+          longQuantity: 10,
+          averagePrice: 100.0,
+          marketValue: 1500.0,
+        },
+        {
+          instrument: { symbol: "AAPL  240315C00150000", assetType: "OPTION" },
+          longQuantity: 1,
+          averagePrice: 2.0,
+          marketValue: 300.0,
+          currentDayProfitLoss: 25.0,
+        },
+      ]
+    end
+
+    let(:quotes_response) do
+      { "AAPL" => { quote: { lastPrice: 150.0, closePrice: 148.0 } } }
+    end
+
+    it "totals P&L and computes day change from prior close" do
+      allow(described_class).to(receive(:get_positions).and_return(positions_response))
+      expect(Schwab::MarketData).to(receive(:get_quotes)
+        .with(["AAPL", "AAPL  240315C00150000"], fields: "quote", client: client)
+        .and_return(quotes_response))
+
+      summary = described_class.get_positions_summary(account_number, client: client)
+
+      expect(summary[:market_value]).to(eq(1800.0))
+      expect(summary[:cost_basis]).to(eq(1002.0))
+      expect(summary[:unrealized_pnl]).to(eq(798.0))
+      expect(summary[:day_change]).to(eq(45.0))
+      expect(summary[:position_count]).to(eq(2))
+      expect(summary[:by_asset_type]["EQUITY"][:day_change]).to(eq(20.0))
+      expect(summary[:by_asset_type]["OPTION"][:day_change]).to(eq(25.0))
+    end
+
+    it "applies the contract multiplier to an option's day change" do
+      allow(described_class).to(receive(:get_positions).and_return(positions_response))
+      allow(Schwab::MarketData).to(receive(:get_quotes).and_return(
+        quotes_response.merge("AAPL  240315C00150000" => { quote: { lastPrice: 3.0, closePrice: 2.5 } }),
+      ))
+
+      summary = described_class.get_positions_summary(account_number, client: client)
+
+      expect(summary[:by_asset_type]["OPTION"][:day_change]).to(eq(50.0))
+      expect(summary[:day_change]).to(eq(70.0))
+    end
+
+    it "returns zeros for an account without positions" do
+      allow(described_class).to(receive(:get_positions).and_return([]))
+      expect(Schwab::MarketData).not_to(receive(:get_quotes))
+
+      summary = described_class.get_positions_summary(account_number, client: client)
+
+      expect(summary[:market_value]).to(eq(0.0))
+      expect(summary[:day_change]).to(eq(0.0))
+      expect(summary[:position_count]).to(eq(0))
+      expect(summary[:by_asset_type]).to(eq({}))
+    end
+  end
+
+  describe ".get_risk_metrics" do
+    it "computes risk metrics from the account's positions" do
+      allow(described_class).to(receive(:get_positions).with(account_number, client: client).and_return([
+        { instrument: { symbol: "AAPL", assetType: "EQUITY" }, longQuantity: 10, marketValue: 1500.0 },
+        { instrument: { symbol: "SPY", assetType: "ETF" }, longQuantity: 1, marketValue: 500.0 },
+      ]))
+
+      risk = described_class.get_risk_metrics(account_number, betas: { "AAPL" => 1.2, "SPY" => 1.0 }, client: client)
+
+      expect(risk.largest_position).to(eq("AAPL"))
+      expect(risk.largest_position_weight).to(eq(0.75))
+      expect(risk.beta).to(eq(1.15))
+    end
+  end
+
+  describe ".get_live_positions" do
+    let(:positions_response) do
+      [
+        { instrument: { symbol: "AAPL", assetType: "EQUITY" }, longQuantity: 10, averagePrice: 100.0, marketValue: 1500.0 },
+        { instrument: { symbol: "TSLA", assetType: "EQUITY" }, shortQuantity: 5, averagePrice: 200.0, marketValue: -950.0 },
+        {
+          instrument: { symbol: "AAPL  240315C00150000", assetType: "OPTION" },
+          longQuantity: 2,
+          averagePrice: 2.0,
+          marketValue: 500.0,
+        },
+        { instrument: { symbol: "XYZ", assetType: "EQUITY" }, longQuantity: 1, averagePrice: 5.0, marketValue: 6.0 },
+      ]
+    end
+
+    let(:quotes_response) do
+      {
+        "AAPL" => { quote: { lastPrice: 155.0 } },
+        "TSLA" => { quote: { lastPrice: 180.0 } },
+        "AAPL  240315C00150000" => { quote: { lastPrice: 3.0 } },
+      }
+    end
+
+    before do
+      allow(described_class).to(receive(:get_positions).and_return(positions_response))
+      allow(Schwab::MarketData).to(receive(:get_quotes)
+        .with(["AAPL", "TSLA", "AAPL  240315C00150000", "XYZ"], fields: "quote", client: client)
+        .and_return(quotes_response))
+    end
+
+    it "recomputes values from live prices alongside the reported ones" do
+      aapl, = described_class.get_live_positions(account_number, client: client)
+
+      expect(aapl.position).to(eq(positions_response.first))
+      expect(aapl.last_price).to(eq(155.0))
+      expect(aapl.market_value).to(eq(1500.0))
+      expect(aapl.live_market_value).to(eq(1550.0))
+      expect(aapl.unrealized_pnl).to(eq(500.0))
+      expect(aapl.live_unrealized_pnl).to(eq(550.0))
+      expect(aapl.live_unrealized_pnl_percentage).to(eq(55.0))
+    end
+
+    it "values short and option positions" do
+      _, tsla, option, = described_class.get_live_positions(account_number, client: client)
+
+      expect(tsla.live_market_value).to(eq(-900.0))
+      expect(tsla.live_unrealized_pnl).to(eq(100.0))
+      expect(tsla.live_unrealized_pnl_percentage).to(eq(10.0))
+      expect(option.live_market_value).to(eq(600.0))
+      expect(option.live_unrealized_pnl).to(eq(200.0))
+    end
+
+    it "leaves live values empty without a quote" do
+      xyz = described_class.get_live_positions(account_number, client: client).last
+
+      expect(xyz.market_value).to(eq(6.0))
+      expect(xyz.last_price).to(be_nil)
+      expect(xyz.live_market_value).to(be_nil)
+      expect(xyz.live_unrealized_pnl).to(be_nil)
+    end
   end
 
   describe ".get_transactions" do
@@ -176,12 +648,362 @@ RSpec.describe(Schwab::Accounts) do
       expect(client).to(receive(:get)
         .with(
           "/trader/v1/accounts/#{encrypted_account}/transactions",
-          { types: "TRADE,DIVIDEND" },
+          { types: "TRADE,DIVIDEND_OR_INTEREST" },
+          Schwab::Resources::Transaction,
+        )
+        .and_return(transactions_response))
+
+      described_class.get_transactions(account_number, types: ["TRADE", "DIVIDEND_OR_INTEREST"])
+    end
+
+    it "accepts a set of transaction types" do
+      expect(client).to(receive(:get)
+        .with(
+          "/trader/v1/accounts/#{encrypted_account}/transactions",
+          { types: "WIRE_IN,WIRE_OUT" },
           Schwab::Resources::Transaction,
         )
         .and_return(transactions_response))
 
-      described_class.get_transactions(account_number, types: ["TRADE", "DIVIDEND"])
+      described_class.get_transactions(account_number, types: Set[:wire_in, Schwab::TransactionType::WIRE_OUT])
+    end
+
+    it "rejects unknown transaction types before requesting" do
+      expect(client).not_to(receive(:get))
+
+      expect { described_class.get_transactions(account_number, types: ["TRADE", "DIVIDEND"]) }
+        .to(raise_error(ArgumentError, /DIVIDEND/))
+    end
+
+    it "splits long date ranges into windows and removes boundary duplicates" do
+      path = "/trader/v1/accounts/#{encrypted_account}/transactions"
+      expect(client).to(receive(:get)
+        .with(path, { types: "TRADE", startDate: "2022-01-01", endDate: "2023-01-01" }, Schwab::Resources::Transaction)
+        .ordered
+        .and_return([{ "activityId" => 1 }, { "activityId" => 2 }]))
+      expect(client).to(receive(:get)
+        .with(path, { types: "TRADE", startDate: "2023-01-01", endDate: "2024-01-01" }, Schwab::Resources::Transaction)
+        .ordered
+        .and_return([{ "activityId" => 2 }, { "activityId" => 3 }]))
+      expect(client).to(receive(:get)
+        .with(path, { types: "TRADE", startDate: "2024-01-01", endDate: "2024-06-30" }, Schwab::Resources::Transaction)
+        .ordered
+        .and_return([{ "activityId" => 4 }]))
+
+      result = described_class.get_transactions(
+        account_number,
+        types: "TRADE",
+        start_date: Date.new(2022, 1, 1),
+        end_date: Date.new(2024, 6, 30),
+      )
+
+      expect(result.map { |transaction| transaction["activityId"] }).to(eq([1, 2, 3, 4]))
+    end
+
+    it "reports a checkpoint after each window and resumes from one" do
+      path = "/trader/v1/accounts/#{encrypted_account}/transactions"
+      allow(client).to(receive(:get)
+        .with(path, { types: "TRADE", startDate: "2022-01-01", endDate: "2023-01-01" }, Schwab::Resources::Transaction)
+        .and_return([{ "activityId" => 1 }]))
+      allow(client).to(receive(:get)
+        .with(path, { types: "TRADE", startDate: "2023-01-01", endDate: "2024-01-01" }, Schwab::Resources::Transaction)
+        .and_raise(Schwab::ServerError, "down"))
+      checkpoints = []
+      export = lambda do |resume_from|
+        described_class.get_transactions(
+          account_number,
+          types: "TRADE",
+          start_date: Date.new(2022, 1, 1),
+          end_date: Date.new(2024, 6, 30),
+          resume_from: resume_from,
+          on_chunk: ->(checkpoint, chunk) { checkpoints << [checkpoint, chunk.size] },
+        )
+      end
+
+      expect { export.call(nil) }.to(raise_error(Schwab::ServerError))
+      expect(checkpoints).to(eq([[Date.new(2023, 1, 1), 1]]))
+
+      allow(client).to(receive(:get)
+        .with(path, { types: "TRADE", startDate: "2023-01-01", endDate: "2024-01-01" }, Schwab::Resources::Transaction)
+        .and_return([{ "activityId" => 2 }]))
+      allow(client).to(receive(:get)
+        .with(path, { types: "TRADE", startDate: "2024-01-01", endDate: "2024-06-30" }, Schwab::Resources::Transaction)
+        .and_return([{ "activityId" => 3 }]))
+
+      result = export.call(checkpoints.last.first)
+
+      expect(result.map { |transaction| transaction["activityId"] }).to(eq([2, 3]))
+      expect(checkpoints.drop(1)).to(eq([[Date.new(2024, 1, 1), 1], [Date.new(2024, 6, 30), 1]]))
+    end
+
+    it "rejects a checkpoint after the end date" do
+      expect { described_class.get_transactions(account_number, end_date: "2024-01-31", resume_from: "2024-02-01") }
+        .to(raise_error(ArgumentError, /resume_from/))
+    end
+
+    it "fetches windows ahead with prefetch while keeping them in order" do
+      started = Queue.new
+      release = Queue.new
+      allow(client).to(receive(:get)) do |_path, params, _resource_class|
+        started << params[:startDate]
+        release.pop if params[:startDate] == "2022-01-01"
+        [{ "activityId" => params[:startDate] }]
+      end
+      chunks = []
+
+      export = Thread.new do
+        described_class.get_transactions(
+          account_number,
+          start_date: Date.new(2022, 1, 1),
+          end_date: Date.new(2024, 6, 30),
+          prefetch: 2,
+          on_chunk: ->(checkpoint, _chunk) { chunks << checkpoint },
+        )
+      end
+      # Every window is requested while the first is still waiting for its response
+      fetched = Array.new(3) { started.pop }
+      release << true
+
+      expect(fetched).to(contain_exactly("2022-01-01", "2023-01-01", "2024-01-01"))
+      expect(export.value.map { |transaction| transaction["activityId"] })
+        .to(eq(["2022-01-01", "2023-01-01", "2024-01-01"]))
+      expect(chunks).to(eq([Date.new(2023, 1, 1), Date.new(2024, 1, 1), Date.new(2024, 6, 30)]))
+    end
+
+    it "raises a prefetched window's error in turn and starts no more windows" do
+      allow(client).to(receive(:get)) do |_path, params, _resource_class|
+        raise Schwab::ServerError, "down" if params[:startDate] == "2022-01-01"
+
+        [{ "activityId" => params[:startDate] }]
+      end
+      chunks = []
+
+      expect do
+        described_class.get_transactions(
+          account_number,
+          start_date: Date.new(2021, 1, 1),
+          end_date: Date.new(2024, 6, 30),
+          prefetch: 1,
+          on_chunk: ->(checkpoint, _chunk) { chunks << checkpoint },
+        )
+      end.to(raise_error(Schwab::ServerError))
+      expect(chunks).to(eq([Date.new(2022, 1, 1)]))
+      expect(client).not_to(have_received(:get).with(anything, hash_including(startDate: "2024-01-01"), anything))
+    end
+
+    it "keeps the caller's labels and response captures for prefetched windows" do
+      labels = Queue.new
+      allow(client).to(receive(:get)) do |_path, params, _resource_class|
+        labels << Schwab.current_labels
+        Schwab::Response.record(instance_double(Faraday::Response))
+        [{ "activityId" => params[:startDate] }]
+      end
+
+      response = Schwab.with_labels(job: "export") do
+        Schwab.with_response do
+          described_class.get_transactions(
+            account_number,
+            start_date: Date.new(2023, 1, 1),
+            end_date: Date.new(2024, 6, 30),
+            prefetch: 1,
+          )
+        end
+      end
+
+      expect(Array.new(2) { labels.pop }).to(all(eq({ job: "export" })))
+      expect(response.http_responses.size).to(eq(2))
+    end
+
+    it "rejects a negative prefetch" do
+      expect { described_class.get_transactions(account_number, prefetch: -1) }
+        .to(raise_error(ArgumentError, /prefetch/))
+    end
+
+    it "uses the configured window size" do
+      expect(client).to(receive(:get).twice.and_return([]))
+
+      described_class.get_transactions(
+        account_number,
+        start_date: "2024-01-01",
+        end_date: "2024-03-01",
+        window_days: 30,
+      )
+    end
+  end
+
+  describe ".get_balance_history" do
+    let(:today) { Date.new(2024, 3, 10) }
+    let(:account_response) { { "securitiesAccount" => { "currentBalances" => { "cashBalance" => 1000.0 } } } }
+    let(:transactions_response) do
+      [
+        { "activityId" => 1, "time" => "2024-03-06T14:30:00+0000", "netAmount" => -250.0 },
+        { "activityId" => 2, "time" => "2024-03-08T14:30:00+0000", "netAmount" => 100.0 },
+        { "activityId" => 3, "tradeDate" => "2024-03-09", "netAmount" => 50.0 },
+      ]
+    end
+
+    before do
+      allow(Date).to(receive(:today).and_return(today))
+      allow(client).to(receive(:get)
+        .with("/trader/v1/accounts/#{encrypted_account}", {}, Schwab::Resources::Account)
+        .and_return(account_response))
+      allow(client).to(receive(:get)
+        .with("/trader/v1/accounts/#{encrypted_account}/transactions", anything, Schwab::Resources::Transaction)
+        .and_return(transactions_response))
+    end
+
+    it "reconstructs daily cash balances from transactions" do
+      history = described_class.get_balance_history(account_number, start_date: "2024-03-05", end_date: "2024-03-08")
+
+      expect(history.map(&:date)).to(eq((Date.new(2024, 3, 5)..Date.new(2024, 3, 8)).to_a))
+      expect(history.map(&:cash_balance)).to(eq([1100.0, 850.0, 850.0, 950.0]))
+      expect(history.map(&:net_change)).to(eq([0.0, -250.0, 0.0, 100.0]))
+    end
+
+    it "requests cash-affecting transactions from the start date through today" do
+      described_class.get_balance_history(account_number, start_date: "2024-03-05", end_date: "2024-03-08")
+
+      expect(client).to(have_received(:get).with(
+        "/trader/v1/accounts/#{encrypted_account}/transactions",
+        hash_including(startDate: "2024-03-05", endDate: "2024-03-10", types: include("TRADE,")),
+        Schwab::Resources::Transaction,
+      ))
+    end
+
+    it "rejects inverted and future ranges" do
+      expect { described_class.get_balance_history(account_number, start_date: "2024-03-08", end_date: "2024-03-05") }
+        .to(raise_error(ArgumentError, /start_date/))
+      expect { described_class.get_balance_history(account_number, start_date: "2024-03-05", end_date: "2024-03-11") }
+        .to(raise_error(ArgumentError, /future/))
+    end
+  end
+
+  describe ".get_realized_gains" do
+    let(:transactions_response) do
+      [
+        {
+          "type" => "TRADE",
+          "tradeDate" => "2023-02-01",
+          "netAmount" => -1000.0,
+          "transferItems" => [{ "instrument" => { "symbol" => "AAPL", "assetType" => "EQUITY" }, "amount" => 10 }],
+        },
+        {
+          "type" => "TRADE",
+          "tradeDate" => "2024-02-01",
+          "netAmount" => 1200.0,
+          "transferItems" => [{ "instrument" => { "symbol" => "AAPL", "assetType" => "EQUITY" }, "amount" => -10 }],
+        },
+      ]
+    end
+
+    before do
+      allow(Date).to(receive(:today).and_return(Date.new(2024, 6, 1)))
+      allow(client).to(receive(:get)
+        .with("/trader/v1/accounts/#{encrypted_account}/transactions", anything, Schwab::Resources::Transaction)
+        .and_return(transactions_response))
+    end
+
+    it "matches trades fetched through the end of the year, capped at today" do
+      lots = described_class.get_realized_gains(account_number, year: 2024, since: "2024-01-01")
+
+      expect(lots.map(&:gain)).to(eq([200.0]))
+      expect(client).to(have_received(:get).with(
+        "/trader/v1/accounts/#{encrypted_account}/transactions",
+        hash_including(startDate: "2024-01-01", endDate: "2024-06-01", types: "TRADE"),
+        Schwab::Resources::Transaction,
+      ))
+    end
+
+    it "rejects unknown methods and future years" do
+      expect { described_class.get_realized_gains(account_number, year: 2024, method: :average) }
+        .to(raise_error(ArgumentError, /average/))
+      expect { described_class.get_realized_gains(account_number, year: 2025) }.to(raise_error(ArgumentError, /future/))
+    end
+  end
+
+  describe ".get_lots" do
+    let(:transactions_response) do
+      [
+        {
+          "activityId" => 11,
+          "type" => "TRADE",
+          "tradeDate" => "2024-02-01",
+          "netAmount" => -1000.0,
+          "transferItems" => [{ "instrument" => { "symbol" => "AAPL", "assetType" => "EQUITY" }, "amount" => 10 }],
+        },
+      ]
+    end
+
+    before do
+      allow(Date).to(receive(:today).and_return(Date.new(2024, 6, 1)))
+      allow(client).to(receive(:get)
+        .with("/trader/v1/accounts/#{encrypted_account}/transactions", anything, Schwab::Resources::Transaction)
+        .and_return(transactions_response))
+    end
+
+    it "rebuilds open lots from the symbol's trades" do
+      lots = described_class.get_lots(account_number, "aapl", since: "2024-01-01")
+
+      expect(lots.map(&:to_h)).to(eq([
+        { id: "11", symbol: "AAPL", quantity: 10.0, open_date: Date.new(2024, 2, 1), cost_basis: 1000.0 },
+      ]))
+      expect(client).to(have_received(:get).with(
+        "/trader/v1/accounts/#{encrypted_account}/transactions",
+        hash_including(startDate: "2024-01-01", endDate: "2024-06-01", types: "TRADE", symbol: "AAPL"),
+        Schwab::Resources::Transaction,
+      ))
+    end
+  end
+
+  describe ".get_returns" do
+    let(:account_response) { { "securitiesAccount" => { "currentBalances" => { "liquidationValue" => 2400.0 } } } }
+    let(:transactions_response) do
+      [
+        { "activityId" => 1, "type" => "ACH_RECEIPT", "time" => "2024-07-01T14:30:00+0000", "netAmount" => 1000.0 },
+      ]
+    end
+
+    before do
+      allow(Date).to(receive(:today).and_return(Date.new(2024, 12, 31)))
+      allow(client).to(receive(:get)
+        .with("/trader/v1/accounts/#{encrypted_account}", {}, Schwab::Resources::Account)
+        .and_return(account_response))
+      allow(client).to(receive(:get)
+        .with("/trader/v1/accounts/#{encrypted_account}/transactions", anything, Schwab::Resources::Transaction)
+        .and_return(transactions_response))
+    end
+
+    it "computes returns from external flows, ending at the current liquidation value" do
+      report = described_class.get_returns(
+        account_number,
+        start_date: "2024-01-01",
+        start_value: 1000.0,
+        valuations: { Date.new(2024, 7, 1) => 1600.0 },
+      )
+
+      expect(report.end_value).to(eq(2400.0))
+      expect(report.net_cash_flow).to(eq(1000.0))
+      expect(report.time_weighted_return).to(be_within(1e-6).of(-0.1))
+      expect(report.money_weighted_return).to(be_within(1e-5).of(0.27186))
+      expect(client).to(have_received(:get).with(
+        "/trader/v1/accounts/#{encrypted_account}/transactions",
+        hash_including(startDate: "2024-01-01", endDate: "2024-12-31", types: include("ACH_RECEIPT")),
+        Schwab::Resources::Transaction,
+      ))
+    end
+
+    it "requires an end value for past end dates" do
+      expect do
+        described_class.get_returns(account_number, start_date: "2024-01-01", start_value: 1000.0, end_date: "2024-06-30")
+      end.to(raise_error(ArgumentError, /end_value/))
+    end
+
+    it "rejects empty and future ranges" do
+      expect { described_class.get_returns(account_number, start_date: "2024-12-31", start_value: 1000.0) }
+        .to(raise_error(ArgumentError, /start_date/))
+      expect do
+        described_class.get_returns(account_number, start_date: "2024-01-01", start_value: 1000.0, end_date: "2025-01-01")
+      end.to(raise_error(ArgumentError, /future/))
     end
   end
 
@@ -260,16 +1082,34 @@ RSpec.describe(Schwab::Accounts) do
       expect(result).to(eq(orders_response))
     end
 
-    it "applies filters when specified" do
+    it "sends a single status filter to the API" do
       expect(client).to(receive(:get)
-        .with(
-          "/trader/v1/orders",
-          { status: "WORKING,PENDING_ACTIVATION" },
-          Schwab::Resources::Order,
-        )
+        .with("/trader/v1/orders", { status: "WORKING" }, Schwab::Resources::Order)
         .and_return(orders_response))
 
-      described_class.get_all_orders(status: ["WORKING", "PENDING_ACTIVATION"])
+      described_class.get_all_orders(status: ["working"])
+    end
+
+    it "filters multiple statuses client-side" do
+      mixed_orders = [
+        { orderId: "1", status: "WORKING" },
+        { orderId: "2", status: "FILLED" },
+        { orderId: "3", status: "PENDING_ACTIVATION" },
+      ]
+      expect(client).to(receive(:get)
+        .with("/trader/v1/orders", {}, Schwab::Resources::Order)
+        .and_return(mixed_orders))
+
+      result = described_class.get_all_orders(status: ["WORKING", "PENDING_ACTIVATION"])
+      expect(result.map { |order| order[:orderId] }).to(eq(["1", "3"]))
+    end
+
+    it "accepts the open status set" do
+      mixed_orders = [{ orderId: "1", status: "QUEUED" }, { orderId: "2", status: "CANCELED" }]
+      allow(client).to(receive(:get).and_return(mixed_orders))
+
+      result = described_class.get_all_orders(status: Schwab::Resources::Order::OPEN_STATUSES)
+      expect(result.map { |order| order[:orderId] }).to(eq(["1"]))
     end
   end
 
@@ -343,6 +1183,35 @@ RSpec.describe(Schwab::Accounts) do
       result = described_class.preview_order(account_number, order_data)
       expect(result).to(eq(preview_response))
     end
+
+    it "rounds leg quantities when quantity rounding is configured" do
+      config.quantity_rounding = :down
+      order_data[:orderLegCollection].first[:quantity] = 10.8
+      rounded_leg = hash_including(quantity: 10)
+
+      expect(client).to(receive(:post)
+        .with(
+          "/trader/v1/accounts/#{encrypted_account}/previewOrder",
+          hash_including(orderLegCollection: [rounded_leg]),
+        )
+        .and_return(preview_response))
+
+      described_class.preview_order(account_number, order_data)
+    end
+
+    it "keeps decimals for configured fractional-enabled symbols" do
+      config.quantity_rounding = :down
+      config.fractional_symbols = ["AAPL"]
+      order_data[:orderLegCollection].first[:quantity] = 0.5
+
+      expect(client).to(receive(:post)
+        .with("/trader/v1/accounts/#{encrypted_account}/previewOrder", hash_including(orderLegCollection: [
+          hash_including(quantity: 0.5),
+        ]))
+        .and_return(preview_response))
+
+      described_class.preview_order(account_number, order_data)
+    end
   end
 
   describe ".get_account_numbers" do
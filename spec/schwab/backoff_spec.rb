@@ -0,0 +1,61 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "schwab/backoff"
+
+RSpec.describe(Schwab::Backoff) do
+  def response(headers = {})
+    instance_double(Faraday::Response, headers: Faraday::Utils::Headers.new(headers))
+  end
+
+  describe Schwab::Backoff::Constant do
+    it "waits the same delay every time" do
+      strategy = described_class.new(2)
+
+      expect([1, 2, 5].map { |attempt| strategy.next_delay(attempt, nil) }).to(eq([2, 2, 2]))
+    end
+  end
+
+  describe Schwab::Backoff::ExponentialJitter do
+    it "draws a random delay below an exponentially growing cap" do
+      random = instance_double(Random, rand: 0.5)
+      strategy = described_class.new(base: 1, factor: 2, max: 10, random: random)
+
+      expect([1, 2, 3, 4, 5].map { |attempt| strategy.next_delay(attempt, nil) }).to(eq([0.5, 1.0, 2.0, 4.0, 5.0]))
+    end
+
+    it "stays within the cap" do
+      strategy = described_class.new(base: 1, max: 4)
+
+      delays = Array.new(50) { strategy.next_delay(3, nil) }
+      expect(delays).to(all(be_between(0, 4)))
+    end
+  end
+
+  describe Schwab::Backoff::RetryAfter do
+    let(:fallback) { Schwab::Backoff::Constant.new(7) }
+    let(:strategy) { described_class.new(fallback: fallback) }
+
+    it "waits the seconds in the Retry-After header" do
+      expect(strategy.next_delay(1, response("Retry-After" => "3"))).to(eq(3))
+    end
+
+    it "waits until a Retry-After date" do
+      delay = strategy.next_delay(1, response("Retry-After" => (Time.now + 10).httpdate))
+
+      expect(delay).to(be_within(1.5).of(10))
+    end
+
+    it "uses the fallback without a usable header or response" do
+      expect(strategy.next_delay(1, response)).to(eq(7))
+      expect(strategy.next_delay(1, response("Retry-After" => "soon"))).to(eq(7))
+      expect(strategy.next_delay(1, nil)).to(eq(7))
+    end
+
+    it "caps long waits" do
+      capped = described_class.new(fallback: fallback, max: 5)
+
+      expect(capped.next_delay(1, response("Retry-After" => "120"))).to(eq(5))
+    end
+  end
+end
@@ -2,6 +2,7 @@
 
 require "spec_helper"
 require "schwab/client"
+require "tempfile"
 
 RSpec.describe(Schwab::Client) do
   let(:access_token) { "test_access_token" }
@@ -53,6 +54,124 @@ RSpec.describe(Schwab::Client) do
     end
   end
 
+  describe ".validated" do
+    it "builds a client from a usable configuration" do
+      client = described_class.validated(access_token: access_token, config: config)
+
+      expect(client.access_token).to(eq(access_token))
+      expect(client.config).to(eq(config))
+    end
+
+    it "rejects blank credentials and a malformed base URL with every problem listed" do
+      config.client_id = ""
+      config.client_secret = nil
+      config.api_base_url = "api.test.com"
+
+      expect { described_class.validated(access_token: access_token, config: config) }
+        .to(raise_error(Schwab::InvalidConfigurationError) do |error|
+          expect(error.errors).to(eq([
+            "client_id is blank",
+            "client_secret is blank",
+            "api_base_url is not an absolute http(s) URL: \"api.test.com\"",
+          ]))
+        end)
+    end
+
+    it "rejects a base URL with a query string" do
+      config.api_base_url = "https://gw.example.com/external/schwab/?tenant=1"
+
+      expect { described_class.validated(access_token: access_token, config: config) }
+        .to(raise_error(Schwab::InvalidConfigurationError, /must not include a query or fragment/))
+    end
+
+    it "warns through the logger when the plain constructor gets blank credentials" do
+      output = StringIO.new
+      config.logger = Logger.new(output)
+      config.client_secret = " "
+
+      described_class.new(access_token: access_token, config: config)
+
+      expect(output.string).to(include("WARN"))
+      expect(output.string).to(include("blank client_secret; token refresh will fail"))
+    end
+  end
+
+  describe ".from_env" do
+    let(:env) do
+      {
+        "SCHWAB_CLIENT_ID" => "env_id",
+        "SCHWAB_CLIENT_SECRET" => "env_secret",
+        "SCHWAB_REFRESH_TOKEN" => refresh_token,
+      }
+    end
+
+    it "builds a client from environment variables" do
+      client = described_class.from_env(env: env, config: config)
+
+      expect(client.config.client_id).to(eq("env_id"))
+      expect(client.config.client_secret).to(eq("env_secret"))
+      expect(client.refresh_token).to(eq(refresh_token))
+      expect(client.auto_refresh).to(be(true))
+      expect(config.client_id).to(eq("test_client_id"))
+    end
+
+    it "lists every missing required variable" do
+      expect { described_class.from_env(env: { "SCHWAB_REFRESH_TOKEN" => refresh_token }) }
+        .to(raise_error(Schwab::MissingCredentialsError) do |error|
+          expect(error.missing).to(eq(["SCHWAB_CLIENT_ID", "SCHWAB_CLIENT_SECRET"]))
+          expect(error.message).to(include("SCHWAB_CLIENT_ID, SCHWAB_CLIENT_SECRET"))
+        end)
+    end
+
+    it "falls back to the credentials file for values not in the environment" do
+      Tempfile.create(["credentials", ".json"]) do |file|
+        file.write(JSON.generate({ client_id: "file_id", client_secret: "file_secret", access_token: "file_token" }))
+        file.flush
+
+        client = described_class.from_env(
+          env: { "SCHWAB_CLIENT_ID" => "env_id", "SCHWAB_CREDENTIALS_FILE" => file.path },
+          config: config,
+        )
+
+        expect(client.config.client_id).to(eq("env_id"))
+        expect(client.config.client_secret).to(eq("file_secret"))
+        expect(client.access_token).to(eq("file_token"))
+        expect(client.auto_refresh).to(be(false))
+      end
+    end
+
+    it "skips a saved access token whose expiry has passed" do
+      Tempfile.create(["credentials", ".json"]) do |file|
+        file.write(JSON.generate({
+          client_id: "file_id",
+          client_secret: "file_secret",
+          refresh_token: refresh_token,
+          access_token: "saved_token",
+          issued_at: (Time.now - 1900).iso8601,
+          expires_in: 1800,
+        }))
+        file.flush
+
+        client = described_class.from_env(env: { "SCHWAB_CREDENTIALS_FILE" => file.path }, config: config)
+
+        expect(client.access_token).to(be_nil)
+        expect(client.refresh_token).to(eq(refresh_token))
+      end
+    end
+
+    it "fetches an access token before the first request when only a refresh token is set" do
+      allow(Schwab::OAuth).to(receive(:refresh_token).and_return({ access_token: "fresh_token" }))
+      stub_request(:get, "https://api.test.com/test")
+        .with(headers: { "Authorization" => "Bearer fresh_token" })
+        .to_return(status: 200, body: "{}", headers: { "Content-Type" => "application/json" })
+
+      described_class.from_env(env: env, config: config).get("/test")
+
+      expect(Schwab::OAuth).to(have_received(:refresh_token)
+        .with(hash_including(refresh_token: refresh_token, client_id: "env_id", client_secret: "env_secret")))
+    end
+  end
+
   describe "#connection" do
     let(:client) { described_class.new(access_token: access_token, config: config) }
 
@@ -169,6 +288,63 @@ RSpec.describe(Schwab::Client) do
         expect(result).to(eq(response_body))
       end
     end
+
+    describe "#raw_json_request" do
+      it "returns the body exactly as sent along with the response" do
+        raw = '{"data":  "test", "newField": [1, 2]}'
+        stub_request(:get, "https://api.test.com/trader/v1/newEndpoint?fields=all")
+          .to_return(status: 200, body: raw, headers: { "Content-Type" => "application/json" })
+
+        json, response = client.raw_json_request(:get, "/trader/v1/newEndpoint", { fields: "all" })
+
+        expect(json).to(eq(raw))
+        expect(response.status).to(eq(200))
+      end
+
+      it "returns nil for an empty body" do
+        stub_request(:delete, "https://api.test.com/trader/v1/thing").to_return(status: 204, body: "")
+
+        json, response = client.raw_json_request(:delete, "/trader/v1/thing")
+
+        expect(json).to(be_nil)
+        expect(response.status).to(eq(204))
+      end
+
+      it "classifies errors like the other request methods" do
+        stub_request(:get, "https://api.test.com/trader/v1/missing").to_return(status: 404, body: "Not Found")
+
+        expect { client.raw_json_request(:get, "/trader/v1/missing") }.to(raise_error(Schwab::NotFoundError))
+      end
+    end
+  end
+
+  describe "with a gateway base URL" do
+    before do
+      stub_request(:get, "https://gw.example.com/external/schwab/marketdata/v1/quotes")
+        .with(query: { symbols: "AAPL" })
+        .to_return(status: 200, body: "{}", headers: { "Content-Type" => "application/json" })
+    end
+
+    ["https://gw.example.com/external/schwab/", "https://gw.example.com/external/schwab"].each do |base_url|
+      it "resolves request paths beneath the prefix of #{base_url}" do
+        config.api_base_url = base_url
+        client = described_class.new(access_token: access_token, config: config)
+
+        client.get("/marketdata/v1/quotes", { symbols: "AAPL" })
+
+        expect(WebMock).to(have_requested(:get, "https://gw.example.com/external/schwab/marketdata/v1/quotes")
+          .with(query: { symbols: "AAPL" }))
+      end
+    end
+
+    it "groups prefixed requests for endpoint limits" do
+      config.api_base_url = "https://gw.example.com/external/schwab/"
+      config.endpoint_limit(:marketdata, rps: 1, burst: 1)
+      client = described_class.new(access_token: access_token, config: config)
+      expect(config.endpoint_limiter).to(receive(:acquire).with(:get, "/marketdata/v1/quotes").and_call_original)
+
+      client.get("/marketdata/v1/quotes", { symbols: "AAPL" })
+    end
   end
 
   describe "#update_access_token" do
@@ -267,6 +443,35 @@ RSpec.describe(Schwab::Client) do
       end
     end
 
+    context "with an error classifier" do
+      before do
+        stub_request(:get, "https://api.test.com/test")
+          .to_return(status: 409, body: { message: "Slow down" }.to_json, headers: { "Content-Type" => "application/json" })
+      end
+
+      it "maps a gateway's 409 to RateLimitError" do
+        config.error_classifier = ->(status, _body, _headers) { Schwab::RateLimitError if status == 409 }
+
+        expect { client.get("/test") }.to(raise_error(Schwab::RateLimitError) do |error|
+          expect(error.status).to(eq(409))
+          expect(error.error_message).to(eq("Slow down"))
+        end)
+      end
+
+      it "raises an exception the classifier returns as is" do
+        custom = Schwab::Error.new("gateway conflict")
+        config.error_classifier = ->(_status, body, _headers) { custom if body.include?("Slow") }
+
+        expect { client.get("/test") }.to(raise_error(custom))
+      end
+
+      it "falls through to the default handling when the classifier returns nil" do
+        config.error_classifier = ->(_status, _body, _headers) {}
+
+        expect { client.get("/test") }.to(raise_error(Schwab::Error, /Request failed/))
+      end
+    end
+
     context "when API returns 500" do
       before do
         stub_request(:get, "https://api.test.com/test")
@@ -278,6 +483,119 @@ RSpec.describe(Schwab::Client) do
       end
     end
 
+    context "when API returns 409" do
+      before do
+        stub_request(:get, "https://api.test.com/test")
+          .to_return(
+            status: 409,
+            body: File.read(File.expand_path("../fixtures/errors/order_conflict.json", __dir__)),
+            headers: { "Content-Type" => "application/json" },
+          )
+      end
+
+      it "raises ConflictError, distinct from a bad request" do
+        expect { client.get("/test") }.to(raise_error(Schwab::ConflictError) do |error|
+          expect(error).not_to(be_a(Schwab::BadRequestError))
+          expect(error.status).to(eq(409))
+          expect(error.error_message).to(eq("Order cannot be canceled in its current state."))
+        end)
+      end
+    end
+
+    context "when API returns problem+json" do
+      before do
+        stub_request(:get, "https://api.test.com/test")
+          .to_return(
+            status: 400,
+            body: File.read(File.expand_path("../fixtures/errors/problem.json", __dir__)),
+            headers: { "Content-Type" => "application/problem+json" },
+          )
+      end
+
+      it "exposes the problem details" do
+        expect { client.get("/test") }.to(raise_error(Schwab::BadRequestError) do |error|
+          expect(error).to(be_problem)
+          expect(error.status).to(eq(400))
+          expect(error.title).to(eq("Bad Request"))
+          expect(error.detail).to(eq("Symbol 'ZZZZZ' is not valid"))
+          expect(error.instance).to(eq("/marketdata/v1/quotes"))
+        end)
+      end
+    end
+
+    context "when API returns a plain JSON error" do
+      before do
+        stub_request(:get, "https://api.test.com/test")
+          .to_return(
+            status: 400,
+            body: { message: "Invalid order", code: "ORDER_INVALID", errors: ["Price is required"] }.to_json,
+            headers: { "Content-Type" => "application/json" },
+          )
+      end
+
+      it "falls back to the message and code" do
+        expect { client.get("/test") }.to(raise_error(Schwab::BadRequestError) do |error|
+          expect(error).not_to(be_problem)
+          expect(error.error_message).to(eq("Invalid order"))
+          expect(error.error_code).to(eq("ORDER_INVALID"))
+          expect(error.errors).to(eq(["Price is required"]))
+          expect(error.title).to(be_nil)
+        end)
+      end
+    end
+
+    context "when Schwab rejects an order because the market is closed" do
+      before do
+        stub_request(:get, "https://api.test.com/test")
+          .to_return(
+            status: 400,
+            body: File.read(File.expand_path("../fixtures/errors/market_closed.json", __dir__)),
+            headers: { "Content-Type" => "application/json" },
+          )
+      end
+
+      it "raises MarketClosedError, which is still a BadRequestError" do
+        expect { client.get("/test") }.to(raise_error(Schwab::MarketClosedError) do |error|
+          expect(error).to(be_a(Schwab::BadRequestError))
+          expect(error.status).to(eq(400))
+          expect(error.errors.first).to(include("market is closed"))
+        end)
+      end
+
+      it "recognizes the rejection by its code" do
+        stub_request(:get, "https://api.test.com/test")
+          .to_return(status: 400, body: { message: "Rejected", code: "MARKET_CLOSED" }.to_json)
+
+        expect { client.get("/test") }.to(raise_error(Schwab::MarketClosedError))
+      end
+
+      it "recognizes the rejections Order#reject_reason classifies as market closed" do
+        stub_request(:get, "https://api.test.com/test")
+          .to_return(status: 400, body: { message: "The trading session is not open" }.to_json)
+
+        expect { client.get("/test") }.to(raise_error(Schwab::MarketClosedError))
+      end
+    end
+
+    context "when API returns an errors array of problem details" do
+      before do
+        stub_request(:get, "https://api.test.com/test")
+          .to_return(
+            status: 404,
+            body: { errors: [{ status: "404", title: "Not Found", detail: "No quote for ZZZZZ" }] }.to_json,
+            headers: { "Content-Type" => "application/json" },
+          )
+      end
+
+      it "reads the details from the first entry" do
+        expect { client.get("/test") }.to(raise_error(Schwab::NotFoundError) do |error|
+          expect(error.status).to(eq(404))
+          expect(error.title).to(eq("Not Found"))
+          expect(error.detail).to(eq("No quote for ZZZZZ"))
+        end)
+      end
+    end
+
     context "when request times out" do
       before do
         stub_request(:get, "https://api.test.com/test").to_timeout
@@ -289,6 +607,33 @@ RSpec.describe(Schwab::Client) do
     end
   end
 
+  describe "unmapped key reporting" do
+    let(:reports) { [] }
+    let(:client) do
+      config.on_unmapped_keys = ->(endpoint, keys) { reports << [endpoint, keys] }
+      described_class.new(access_token: access_token, config: config)
+    end
+
+    before do
+      stub_request(:get, "https://api.test.com/orders")
+        .to_return(
+          status: 200,
+          body: [{ orderId: 1, status: "FILLED", netChange: 1.5 }].to_json,
+          headers: { "Content-Type" => "application/json" },
+        )
+    end
+
+    it "reports keys the resource class does not declare" do
+      client.get("/orders", {}, Schwab::Resources::Order)
+      expect(reports).to(eq([["/orders", ["netChange"]]]))
+    end
+
+    it "does not report for untyped requests" do
+      client.get("/orders")
+      expect(reports).to(be_empty)
+    end
+  end
+
   describe "token refresh callback" do
     let(:callback_spy) { double("callback", call: nil) }
     let(:client) do
@@ -317,6 +662,143 @@ RSpec.describe(Schwab::Client) do
     end
   end
 
+  describe "#update_credentials" do
+    let(:client) do
+      described_class.new(access_token: access_token, refresh_token: refresh_token, config: config)
+    end
+
+    it "re-authenticates with the new credentials before the next request" do
+      allow(Schwab::OAuth).to(receive(:refresh_token).and_return({ access_token: "rotated_token" }))
+      stub_request(:get, "https://api.test.com/test")
+        .with(headers: { "Authorization" => "Bearer rotated_token" })
+        .to_return(status: 200, body: "{}", headers: { "Content-Type" => "application/json" })
+
+      client.update_credentials(client_id: "new_id", client_secret: "new_secret")
+      client.get("/test")
+
+      expect(Schwab::OAuth).to(have_received(:refresh_token)
+        .with(hash_including(refresh_token: refresh_token, client_id: "new_id", client_secret: "new_secret")).once)
+      expect(client.access_token).to(eq("rotated_token"))
+    end
+
+    it "does not change the shared configuration" do
+      client.update_credentials(client_id: "new_id", client_secret: "new_secret")
+
+      expect(client.config.client_id).to(eq("new_id"))
+      expect(config.client_id).to(eq("test_client_id"))
+    end
+
+    it "never exposes a half-updated credential pair" do
+      client.update_credentials(client_id: "id_initial", client_secret: "secret_initial")
+      mismatches = Queue.new
+      readers = Array.new(4) do
+        Thread.new do
+          500.times do
+            current = client.config
+            id = current.client_id.delete_prefix("id_")
+            mismatches << [current.client_id, current.client_secret] unless current.client_secret == "secret_#{id}"
+          end
+        end
+      end
+
+      200.times { |i| client.update_credentials(client_id: "id_#{i}", client_secret: "secret_#{i}") }
+      readers.each(&:join)
+
+      expect(mismatches).to(be_empty)
+    end
+
+    it "rejects blank credentials" do
+      expect { client.update_credentials(client_id: "", client_secret: "secret") }.to(raise_error(ArgumentError))
+    end
+  end
+
+  describe "#close" do
+    let(:client) { described_class.new(access_token: nil, refresh_token: refresh_token, config: config) }
+
+    it "cancels a hanging authentication promptly" do
+      allow(Schwab::OAuth).to(receive(:refresh_token) { sleep(10) })
+      request = Thread.new do
+        client.get("/test")
+      rescue Schwab::Error => e
+        e
+      end
+      wait_for { client.authenticating? }
+
+      started = Process.clock_gettime(Process::CLOCK_MONOTONIC)
+      client.close
+      error = request.value
+
+      expect(Process.clock_gettime(Process::CLOCK_MONOTONIC) - started).to(be < 1)
+      expect(error).to(be_a(Schwab::ClientClosedError))
+      expect(client.authenticating?).to(be(false))
+    end
+
+    it "rejects requests after closing" do
+      client.close
+
+      expect(client.closed?).to(be(true))
+      expect { client.get("/test") }.to(raise_error(Schwab::ClientClosedError))
+    end
+
+    it "passes auth_timeout to token requests" do
+      config.auth_timeout = 2
+      oauth_client = Schwab::OAuth.send(:oauth2_client, client_id: "id", client_secret: "secret", config: config)
+
+      expect(oauth_client.options[:connection_opts]).to(eq({ request: { timeout: 2, open_timeout: 2 } }))
+    end
+  end
+
+  describe "revoked token handling" do
+    let(:client) do
+      described_class.new(
+        access_token: access_token,
+        refresh_token: refresh_token,
+        auto_refresh: true,
+        config: config,
+      )
+    end
+
+    before do
+      allow(Schwab::OAuth).to(receive(:refresh_token)
+        .and_return({ access_token: "new_token", refresh_token: "new_refresh" }))
+    end
+
+    it "refreshes the token and retries once after a 401" do
+      stub_request(:get, "https://api.test.com/test")
+        .with(headers: { "Authorization" => "Bearer #{access_token}" })
+        .to_return(status: 401, body: "Unauthorized")
+      stub_request(:get, "https://api.test.com/test")
+        .with(headers: { "Authorization" => "Bearer new_token" })
+        .to_return(status: 200, body: { ok: true }.to_json, headers: { "Content-Type" => "application/json" })
+
+      expect(client.get("/test")).to(eq({ "ok" => true }))
+      expect(client.access_token).to(eq("new_token"))
+      expect(Schwab::OAuth).to(have_received(:refresh_token).once)
+    end
+
+    it "resends the original request body on retry" do
+      stub_request(:post, "https://api.test.com/orders")
+        .with(headers: { "Authorization" => "Bearer #{access_token}" })
+        .to_return(status: 401, body: "Unauthorized")
+      stub_request(:post, "https://api.test.com/orders")
+        .with(headers: { "Authorization" => "Bearer new_token" }, body: { symbol: "AAPL" }.to_json)
+        .to_return(status: 201, body: "")
+
+      client.post("/orders", { symbol: "AAPL" })
+
+      expect(WebMock).to(have_requested(:post, "https://api.test.com/orders")
+        .with(body: { symbol: "AAPL" }.to_json).twice)
+    end
+
+    it "surfaces the error when the refreshed token is also rejected" do
+      stub_request(:get, "https://api.test.com/test").to_return(status: 401, body: "Unauthorized")
+
+      expect { client.get("/test") }.to(raise_error(Schwab::AuthenticationError))
+      expect(Schwab::OAuth).to(have_received(:refresh_token).once)
+      expect(WebMock).to(have_requested(:get, "https://api.test.com/test").twice)
+    end
+  end
+
   describe "account number resolution" do
     let(:client) { described_class.new(access_token: access_token, config: config) }
 
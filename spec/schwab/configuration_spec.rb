@@ -49,6 +49,79 @@ RSpec.describe(Schwab::Configuration) do
     end
   end
 
+  describe "#normalize_symbol_case=" do
+    it "is enabled by default and can be turned off" do
+      config = described_class.new
+      expect(config.normalize_symbol_case).to(be(true))
+
+      config.normalize_symbol_case = false
+      expect(config.normalize_symbol_case).to(be(false))
+    end
+
+    it "raises ArgumentError for non-boolean values" do
+      config = described_class.new
+      expect { config.normalize_symbol_case = "yes" }.to(raise_error(ArgumentError, /normalize_symbol_case/))
+    end
+  end
+
+  describe "#fractional_symbols=" do
+    it "is empty by default and upper-cases the symbols given" do
+      config = described_class.new
+      expect(config.fractional_symbols).to(eq([]))
+
+      config.fractional_symbols = ["vti", "VOO"]
+      expect(config.fractional_symbols).to(eq(["VTI", "VOO"]))
+    end
+
+    it "accepts a callable and rejects anything else" do
+      config = described_class.new
+      lookup = ->(symbol) { symbol == "VTI" }
+      config.fractional_symbols = lookup
+
+      expect(config.fractional_symbols).to(be(lookup))
+      expect { config.fractional_symbols = "VTI" }.to(raise_error(ArgumentError, /fractional_symbols/))
+    end
+  end
+
+  describe "#max_order_notional=" do
+    it "has no cap by default and quotes unpriced orders" do
+      config = described_class.new
+      expect(config.max_order_notional).to(be_nil)
+      expect(config.quote_order_notional).to(be(true))
+    end
+
+    it "accepts a positive number or nil" do
+      config = described_class.new
+      config.max_order_notional = 50_000
+      expect(config.max_order_notional).to(eq(50_000))
+
+      config.max_order_notional = nil
+      expect(config.max_order_notional).to(be_nil)
+    end
+
+    it "raises ArgumentError for invalid values" do
+      config = described_class.new
+      expect { config.max_order_notional = 0 }.to(raise_error(ArgumentError, /max_order_notional/))
+      expect { config.max_order_notional = "50000" }.to(raise_error(ArgumentError, /max_order_notional/))
+      expect { config.quote_order_notional = nil }.to(raise_error(ArgumentError, /quote_order_notional/))
+    end
+  end
+
+  describe "#max_redirects=" do
+    it "defaults to following a few redirects" do
+      expect(described_class.new.max_redirects).to(eq(Schwab::Middleware::FollowRedirects::DEFAULT_MAX_REDIRECTS))
+    end
+
+    it "accepts zero and rejects negative or non-integer limits" do
+      config = described_class.new
+      config.max_redirects = 0
+
+      expect(config.max_redirects).to(eq(0))
+      expect { config.max_redirects = -1 }.to(raise_error(ArgumentError, /max_redirects/))
+      expect { config.max_redirects = 2.5 }.to(raise_error(ArgumentError, /max_redirects/))
+    end
+  end
+
   describe "#api_endpoint" do
     it "combines base URL and version" do
       config = described_class.new
@@ -64,6 +137,21 @@ RSpec.describe(Schwab::Configuration) do
     end
   end
 
+  describe "#api_base_path" do
+    it "is empty without a path prefix" do
+      expect(described_class.new.api_base_path).to(eq(""))
+    end
+
+    it "returns a gateway prefix without its trailing slash" do
+      config = described_class.new
+      config.api_base_url = "https://gw.example.com/external/schwab/"
+
+      expect(config.api_base_path).to(eq("/external/schwab"))
+      expect(config.api_endpoint).to(eq("https://gw.example.com/external/schwab/v1"))
+      expect(config.oauth_token_url).to(eq("https://gw.example.com/external/schwab/v1/oauth/token"))
+    end
+  end
+
   describe "#oauth_authorize_url" do
     it "returns the OAuth authorization endpoint" do
       config = described_class.new
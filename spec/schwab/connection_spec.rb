@@ -28,11 +28,8 @@ RSpec.describe(Schwab::Connection) do
         connection = described_class.build(config: config)
         middleware = connection.builder.handlers
 
-        # Check for request JSON encoder
-        expect(middleware).to(include(Faraday::Request::Json))
-
-        # Check for response JSON parser
-        expect(middleware).to(include(Faraday::Response::Json))
+        # Requests are encoded and responses parsed by the configured codec
+        expect(middleware).to(include(Schwab::Middleware::Codec))
       end
 
       it "includes error raising middleware" do
@@ -170,5 +167,15 @@ RSpec.describe(Schwab::Connection) do
       # Faraday handles that internally)
       expect(handlers).not_to(be_empty)
     end
+
+    it "follows redirects below authorization, and not at all when max_redirects is 0" do
+      handlers = described_class.build(access_token: "token", config: config).builder.handlers
+
+      expect(handlers.index(Schwab::Middleware::FollowRedirects)).to(be > handlers.index(Faraday::Request::Authorization))
+
+      config.max_redirects = 0
+      expect(described_class.build(access_token: "token", config: config).builder.handlers)
+        .not_to(include(Schwab::Middleware::FollowRedirects))
+    end
   end
 end
@@ -0,0 +1,70 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+
+RSpec.describe(Schwab::DuplicateOrderGuard) do
+  let(:order) do
+    {
+      orderType: "LIMIT",
+      price: 150.0,
+      orderLegCollection: [{ instruction: "BUY", quantity: 10, instrument: { symbol: "AAPL", assetType: "EQUITY" } }],
+    }
+  end
+
+  describe ".fingerprint" do
+    it "matches the same order regardless of key style and number format" do
+      same = {
+        "orderType" => "LIMIT",
+        "price" => "150.00",
+        "orderLegCollection" => [{ "instruction" => "buy", "quantity" => 10.0, "instrument" => { "symbol" => "aapl" } }],
+      }
+
+      expect(described_class.fingerprint("123", same)).to(eq(described_class.fingerprint("123", order)))
+    end
+
+    it "differs by account, price, quantity, side, and symbol" do
+      base = described_class.fingerprint("123", order)
+      leg = order[:orderLegCollection].first
+
+      expect(described_class.fingerprint("456", order)).not_to(eq(base))
+      expect(described_class.fingerprint("123", order.merge(price: 151.0))).not_to(eq(base))
+      [{ quantity: 11 }, { instruction: "SELL" }, { instrument: { symbol: "MSFT" } }].each do |change|
+        changed = order.merge(orderLegCollection: [leg.merge(change)])
+        expect(described_class.fingerprint("123", changed)).not_to(eq(base))
+      end
+    end
+  end
+
+  describe "#check!" do
+    let(:guard) { described_class.new(0.05) }
+    let(:fingerprint) { described_class.fingerprint("123", order) }
+
+    it "rejects a repeat within the window" do
+      guard.check!(fingerprint)
+
+      expect { guard.check!(fingerprint) }.to(raise_error(Schwab::DuplicateOrderError) do |error|
+        expect(error.fingerprint).to(eq(fingerprint))
+        expect(error.message).to(include("allow_duplicate: true"))
+      end)
+    end
+
+    it "evicts orders once the window has passed" do
+      guard.check!(fingerprint)
+      sleep(0.06)
+
+      expect(guard.size).to(eq(0))
+      expect { guard.check!(fingerprint) }.not_to(raise_error)
+    end
+
+    it "accepts an order again once forgotten" do
+      guard.check!(fingerprint)
+      guard.forget(fingerprint)
+
+      expect { guard.check!(fingerprint) }.not_to(raise_error)
+    end
+  end
+
+  it "rejects windows that are not positive" do
+    expect { described_class.new(0) }.to(raise_error(ArgumentError, /window/))
+  end
+end
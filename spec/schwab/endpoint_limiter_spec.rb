@@ -0,0 +1,95 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "schwab/connection"
+
+RSpec.describe(Schwab::EndpointLimiter) do
+  let(:now) { [100.0] }
+  let(:waits) { [] }
+  let(:limiter) { described_class.new(clock: -> { now[0] }, sleeper: ->(seconds) { waits << seconds }) }
+
+  describe ".group_for" do
+    it "sorts requests into endpoint groups" do
+      expect(described_class.group_for(:get, "/marketdata/v1/quotes")).to(eq("marketdata"))
+      expect(described_class.group_for(:post, "/trader/v1/accounts/ABC/orders")).to(eq("trading"))
+      expect(described_class.group_for(:put, "/trader/v1/accounts/ABC/orders/1001")).to(eq("trading"))
+      expect(described_class.group_for(:delete, "trader/v1/accounts/ABC/orders/1001")).to(eq("trading"))
+      expect(described_class.group_for(:post, "/trader/v1/accounts/ABC/previewOrder")).to(eq("trading"))
+      expect(described_class.group_for(:get, "/trader/v1/accounts/ABC/orders")).to(eq("account"))
+      expect(described_class.group_for(:get, "/trader/v1/accounts")).to(eq("account"))
+      expect(described_class.group_for(:post, "/v1/oauth/token")).to(be_nil)
+    end
+  end
+
+  describe "#limit" do
+    it "falls back to the default limits" do
+      limiter.limit(:marketdata)
+      limiter.limit("trading", rps: 0.5)
+
+      expect(limiter.limits).to(eq(
+        "marketdata" => { rps: 2.0, burst: 10 },
+        "trading" => { rps: 0.5, burst: 2 },
+      ))
+    end
+
+    it "rejects unknown groups and non-positive limits" do
+      expect { limiter.limit(:options) }.to(raise_error(ArgumentError, /Unknown endpoint group/))
+      expect { limiter.limit(:trading, rps: 0) }.to(raise_error(ArgumentError, /rps/))
+      expect { limiter.limit(:trading, burst: 1.5) }.to(raise_error(ArgumentError, /burst/))
+    end
+  end
+
+  describe "#acquire" do
+    before { limiter.limit(:marketdata, rps: 2, burst: 2) }
+
+    it "allows a burst and then spaces requests at the configured rate" do
+      waited = Array.new(4) { limiter.acquire(:get, "/marketdata/v1/quotes") }
+
+      expect(waited).to(eq([0.0, 0.0, 0.5, 1.0]))
+      expect(waits).to(eq([0.5, 1.0]))
+    end
+
+    it "refills over time" do
+      2.times { limiter.acquire(:get, "/marketdata/v1/quotes") }
+      now[0] += 1.0
+
+      expect(limiter.acquire(:get, "/marketdata/v1/quotes")).to(eq(0.0))
+    end
+
+    it "keeps groups independent" do
+      3.times { limiter.acquire(:get, "/marketdata/v1/quotes") }
+
+      expect(limiter.acquire(:post, "/trader/v1/accounts/ABC/orders")).to(eq(0.0))
+      expect(waits).to(eq([0.5]))
+    end
+
+    it "stops throttling a removed group" do
+      limiter.remove(:marketdata)
+
+      expect(Array.new(5) { limiter.acquire(:get, "/marketdata/v1/quotes") }.sum).to(eq(0.0))
+    end
+  end
+
+  describe "connection middleware" do
+    let(:config) do
+      Schwab::Configuration.new.tap do |c|
+        c.api_base_url = "https://api.test.com"
+        c.endpoint_limit(:marketdata, rps: 1, burst: 1)
+      end
+    end
+    let(:connection) { Schwab::Connection.build(access_token: "token", config: config) }
+
+    before do
+      stub_request(:get, "https://api.test.com/marketdata/v1/quotes")
+        .to_return(status: 200, body: "{}", headers: { "Content-Type" => "application/json" })
+    end
+
+    it "waits on the configured limiter before each request" do
+      expect(config.endpoint_limiter).to(receive(:acquire).with(:get, "/marketdata/v1/quotes").twice.and_call_original)
+      allow(config.endpoint_limiter).to(receive(:sleep))
+
+      connection.get("/marketdata/v1/quotes")
+      connection.get("/marketdata/v1/quotes")
+    end
+  end
+end
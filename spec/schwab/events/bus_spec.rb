@@ -0,0 +1,52 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+
+RSpec.describe(Schwab::Events::Bus) do
+  let(:bus) { described_class.new }
+
+  it "delivers every event to every subscriber" do
+    first = bus.subscribe
+    second = bus.subscribe
+
+    bus.publish(:one)
+    bus.publish(:two)
+
+    expect([first.pop, first.pop]).to(eq([:one, :two]))
+    expect([second.pop, second.pop]).to(eq([:one, :two]))
+  end
+
+  it "drops the oldest events once a subscriber's buffer is full" do
+    slow = bus.subscribe(buffer: 2)
+    fast = bus.subscribe(buffer: 10)
+
+    [1, 2, 3, 4].each { |event| bus.publish(event) }
+
+    expect(slow.dropped).to(eq(2))
+    expect([slow.pop, slow.pop]).to(eq([3, 4]))
+    expect(fast.dropped).to(eq(0))
+    expect(fast.size).to(eq(4))
+  end
+
+  it "stops delivering after cancel and wakes waiting consumers" do
+    subscription = bus.subscribe
+    waiter = Thread.new { subscription.pop }
+
+    wait_for { waiter.status == "sleep" }
+    subscription.cancel
+    bus.publish(:ignored)
+
+    expect(waiter.value).to(be_nil)
+    expect(bus.subscriber_count).to(eq(0))
+  end
+
+  it "returns nil when pop times out" do
+    expect(bus.subscribe.pop(timeout: 0.01)).to(be_nil)
+  end
+
+  it "is shared per client" do
+    client = Schwab::Client.new(access_token: "token")
+
+    expect(client.events).to(be(client.events))
+  end
+end
@@ -0,0 +1,89 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+
+RSpec.describe(Schwab::Events::OrderWatcher) do
+  let(:client) { instance_double("Schwab::Client") }
+  let(:bus) { Schwab::Events::Bus.new(client) }
+  let(:subscription) { bus.subscribe }
+  let(:watcher) { described_class.new("123456", bus: bus, client: client, interval: 60) }
+
+  def order(status, filled)
+    {
+      "orderId" => 42,
+      "status" => status,
+      "quantity" => 10,
+      "filledQuantity" => filled,
+      "price" => 150.0,
+      "orderActivityCollection" => [{ "executionLegs" => [{ "price" => 149.95, "quantity" => filled }] }],
+    }
+  end
+
+  def poll_with(*orders)
+    allow(Schwab::Accounts).to(receive(:get_orders).and_return(orders))
+    watcher.poll
+  end
+
+  it "records existing orders on the first poll without publishing" do
+    subscription
+    poll_with(order("WORKING", 0))
+
+    expect(subscription.size).to(eq(0))
+  end
+
+  it "publishes status changes and fills" do
+    subscription
+    poll_with(order("WORKING", 0))
+    poll_with(order("FILLED", 10))
+
+    status = subscription.pop
+    fill = subscription.pop
+
+    expect(status).to(have_attributes(order_id: 42, status: "FILLED", previous_status: "WORKING"))
+    expect(fill).to(be_a(Schwab::Events::FillEvent))
+    expect(fill).to(have_attributes(quantity: 10.0, filled_quantity: 10.0, price: 149.95))
+  end
+
+  it "publishes only the newly filled quantity on partial fills" do
+    subscription
+    poll_with(order("WORKING", 2))
+    poll_with(order("WORKING", 5))
+
+    fill = subscription.pop
+    expect(fill).to(have_attributes(quantity: 3.0, filled_quantity: 5.0, remaining_quantity: 5.0))
+    expect(fill).to(be_partial)
+    expect(fill.execution[:quantity]).to(eq(5))
+    expect(subscription.size).to(eq(0))
+  end
+
+  it "marks the final fill as complete" do
+    subscription
+    poll_with(order("WORKING", 5))
+    poll_with(order("FILLED", 10))
+    subscription.pop
+
+    expect(subscription.pop).not_to(be_partial)
+  end
+
+  it "publishes each fill level once, ignoring stale reads" do
+    subscription
+    poll_with(order("WORKING", 2))
+    poll_with(order("WORKING", 5))
+    poll_with(order("WORKING", 2))
+    poll_with(order("WORKING", 5))
+    poll_with(order("WORKING", 6))
+
+    expect([subscription.pop, subscription.pop].map(&:quantity)).to(eq([3.0, 1.0]))
+    expect(subscription.size).to(eq(0))
+  end
+
+  it "reports poll errors and keeps running" do
+    errors = Queue.new
+    allow(Schwab::Accounts).to(receive(:get_orders).and_raise(Schwab::ServerError, "down"))
+
+    watcher = bus.watch_orders("123456", interval: 60) { |error| errors << error }
+
+    expect(errors.pop).to(be_a(Schwab::ServerError))
+    watcher.stop
+  end
+end
@@ -0,0 +1,55 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+
+RSpec.describe(Schwab::Household) do
+  let(:client) { instance_double("Schwab::Client") }
+  let(:household) { described_class.new(["111", "222"], client: client) }
+
+  it "tags positions from every member account" do
+    allow(Schwab::Accounts).to(receive(:get_positions)) do |number, **|
+      number == "111" ? [{ symbol: "AAPL" }, { symbol: "MSFT" }] : [{ symbol: "VTI" }]
+    end
+
+    result = household.positions(asset_type: "EQUITY")
+
+    expect(result).to(be_success)
+    expect(result.items.map { |item| [item.account_number, item.value[:symbol]] })
+      .to(eq([["111", "AAPL"], ["111", "MSFT"], ["222", "VTI"]]))
+    expect(Schwab::Accounts).to(have_received(:get_positions).with("222", asset_type: "EQUITY", client: client))
+  end
+
+  it "keeps other accounts when one fails" do
+    allow(Schwab::Accounts).to(receive(:get_orders).with("111", status: "WORKING", client: client)
+      .and_return([{ orderId: 1 }]))
+    allow(Schwab::Accounts).to(receive(:get_orders).with("222", status: "WORKING", client: client)
+      .and_raise(Schwab::AuthorizationError, "forbidden"))
+
+    result = household.orders(status: "WORKING")
+
+    expect(result.accounts).to(eq({ "111" => [{ orderId: 1 }] }))
+    expect(result.errors.keys).to(eq(["222"]))
+    expect(result.error).to(be_a(Schwab::MultiError))
+  end
+
+  it "sums numeric balances across accounts" do
+    allow(Schwab::Accounts).to(receive(:get_balances)) do |number, **|
+      { cashBalance: number == "111" ? 100.25 : 50.5, liquidationValue: 1000.0, accountType: "CASH" }
+    end
+
+    result = household.balances
+
+    expect(result.items.map(&:account_number)).to(eq(["111", "222"]))
+    expect(household.total_balances(result)).to(eq({ cashBalance: 150.75, liquidationValue: 2000.0 }))
+  end
+
+  it "requires at least one account" do
+    expect { described_class.new([]) }.to(raise_error(ArgumentError, /at least one/))
+  end
+
+  it "is available from a client" do
+    client = Schwab::Client.new(access_token: "token")
+
+    expect(client.household(["111"]).account_numbers).to(eq(["111"]))
+  end
+end
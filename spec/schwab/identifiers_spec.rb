@@ -0,0 +1,89 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "schwab/identifiers"
+
+RSpec.describe(Schwab::Identifiers) do
+  let(:account) { Schwab::AccountNumber("123456789") }
+  let(:symbol) { Schwab::InstrumentSymbol(" AAPL ") }
+
+  describe "identifier values" do
+    it "converts from strings" do
+      expect(symbol.to_s).to(eq("AAPL"))
+      expect(account).to(be_a(Schwab::AccountNumber))
+      expect(Schwab::AccountNumber(account)).to(be(account))
+    end
+
+    it "compares by kind and value" do
+      expect(Schwab::InstrumentSymbol("AAPL")).to(eq(symbol))
+      expect(Schwab::AccountNumber("AAPL")).not_to(eq(symbol))
+      expect([symbol, Schwab::InstrumentSymbol("AAPL")].uniq.size).to(eq(1))
+    end
+
+    it "serializes as a plain string" do
+      expect(JSON.generate({ symbol: symbol, accountNumber: account })).to(eq('{"symbol":"AAPL","accountNumber":"123456789"}'))
+    end
+
+    it "rejects blank values" do
+      expect { Schwab::InstrumentSymbol(" ") }.to(raise_error(ArgumentError, /InstrumentSymbol cannot be blank/))
+    end
+  end
+
+  describe "service arguments" do
+    let(:client) { instance_double("Schwab::Client", config: Schwab::Configuration.new) }
+
+    it "accepts typed symbols for market data" do
+      expect(client).to(receive(:get)
+        .with("/marketdata/v1/quotes", { symbols: "AAPL,MSFT", indicative: false })
+        .and_return({}))
+
+      Schwab::MarketData.get_quotes([symbol, "MSFT"], client: client)
+    end
+
+    it "rejects account numbers passed as symbols" do
+      expect(client).not_to(receive(:get))
+
+      expect { Schwab::MarketData.get_quotes([account], client: client) }.to(raise_error(ArgumentError, /Expected a symbol/))
+      expect { Schwab::MarketData.get_quote(account, client: client) }.to(raise_error(ArgumentError))
+    end
+
+    it "accepts typed account numbers for account calls" do
+      expect(client).to(receive(:resolve_account_number).with("123456789").and_return("HASH"))
+      expect(client).to(receive(:get).with("/trader/v1/accounts/HASH", {}, Schwab::Resources::Account).and_return({}))
+
+      Schwab::Accounts.get_account(account, client: client)
+    end
+
+    it "rejects symbols passed as account numbers" do
+      expect(client).not_to(receive(:resolve_account_number))
+
+      expect { Schwab::Accounts.get_account(symbol, client: client) }.to(raise_error(ArgumentError, /Expected an account number/))
+      expect { Schwab::Trading.cancel_order(symbol, "1001", client: client) }.to(raise_error(ArgumentError))
+    end
+
+    it "rejects blank and malformed account numbers in every service before any request" do
+      expect(client).not_to(receive(:resolve_account_number))
+
+      expect { Schwab::Accounts.get_account("  ", client: client) }
+        .to(raise_error(Schwab::InvalidRequestError, "Account number is blank"))
+      expect { Schwab::Accounts.get_positions(nil, client: client) }
+        .to(raise_error(Schwab::InvalidRequestError, "Account number is blank"))
+      expect { Schwab::Trading.cancel_order("1234/5678", "1001", client: client) }
+        .to(raise_error(Schwab::InvalidRequestError, 'Invalid account number: "1234/5678"'))
+    end
+  end
+
+  describe ".account_number!" do
+    it "strips surrounding whitespace" do
+      expect(described_class.account_number!(" 123456789\n")).to(eq("123456789"))
+      expect(described_class.account_number!(123_456_789)).to(eq("123456789"))
+    end
+
+    it "reports whether an account number is well formed" do
+      expect(described_class.valid_account_number?("ABC123XYZ")).to(be(true))
+      expect(described_class.valid_account_number?("")).to(be(false))
+      expect(described_class.valid_account_number?("1234 5678")).to(be(false))
+      expect(described_class.valid_account_number?(Schwab::InstrumentSymbol("AAPL"))).to(be(false))
+    end
+  end
+end
@@ -0,0 +1,106 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "schwab/instruments"
+
+RSpec.describe(Schwab::Instruments) do
+  let(:client) { instance_double("Schwab::Client", config: Schwab::Configuration.new) }
+
+  describe ".trading_capabilities" do
+    it "derives capabilities from the quoted asset type" do
+      expect(client).to(receive(:get)
+        .with("/marketdata/v1/AAPL/quotes", { fields: "quote,reference" })
+        .and_return({ "AAPL" => { "assetMainType" => "EQUITY", "reference" => { "isShortable" => true } } }))
+
+      capabilities = described_class.trading_capabilities("AAPL", client: client)
+
+      expect(capabilities.symbol).to(eq("AAPL"))
+      expect(capabilities.asset_type).to(eq("EQUITY"))
+      expect(capabilities.supports_order_type?(:trailing_stop)).to(be(true))
+      expect(capabilities.supports_session?("PM")).to(be(true))
+      expect(capabilities).not_to(be_fractional)
+      expect(capabilities).to(be_shortable)
+    end
+
+    it "treats ETFs as equities" do
+      allow(client).to(receive(:get)
+        .and_return({ "SPY" => { "assetMainType" => "EQUITY", "assetSubType" => "ETF" } }))
+
+      capabilities = described_class.trading_capabilities("SPY", client: client)
+
+      expect(capabilities.asset_type).to(eq("ETF"))
+      expect(capabilities.supports_order_type?("STOP_LIMIT")).to(be(true))
+      expect(capabilities.shortable?).to(be_nil)
+    end
+  end
+
+  describe ".exists" do
+    it "reports which symbols the instruments search finds" do
+      expect(client).to(receive(:get)
+        .with("/marketdata/v1/instruments", { symbol: "AAPL,MSFT,NOPE", projection: "symbol-search" })
+        .and_return({ "instruments" => [{ "symbol" => "AAPL" }, { "symbol" => "MSFT" }] }))
+
+      expect(described_class.exists(["AAPL", "MSFT", "NOPE"], client: client))
+        .to(eq("AAPL" => true, "MSFT" => true, "NOPE" => false))
+    end
+
+    it "looks symbols up in batches" do
+      expect(client).to(receive(:get)
+        .with("/marketdata/v1/instruments", { symbol: "A,B", projection: "symbol-search" })
+        .and_return({ "instruments" => [{ "symbol" => "A" }] }))
+      expect(client).to(receive(:get)
+        .with("/marketdata/v1/instruments", { symbol: "C", projection: "symbol-search" })
+        .and_return({ "instruments" => [{ "symbol" => "C" }] }))
+
+      expect(described_class.exists(["A", "B", "C"], batch_size: 2, client: client))
+        .to(eq("A" => true, "B" => false, "C" => true))
+    end
+
+    it "upper-cases symbols like quote requests and keys results by the symbol given" do
+      expect(client).to(receive(:get)
+        .with("/marketdata/v1/instruments", { symbol: "AAPL", projection: "symbol-search" })
+        .and_return({ instruments: [{ symbol: "AAPL" }] }))
+
+      expect(described_class.exists(["aapl", "AAPL"], client: client)).to(eq("aapl" => true, "AAPL" => true))
+    end
+
+    it "sends symbols as given when case normalization is off" do
+      client.config.normalize_symbol_case = false
+      expect(client).to(receive(:get)
+        .with("/marketdata/v1/instruments", { symbol: "brk.b", projection: "symbol-search" })
+        .and_return({}))
+
+      expect(described_class.exists("brk.b", client: client)).to(eq("brk.b" => false))
+    end
+
+    it "rejects an invalid batch size" do
+      expect { described_class.exists(["AAPL"], batch_size: 0, client: client) }
+        .to(raise_error(ArgumentError, /batch size/))
+    end
+  end
+
+  describe ".capabilities_for" do
+    it "restricts mutual funds to regular-session market orders" do
+      capabilities = described_class.capabilities_for("MUTUAL_FUND")
+
+      expect(capabilities.order_types).to(eq(["MARKET"]))
+      expect(capabilities.supports_session?("AM")).to(be(false))
+      expect(capabilities).to(be_fractional)
+    end
+
+    it "keeps options to the regular session" do
+      capabilities = described_class.capabilities_for(:option)
+
+      expect(capabilities.supports_order_type?("NET_DEBIT")).to(be(true))
+      expect(capabilities.supports_order_type?("TRAILING_STOP")).to(be(false))
+      expect(capabilities.supports_session?("SEAMLESS")).to(be(false))
+    end
+
+    it "does not restrict unknown asset types" do
+      capabilities = described_class.capabilities_for("WARRANT")
+
+      expect(capabilities.supports_order_type?("MARKET_ON_CLOSE")).to(be(true))
+      expect(capabilities.supports_session?("PM")).to(be(true))
+    end
+  end
+end
@@ -0,0 +1,65 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+
+RSpec.describe(Schwab::LatencyAlert) do
+  let(:alerts) { Queue.new }
+
+  it "calls back for durations over the threshold" do
+    alert = described_class.new(1.0) { |endpoint, duration| alerts << [endpoint, duration] }
+
+    expect(alert.observe("/marketdata/v1/quotes", 0.5)).to(be(false))
+    expect(alert.observe("/marketdata/v1/quotes", 1.0)).to(be(false))
+    expect(alert.observe("/trader/v1/accounts", 2.5)).to(be(true))
+
+    expect(alerts.pop).to(eq(["/trader/v1/accounts", 2.5]))
+    expect(alerts).to(be_empty)
+  end
+
+  it "runs the callback off the calling thread so a slow callback cannot stall requests" do
+    release = Queue.new
+    alert = described_class.new(0.1) do |endpoint, _duration|
+      release.pop
+      alerts << [endpoint, Thread.current]
+    end
+
+    started = Process.clock_gettime(Process::CLOCK_MONOTONIC)
+    alert.observe("/trader/v1/orders", 0.2)
+    expect(Process.clock_gettime(Process::CLOCK_MONOTONIC) - started).to(be < 0.1)
+
+    release << true
+    endpoint, thread = alerts.pop
+    expect(endpoint).to(eq("/trader/v1/orders"))
+    expect(thread).not_to(eq(Thread.current))
+  end
+
+  it "keeps alerting after the callback raises" do
+    calls = 0
+    alert = described_class.new(0.1) do |endpoint, _duration|
+      calls += 1
+      raise "pager down" if calls == 1
+
+      alerts << endpoint
+    end
+
+    alert.observe("/first", 1.0)
+    alert.observe("/second", 1.0)
+
+    expect(alerts.pop).to(eq("/second"))
+  end
+
+  it "drops alerts while too many are waiting" do
+    release = Queue.new
+    alert = described_class.new(0.1) { |_endpoint, _duration| release.pop }
+
+    (described_class::MAX_PENDING + 5).times { alert.observe("/slow", 1.0) }
+
+    expect(alert.dropped).to(be_between(4, 5))
+    (described_class::MAX_PENDING + 5).times { release << true }
+  end
+
+  it "rejects invalid thresholds and a missing callback" do
+    expect { described_class.new(0) { nil } }.to(raise_error(ArgumentError, /threshold/))
+    expect { described_class.new(1) }.to(raise_error(ArgumentError, /callback/))
+  end
+end
@@ -0,0 +1,187 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "schwab/market_data"
+
+RSpec.describe(Schwab::MarketData) do
+  let(:client) { instance_double("Schwab::Client", config: Schwab::Configuration.new) }
+
+  def quote_payload(symbol, time)
+    { symbol: symbol, quote: { lastPrice: 100.0, quoteTime: (time.to_f * 1000).to_i } }
+  end
+
+  describe ".get_quotes" do
+    it "passes the indicative flag" do
+      expect(client).to(receive(:get)
+        .with("/marketdata/v1/quotes", { symbols: "SPY", indicative: true })
+        .and_return({}))
+
+      described_class.get_quotes("SPY", indicative: true, client: client)
+    end
+
+    it "normalizes symbols when requested" do
+      expect(client).to(receive(:get)
+        .with("/marketdata/v1/quotes", { symbols: "$SPX,AAPL", indicative: false })
+        .and_return({}))
+
+      described_class.get_quotes(["$spx.x", "aapl"], normalize: true, client: client)
+    end
+
+    it "upper-cases mixed-case equity and index symbols" do
+      expect(client).to(receive(:get)
+        .with("/marketdata/v1/quotes", { symbols: "AAPL,$SPX.X,BRK.B", indicative: false })
+        .and_return({}))
+
+      described_class.get_quotes(["aApl", "$spx.x", "brk.B"], client: client)
+    end
+
+    it "sends symbols as given when case normalization is off" do
+      client.config.normalize_symbol_case = false
+      expect(client).to(receive(:get)
+        .with("/marketdata/v1/quotes", { symbols: "aapl", indicative: false })
+        .and_return({}))
+
+      described_class.get_quotes("aapl", client: client)
+    end
+
+    it "rejects a non-boolean indicative flag" do
+      expect { described_class.get_quotes("SPY", indicative: "realtime", client: client) }
+        .to(raise_error(ArgumentError, /indicative/))
+    end
+  end
+
+  describe "with a quote cache" do
+    let(:cache) { Schwab::QuoteCache.new(ttl: 60) }
+    let(:other_client) { instance_double("Schwab::Client", config: Schwab::Configuration.new) }
+
+    before do
+      client.config.quote_cache = cache
+      other_client.config.quote_cache = cache
+    end
+
+    it "requests only symbols missing from the cache, across clients sharing it" do
+      expect(client).to(receive(:get)
+        .with("/marketdata/v1/quotes", { symbols: "AAPL,MSFT", indicative: false })
+        .and_return({ "AAPL" => { "symbol" => "AAPL" }, "MSFT" => { "symbol" => "MSFT" } }))
+      expect(other_client).to(receive(:get)
+        .with("/marketdata/v1/quotes", { symbols: "SPY", indicative: false })
+        .and_return({ "SPY" => { "symbol" => "SPY" } }))
+
+      described_class.get_quotes(["AAPL", "MSFT"], client: client)
+      quotes = described_class.get_quotes(["aapl", "SPY"], client: other_client)
+
+      expect(quotes.keys).to(contain_exactly("AAPL", "SPY"))
+      expect(quotes["AAPL"]).to(eq({ "symbol" => "AAPL" }))
+    end
+
+    it "answers fully cached requests without a call" do
+      cache.write(Schwab::QuoteCache.key("AAPL"), { "symbol" => "AAPL" })
+      expect(client).not_to(receive(:get))
+
+      expect(described_class.get_quotes("AAPL", client: client)).to(eq({ "AAPL" => { "symbol" => "AAPL" } }))
+      expect(described_class.get_quote("AAPL", client: client)).to(eq({ "AAPL" => { "symbol" => "AAPL" } }))
+    end
+
+    it "keys entries by fields and indicative flag" do
+      cache.write(Schwab::QuoteCache.key("AAPL"), { "symbol" => "AAPL" })
+      expect(client).to(receive(:get)
+        .with("/marketdata/v1/quotes", { symbols: "AAPL", indicative: false, fields: "quote,fundamental" })
+        .and_return({ "AAPL" => { "symbol" => "AAPL", "fundamental" => {} } }))
+
+      described_class.get_quotes("AAPL", fields: ["quote", "fundamental"], client: client)
+
+      expect(cache.read("AAPL|quote,fundamental|regular")).to(include("fundamental"))
+    end
+
+    it "does not cache error entries" do
+      allow(client).to(receive(:get).and_return({ "errors" => { "invalidSymbols" => ["NOPE"] } }))
+
+      described_class.get_quotes("NOPE", client: client)
+
+      expect(cache.size).to(eq(0))
+    end
+
+    it "bypasses the cache when asked and refreshes it" do
+      cache.write(Schwab::QuoteCache.key("AAPL"), { "symbol" => "AAPL", "old" => true })
+      expect(client).to(receive(:get).and_return({ "AAPL" => { "symbol" => "AAPL" } }))
+
+      described_class.get_quotes("AAPL", cached: false, client: client)
+
+      expect(cache.read(Schwab::QuoteCache.key("AAPL"))).to(eq({ "symbol" => "AAPL" }))
+    end
+
+    it "rejects caches without read and write" do
+      expect { client.config.quote_cache = {} }.to(raise_error(ArgumentError, /quote_cache/))
+    end
+  end
+
+  describe ".get_quotes_ordered" do
+    let(:now) { Time.now }
+
+    it "aligns quotes to the input order, repeating duplicates and leaving gaps for unknown symbols" do
+      expect(client).to(receive(:get)
+        .with("/marketdata/v1/quotes", { symbols: "MSFT,AAPL,NOPE", indicative: false })
+        .and_return({
+          "AAPL" => quote_payload("AAPL", now),
+          "MSFT" => quote_payload("MSFT", now),
+          "errors" => { "invalidSymbols" => ["NOPE"] },
+        }))
+
+      quotes = described_class.get_quotes_ordered(["MSFT", "AAPL", "NOPE", "MSFT"], client: client)
+
+      expect(quotes.map { |quote| quote&.symbol }).to(eq(["MSFT", "AAPL", nil, "MSFT"]))
+      expect(quotes.first).to(be(quotes.last))
+    end
+
+    it "matches normalized symbols case-insensitively" do
+      expect(client).to(receive(:get)
+        .with("/marketdata/v1/quotes", { symbols: "$SPX,AAPL", indicative: false })
+        .and_return({ "$SPX" => quote_payload("$SPX", now), "AAPL" => quote_payload("AAPL", now) }))
+
+      quotes = described_class.get_quotes_ordered(["$spx.x", "aapl"], normalize: true, client: client)
+
+      expect(quotes.map(&:symbol)).to(eq(["$SPX", "AAPL"]))
+    end
+
+    it "returns an empty array without a request for no symbols" do
+      expect(client).not_to(receive(:get))
+
+      expect(described_class.get_quotes_ordered([], client: client)).to(eq([]))
+    end
+  end
+
+  describe ".get_fresh_quotes" do
+    let(:now) { Time.now }
+
+    it "returns quotes keyed by symbol when all are fresh" do
+      expect(client).to(receive(:get)
+        .with("/marketdata/v1/quotes", { symbols: "AAPL,MSFT", indicative: false })
+        .and_return({ "AAPL" => quote_payload("AAPL", now), "MSFT" => quote_payload("MSFT", now) }))
+
+      quotes = described_class.get_fresh_quotes(["AAPL", "MSFT"], max_age: 60, client: client)
+
+      expect(quotes.keys).to(eq(["AAPL", "MSFT"]))
+      expect(quotes["AAPL"]).to(be_a(Schwab::Resources::Quote))
+    end
+
+    it "re-fetches stale quotes once" do
+      expect(client).to(receive(:get)
+        .with("/marketdata/v1/quotes", { symbols: "AAPL,MSFT", indicative: false })
+        .and_return({ "AAPL" => quote_payload("AAPL", now), "MSFT" => quote_payload("MSFT", now - 600) }))
+      expect(client).to(receive(:get)
+        .with("/marketdata/v1/quotes", { symbols: "MSFT", indicative: false })
+        .and_return({ "MSFT" => quote_payload("MSFT", now) }))
+
+      quotes = described_class.get_fresh_quotes(["AAPL", "MSFT"], max_age: 60, client: client)
+      expect(quotes["MSFT"].stale?(60)).to(be(false))
+    end
+
+    it "raises StaleQuoteError when a quote is still stale" do
+      stale = { "MSFT" => quote_payload("MSFT", now - 600) }
+      allow(client).to(receive(:get).and_return(stale))
+
+      expect { described_class.get_fresh_quotes("MSFT", max_age: 60, client: client) }
+        .to(raise_error(Schwab::StaleQuoteError) { |error| expect(error.symbol).to(eq("MSFT")) })
+    end
+  end
+end
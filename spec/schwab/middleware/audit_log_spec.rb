@@ -0,0 +1,80 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "schwab/connection"
+require "stringio"
+
+RSpec.describe(Schwab::Middleware::AuditLog) do
+  let(:sink) { StringIO.new }
+  let(:config) do
+    Schwab::Configuration.new.tap do |c|
+      c.api_base_url = "https://api.test.com"
+      c.audit_sink = sink
+    end
+  end
+  let(:connection) { Schwab::Connection.build(access_token: "secret-access-token", config: config) }
+
+  def records
+    sink.string.lines.map { |line| JSON.parse(line) }
+  end
+
+  it "writes one JSON line per request with the bodies as sent and received" do
+    stub_request(:post, "https://api.test.com/trader/v1/accounts/ABC/orders")
+      .to_return(status: 201, body: "", headers: { "Location" => "/orders/1" })
+    stub_request(:get, "https://api.test.com/marketdata/v1/quotes?symbols=AAPL")
+      .to_return(status: 200, body: '{"AAPL":{"quote":{"lastPrice":190.1}}}', headers: { "Content-Type" => "application/json" })
+
+    connection.post("/trader/v1/accounts/ABC/orders", { orderType: "LIMIT", price: 150.0 })
+    connection.get("/marketdata/v1/quotes", { symbols: "AAPL" })
+
+    order, quote = records
+    expect(order).to(include(
+      "method" => "POST",
+      "path" => "/trader/v1/accounts/ABC/orders",
+      "status" => 201,
+      "request_body" => '{"orderType":"LIMIT","price":150.0}',
+      "response_body" => "",
+    ))
+    expect(Time.iso8601(order["timestamp"])).to(be_within(5).of(Time.now))
+    expect(quote).to(include("path" => "/marketdata/v1/quotes?symbols=AAPL", "status" => 200))
+    expect(quote["response_body"]).to(eq('{"AAPL":{"quote":{"lastPrice":190.1}}}'))
+  end
+
+  it "redacts credentials and never writes headers" do
+    stub_request(:post, "https://api.test.com/hooks?api_key=k123&name=fills").to_return(status: 200, body: "{}")
+
+    connection.post("/hooks?api_key=k123&name=fills", { url: "https://example.com", client_secret: "s3cret" })
+
+    line = sink.string
+    expect(line).not_to(include("secret-access-token", "s3cret", "k123"))
+    expect(records.first).to(include(
+      "path" => "/hooks?api_key=%5BREDACTED%5D&name=fills",
+      "request_body" => '{"url":"https://example.com","client_secret":"[REDACTED]"}',
+    ))
+  end
+
+  it "records failed responses and requests that got no response" do
+    stub_request(:get, "https://api.test.com/missing").to_return(status: 404, body: "Not Found")
+    stub_request(:get, "https://api.test.com/down").to_raise(Faraday::ConnectionFailed.new("refused"))
+
+    expect { connection.get("/missing") }.to(raise_error(Faraday::ResourceNotFound))
+    expect { connection.get("/down") }.to(raise_error(Faraday::ConnectionFailed))
+
+    expect(records.map { |record| record["status"] }).to(eq([404, nil]))
+    expect(records.last["error"]).to(include("ConnectionFailed", "refused"))
+  end
+
+  it "does not break requests when the sink fails" do
+    broken = Object.new
+    def broken.write(*)
+      raise IOError, "closed stream"
+    end
+    output = StringIO.new
+    config.audit_sink = broken
+    config.logger = Logger.new(output)
+    stub_request(:get, "https://api.test.com/ok").to_return(status: 200, body: "ok")
+
+    expect(connection.get("/ok").body).to(eq("ok"))
+    expect(output.string).to(include("audit sink write failed: IOError: closed stream"))
+  end
+end
@@ -0,0 +1,66 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "schwab/connection"
+
+RSpec.describe(Schwab::Middleware::Codec) do
+  let(:calls) { [] }
+  let(:codec) do
+    log = calls
+    Module.new do
+      define_singleton_method(:name) { "TracingCodec" }
+      define_singleton_method(:encode) do |object|
+        log << :encode
+        JSON.generate(object)
+      end
+      define_singleton_method(:decode) do |json|
+        log << :decode
+        raise ArgumentError, "unexpected character" if json.include?("<")
+
+        JSON.parse(json)
+      end
+    end
+  end
+  let(:config) do
+    Schwab::Configuration.new.tap do |c|
+      c.api_base_url = "https://api.test.com"
+      c.codec = codec
+    end
+  end
+  let(:connection) { Schwab::Connection.build(access_token: "token", config: config) }
+  let(:client) { Schwab::Client.new(access_token: "token", config: config) }
+
+  it "encodes request bodies and decodes JSON responses with the configured codec" do
+    stub_request(:post, "https://api.test.com/trader/v1/accounts/ABC/orders")
+      .with(body: '{"orderType":"LIMIT"}', headers: { "Content-Type" => "application/json" })
+      .to_return(status: 200, body: '{"orderId":1}', headers: { "Content-Type" => "application/json; charset=utf-8" })
+
+    response = connection.post("/trader/v1/accounts/ABC/orders", { orderType: "LIMIT" })
+
+    expect(response.body).to(eq({ "orderId" => 1 }))
+    expect(response.env[:raw_body]).to(eq('{"orderId":1}'))
+    expect(calls).to(eq([:encode, :decode]))
+  end
+
+  it "leaves string bodies and non-JSON responses alone" do
+    stub_request(:post, "https://api.test.com/upload").with(body: "raw")
+      .to_return(status: 200, body: "<html>", headers: { "Content-Type" => "text/html" })
+
+    expect(connection.post("/upload", "raw").body).to(eq("<html>"))
+    expect(calls).to(be_empty)
+  end
+
+  it "names the request and codec when decoding fails" do
+    stub_request(:get, "https://api.test.com/trader/v1/accounts")
+      .to_return(status: 200, body: "<html>", headers: { "Content-Type" => "application/json" })
+
+    expect { client.get("/trader/v1/accounts") }.to(raise_error(Schwab::Error) do |error|
+      expect(error.message).to(include("GET /trader/v1/accounts", "TracingCodec", "ArgumentError: unexpected character"))
+    end)
+  end
+
+  it "defaults to the standard library codec and rejects objects that are not codecs" do
+    expect(Schwab::Configuration.new.codec).to(eq(Schwab::Codec::Stdlib))
+    expect { config.codec = Object.new }.to(raise_error(ArgumentError, /encode and #decode/))
+  end
+end
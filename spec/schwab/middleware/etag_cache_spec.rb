@@ -0,0 +1,62 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "schwab/connection"
+
+RSpec.describe(Schwab::Middleware::ETagCache) do
+  let(:config) do
+    Schwab::Configuration.new.tap do |c|
+      c.api_base_url = "https://api.test.com"
+      c.etag_cache = true
+    end
+  end
+  let(:connection) { Schwab::Connection.build(access_token: "token", config: config) }
+  let(:url) { "https://api.test.com/marketdata/v1/instruments?projection=fundamental&symbol=AAPL" }
+  let(:params) { { symbol: "AAPL", projection: "fundamental" } }
+
+  before do
+    stub_request(:get, url)
+      .to_return(
+        status: 200,
+        body: '{"instruments":[{"symbol":"AAPL"}]}',
+        headers: { "Content-Type" => "application/json", "ETag" => '"v1"' },
+      )
+      .then
+      .to_return(status: 304, body: "", headers: { "ETag" => '"v1"' })
+  end
+
+  it "sends If-None-Match and serves the cached body on 304" do
+    first = connection.get("/marketdata/v1/instruments", params)
+    second = connection.get("/marketdata/v1/instruments", params)
+
+    expect(a_request(:get, url).with(headers: { "If-None-Match" => '"v1"' })).to(have_been_made.once)
+    expect(second.status).to(eq(200))
+    expect(second.body).to(eq(first.body))
+    expect(second.body).to(eq({ "instruments" => [{ "symbol" => "AAPL" }] }))
+  end
+
+  it "exposes the cache for inspection and clearing" do
+    connection.get("/marketdata/v1/instruments", params)
+
+    expect(config.etag_cache.urls).to(eq([url]))
+    expect(config.etag_cache.etag(url)).to(eq('"v1"'))
+
+    config.etag_cache.clear
+    expect(config.etag_cache.size).to(eq(0))
+  end
+
+  it "does not cache non-GET requests" do
+    stub_request(:post, "https://api.test.com/orders")
+      .to_return(status: 200, body: "{}", headers: { "Content-Type" => "application/json", "ETag" => '"p1"' })
+
+    connection.post("/orders", {})
+
+    expect(config.etag_cache.size).to(eq(0))
+  end
+
+  it "is not installed unless enabled" do
+    config.etag_cache = false
+
+    expect(connection.builder.handlers).not_to(include(described_class))
+  end
+end
@@ -0,0 +1,78 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "schwab/connection"
+
+RSpec.describe(Schwab::Middleware::FollowRedirects) do
+  let(:config) { Schwab::Configuration.new.tap { |c| c.api_base_url = "https://api.test.com" } }
+  let(:connection) { Schwab::Connection.build(access_token: "secret_token", config: config) }
+
+  def redirect(status, location)
+    { status: status, headers: { "Location" => location } }
+  end
+
+  it "drops the bearer token when redirected to another host" do
+    stub_request(:get, "https://api.test.com/trader/v1/accounts")
+      .to_return(redirect(302, "https://elsewhere.example.com/accounts"))
+    stub_request(:get, "https://elsewhere.example.com/accounts").to_return(status: 200, body: "[]")
+
+    response = connection.get("trader/v1/accounts")
+
+    expect(response.status).to(eq(200))
+    expect(WebMock).to(have_requested(:get, "https://api.test.com/trader/v1/accounts")
+      .with(headers: { "Authorization" => "Bearer secret_token" }))
+    expect(WebMock).to(have_requested(:get, "https://elsewhere.example.com/accounts")
+      .with { |request| !request.headers.key?("Authorization") })
+  end
+
+  it "treats a different scheme or port as another origin" do
+    stub_request(:get, "https://api.test.com/trader/v1/accounts")
+      .to_return(redirect(301, "http://api.test.com/accounts"))
+    stub_request(:get, "http://api.test.com/accounts").to_return(status: 200, body: "[]")
+
+    connection.get("trader/v1/accounts")
+
+    expect(WebMock).to(have_requested(:get, "http://api.test.com/accounts")
+      .with { |request| !request.headers.key?("Authorization") })
+  end
+
+  it "keeps the bearer token on the same host and repeats the body for a 307" do
+    stub_request(:post, "https://api.test.com/trader/v1/orders").to_return(redirect(307, "/trader/v2/orders"))
+    stub_request(:post, "https://api.test.com/trader/v2/orders").to_return(status: 201)
+
+    response = connection.post("trader/v1/orders", { orderType: "LIMIT" })
+
+    expect(response.status).to(eq(201))
+    expect(WebMock).to(have_requested(:post, "https://api.test.com/trader/v2/orders")
+      .with(body: { orderType: "LIMIT" }.to_json, headers: { "Authorization" => "Bearer secret_token" }))
+  end
+
+  it "follows a 303 with a GET and no body" do
+    stub_request(:post, "https://api.test.com/trader/v1/orders").to_return(redirect(303, "/trader/v1/orders/1"))
+    stub_request(:get, "https://api.test.com/trader/v1/orders/1").to_return(status: 200, body: "{}")
+
+    expect(connection.post("trader/v1/orders", { orderType: "LIMIT" }).status).to(eq(200))
+    expect(WebMock).to(have_requested(:get, "https://api.test.com/trader/v1/orders/1")
+      .with { |request| request.body.to_s.empty? })
+  end
+
+  it "raises after max_redirects redirects in a row" do
+    config.max_redirects = 2
+    stub_request(:get, "https://api.test.com/loop").to_return(redirect(302, "/loop"))
+
+    expect { connection.get("loop") }.to(raise_error(Schwab::TooManyRedirectsError) do |error|
+      expect(error.max_redirects).to(eq(2))
+      expect(error.location).to(eq("/loop"))
+    end)
+    expect(WebMock).to(have_requested(:get, "https://api.test.com/loop").times(3))
+  end
+
+  it "returns redirects unfollowed when max_redirects is 0" do
+    config.max_redirects = 0
+    stub_request(:get, "https://api.test.com/trader/v1/accounts")
+      .to_return(redirect(302, "https://elsewhere.example.com/accounts"))
+
+    expect(connection.get("trader/v1/accounts").status).to(eq(302))
+    expect(WebMock).not_to(have_requested(:get, "https://elsewhere.example.com/accounts"))
+  end
+end
@@ -0,0 +1,99 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "schwab/connection"
+
+RSpec.describe(Schwab::Middleware::Instrumentation) do
+  let(:events) { [] }
+  let(:config) do
+    Schwab::Configuration.new.tap do |c|
+      c.api_base_url = "https://api.test.com"
+      c.on_request = ->(event) { events << event }
+    end
+  end
+  let(:connection) { Schwab::Connection.build(access_token: "token", config: config) }
+
+  before do
+    stub_request(:get, "https://api.test.com/trader/v1/accounts")
+      .to_return(status: 200, body: "[]", headers: { "Content-Type" => "application/json" })
+  end
+
+  it "defaults the operation to the endpoint" do
+    connection.get("/trader/v1/accounts")
+
+    expect(events.size).to(eq(1))
+    expect(events.first).to(include(
+      method: "GET",
+      endpoint: "/trader/v1/accounts",
+      operation: "/trader/v1/accounts",
+      status: 200,
+      error: nil,
+    ))
+    expect(events.first[:duration]).to(be >= 0)
+  end
+
+  it "tags requests with the operation set by Schwab.with_operation" do
+    Schwab.with_operation("rebalance") { connection.get("/trader/v1/accounts") }
+
+    expect(events.first[:operation]).to(eq("rebalance"))
+    expect(Schwab.current_operation).to(be_nil)
+  end
+
+  it "restores the outer operation after a nested block" do
+    Schwab.with_operation(:rebalance) do
+      Schwab.with_operation("quote_refresh") { connection.get("/trader/v1/accounts") }
+      connection.get("/trader/v1/accounts")
+    end
+
+    expect(events.map { |event| event[:operation] }).to(eq(["quote_refresh", "rebalance"]))
+  end
+
+  it "keeps operations separate across threads" do
+    labels = ["a", "b"].map do |name|
+      Thread.new { Schwab.with_operation(name) { Schwab.current_operation } }
+    end.map(&:value)
+
+    expect(labels).to(eq(["a", "b"]))
+  end
+
+  it "attaches labels set by Schwab.with_labels" do
+    Schwab.with_labels(strategy: "momentum") do
+      Schwab.with_labels(cancel_reason: "stale quote") { connection.get("/trader/v1/accounts") }
+      connection.get("/trader/v1/accounts")
+    end
+    connection.get("/trader/v1/accounts")
+
+    expect(events.map { |event| event[:labels] }).to(eq([
+      { strategy: "momentum", cancel_reason: "stale quote" },
+      { strategy: "momentum" },
+      {},
+    ]))
+  end
+
+  it "reports failed requests before re-raising" do
+    stub_request(:get, "https://api.test.com/trader/v1/orders")
+      .to_return(status: 500, body: "{}", headers: { "Content-Type" => "application/json" })
+
+    expect { connection.get("/trader/v1/orders") }.to(raise_error(Faraday::ServerError))
+    expect(events.first[:status]).to(eq(500))
+    expect(events.first[:error]).to(be_a(Faraday::ServerError))
+  end
+
+  it "logs the operation when a logger is configured" do
+    output = StringIO.new
+    config.logger = Logger.new(output)
+
+    Schwab.with_operation("rebalance") { connection.get("/trader/v1/accounts") }
+
+    expect(output.string).to(include("operation=rebalance status=200"))
+  end
+
+  it "logs labels after the timing" do
+    output = StringIO.new
+    config.logger = Logger.new(output)
+
+    Schwab.with_labels(cancel_reason: "stale quote") { connection.get("/trader/v1/accounts") }
+
+    expect(output.string).to(match(/ms cancel_reason="stale quote"$/))
+  end
+end
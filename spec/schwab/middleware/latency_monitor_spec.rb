@@ -0,0 +1,63 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "schwab/connection"
+
+RSpec.describe(Schwab::Middleware::LatencyMonitor) do
+  let(:alerts) { Queue.new }
+  let(:config) do
+    Schwab::Configuration.new.tap do |c|
+      c.api_base_url = "https://api.test.com"
+      c.on_slow_request(0.05) { |endpoint, duration| alerts << [endpoint, duration] }
+    end
+  end
+  let(:connection) { Schwab::Connection.build(access_token: "token", config: config) }
+
+  it "alerts with the endpoint and duration of slow requests" do
+    stub_request(:get, "https://api.test.com/trader/v1/accounts").to_return(lambda do |_request|
+      sleep(0.1)
+      { status: 200, body: "[]", headers: { "Content-Type" => "application/json" } }
+    end)
+
+    connection.get("/trader/v1/accounts", { fields: "positions" })
+
+    endpoint, duration = alerts.pop
+    expect(endpoint).to(eq("/trader/v1/accounts"))
+    expect(duration).to(be >= 0.1)
+  end
+
+  it "stays quiet for fast requests" do
+    stub_request(:get, "https://api.test.com/trader/v1/accounts").to_return(status: 200, body: "[]")
+
+    connection.get("/trader/v1/accounts")
+
+    expect(config.latency_alert.observe("/probe", 1.0)).to(be(true))
+    expect(alerts.pop.first).to(eq("/probe"))
+  end
+
+  it "times requests that fail without a response" do
+    config.on_slow_request(Float::EPSILON) { |endpoint, duration| alerts << [endpoint, duration] }
+    stub_request(:get, "https://api.test.com/trader/v1/accounts").to_timeout
+
+    expect { connection.get("/trader/v1/accounts") }.to(raise_error(Faraday::TimeoutError))
+    expect(alerts.pop.first).to(eq("/trader/v1/accounts"))
+  end
+
+  it "does not count client-side retry waits" do
+    config.backoff = Schwab::Backoff::Constant.new(0.1)
+    config.max_retries = 1
+    stub_request(:get, "https://api.test.com/trader/v1/accounts")
+      .to_return({ status: 503, body: "" }, { status: 200, body: "[]" })
+
+    connection.get("/trader/v1/accounts")
+
+    expect(config.latency_alert.observe("/probe", 1.0)).to(be(true))
+    expect(alerts.pop.first).to(eq("/probe"))
+  end
+
+  it "can be turned off" do
+    config.on_slow_request(nil)
+
+    expect(config.latency_alert).to(be_nil)
+  end
+end
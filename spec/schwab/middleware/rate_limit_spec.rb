@@ -0,0 +1,79 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "schwab/connection"
+
+RSpec.describe(Schwab::Middleware::RateLimit) do
+  let(:config) do
+    Schwab::Configuration.new.tap do |c|
+      c.api_base_url = "https://api.test.com"
+      c.max_retries = 2
+    end
+  end
+  let(:connection) { Schwab::Connection.build(access_token: "token", config: config) }
+  let(:strategy) { Schwab::Backoff::Constant.new(0) }
+
+  it "does not retry unless a backoff is configured" do
+    stub = stub_request(:get, "https://api.test.com/quotes").to_return(status: 429)
+
+    expect { connection.get("/quotes") }.to(raise_error(Faraday::TooManyRequestsError))
+    expect(stub).to(have_been_requested.once)
+  end
+
+  it "retries rate-limited requests with the configured strategy" do
+    config.backoff = strategy
+    stub_request(:get, "https://api.test.com/quotes")
+      .to_return({ status: 429 }, { status: 200, body: "{}", headers: { "Content-Type" => "application/json" } })
+    expect(strategy).to(receive(:next_delay).with(1, an_instance_of(Faraday::Response)).and_call_original)
+
+    expect(connection.get("/quotes").status).to(eq(200))
+  end
+
+  it "passes nil to the strategy after network errors" do
+    config.backoff = strategy
+    stub_request(:get, "https://api.test.com/quotes").to_timeout.then.to_return(status: 200, body: "{}")
+    expect(strategy).to(receive(:next_delay).with(1, nil).and_call_original)
+
+    expect(connection.get("/quotes").status).to(eq(200))
+  end
+
+  it "gives up after max_retries" do
+    config.backoff = strategy
+    stub = stub_request(:get, "https://api.test.com/quotes").to_return(status: 503)
+
+    expect { connection.get("/quotes") }.to(raise_error(Faraday::ServerError))
+    expect(stub).to(have_been_requested.times(3))
+  end
+
+  it "does not retry a POST that timed out" do
+    config.backoff = strategy
+    stub = stub_request(:post, "https://api.test.com/trader/v1/accounts/ABC/orders").to_timeout
+
+    expect { connection.post("/trader/v1/accounts/ABC/orders", { orderType: "MARKET" }) }
+      .to(raise_error(Faraday::TimeoutError))
+    expect(stub).to(have_been_requested.once)
+  end
+
+  it "does not retry a POST that got a 503" do
+    config.backoff = strategy
+    stub = stub_request(:post, "https://api.test.com/trader/v1/accounts/ABC/orders").to_return(status: 503)
+
+    expect { connection.post("/trader/v1/accounts/ABC/orders", { orderType: "MARKET" }) }
+      .to(raise_error(Faraday::ServerError))
+    expect(stub).to(have_been_requested.once)
+  end
+
+  it "retries a rate-limited POST with its original body" do
+    config.backoff = strategy
+    stub = stub_request(:post, "https://api.test.com/trader/v1/accounts/ABC/orders")
+      .with(body: { orderType: "MARKET" }.to_json)
+      .to_return({ status: 429, body: "slow down" }, { status: 201 })
+
+    expect(connection.post("/trader/v1/accounts/ABC/orders", { orderType: "MARKET" }).status).to(eq(201))
+    expect(stub).to(have_been_requested.twice)
+  end
+
+  it "rejects strategies without next_delay" do
+    expect { config.backoff = 5 }.to(raise_error(ArgumentError, /next_delay/))
+  end
+end
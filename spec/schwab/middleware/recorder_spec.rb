@@ -0,0 +1,67 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "tmpdir"
+require "schwab/connection"
+
+RSpec.describe(Schwab::Middleware::Recorder) do
+  let(:dir) { Dir.mktmpdir("schwab-recordings") }
+  let(:config) do
+    Schwab::Configuration.new.tap do |c|
+      c.api_base_url = "https://api.test.com"
+      c.recorder_dir = dir
+    end
+  end
+
+  after { FileUtils.remove_entry(dir) }
+
+  def build_connection(mode)
+    config.recorder_mode = mode
+    Schwab::Connection.build(access_token: "secret_token", config: config)
+  end
+
+  describe "record mode" do
+    it "saves the response with authorization redacted" do
+      stub_request(:get, "https://api.test.com/trader/v1/accounts")
+        .to_return(status: 200, body: '[{"accountNumber":"123"}]', headers: { "Content-Type" => "application/json" })
+
+      response = build_connection(:record).get("/trader/v1/accounts", { fields: "positions" })
+      expect(response.body).to(eq([{ "accountNumber" => "123" }]))
+
+      recording = File.read(File.join(dir, "get_trader_v1_accounts.json"))
+      expect(recording).not_to(include("secret_token"))
+      expect(JSON.parse(recording)["response"]["status"]).to(eq(200))
+    end
+  end
+
+  describe "replay mode" do
+    it "serves recorded responses without network access" do
+      stub_request(:get, "https://api.test.com/trader/v1/accounts")
+        .to_return(status: 200, body: '[{"accountNumber":"123"}]', headers: { "Content-Type" => "application/json" })
+      build_connection(:record).get("/trader/v1/accounts")
+      WebMock.reset!
+
+      response = build_connection(:replay).get("/trader/v1/accounts")
+
+      expect(response.status).to(eq(200))
+      expect(response.body).to(eq([{ "accountNumber" => "123" }]))
+      expect(WebMock).not_to(have_requested(:get, "https://api.test.com/trader/v1/accounts"))
+    end
+
+    it "raises when no recording exists" do
+      expect { build_connection(:replay).get("/trader/v1/orders") }
+        .to(raise_error(Schwab::Error, /No recording/))
+    end
+  end
+
+  describe "configuration" do
+    it "rejects unknown modes" do
+      expect { config.recorder_mode = :live }.to(raise_error(ArgumentError, /recorder_mode/))
+    end
+
+    it "requires a recorder directory" do
+      config.recorder_dir = nil
+      expect { build_connection(:replay) }.to(raise_error(Schwab::Error, /recorder_dir/))
+    end
+  end
+end
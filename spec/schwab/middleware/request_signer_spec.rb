@@ -0,0 +1,54 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "openssl"
+require "schwab/connection"
+
+RSpec.describe(Schwab::Middleware::RequestSigner) do
+  let(:signed) { [] }
+  let(:config) do
+    Schwab::Configuration.new.tap do |c|
+      c.api_base_url = "https://api.test.com"
+      c.request_signer = lambda do |env|
+        signed << { body: env.body, authorization: env.request_headers["Authorization"] }
+        payload = [env.method.to_s.upcase, env.url.request_uri, env.body.to_s].join("\n")
+        env.request_headers["X-Gateway-Signature"] = OpenSSL::HMAC.hexdigest("SHA256", "secret", payload)
+      end
+    end
+  end
+  let(:connection) { Schwab::Connection.build(access_token: "token", config: config) }
+
+  it "signs the final JSON body after the authorization header is set" do
+    payload = ["POST", "/trader/v1/accounts/ABC/orders", '{"orderType":"LIMIT"}'].join("\n")
+    signature = OpenSSL::HMAC.hexdigest("SHA256", "secret", payload)
+    stub_request(:post, "https://api.test.com/trader/v1/accounts/ABC/orders")
+      .with(headers: { "X-Gateway-Signature" => signature })
+      .to_return(status: 201, body: "")
+
+    connection.post("/trader/v1/accounts/ABC/orders", { orderType: "LIMIT" })
+
+    expect(signed).to(eq([{ body: '{"orderType":"LIMIT"}', authorization: "Bearer token" }]))
+  end
+
+  it "signs every retry again" do
+    config.backoff = Schwab::Backoff::Constant.new(0)
+    stub_request(:get, "https://api.test.com/trader/v1/accounts")
+      .to_return({ status: 503, body: "" }, { status: 200, body: "[]", headers: { "Content-Type" => "application/json" } })
+
+    connection.get("/trader/v1/accounts")
+
+    expect(signed.size).to(eq(2))
+  end
+
+  it "aborts the request when the signer raises" do
+    config.request_signer = ->(_env) { raise Schwab::Error, "signing key unavailable" }
+    stub = stub_request(:get, "https://api.test.com/trader/v1/accounts")
+
+    expect { connection.get("/trader/v1/accounts") }.to(raise_error(Schwab::Error, "signing key unavailable"))
+    expect(stub).not_to(have_been_requested)
+  end
+
+  it "requires a callable signer" do
+    expect { config.request_signer = "secret" }.to(raise_error(ArgumentError, /request_signer/))
+  end
+end
@@ -0,0 +1,48 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "json"
+require "schwab/net_price"
+
+RSpec.describe(Schwab::NetPrice) do
+  let(:legs) do
+    [
+      { instruction: "BUY_TO_OPEN", quantity: 1, instrument: { symbol: "AAPL  240517C00190000", assetType: "OPTION" } },
+      { instruction: "SELL_TO_OPEN", quantity: 1, instrument: { symbol: "AAPL  240517C00200000", assetType: "OPTION" } },
+    ]
+  end
+
+  it "serializes a vertical spread debit as a positive NET_DEBIT price" do
+    order = {
+      orderStrategyType: "SINGLE",
+      complexOrderStrategyType: "VERTICAL",
+      session: "NORMAL",
+      duration: "DAY",
+      orderLegCollection: legs,
+    }.merge(described_class.debit(1.25).order_fields)
+
+    json = JSON.parse(JSON.generate(order))
+
+    expect(json).to(include("orderType" => "NET_DEBIT", "price" => "1.25", "complexOrderStrategyType" => "VERTICAL"))
+    expect(json["orderLegCollection"].map { |leg| leg["instruction"] }).to(eq(["BUY_TO_OPEN", "SELL_TO_OPEN"]))
+  end
+
+  it "serializes a credit as a positive NET_CREDIT price" do
+    expect(described_class.credit(0.8).order_fields).to(eq({ orderType: "NET_CREDIT", price: "0.8000" }))
+    expect(described_class.credit(0.8).order_fields(precision: 2)).to(eq({ orderType: "NET_CREDIT", price: "0.80" }))
+  end
+
+  it "reads signed amounts as debits when positive and credits when negative" do
+    expect(described_class.from_signed(1.5)).to(eq(described_class.debit(1.5)))
+    expect(described_class.from_signed(-0.75)).to(be_credit)
+    expect(described_class.from_signed(-0.75).amount).to(eq(Rational(3, 4)))
+    expect(described_class.from_signed(0).price_type).to(eq("NET_ZERO"))
+    expect(described_class.credit(0.75).signed_amount).to(eq(Rational(-3, 4)))
+  end
+
+  it "rejects negative amounts and unknown types" do
+    expect { described_class.debit(-1) }.to(raise_error(ArgumentError, /NET_CREDIT/))
+    expect { described_class.new(1, "LIMIT") }.to(raise_error(ArgumentError, /Unknown net price type/))
+    expect { described_class.new(1, "NET_ZERO") }.to(raise_error(ArgumentError, /must be 0/))
+  end
+end
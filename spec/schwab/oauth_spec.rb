@@ -133,6 +133,7 @@ RSpec.describe(Schwab::OAuth) do
         expect(result[:refresh_token]).to(be_a(String))
         expect(result[:expires_in]).to(be_a(Integer))
         expect(result[:expires_at]).to(be_a(Time))
+        expect(result[:issued_at]).to(be_within(5).of(Time.now))
         expect(result[:token_type]).to(eq("Bearer"))
       end
     end
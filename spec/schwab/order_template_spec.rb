@@ -0,0 +1,111 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+
+RSpec.describe(Schwab::OrderTemplate) do
+  let(:quote) { { "AAPL" => { quote: { lastPrice: 150.25, bidPrice: 150.1, askPrice: 150.3 } } } }
+
+  describe "#build" do
+    it "substitutes the symbol and quantity into the fixed fields" do
+      template = described_class.new(instruction: "sell", order_type: "LIMIT", duration: "GOOD_TILL_CANCEL", price: 200)
+
+      order = template.build("MSFT", 15)
+
+      expect(order).to(eq({
+        orderType: "LIMIT",
+        session: "NORMAL",
+        duration: "GOOD_TILL_CANCEL",
+        orderStrategyType: "SINGLE",
+        orderLegCollection: [
+          { instruction: "SELL", quantity: 15, instrument: { symbol: "MSFT", assetType: "EQUITY" } },
+        ],
+        price: "200.00",
+      }))
+      expect(template.build("AAPL", 3)[:orderLegCollection].first)
+        .to(include(quantity: 3, instrument: { symbol: "AAPL", assetType: "EQUITY" }))
+    end
+
+    it "prices a limit order as an offset from the chosen quote price" do
+      template = described_class.new(instruction: "BUY", price_offset: -0.05, price_reference: "bid")
+
+      expect(template.build("AAPL", 10, quote: quote)[:price]).to(eq("150.05"))
+    end
+
+    it "offsets from the last price by default, or from a price given directly" do
+      template = described_class.new(instruction: "BUY", price_offset: 0.1)
+
+      expect(template.build("AAPL", 10, quote: Schwab::Resources::Quote.new(quote["AAPL"]))[:price]).to(eq("150.35"))
+      expect(template.build("AAPL", 10, quote: 42)[:price]).to(eq("42.10"))
+    end
+
+    it "offsets stop and limit prices together" do
+      template = described_class.new(
+        instruction: "SELL",
+        order_type: "STOP_LIMIT",
+        stop_offset: -1,
+        price_offset: -1.5,
+        price_reference: "mid",
+      )
+
+      order = template.build("AAPL", 10, quote: quote)
+
+      expect(order).to(include(orderType: "STOP_LIMIT", stopPrice: "149.20", price: "148.70"))
+    end
+
+    it "leaves market orders unpriced" do
+      order = described_class.new(instruction: "BUY", order_type: "MARKET").build("AAPL", 1)
+
+      expect(order).not_to(have_key(:price))
+      expect(order).not_to(have_key(:stopPrice))
+    end
+
+    it "requires a quote with the reference price when pricing from an offset" do
+      template = described_class.new(instruction: "BUY", price_offset: -0.05, price_reference: "ask")
+
+      expect { template.build("AAPL", 10) }.to(raise_error(ArgumentError, /quote is required/))
+      expect { template.build("AAPL", 10, quote: { quote: { lastPrice: 150.0 } }) }
+        .to(raise_error(ArgumentError, /no ask price/))
+    end
+
+    it "rejects offsets that leave no positive price" do
+      template = described_class.new(instruction: "BUY", price_offset: -5)
+
+      expect { template.build("XYZ", 10, quote: 4.5) }.to(raise_error(ArgumentError, /not a positive price/))
+    end
+
+    it "rejects a non-positive quantity" do
+      template = described_class.new(instruction: "BUY", order_type: "MARKET")
+
+      expect { template.build("AAPL", 0) }.to(raise_error(ArgumentError, /quantity/))
+    end
+
+    it "validates the built order" do
+      template = described_class.new(instruction: "BUY", order_type: "MARKET", session: "OVERNIGHT")
+
+      expect { template.build("AAPL", 1) }.to(raise_error(Schwab::InvalidRequestError))
+    end
+  end
+
+  describe "#initialize" do
+    it "requires the prices the order type needs" do
+      expect { described_class.new(instruction: "BUY") }.to(raise_error(ArgumentError, /need a price/))
+      expect { described_class.new(instruction: "SELL", order_type: "STOP") }
+        .to(raise_error(ArgumentError, /need a stop price/))
+    end
+
+    it "rejects prices the order type does not take" do
+      expect { described_class.new(instruction: "BUY", order_type: "MARKET", price: 10) }
+        .to(raise_error(ArgumentError, /do not take a price/))
+    end
+
+    it "rejects a fixed price combined with an offset" do
+      expect { described_class.new(instruction: "BUY", price: 10, price_offset: 0.1) }
+        .to(raise_error(ArgumentError, /not both/))
+    end
+
+    it "rejects an unknown price reference" do
+      expect { described_class.new(instruction: "BUY", price_offset: 0.1, price_reference: "vwap") }
+        .to(raise_error(ArgumentError, /Unknown price reference/))
+    end
+  end
+end
@@ -0,0 +1,69 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+
+RSpec.describe(Schwab::Performance) do
+  let(:deposit) { described_class::CashFlow.new(date: Date.new(2024, 7, 1), amount: 1000.0) }
+  let(:valuations) do
+    { Date.new(2024, 1, 1) => 1000.0, Date.new(2024, 7, 1) => 1600.0, Date.new(2024, 12, 31) => 2400.0 }
+  end
+
+  describe ".returns" do
+    it "computes TWR and MWR for a known cash-flow scenario" do
+      report = described_class.returns(valuations, [deposit])
+
+      # Jan-Jul: 1000 -> 600 before the deposit (-40%); Jul-Dec: 1600 -> 2400 (+50%)
+      expect(report.time_weighted_return).to(be_within(1e-6).of(0.6 * 1.5 - 1))
+      expect(report.money_weighted_return).to(be_within(1e-5).of(0.27186))
+      expect(report.annualized_money_weighted_return).to(be_within(1e-5).of(0.27186))
+      expect(report.net_cash_flow).to(eq(1000.0))
+      expect(report.gain).to(eq(400.0))
+      expect(report.skipped_periods).to(be_empty)
+    end
+
+    it "matches simple growth when there are no flows" do
+      report = described_class.returns({ "2023-01-01" => 1000.0, "2024-01-01" => 1100.0 })
+
+      expect(report.time_weighted_return).to(be_within(1e-6).of(0.1))
+      expect(report.money_weighted_return).to(be_within(1e-6).of(0.1))
+      expect(report.annualized_money_weighted_return).to(be_within(1e-6).of(0.1))
+    end
+
+    it "annualizes the MWR over partial years" do
+      report = described_class.returns({ Date.new(2024, 1, 1) => 1000.0, Date.new(2024, 7, 1) => 1100.0 })
+
+      expect(report.money_weighted_return).to(be_within(1e-6).of(0.1))
+      expect(report.annualized_money_weighted_return).to(be_within(1e-6).of((1.1**(365.0 / 182)) - 1))
+    end
+
+    it "skips periods that start at zero and funds the account with a deposit" do
+      report = described_class.returns(
+        { Date.new(2024, 1, 1) => 0.0, Date.new(2024, 2, 1) => 1000.0, Date.new(2024, 3, 1) => 1100.0 },
+        [{ date: "2024-02-01", amount: 1000.0 }],
+      )
+
+      expect(report.skipped_periods).to(eq([[Date.new(2024, 1, 1), Date.new(2024, 2, 1)]]))
+      expect(report.time_weighted_return).to(be_within(1e-6).of(0.1))
+      expect(report.annualized_money_weighted_return).to(be_within(1e-4).of((1.1**(365.0 / 29)) - 1))
+    end
+
+    it "leaves returns nil when they are undefined" do
+      report = described_class.returns({ Date.new(2024, 1, 1) => 0.0, Date.new(2024, 3, 1) => 0.0 })
+
+      expect(report.time_weighted_return).to(be_nil)
+      expect(report.money_weighted_return).to(be_nil)
+    end
+
+    it "ignores flows outside the range" do
+      early = described_class::CashFlow.new(date: Date.new(2024, 1, 1), amount: 500.0)
+      late = described_class::CashFlow.new(date: Date.new(2025, 1, 1), amount: 500.0)
+
+      expect(described_class.returns(valuations, [deposit, early, late]).net_cash_flow).to(eq(1000.0))
+    end
+
+    it "requires two valuation dates" do
+      expect { described_class.returns({ Date.new(2024, 1, 1) => 1000.0 }) }
+        .to(raise_error(ArgumentError, /two valuation dates/))
+    end
+  end
+end
@@ -0,0 +1,43 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "schwab/poller"
+
+RSpec.describe(Schwab::Poller) do
+  let(:poller_class) do
+    Class.new do
+      include Schwab::Poller
+
+      attr_reader :ticks
+
+      def initialize(interval)
+        init_poller(interval)
+        @ticks = Queue.new
+      end
+
+      private
+
+      def tick
+        @ticks << Time.now
+      end
+    end
+  end
+  let(:poller) { poller_class.new(60) }
+
+  after { poller.stop }
+
+  it "ticks as soon as it starts and stops without waiting out the interval" do
+    expect(poller.start).to(be(poller))
+    poller.ticks.pop
+    expect(poller).to(be_running)
+
+    started = Process.clock_gettime(Process::CLOCK_MONOTONIC)
+    poller.stop
+    expect(Process.clock_gettime(Process::CLOCK_MONOTONIC) - started).to(be < 1)
+    expect(poller).not_to(be_running)
+  end
+
+  it "rejects a non-positive interval" do
+    expect { poller_class.new(0) }.to(raise_error(ArgumentError, /interval/))
+  end
+end
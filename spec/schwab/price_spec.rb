@@ -0,0 +1,88 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "schwab/price"
+
+RSpec.describe(Schwab::Price) do
+  describe ".format" do
+    {
+      1234.5 => "1234.50",
+      150.1 => "150.10",
+      150 => "150.00",
+      0.0001 => "0.0001",
+      1e-5 => "0.0000",
+      0.12345 => "0.1235",
+      1.005 => "1.01",
+      -2.5 => "-2.50",
+      "12.3" => "12.30",
+    }.each do |value, expected|
+      it "formats #{value.inspect} as #{expected}" do
+        expect(described_class.format(value)).to(eq(expected))
+      end
+    end
+
+    it "never uses scientific notation" do
+      expect(described_class.format(1.5e-7, precision: 8)).to(eq("0.00000015"))
+      expect(described_class.format(2e10)).to(eq("20000000000.00"))
+    end
+
+    it "uses four places for forex prices" do
+      expect(described_class.format(1.0842, asset_type: "FOREX")).to(eq("1.0842"))
+    end
+
+    it "rejects values that are not numbers" do
+      expect { described_class.format("abc") }.to(raise_error(ArgumentError))
+      expect { described_class.format(nil) }.to(raise_error(ArgumentError, /Invalid price/))
+    end
+  end
+
+  describe ".display" do
+    after { Schwab.configuration.display_precision = {} }
+
+    [
+      [150.1, "EQUITY", "150.10"],
+      [0.5, "EQUITY", "0.50"],
+      [2.345, "OPTION", "2.35"],
+      [1.084215, "FOREX", "1.08422"],
+      [4512.25, "FUTURE", "4512.25"],
+      [99.5, nil, "99.50"],
+    ].each do |value, asset_type, expected|
+      it "formats #{value} for #{asset_type.inspect} as #{expected}" do
+        expect(described_class.display(value, asset_type)).to(eq(expected))
+      end
+    end
+
+    it "uses configured places per asset type" do
+      Schwab.configuration.display_precision = { option: 4 }
+
+      expect(described_class.display(2.345, "OPTION")).to(eq("2.3450"))
+      expect(described_class.display(1.084215, "forex")).to(eq("1.08422"))
+    end
+
+    it "rejects invalid configured places" do
+      expect { Schwab.configuration.display_precision = { option: -1 } }.to(raise_error(ArgumentError, /display_precision/))
+    end
+  end
+
+  describe ".format_order" do
+    it "formats price fields, including child orders" do
+      order = {
+        orderType: "STOP_LIMIT",
+        price: 150.1,
+        stopPrice: 149,
+        orderLegCollection: [{ instruction: "BUY", quantity: 10, instrument: { symbol: "AAPL", assetType: "EQUITY" } }],
+        childOrderStrategies: [{ "orderType" => "LIMIT", "price" => 0.05 }],
+      }
+
+      formatted = described_class.format_order(order)
+
+      expect(formatted).to(include(price: "150.10", stopPrice: "149.00"))
+      expect(formatted[:childOrderStrategies].first["price"]).to(eq("0.0500"))
+      expect(formatted[:orderLegCollection]).to(eq(order[:orderLegCollection]))
+    end
+
+    it "applies a fixed precision" do
+      expect(described_class.format_order({ price: 1.5 }, 3)).to(eq({ price: "1.500" }))
+    end
+  end
+end
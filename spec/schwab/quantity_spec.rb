@@ -0,0 +1,86 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "schwab/quantity"
+
+RSpec.describe(Schwab::Quantity) do
+  describe ".round" do
+    {
+      ["EQUITY", :down] => 12,
+      ["EQUITY", :nearest] => 13,
+      ["EQUITY", :up] => 13,
+      ["OPTION", :down] => 12,
+      ["OPTION", :up] => 13,
+      ["FUTURE", :nearest] => 13,
+    }.each do |(asset_type, mode), expected|
+      it "rounds #{asset_type} to whole units with #{mode}" do
+        expect(described_class.round(12.7, asset_type, mode: mode)).to(eq(expected))
+      end
+    end
+
+    it "keeps decimals for fractional-enabled equities" do
+      expect(described_class.round(12.7, "EQUITY", fractional: true)).to(eq(12.7))
+    end
+
+    it "ignores the fractional flag for options" do
+      expect(described_class.round(1.5, "OPTION", fractional: true)).to(eq(1))
+    end
+
+    it "keeps decimals for mutual funds" do
+      expect(described_class.round(3.1234567, "MUTUAL_FUND")).to(eq(3.123457))
+    end
+
+    it "rejects unknown modes" do
+      expect { described_class.round(1.5, "EQUITY", mode: :banker) }.to(raise_error(ArgumentError, /rounding mode/))
+    end
+  end
+
+  describe ".round_order" do
+    it "rounds each leg by its instrument's asset type" do
+      order = {
+        "orderType" => "LIMIT",
+        "orderLegCollection" => [
+          { "quantity" => 2.9, "instrument" => { "assetType" => "OPTION" } },
+          { "quantity" => 0.5, "instrument" => { "assetType" => "MUTUAL_FUND" } },
+        ],
+      }
+
+      rounded = described_class.round_order(order, :down)
+
+      expect(rounded["orderLegCollection"].map { |leg| leg["quantity"] }).to(eq([2, 0.5]))
+      expect(order["orderLegCollection"].first["quantity"]).to(eq(2.9))
+    end
+
+    it "rounds equity legs to whole shares by default" do
+      order = { orderLegCollection: [{ quantity: 10.8, instrument: { symbol: "AAPL", assetType: "EQUITY" } }] }
+
+      expect(described_class.round_order(order, :down)[:orderLegCollection].first[:quantity]).to(eq(10))
+    end
+
+    it "keeps decimals for the fractional-enabled symbols given" do
+      order = { orderLegCollection: [{ quantity: 2.5, instrument: { symbol: "VTI", assetType: "EQUITY" } }] }
+
+      expect(described_class.round_order(order, :down, fractional: ["vti"])).to(eq(order))
+    end
+
+    it "rounds equity legs by a per-symbol fractional lookup" do
+      order = {
+        orderLegCollection: [
+          { quantity: 2.5, instrument: { symbol: "AAPL", assetType: "EQUITY" } },
+          { quantity: 2.5, instrument: { symbol: "XYZ", assetType: "EQUITY" } },
+        ],
+      }
+
+      rounded = described_class.round_order(order, :down, fractional: { "AAPL" => true, "XYZ" => false })
+
+      expect(rounded[:orderLegCollection].map { |leg| leg[:quantity] }).to(eq([2.5, 2]))
+    end
+
+    it "rejects legs that would round to 0" do
+      order = { orderLegCollection: [{ quantity: 0.5, instrument: { symbol: "AAPL", assetType: "EQUITY" } }] }
+
+      expect { described_class.round_order(order, :down) }
+        .to(raise_error(Schwab::InvalidRequestError, /AAPL quantity 0.5 rounds to 0/))
+    end
+  end
+end
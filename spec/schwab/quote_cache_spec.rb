@@ -0,0 +1,69 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+
+RSpec.describe(Schwab::QuoteCache) do
+  describe ".key" do
+    it "combines the symbol, fields, and quote type" do
+      expect(described_class.key("AAPL")).to(eq("AAPL|all|regular"))
+      expect(described_class.key("SPY", fields: ["quote", "reference"], indicative: true))
+        .to(eq("SPY|quote,reference|indicative"))
+    end
+  end
+
+  it "serves quotes until the ttl passes" do
+    clock = [100.0]
+    cache = described_class.new(ttl: 5)
+    allow(cache).to(receive(:now) { clock.first })
+    cache.write("AAPL|all|regular", { "symbol" => "AAPL" })
+
+    clock[0] = 104.9
+    expect(cache.read("AAPL|all|regular")).to(eq({ "symbol" => "AAPL" }))
+
+    clock[0] = 105.0
+    expect(cache.read("AAPL|all|regular")).to(be_nil)
+    expect(cache.size).to(eq(0))
+  end
+
+  it "evicts the least recently used quote when full" do
+    cache = described_class.new(max_size: 2)
+    cache.write("A", { "symbol" => "A" })
+    cache.write("B", { "symbol" => "B" })
+    cache.read("A")
+    cache.write("C", { "symbol" => "C" })
+
+    expect(cache.read("B")).to(be_nil)
+    expect(cache.read("A")).to(eq({ "symbol" => "A" }))
+    expect(cache.read("C")).to(eq({ "symbol" => "C" }))
+  end
+
+  it "is safe for concurrent readers and writers" do
+    cache = described_class.new(max_size: 50)
+
+    threads = Array.new(8) do |thread|
+      Thread.new do
+        200.times do |i|
+          key = "S#{(thread * 200 + i) % 75}"
+          cache.write(key, { "i" => i })
+          cache.read(key)
+        end
+      end
+    end
+    threads.each(&:join)
+
+    expect(cache.size).to(eq(50))
+  end
+
+  it "clears every entry" do
+    cache = described_class.new
+    cache.write("A", {})
+    cache.clear
+
+    expect(cache.size).to(eq(0))
+  end
+
+  it "rejects invalid settings" do
+    expect { described_class.new(ttl: 0) }.to(raise_error(ArgumentError, /ttl/))
+    expect { described_class.new(max_size: 1.5) }.to(raise_error(ArgumentError, /max_size/))
+  end
+end
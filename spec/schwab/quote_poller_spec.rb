@@ -0,0 +1,78 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "schwab/market_data"
+
+RSpec.describe(Schwab::QuotePoller) do
+  let(:client) { instance_double("Schwab::Client", config: Schwab::Configuration.new) }
+  let(:requested) { Queue.new }
+  let(:updates) { Queue.new }
+  let(:poller) do
+    described_class.new(["aapl", "MSFT"], interval: 0.01, client: client) do |quotes, error|
+      updates << [quotes, error]
+    end
+  end
+
+  before do
+    allow(client).to(receive(:get)) do |_path, params|
+      requested << params[:symbols]
+      params[:symbols].split(",").to_h { |symbol| [symbol, { "symbol" => symbol, "quote" => { "lastPrice" => 1.0 } }] }
+    end
+  end
+
+  after { poller.stop }
+
+  it "delivers quotes keyed by symbol on each tick" do
+    poller.start
+    quotes, error = updates.pop
+
+    expect(error).to(be_nil)
+    expect(quotes.keys).to(eq(["AAPL", "MSFT"]))
+    expect(quotes["AAPL"]).to(be_a(Schwab::Resources::Quote))
+    wait_for { requested.size >= 2 }
+  end
+
+  it "picks up added and removed symbols on the next poll" do
+    poller.start
+    requested.pop
+
+    poller.add("nvda")
+    poller.remove("MSFT")
+
+    wait_for { requested.pop == "AAPL,NVDA" }
+    expect(poller.symbols).to(eq(["AAPL", "NVDA"]))
+  end
+
+  it "reports errors and keeps polling" do
+    calls = 0
+    allow(client).to(receive(:get)) do
+      calls += 1
+      raise Schwab::ServerError, "down" if calls == 1
+
+      {}
+    end
+
+    poller.start
+    _, error = updates.pop
+    expect(error).to(be_a(Schwab::ServerError))
+
+    quotes, error = updates.pop
+    expect(error).to(be_nil)
+    expect(quotes).to(eq({}))
+  end
+
+  it "stops polling" do
+    poller.start
+    requested.pop
+    poller.stop
+    count = requested.size
+
+    sleep(0.05)
+    expect(requested.size).to(eq(count))
+    expect(poller.running?).to(be(false))
+  end
+
+  it "requires a positive interval" do
+    expect { described_class.new("AAPL", interval: 0) { nil } }.to(raise_error(ArgumentError))
+  end
+end
@@ -0,0 +1,52 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "stringio"
+require "schwab/market_data"
+
+RSpec.describe(Schwab::QuoteSnapshot) do
+  let(:io) { StringIO.new }
+  let(:config) { Schwab::Configuration.new.tap { |c| c.quote_snapshot = io } }
+  let(:client) { instance_double("Schwab::Client", config: config) }
+  let(:quotes) { { "AAPL" => { "quote" => { "lastPrice" => 190.5 } }, "MSFT" => { "quote" => { "lastPrice" => 410.0 } } } }
+
+  it "tees get_quotes results into the configured writer" do
+    allow(client).to(receive(:get).and_return(quotes))
+
+    Schwab::MarketData.get_quotes(["aapl", "msft"], client: client)
+
+    record = Schwab::QuoteSnapshot::Reader.new(StringIO.new(io.string)).first
+    expect(record.symbols).to(eq(["AAPL", "MSFT"]))
+    expect(record.quotes).to(eq(quotes))
+    expect(record.timestamp).to(be_within(5).of(Time.now))
+  end
+
+  it "replays recorded quotes in order through MarketData" do
+    writer = Schwab::QuoteSnapshot::Writer.new(io)
+    writer.write(["AAPL", "MSFT"], quotes, timestamp: Time.utc(2024, 5, 1, 14, 30))
+    writer.write(["AAPL"], { "AAPL" => { "quote" => { "lastPrice" => 191.0 } } }, timestamp: Time.utc(2024, 5, 1, 14, 31))
+
+    replay = Schwab::QuoteSnapshot::Replay.new(Schwab::QuoteSnapshot::Reader.new(StringIO.new(io.string)))
+
+    first = Schwab::MarketData.get_quotes("AAPL", client: replay)
+    second = Schwab::MarketData.get_quotes("AAPL", client: replay)
+
+    expect(first).to(eq({ "AAPL" => quotes["AAPL"] }))
+    expect(second["AAPL"]["quote"]["lastPrice"]).to(eq(191.0))
+    expect { Schwab::MarketData.get_quotes("AAPL", client: replay) }.to(raise_error(Schwab::Error, /exhausted/))
+  end
+
+  it "starts over when repeating" do
+    replay = Schwab::QuoteSnapshot::Replay.new(
+      [Schwab::QuoteSnapshot::Record.new(timestamp: Time.now, symbols: ["AAPL"], quotes: quotes)],
+      repeat: true,
+    )
+
+    2.times { Schwab::MarketData.get_quotes("AAPL", client: replay) }
+    expect(replay.remaining).to(eq(0))
+  end
+
+  it "rejects targets that cannot be written to" do
+    expect { Schwab::Configuration.new.quote_snapshot = "quotes.jsonl" }.to(raise_error(ArgumentError))
+  end
+end
@@ -0,0 +1,142 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+
+RSpec.describe(Schwab::RealizedGains) do
+  def trade(date, symbol, quantity, price, effect: nil, asset_type: "EQUITY", id: nil)
+    {
+      "activityId" => id,
+      "type" => "TRADE",
+      "tradeDate" => date,
+      "netAmount" => -(quantity * price),
+      "transferItems" => [
+        { "instrument" => { "symbol" => "CURRENCY_USD", "assetType" => "CURRENCY" }, "amount" => -(quantity * price) },
+        {
+          "instrument" => { "symbol" => symbol, "assetType" => asset_type },
+          "amount" => quantity,
+          "price" => price,
+          "positionEffect" => effect,
+        },
+      ],
+    }
+  end
+
+  let(:transactions) do
+    [
+      trade("2023-01-10", "AAPL", 10, 100.0),
+      trade("2023-06-10", "AAPL", 10, 150.0),
+      trade("2024-03-01", "AAPL", -15, 200.0, effect: "CLOSING"),
+    ]
+  end
+
+  it "matches sells to the oldest lots first by default" do
+    lots = described_class.compute(transactions)
+
+    expect(lots.map(&:quantity)).to(eq([10.0, 5.0]))
+    expect(lots.map(&:cost_basis)).to(eq([1000.0, 750.0]))
+    expect(lots.map(&:proceeds)).to(eq([2000.0, 1000.0]))
+    expect(lots.map(&:gain)).to(eq([1000.0, 250.0]))
+    expect(lots.map(&:term)).to(eq([:long, :short]))
+  end
+
+  it "matches the newest lots first with LIFO" do
+    lots = described_class.compute(transactions, method: :lifo)
+
+    expect(lots.map(&:open_date)).to(eq([Date.new(2023, 6, 10), Date.new(2023, 1, 10)]))
+    expect(lots.map(&:quantity)).to(eq([10.0, 5.0]))
+  end
+
+  it "matches the most expensive lots first with HIFO" do
+    transactions.unshift(trade("2022-12-01", "AAPL", 5, 120.0))
+    lots = described_class.compute(transactions, method: :hifo)
+
+    expect(lots.map(&:cost_basis)).to(eq([1500.0, 600.0]))
+  end
+
+  it "includes fees from the net amount in cost basis and proceeds" do
+    buy = trade("2024-01-02", "MSFT", 10, 50.0).merge("netAmount" => -505.0)
+    sell = trade("2024-02-02", "MSFT", -10, 60.0).merge("netAmount" => 595.0)
+
+    expect(described_class.compute([buy, sell]).first.gain).to(eq(90.0))
+  end
+
+  it "closes short sales with later buys as short-term" do
+    lots = described_class.compute([
+      trade("2022-01-03", "TSLA", -5, 300.0),
+      trade("2024-01-03", "TSLA", 5, 200.0, effect: "CLOSING"),
+    ])
+
+    expect(lots.first).to(have_attributes(short_sale: true, proceeds: 1500.0, cost_basis: 1000.0, term: :short))
+  end
+
+  it "reports closes without a matching open with an unknown basis" do
+    lot = described_class.compute([trade("2024-05-01", "NVDA", -2, 900.0, effect: "CLOSING")]).first
+
+    expect(lot).to(have_attributes(proceeds: 1800.0, cost_basis: nil, open_date: nil, term: nil))
+    expect(lot.gain).to(be_nil)
+  end
+
+  it "filters by the year a lot was closed and ignores non-trades" do
+    transactions << trade("2023-12-15", "AAPL", -1, 140.0)
+    transactions << { "type" => "DIVIDEND_OR_INTEREST", "tradeDate" => "2024-02-01", "netAmount" => 12.0 }
+
+    expect(described_class.compute(transactions, year: 2024).map(&:close_date).uniq).to(eq([Date.new(2024, 3, 1)]))
+  end
+
+  it "orders same-day trades by time when transactions are newest first" do
+    buy = trade("2024-04-02", "AMD", 10, 150.0).merge("time" => "2024-04-02T14:30:00+0000")
+    sell = trade("2024-04-02", "AMD", -10, 155.0, effect: "CLOSING").merge("time" => "2024-04-02T19:45:00+0000")
+    lots = described_class.compute([sell, buy])
+
+    expect(lots.size).to(eq(1))
+    expect(lots.first).to(have_attributes(cost_basis: 1500.0, proceeds: 1550.0, term: :short, short_sale: false))
+    expect(described_class.open_lots([sell, buy])).to(be_empty)
+  end
+
+  it "rejects unknown methods" do
+    expect { described_class.compute(transactions, method: :average) }.to(raise_error(ArgumentError, /average/))
+  end
+
+  describe ".open_lots" do
+    it "returns what is left of each lot after matching sells" do
+      transactions << trade("2024-01-05", "AAPL", 10, 120.0, id: 4)
+      transactions << trade("2024-02-01", "MSFT", 3, 400.0, id: 5)
+      transactions[1]["activityId"] = 2
+
+      lots = described_class.open_lots(transactions)
+
+      expect(lots.map(&:id)).to(eq(["2", "4", "5"]))
+      expect(lots.map(&:quantity)).to(eq([5.0, 10.0, 3.0]))
+      expect(lots.map(&:cost_basis)).to(eq([750.0, 1200.0, 1200.0]))
+      expect(lots.first.unit_cost).to(eq(150.0))
+      expect(lots.first.holding_period(Date.new(2024, 6, 11))).to(eq(:long))
+      expect(lots[1].holding_period(Date.new(2024, 6, 11))).to(eq(:short))
+    end
+  end
+
+  describe ".tax_lot_method" do
+    let(:lots) do
+      [
+        described_class::OpenLot.new(id: "1", symbol: "AAPL", quantity: 10.0, open_date: Date.new(2023, 1, 10), cost_basis: 1200.0),
+        described_class::OpenLot.new(id: "2", symbol: "AAPL", quantity: 10.0, open_date: Date.new(2023, 6, 10), cost_basis: 1500.0),
+        described_class::OpenLot.new(id: "3", symbol: "AAPL", quantity: 10.0, open_date: Date.new(2024, 1, 5), cost_basis: 1300.0),
+        described_class::OpenLot.new(id: "4", symbol: "AAPL", quantity: 10.0, open_date: Date.new(2024, 2, 1), cost_basis: 1000.0),
+      ]
+    end
+
+    it "picks a method that sells the requested lot first" do
+      expect(described_class.tax_lot_method(lots, "1")).to(eq("FIFO"))
+      expect(described_class.tax_lot_method(lots, "2", quantity: 5)).to(eq("HIGH_COST"))
+      expect(described_class.tax_lot_method(lots, 4)).to(eq("LIFO"))
+    end
+
+    it "returns nil when no method sells the lot first" do
+      expect(described_class.tax_lot_method(lots, "3")).to(be_nil)
+    end
+
+    it "rejects lots that are not part of the position or too small" do
+      expect { described_class.tax_lot_method(lots, "9") }.to(raise_error(ArgumentError, /not an open lot/))
+      expect { described_class.tax_lot_method(lots, "1", quantity: 11) }.to(raise_error(ArgumentError, /less than 11/))
+    end
+  end
+end
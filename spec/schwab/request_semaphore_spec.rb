@@ -0,0 +1,60 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "schwab/connection"
+
+RSpec.describe(Schwab::RequestSemaphore) do
+  it "rejects non-positive caps" do
+    expect { described_class.new(0) }.to(raise_error(ArgumentError, /positive/))
+    expect { described_class.new(1.5) }.to(raise_error(ArgumentError, /positive/))
+  end
+
+  it "gives up after the timeout when every slot is taken" do
+    semaphore = described_class.new(1)
+    semaphore.acquire
+
+    expect(semaphore.acquire(timeout: 0.01)).to(be(false))
+    semaphore.release
+    expect(semaphore.acquire(timeout: 0.01)).to(be(true))
+  end
+
+  describe "connection middleware" do
+    let(:config) do
+      Schwab::Configuration.new.tap do |c|
+        c.api_base_url = "https://api.test.com"
+        c.max_concurrent_requests = 3
+      end
+    end
+    let(:connection) { Schwab::Connection.build(access_token: "token", config: config) }
+
+    it "never has more requests in flight than the cap under load" do
+      peak = 0
+      peak_lock = Mutex.new
+      stub_request(:get, "https://api.test.com/marketdata/v1/quotes").to_return do
+        peak_lock.synchronize { peak = [peak, config.request_semaphore.in_flight].max }
+        sleep(0.01)
+        { status: 200, body: "{}", headers: { "Content-Type" => "application/json" } }
+      end
+
+      Array.new(12) { Thread.new { connection.get("/marketdata/v1/quotes") } }.each(&:join)
+
+      expect(peak).to(eq(3))
+      expect(config.request_semaphore.in_flight).to(eq(0))
+    end
+
+    it "releases the slot when a request fails" do
+      stub_request(:get, "https://api.test.com/marketdata/v1/quotes").to_return(status: 500, body: "")
+
+      expect { connection.get("/marketdata/v1/quotes") }.to(raise_error(Faraday::ServerError))
+      expect(config.request_semaphore.in_flight).to(eq(0))
+    end
+
+    it "times out waiting for a slot" do
+      config.max_concurrent_requests = 1
+      config.timeout = 0.01
+      config.request_semaphore.acquire
+
+      expect { connection.get("/marketdata/v1/quotes") }.to(raise_error(Faraday::TimeoutError, /max 1 in flight/))
+    end
+  end
+end
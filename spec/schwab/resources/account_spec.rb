@@ -136,6 +136,51 @@ RSpec.describe(Schwab::Resources::Account) do
     end
   end
 
+  describe "#max_buy_quantity" do
+    let(:equity_quote) { { symbol: "AAPL", assetMainType: "EQUITY", quote: { askPrice: 175.0 } } }
+    let(:option_quote) { { symbol: "AAPL  240517C00190000", assetMainType: "OPTION", quote: { askPrice: 2.5 } } }
+    let(:cash_account) do
+      described_class.new({ type: "CASH", currentBalances: { cashAvailableForTrading: 10_000.0, cashBalance: 12_000.0 } })
+    end
+    let(:margin_account) do
+      described_class.new({
+        type: "MARGIN",
+        currentBalances: { cashBalance: 10_000.0, buyingPower: 20_000.0, optionBuyingPower: 10_000.0 },
+      })
+    end
+
+    it "uses the cash available for trading in cash accounts" do
+      expect(cash_account.max_buy_quantity(equity_quote)).to(eq(57))
+      expect(cash_account.max_buy_quantity(option_quote)).to(eq(40))
+    end
+
+    it "includes margin in margin accounts" do
+      expect(margin_account.max_buy_quantity(equity_quote)).to(eq(114))
+    end
+
+    it "uses option buying power and the contract multiplier for options" do
+      expect(margin_account.max_buy_quantity(option_quote, "OPTION")).to(eq(40))
+    end
+
+    it "keeps fractional quantities for mutual funds" do
+      quote = { assetMainType: "MUTUAL_FUND", quote: { askPrice: 3.0 } }
+
+      expect(cash_account.max_buy_quantity(quote)).to(eq(3333.333333))
+    end
+
+    it "returns zero without buying power" do
+      account = described_class.new({ type: "CASH", currentBalances: { cashAvailableForTrading: 0.0 } })
+
+      expect(account.max_buy_quantity(equity_quote)).to(eq(0))
+      expect(described_class.new({}).max_buy_quantity(equity_quote)).to(eq(0))
+    end
+
+    it "raises when the quote has no ask price" do
+      expect { margin_account.max_buy_quantity({ assetMainType: "EQUITY", quote: {} }) }
+        .to(raise_error(ArgumentError, /ask price/))
+    end
+  end
+
   describe "positions" do
     let(:positions_data) do
       [
@@ -209,6 +254,57 @@ RSpec.describe(Schwab::Resources::Account) do
     end
   end
 
+  describe "position categories" do
+    let(:account) do
+      described_class.new({
+        positions: [
+          { instrument: { symbol: "AAPL", assetType: "EQUITY" }, longQuantity: 100, marketValue: 15_000.0 },
+          { instrument: { symbol: "TSLA", assetType: "EQUITY" }, shortQuantity: 20, marketValue: -4_000.0 },
+          { instrument: { symbol: "MSFT", assetType: "EQUITY" }, longQuantity: 10, marketValue: 4_000.0 },
+          { instrument: { symbol: "AAPL  250117C00150000", assetType: "OPTION" }, longQuantity: 2, marketValue: 1_200.0 },
+          { instrument: { symbol: "SPY   250117P00400000", assetType: "OPTION" }, shortQuantity: 3, marketValue: 900.0 },
+          { instrument: { symbol: "SWVXX", assetType: "CASH_EQUIVALENT" }, longQuantity: 5_000, marketValue: 5_000.0 },
+        ],
+      }, client)
+    end
+
+    it "classifies positions by side and asset type" do
+      categories = account.positions.to_h { |position| [position.symbol, position.category] }
+
+      expect(categories).to(eq(
+        "AAPL" => "LONG_EQUITY",
+        "TSLA" => "SHORT_EQUITY",
+        "MSFT" => "LONG_EQUITY",
+        "AAPL  250117C00150000" => "LONG_OPTION",
+        "SPY   250117P00400000" => "SHORT_OPTION",
+        "SWVXX" => "CASH",
+      ))
+    end
+
+    it "buckets positions by category" do
+      groups = account.positions_by_category
+
+      expect(groups.keys).to(contain_exactly("LONG_EQUITY", "SHORT_EQUITY", "LONG_OPTION", "SHORT_OPTION", "CASH"))
+      expect(groups["LONG_EQUITY"].map(&:symbol)).to(eq(["AAPL", "MSFT"]))
+      expect(groups["SHORT_EQUITY"].map(&:symbol)).to(eq(["TSLA"]))
+    end
+
+    it "computes net exposure per category with shorts negative" do
+      expect(account.net_exposure_by_category).to(eq(
+        "LONG_EQUITY" => 19_000.0,
+        "SHORT_EQUITY" => -4_000.0,
+        "LONG_OPTION" => 1_200.0,
+        "SHORT_OPTION" => -900.0,
+        "CASH" => 5_000.0,
+      ))
+    end
+
+    it "returns empty groups for an account without positions" do
+      expect(described_class.new({}).positions_by_category).to(eq({}))
+      expect(described_class.new({}).net_exposure_by_category).to(eq({}))
+    end
+  end
+
   describe "type coercion" do
     it "coerces date/time fields" do
       account = described_class.new({
@@ -292,4 +388,57 @@ RSpec.describe(Schwab::Resources::Account) do
       end
     end
   end
+
+  describe "trading restrictions" do
+    let(:equity_order) do
+      { orderLegCollection: [{ instruction: "BUY", quantity: 1, instrument: { symbol: "AAPL", assetType: "EQUITY" } }] }
+    end
+    let(:option_order) do
+      {
+        orderLegCollection: [{
+          instruction: "BUY_TO_OPEN",
+          quantity: 1,
+          instrument: { symbol: "AAPL  240517C00190000", assetType: "OPTION" },
+        }],
+      }
+    end
+
+    it "allows orders the account can place" do
+      account = described_class.new({ type: "MARGIN", status: "ACTIVE" })
+
+      expect(account.can_trade?(option_order)).to(be(true))
+      expect(account.can_trade!(equity_order)).to(be(account))
+    end
+
+    it "rejects options and short sales in a cash account" do
+      account = described_class.new({ type: "CASH" })
+      short_sale = { orderLegCollection: [{ instruction: "SELL_SHORT", instrument: { assetType: "EQUITY" } }] }
+
+      expect(account.trading_violations(option_order)).to(eq(["Options cannot be traded in a cash account"]))
+      expect(account.trading_violations(short_sale)).to(eq(["Short sales require a margin account"]))
+    end
+
+    it "rejects opening trades on closing-only accounts" do
+      account = described_class.new({ "type" => "MARGIN", "isClosingOnlyRestricted" => true })
+      closing = { orderLegCollection: [{ instruction: "SELL", instrument: { assetType: "EQUITY" } }] }
+
+      expect(account.can_trade?(equity_order)).to(be(false))
+      expect(account.can_trade?(closing)).to(be(true))
+    end
+
+    it "rejects day trades for restricted pattern day traders" do
+      account = described_class.new({ type: "MARGIN", isDayTrader: true, currentBalances: { equity: 10_000 } })
+
+      expect(account.pdt_restricted?).to(be(true))
+      expect(account.can_trade?(equity_order)).to(be(true))
+      expect { account.can_trade!(equity_order, day_trade: true) }
+        .to(raise_error(Schwab::InvalidRequestError, /pattern day trader/))
+    end
+
+    it "rejects orders on inactive accounts" do
+      account = described_class.new({ type: "MARGIN", status: "CLOSED" })
+
+      expect(account.trading_violations(equity_order)).to(eq(["Account is not active (status: CLOSED)"]))
+    end
+  end
 end
@@ -296,6 +296,36 @@ RSpec.describe(Schwab::Resources::Base) do
         expect(resource.price).to(eq(19.99))
         expect(resource.price).to(be_a(Float))
       end
+
+      it "accepts JSON numbers and string-encoded numbers" do
+        expect(test_class.new(price: 150.25).price).to(eq(150.25))
+        expect(test_class.new(price: "150.25").price).to(eq(150.25))
+        expect(test_class.new(price: " 42 ").price).to(eq(42.0))
+      end
+
+      it "treats empty strings as nil" do
+        expect(test_class.new(price: "").price).to(be_nil)
+        expect(test_class.new(count: "").count).to(be_nil)
+      end
+
+      it "returns the original value for non-numeric strings" do
+        expect(test_class.new(price: "N/A").price).to(eq("N/A"))
+      end
+
+      it "applies snake_case field types to camelCase keys" do
+        klass = Class.new(described_class) { set_field_type :average_price, :float }
+        resource = klass.new({ averagePrice: "150.25" })
+
+        expect(resource[:averagePrice]).to(eq(150.25))
+        expect(resource.averagePrice).to(eq(150.25))
+      end
+
+      it "does not apply other snake_case field types to camelCase keys" do
+        klass = Class.new(described_class) { set_field_type :entered_time, :datetime }
+        resource = klass.new({ enteredTime: "2024-01-15T10:30:00Z" })
+
+        expect(resource.enteredTime).to(eq("2024-01-15T10:30:00Z"))
+      end
     end
 
     context "boolean coercion" do
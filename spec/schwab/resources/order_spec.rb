@@ -0,0 +1,515 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "schwab/resources/order"
+
+RSpec.describe(Schwab::Resources::Order) do
+  let(:order_data) do
+    {
+      orderType: "LIMIT",
+      session: "NORMAL",
+      duration: "DAY",
+      price: 150.0,
+      orderStrategyType: "SINGLE",
+      orderLegCollection: [
+        { instruction: "BUY", quantity: 10, instrument: { symbol: "AAPL", assetType: "EQUITY" } },
+      ],
+    }
+  end
+  let(:order) { described_class.new(order_data) }
+
+  describe "routing fields" do
+    it "serializes destination and special instruction into the payload" do
+      order.destination = :nasdaq
+      order.special_instruction = "do_not_reduce"
+
+      expect(order.destination).to(eq("NASDAQ"))
+      expect(order.special_instruction).to(eq("DO_NOT_REDUCE"))
+      expect(order.to_h).to(include(requestedDestination: "NASDAQ", specialInstruction: "DO_NOT_REDUCE"))
+    end
+
+    it "replaces string-keyed values from API responses" do
+      order = described_class.new({ "specialInstruction" => "ALL_OR_NONE" })
+      order.special_instruction = nil
+
+      expect(order.to_h).to(eq({}))
+    end
+
+    it "reads routing fields from API responses" do
+      order = described_class.new({ "requestedDestination" => "AUTO", "specialInstruction" => "ALL_OR_NONE" })
+
+      expect(order.destination).to(eq("AUTO"))
+      expect(order.all_or_none?).to(be(true))
+    end
+  end
+
+  describe "#validate" do
+    it "accepts known destinations and instructions" do
+      order.destination = "CBOE"
+      order.special_instruction = "ALL_OR_NONE"
+
+      expect(order.validate).to(be_empty)
+      expect(order).to(be_valid)
+    end
+
+    it "rejects unknown destinations and instructions" do
+      order.destination = "MOON"
+      order.special_instruction = "HIDDEN"
+
+      expect(order.validate).to(contain_exactly(/destination: MOON/, /special instruction: HIDDEN/))
+    end
+
+    ["FILL_OR_KILL", "IMMEDIATE_OR_CANCEL"].each do |duration|
+      it "rejects ALL_OR_NONE with #{duration}" do
+        order[:duration] = duration
+        order.special_instruction = "ALL_OR_NONE"
+
+        expect(order.validate).to(include(/ALL_OR_NONE cannot be combined with #{duration}/))
+      end
+    end
+
+    it "allows DO_NOT_REDUCE with immediate durations" do
+      order[:duration] = "IMMEDIATE_OR_CANCEL"
+      order.special_instruction = "DO_NOT_REDUCE"
+
+      expect(order).to(be_valid)
+    end
+  end
+
+  describe "activation price" do
+    let(:trailing_stop) do
+      order_data.except(:price).merge(
+        orderType: "TRAILING_STOP",
+        stopPriceLinkBasis: "LAST",
+        stopPriceLinkType: "VALUE",
+        stopPriceOffset: 2.0,
+        orderLegCollection: [{ instruction: "SELL", quantity: 10, instrument: { symbol: "AAPL", assetType: "EQUITY" } }],
+      )
+    end
+
+    it "serializes the activation price of a trailing stop" do
+      order = described_class.new(trailing_stop)
+      order.activation_price = 160.0
+
+      expect(order.to_h).to(include(activationPrice: 160.0))
+      expect(order).to(be_valid)
+      expect(order.summary).to(include("activation 160.00"))
+    end
+
+    it "is omitted from orders that do not set it" do
+      expect(order.to_h).not_to(have_key(:activationPrice))
+      expect(described_class.new(trailing_stop).to_h).not_to(have_key(:activationPrice))
+
+      order.activation_price = nil
+      expect(order.to_h).not_to(have_key(:activationPrice))
+    end
+
+    it "is rejected on order types that do not use it" do
+      order.activation_price = 160.0
+
+      expect(order.validate).to(include(/LIMIT orders do not accept an activation price/))
+    end
+
+    it "must be positive" do
+      order = described_class.new(trailing_stop.merge(activationPrice: 0))
+
+      expect(order.validate).to(eq(["Activation price must be positive"]))
+    end
+  end
+
+  describe "net prices" do
+    let(:vertical) do
+      {
+        orderType: "NET_DEBIT",
+        price: 1.25,
+        session: "NORMAL",
+        duration: "DAY",
+        complexOrderStrategyType: "VERTICAL",
+        orderLegCollection: [
+          { instruction: "BUY_TO_OPEN", quantity: 1, instrument: { symbol: "AAPL  240517C00190000" } },
+          { instruction: "SELL_TO_OPEN", quantity: 1, instrument: { symbol: "AAPL  240517C00200000" } },
+        ],
+      }
+    end
+
+    it "reads the net price of a spread" do
+      order = described_class.new(vertical)
+
+      expect(order).to(be_net_price)
+      expect(order).to(be_complex_strategy)
+      expect(order.net_price).to(eq(Schwab::NetPrice.debit(1.25)))
+      expect(order).to(be_valid)
+    end
+
+    it "requires a net price type for multi-leg LIMIT orders" do
+      order = described_class.new(vertical.merge(orderType: "LIMIT"))
+
+      expect(order.validate).to(include(/Multi-leg LIMIT orders must be priced as NET_DEBIT, NET_CREDIT, or NET_ZERO/))
+      expect(order.net_price).to(be_nil)
+    end
+
+    it "requires a positive price for net debit and credit orders" do
+      expect(described_class.new(vertical.except(:price)).validate).to(include("NET_DEBIT orders require a price"))
+      expect(described_class.new(vertical.merge(price: -0.5)).validate).to(include(/cannot be negative/))
+      expect(described_class.new(vertical.merge(orderType: "NET_ZERO").except(:price))).to(be_valid)
+    end
+
+    it "leaves single-leg limit orders alone" do
+      expect(order).not_to(be_complex_strategy)
+      expect(order).to(be_valid)
+    end
+  end
+
+  describe "session" do
+    it "defaults to the regular session" do
+      expect(described_class.new({}).session).to(eq("NORMAL"))
+    end
+
+    it "serializes the session into the payload" do
+      order.session = :am
+
+      expect(order.session).to(eq("AM"))
+      expect(order.to_h).to(include(session: "AM"))
+      expect(order).to(be_extended_hours)
+    end
+
+    {
+      ["NORMAL", "MARKET"] => true,
+      ["NORMAL", "STOP"] => true,
+      ["AM", "LIMIT"] => true,
+      ["PM", "LIMIT"] => true,
+      ["SEAMLESS", "LIMIT"] => true,
+      ["AM", "MARKET"] => false,
+      ["PM", "STOP"] => false,
+      ["SEAMLESS", "STOP_LIMIT"] => false,
+      ["PM", "TRAILING_STOP"] => false,
+    }.each do |(session, order_type), allowed|
+      it "#{allowed ? "accepts" : "rejects"} #{order_type} orders in the #{session} session" do
+        order.session = session
+        order[:orderType] = order_type
+        order[:stopPrice] = 149.0
+
+        if allowed
+          expect(order).to(be_valid)
+        else
+          expect(order.validate).to(contain_exactly(/#{order_type} orders are not allowed in the #{session} session/))
+        end
+      end
+    end
+
+    it "rejects unknown sessions" do
+      order.session = "OVERNIGHT"
+
+      expect(order.validate).to(contain_exactly(/Unknown session: OVERNIGHT/))
+    end
+  end
+
+  describe "stop-limit prices" do
+    let(:stop_limit) do
+      described_class.new(order_data.merge(orderType: "STOP_LIMIT", stopPrice: 140.0, price: 139.5).tap do |data|
+        data[:orderLegCollection] = [data[:orderLegCollection].first.merge(instruction: "SELL")]
+      end)
+    end
+
+    it "requires both the stop and limit price" do
+      order[:orderType] = "STOP_LIMIT"
+      order[:price] = nil
+
+      expect(order.validate).to(contain_exactly(/require a stop price/, /require a limit price/))
+    end
+
+    it "does not compare prices with the market in structural validation" do
+      stop_limit[:stopPrice] = 160.0
+      stop_limit[:price] = 159.0
+
+      expect(stop_limit).to(be_valid)
+    end
+
+    describe "#validate_with_quote" do
+      it "accepts a sell stop below the market with a nearby limit" do
+        expect(stop_limit.validate_with_quote(Schwab::Resources::Quote.new({ quote: { lastPrice: 150.0 } }))).to(be_empty)
+      end
+
+      it "warns when a sell stop is at or above the market" do
+        warnings = stop_limit.validate_with_quote(135.0)
+
+        expect(warnings.map(&:field)).to(eq([:stop_price]))
+        expect(warnings.first.to_s).to(match(/Sell stop 140.00 is at or above the market \(135.00\)/))
+      end
+
+      it "warns when a buy stop is at or below the market" do
+        stop_limit[:orderLegCollection] = [order_data[:orderLegCollection].first]
+        stop_limit[:price] = 140.5
+
+        expect(stop_limit.validate_with_quote({ quote: { mark: 145.0 } }).map(&:field)).to(eq([:stop_price]))
+        expect(stop_limit.validate_with_quote(135.0)).to(be_empty)
+      end
+
+      it "warns when the limit is on the wrong side of the stop" do
+        stop_limit[:price] = 141.0
+
+        expect(stop_limit.validate_with_quote(150.0).map(&:message)).to(contain_exactly(/above the stop 140.00/))
+      end
+
+      it "warns when the limit is far from the stop" do
+        stop_limit[:price] = 120.0
+
+        expect(stop_limit.validate_with_quote(150.0).map(&:message)).to(contain_exactly(/more than 10% from the stop/))
+      end
+
+      it "returns no warnings without a reference price or for other order types" do
+        expect(stop_limit.validate_with_quote({ quote: {} })).to(be_empty)
+        expect(order.validate_with_quote(100.0)).to(be_empty)
+      end
+    end
+  end
+
+  describe "#validate_for_instrument" do
+    it "accepts orders the instrument supports" do
+      expect(order.validate_for_instrument(Schwab::Instruments.capabilities_for("EQUITY", symbol: "AAPL"))).to(be_empty)
+    end
+
+    it "rejects order types, sessions, and quantities the instrument does not support" do
+      order.session = "AM"
+      order[:orderLegCollection] = [order_data[:orderLegCollection].first.merge(quantity: 2.5)]
+      capabilities = Schwab::Instruments.capabilities_for("OPTION", symbol: "AAPL  240315C00150000")
+
+      expect(order.validate_for_instrument(capabilities)).to(contain_exactly(
+        "AAPL  240315C00150000 cannot trade in the AM session",
+        "AAPL  240315C00150000 does not support fractional quantities",
+      ))
+    end
+
+    it "rejects short sales of instruments that cannot be shorted" do
+      order[:orderLegCollection] = [order_data[:orderLegCollection].first.merge(instruction: "SELL_SHORT")]
+      capabilities = Schwab::Instruments.capabilities_for("EQUITY", symbol: "GME", shortable: false)
+
+      expect(order.validate_for_instrument(capabilities)).to(eq(["GME is not available to sell short"]))
+    end
+
+    it "includes structural errors" do
+      order.destination = "MOON"
+      capabilities = Schwab::Instruments.capabilities_for("MUTUAL_FUND")
+
+      expect(order.validate_for_instrument(capabilities)).to(contain_exactly(
+        /Unknown destination/,
+        "MUTUAL_FUND does not support LIMIT orders",
+      ))
+    end
+  end
+
+  describe ".validate_json" do
+    it "accepts a valid serialized order" do
+      json = JSON.generate(order_data)
+
+      expect(described_class.validate_json(json)).to(eq([]))
+      expect(described_class.validate_json!(json)).to(be_a(described_class))
+    end
+
+    it "reports every violation at once" do
+      json = JSON.generate(order_data.merge(
+        orderType: "STOP_LIMIT",
+        session: "PM",
+        requestedDestination: "MOON",
+        specialInstruction: "ALL_OR_NONE",
+        duration: "FILL_OR_KILL",
+      ))
+
+      errors = described_class.validate_json(json)
+
+      expect(errors).to(contain_exactly(
+        "Unknown destination: MOON",
+        "ALL_OR_NONE cannot be combined with FILL_OR_KILL duration",
+        /STOP_LIMIT orders are not allowed in the PM session/,
+        "STOP_LIMIT orders require a stop price",
+      ))
+      expect { described_class.validate_json!(json) }
+        .to(raise_error(Schwab::InvalidRequestError) { |error| expect(error.errors.size).to(eq(4)) })
+    end
+
+    it "reports malformed JSON as an error" do
+      expect(described_class.validate_json("{orderType:")).to(contain_exactly(/Invalid order JSON/))
+      expect(described_class.validate_json("[]")).to(eq(["Order JSON must be an object"]))
+    end
+  end
+
+  describe "#validate!" do
+    it "raises InvalidRequestError with every error" do
+      order.destination = "MOON"
+      order.special_instruction = "HIDDEN"
+
+      expect { order.validate! }.to(raise_error(Schwab::InvalidRequestError) { |e| expect(e.errors.size).to(eq(2)) })
+    end
+
+    it "returns the order when valid" do
+      expect(order.validate!).to(be(order))
+    end
+  end
+
+  describe "#time_to_fill" do
+    it "measures from entry to close for a closed order" do
+      order = described_class.new(order_data.merge(
+        status: "FILLED",
+        enteredTime: "2024-03-15T14:30:00+0000",
+        closeTime: "2024-03-15T14:30:02.500+0000",
+      ))
+
+      expect(order.entered_time).to(eq(Time.utc(2024, 3, 15, 14, 30, 0)))
+      expect(order.time_to_fill).to(be_within(0.001).of(2.5))
+    end
+
+    it "returns nil for orders that are still working" do
+      order = described_class.new(order_data.merge(status: "WORKING", enteredTime: "2024-03-15T14:30:00+0000"))
+
+      expect(order.close_time).to(be_nil)
+      expect(order.time_to_fill).to(be_nil)
+    end
+
+    it "returns nil when a working order reports a close time" do
+      order = described_class.new(order_data.merge(
+        status: "PENDING_CANCEL",
+        enteredTime: "2024-03-15T14:30:00+0000",
+        closeTime: "2024-03-15T14:31:00+0000",
+      ))
+
+      expect(order.time_to_fill).to(be_nil)
+    end
+  end
+
+  describe "#summary" do
+    it "describes a single-leg order" do
+      order = described_class.new(order_data.merge(status: "QUEUED"))
+
+      expect(order.summary).to(eq("BUY 10 AAPL @ LIMIT 150.00 DAY (QUEUED)"))
+      expect(order.to_s).to(eq(order.summary))
+    end
+
+    it "includes fill progress" do
+      order = described_class.new({
+        "orderType" => "LIMIT",
+        "price" => 150,
+        "duration" => "DAY",
+        "status" => "WORKING",
+        "filledQuantity" => 2.0,
+        "orderLegCollection" => [{ "instruction" => "BUY", "quantity" => 5, "instrument" => { "symbol" => "AAPL" } }],
+      })
+
+      expect(order.summary).to(eq("BUY 5 AAPL @ LIMIT 150.00 DAY (WORKING, filled 2/5)"))
+    end
+
+    it "summarizes each option leg" do
+      order = described_class.new({
+        orderType: "NET_DEBIT",
+        price: 1.25,
+        duration: "GOOD_TILL_CANCEL",
+        orderLegCollection: [
+          { instruction: "BUY_TO_OPEN", quantity: 1, instrument: { symbol: "AAPL  240517C00190000" } },
+          { instruction: "SELL_TO_OPEN", quantity: 1, instrument: { symbol: "AAPL  240517C00200000" } },
+        ],
+      })
+
+      expect(order.summary).to(eq(
+        "BUY_TO_OPEN 1 AAPL  240517C00190000 / SELL_TO_OPEN 1 AAPL  240517C00200000 @ NET_DEBIT 1.25 GOOD_TILL_CANCEL",
+      ))
+    end
+
+    it "shows prices with the places configured for the asset type" do
+      Schwab.configuration.display_precision = { option: 4 }
+      order = described_class.new({
+        orderType: "LIMIT",
+        price: 1.2,
+        orderLegCollection: [
+          { instruction: "BUY_TO_OPEN", quantity: 1, instrument: { symbol: "AAPL  240517C00190000", assetType: "OPTION" } },
+        ],
+      })
+
+      expect(order.summary).to(eq("BUY_TO_OPEN 1 AAPL  240517C00190000 @ LIMIT 1.2000"))
+    ensure
+      Schwab.configuration.display_precision = {}
+    end
+
+    it "shows the stop price of stop-limit orders" do
+      order = described_class.new(order_data.merge(orderType: "STOP_LIMIT", stopPrice: 151))
+
+      expect(order.summary).to(eq("BUY 10 AAPL @ STOP_LIMIT 150.00 stop 151.00 DAY"))
+    end
+  end
+
+  describe "#fees" do
+    it "breaks down commissions and fees from commissionAndFee" do
+      order = described_class.new({
+        "commissionAndFee" => {
+          "commission" => {
+            "commissionLegs" => [{ "commissionValues" => [{ "value" => 0.65, "type" => "COMMISSION" }] }],
+          },
+          "fee" => {
+            "feeLegs" => [{
+              "feeValues" => [
+                { "value" => 0.02, "type" => "SEC_FEE" },
+                { "value" => 0.01, "type" => "TAF_FEE" },
+                { "value" => 0.03, "type" => "OPT_REG_FEE" },
+                { "value" => 0.05, "type" => "INDEX_OPTION_FEE" },
+              ],
+            }],
+          },
+        },
+      })
+
+      expect(order.fees.to_h).to(eq(commission: 0.65, sec_fee: 0.02, taf_fee: 0.01, option_reg_fee: 0.03, other: 0.05))
+      expect(order.total_fees).to(eq(0.76))
+    end
+
+    it "defaults every charge to zero" do
+      expect(order.fees.to_h.values).to(all(eq(0.0)))
+      expect(order.total_fees).to(eq(0.0))
+    end
+  end
+
+  describe "#reject_reason" do
+    def rejected(description)
+      described_class.new(order_data.merge(status: "REJECTED", statusDescription: description))
+    end
+
+    {
+      "Your buying power is insufficient for this order." => "INSUFFICIENT_FUNDS",
+      "Not enough funds available to cover this trade" => "INSUFFICIENT_FUNDS",
+      "This order exceeds your available funds" => "INSUFFICIENT_FUNDS",
+      "The market is closed" => "MARKET_CLOSED",
+      "Orders for this security cannot be placed outside of market hours" => "MARKET_CLOSED",
+      "The trading session is not open" => "MARKET_CLOSED",
+      "Invalid symbol: XYZQ" => "INVALID_SYMBOL",
+      "The symbol XYZQ was not found" => "INVALID_SYMBOL",
+      "Order quantity exceeds the maximum allowed for this security" => "RISK_LIMIT",
+      "This order would exceed the account's risk limits" => "RISK_LIMIT",
+      "Trading in this security is restricted" => "RISK_LIMIT",
+      "Duplicate order detected" => "DUPLICATE",
+      "An identical order was placed within the last minute" => "DUPLICATE",
+      "Rejected by exchange" => "OTHER",
+    }.each do |message, code|
+      it "classifies #{message.inspect} as #{code}" do
+        reason = rejected(message).reject_reason
+
+        expect(reason.code).to(eq(code))
+        expect(reason.text).to(eq(message))
+      end
+    end
+
+    it "falls back to OTHER without a description" do
+      reason = rejected(nil).reject_reason
+
+      expect(reason.code).to(eq("OTHER"))
+      expect(reason.text).to(be_nil)
+      expect(reason.to_s).to(eq("OTHER"))
+    end
+
+    it "is nil unless the order was rejected" do
+      order = described_class.new(order_data.merge(status: "WORKING", statusDescription: "Insufficient buying power"))
+
+      expect(order.reject_reason).to(be_nil)
+    end
+
+    it "only uses codes from REJECT_REASONS" do
+      expect(described_class::REJECT_REASON_PATTERNS.map(&:first) - described_class::REJECT_REASONS).to(be_empty)
+    end
+  end
+end
@@ -0,0 +1,46 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "schwab/resources/pagination"
+
+RSpec.describe(Schwab::Resources::Pagination) do
+  describe ".from_response" do
+    it "reads the metadata object from hash responses" do
+      response = {
+        "instruments" => [],
+        "metadata" => { "totalCount" => 250, "limit" => 100, "offset" => 100, "nextPageToken" => "abc" },
+      }
+      page = described_class.from_response(response)
+
+      expect(page.total_count).to(eq(250))
+      expect(page.limit).to(eq(100))
+      expect(page.offset).to(eq(100))
+      expect(page.next_page_token).to(eq("abc"))
+      expect(page.next_page?).to(be(true))
+    end
+
+    it "reads the metadata object from resource responses" do
+      resource = Schwab::Resources::Base.new({ metadata: { totalCount: 2, nextPageToken: "" } })
+      page = described_class.from_response(resource)
+
+      expect(page.total_count).to(eq(2))
+      expect(page.next_page?).to(be(false))
+    end
+
+    it "infers a single complete page for bare lists" do
+      page = described_class.from_response([{ orderId: 1 }, { orderId: 2 }])
+
+      expect(page.total_count).to(eq(2))
+      expect(page.offset).to(eq(0))
+      expect(page.next_page?).to(be(false))
+    end
+
+    it "returns nil when the response has no list metadata" do
+      expect(described_class.from_response({ "symbol" => "AAPL" })).to(be_nil)
+    end
+  end
+
+  it "is not reported as an unmapped key" do
+    expect(Schwab::Resources::Order.unmapped_keys({ "orderId" => 1, "metadata" => {} })).to(be_empty)
+  end
+end
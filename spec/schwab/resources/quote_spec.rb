@@ -0,0 +1,123 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "schwab/resources/quote"
+
+RSpec.describe(Schwab::Resources::Quote) do
+  let(:quote_time) { Time.at(1_700_000_000) }
+  let(:quote_data) do
+    {
+      symbol: "AAPL",
+      assetMainType: "EQUITY",
+      quote: {
+        bidPrice: 149.95,
+        askPrice: 150.05,
+        lastPrice: 150.0,
+        closePrice: 148.0,
+        quoteTime: (quote_time.to_f * 1000).to_i,
+      },
+    }
+  end
+
+  describe "price accessors" do
+    it "reads prices from the nested quote block" do
+      quote = described_class.new(quote_data)
+
+      expect(quote.symbol).to(eq("AAPL"))
+      expect(quote.asset_type).to(eq("EQUITY"))
+      expect(quote.last_price).to(eq(150.0))
+      expect(quote.bid_price).to(eq(149.95))
+      expect(quote.ask_price).to(eq(150.05))
+      expect(quote.close_price).to(eq(148.0))
+    end
+  end
+
+  describe "spread and mid-price" do
+    def quote_with(bid, ask)
+      described_class.new({ symbol: "AAPL", quote: { bidPrice: bid, askPrice: ask } })
+    end
+
+    it "derives spread, spread percent, and mid-price from the bid and ask" do
+      quote = described_class.new(quote_data)
+
+      expect(quote.spread).to(be_within(1e-9).of(0.10))
+      expect(quote.mid_price).to(be_within(1e-9).of(150.0))
+      expect(quote.spread_percent).to(be_within(1e-9).of(0.10 / 150.0 * 100))
+      expect(quote).to(be_two_sided)
+    end
+
+    it "treats a locked market as a zero spread" do
+      quote = quote_with(10.0, 10.0)
+
+      expect(quote.spread).to(eq(0.0))
+      expect(quote.mid_price).to(eq(10.0))
+    end
+
+    it "returns nil for crossed quotes and flags them" do
+      quote = quote_with(10.05, 10.0)
+
+      expect(quote).to(be_crossed)
+      expect([quote.spread, quote.spread_percent, quote.mid_price]).to(eq([nil, nil, nil]))
+    end
+
+    it "returns nil for missing or zero sides" do
+      [[0.0, 10.0], [nil, 10.0], [10.0, nil]].each do |bid, ask|
+        quote = quote_with(bid, ask)
+
+        expect(quote).not_to(be_two_sided)
+        expect(quote).not_to(be_crossed)
+        expect(quote.mid_price).to(be_nil)
+      end
+    end
+  end
+
+  describe "real-time flags" do
+    it "reports real-time quotes" do
+      quote = described_class.new({ symbol: "AAPL", realtime: true, quoteType: "NBBO" })
+
+      expect(quote.quote_type).to(eq("NBBO"))
+      expect(quote.realtime?).to(be(true))
+      expect(quote.delayed?).to(be(false))
+    end
+
+    it "reports delayed quotes" do
+      quote = described_class.new({ symbol: "AAPL", realtime: false, quoteType: "NFL" })
+      expect(quote.delayed?).to(be(true))
+    end
+
+    it "falls back to the quote type when the realtime flag is absent" do
+      expect(described_class.new({ quoteType: "NFL" }).delayed?).to(be(true))
+      expect(described_class.new({ quoteType: "NBBO" }).realtime?).to(be(true))
+    end
+  end
+
+  describe "freshness" do
+    it "parses the millisecond quote timestamp" do
+      expect(described_class.new(quote_data).quote_time).to(eq(quote_time))
+    end
+
+    it "falls back to trade time when quote time is missing" do
+      data = { symbol: "$SPX", quote: { tradeTime: (quote_time.to_f * 1000).to_i } }
+      expect(described_class.new(data).quote_time).to(eq(quote_time))
+    end
+
+    it "computes age relative to the given time" do
+      quote = described_class.new(quote_data)
+      expect(quote.age(quote_time + 30)).to(eq(30.0))
+    end
+
+    it "checks staleness against a maximum age" do
+      quote = described_class.new(quote_data)
+
+      expect(quote.stale?(60, quote_time + 30)).to(be(false))
+      expect(quote.stale?(10, quote_time + 30)).to(be(true))
+    end
+
+    it "treats quotes without a timestamp as stale" do
+      quote = described_class.new({ symbol: "AAPL", quote: { lastPrice: 150.0 } })
+
+      expect(quote.age).to(be_nil)
+      expect(quote.stale?(60)).to(be(true))
+    end
+  end
+end
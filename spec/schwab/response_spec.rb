@@ -0,0 +1,66 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+
+RSpec.describe(Schwab::Response) do
+  let(:config) do
+    Schwab::Configuration.new.tap do |c|
+      c.api_base_url = "https://api.test.com"
+      c.client_id = "test_client_id"
+      c.client_secret = "test_client_secret"
+    end
+  end
+  let(:client) { Schwab::Client.new(access_token: "test_access_token", config: config) }
+
+  before do
+    stub_request(:get, "https://api.test.com/first").to_return(
+      status: 200,
+      body: { "n" => 1 }.to_json,
+      headers: { "Content-Type" => "application/json", "Schwab-Client-CorrelId" => "corr-1" },
+    )
+    stub_request(:get, "https://api.test.com/second").to_return(
+      status: 200,
+      body: { "n" => 2 }.to_json,
+      headers: { "Content-Type" => "application/json", "Schwab-Client-CorrelId" => "corr-2" },
+    )
+  end
+
+  it "returns the block's result with the response behind it" do
+    response = Schwab.with_response { client.get("/first") }
+
+    expect(response.data).to(eq({ "n" => 1 }))
+    expect(response.status).to(eq(200))
+    expect(response.request_id).to(eq("corr-1"))
+    expect(response.headers["content-type"]).to(eq("application/json"))
+  end
+
+  it "collects every response, with the last one as the http_response" do
+    response = Schwab.with_response do
+      client.get("/first")
+      client.get("/second")
+    end
+
+    expect(response.http_responses.map { |r| r.headers["Schwab-Client-CorrelId"] }).to(eq(["corr-1", "corr-2"]))
+    expect(response.request_id).to(eq("corr-2"))
+  end
+
+  it "shares inner responses with outer captures" do
+    inner = nil
+    outer = Schwab.with_response do
+      client.get("/first")
+      inner = Schwab.with_response { client.get("/second") }
+    end
+
+    expect(inner.http_responses.size).to(eq(1))
+    expect(outer.http_responses.size).to(eq(2))
+  end
+
+  it "does not record responses outside a capture" do
+    client.get("/first")
+    response = Schwab.with_response { :no_requests }
+
+    expect(response.data).to(eq(:no_requests))
+    expect(response.http_response).to(be_nil)
+    expect(response.headers).to(eq({}))
+  end
+end
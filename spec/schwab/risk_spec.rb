@@ -0,0 +1,77 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+
+RSpec.describe(Schwab::Risk) do
+  let(:positions) do
+    [
+      { instrument: { symbol: "AAPL", assetType: "EQUITY" }, longQuantity: 30, marketValue: 6000.0 },
+      { instrument: { symbol: "XOM", assetType: "EQUITY" }, longQuantity: 25, marketValue: 3000.0 },
+      { instrument: { symbol: "TSLA", assetType: "EQUITY" }, shortQuantity: 5, marketValue: -1000.0 },
+    ]
+  end
+
+  describe ".metrics" do
+    it "computes exposure and concentration from gross market value" do
+      metrics = described_class.metrics(positions)
+
+      expect(metrics.holdings_count).to(eq(3))
+      expect(metrics.gross_exposure).to(eq(10_000.0))
+      expect(metrics.net_exposure).to(eq(8000.0))
+      expect(metrics.largest_position).to(eq("AAPL"))
+      expect(metrics.largest_position_weight).to(eq(0.6))
+      expect(metrics.asset_type_weights).to(eq({ "EQUITY" => 1.0 }))
+      expect(metrics.sector_weights).to(eq({}))
+    end
+
+    it "groups sectors from the map, with unmapped symbols as UNKNOWN" do
+      metrics = described_class.metrics(positions, sectors: { "aapl" => "Technology", "XOM" => "Energy" })
+
+      expect(metrics.sector_weights).to(eq({ "Technology" => 0.6, "Energy" => 0.3, "UNKNOWN" => 0.1 }))
+    end
+
+    it "weights beta by market value over the covered positions" do
+      metrics = described_class.metrics(positions, betas: { "AAPL" => 1.2, "XOM" => 0.9 })
+
+      expect(metrics.beta).to(eq(1.1))
+      expect(metrics.beta_coverage).to(eq(0.9))
+    end
+
+    it "counts shorts against beta" do
+      hedged = [
+        { instrument: { symbol: "AAPL", assetType: "EQUITY" }, longQuantity: 25, marketValue: 5000.0 },
+        { instrument: { symbol: "QQQ", assetType: "ETF" }, shortQuantity: 10, marketValue: -5000.0 },
+      ]
+
+      metrics = described_class.metrics(hedged, betas: { "AAPL" => 1.2, "QQQ" => 1.2 })
+
+      expect(metrics.beta).to(eq(0.0))
+      expect(metrics.net_exposure).to(eq(0.0))
+      expect(metrics.asset_type_weights).to(eq({ "EQUITY" => 0.5, "ETF" => 0.5 }))
+    end
+
+    it "leaves beta nil without a beta map" do
+      expect(described_class.metrics(positions).beta).to(be_nil)
+    end
+
+    it "returns zeros for an empty portfolio" do
+      metrics = described_class.metrics([], betas: { "AAPL" => 1.2 }, sectors: { "AAPL" => "Technology" })
+
+      expect(metrics.holdings_count).to(eq(0))
+      expect(metrics.gross_exposure).to(eq(0.0))
+      expect(metrics.net_exposure).to(eq(0.0))
+      expect(metrics.largest_position).to(be_nil)
+      expect(metrics.largest_position_weight).to(eq(0.0))
+      expect(metrics.asset_type_weights).to(eq({}))
+      expect(metrics.sector_weights).to(eq({}))
+      expect(metrics.beta).to(eq(0.0))
+      expect(metrics.beta_coverage).to(eq(0.0))
+    end
+
+    it "accepts position resources" do
+      metrics = described_class.metrics(positions.map { |position| Schwab::Resources::Position.new(position) })
+
+      expect(metrics.largest_position).to(eq("AAPL"))
+    end
+  end
+end
@@ -0,0 +1,93 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+
+RSpec.describe(Schwab::Streaming::BalanceStream) do
+  let(:client) { instance_double("Schwab::Client", access_token: "stream_token") }
+  let(:transport) { FakeStreamTransport.new }
+  let(:streamer) { Schwab::Streaming::Streamer.new(client: client, transport: transport, reconnect_delay: 0) }
+  let(:updates) { Queue.new }
+  let(:preferences) do
+    { streamerInfo: [{ streamerSocketUrl: "wss://streamer.test/ws", schwabClientCustomerId: "customer" }] }
+  end
+
+  def activity(message_type, account: "123456")
+    {
+      data: [{
+        service: "ACCT_ACTIVITY",
+        timestamp: 1_700_000_000_000,
+        content: [{ "key" => "Account Activity", "1" => account, "2" => message_type, "3" => "{}" }],
+      }],
+    }
+  end
+
+  before do
+    allow(Schwab::Accounts).to(receive(:get_user_preferences).and_return(preferences))
+  end
+
+  after { streamer.close }
+
+  it "yields the initial balances and subscribes to account activity" do
+    allow(Schwab::Accounts).to(receive(:get_balances).with("123456", client: client)
+      .and_return({ buyingPower: 10_000.0 }))
+
+    stream = described_class.new("123456", streamer: streamer) { |update| updates << update }.start
+    initial = updates.pop
+    wait_for { streamer.connected? }
+
+    expect(initial.message_type).to(be_nil)
+    expect(initial.buying_power).to(eq(10_000.0))
+    expect(stream.latest).to(eq(initial))
+    expect(transport.requests("SUBS").map { |request| request["service"] }).to(eq(["ACCT_ACTIVITY"]))
+  ensure
+    stream&.close
+  end
+
+  it "re-fetches balances on fills for the account" do
+    allow(Schwab::Accounts).to(receive(:get_balances)
+      .and_return({ buyingPower: 10_000.0 }, { "buyingPower" => 8_500.0 }))
+
+    stream = described_class.new("123456", streamer: streamer) { |update| updates << update }.start
+    updates.pop
+    wait_for { streamer.connected? }
+
+    transport.push(activity("OrderAccepted"))
+    transport.push(activity("OrderFill", account: "999999"))
+    transport.push(activity("OrderFill"))
+    update = updates.pop
+
+    expect(update.message_type).to(eq("OrderFill"))
+    expect(update.buying_power).to(eq(8_500.0))
+    expect(Schwab::Accounts).to(have_received(:get_balances).twice)
+  ensure
+    stream&.close
+  end
+
+  it "reports failed balance requests to error handlers" do
+    errors = Queue.new
+    allow(Schwab::Accounts).to(receive(:get_balances).and_raise(Schwab::ServerError, "unavailable"))
+
+    stream = described_class.new("123456", streamer: streamer)
+    stream.on_error { |error| errors << error }
+    stream.start
+
+    expect(errors.pop).to(be_a(Schwab::ServerError))
+    expect(stream.latest).to(be_nil)
+  ensure
+    stream&.close
+  end
+
+  it "stops yielding and unsubscribes a shared streamer when closed" do
+    allow(Schwab::Accounts).to(receive(:get_balances).and_return({}))
+    stream = described_class.new("123456", streamer: streamer) { |update| updates << update }.start
+    updates.pop
+    wait_for { streamer.connected? }
+
+    stream.close
+
+    expect(streamer.subscriptions).not_to(have_key("ACCT_ACTIVITY"))
+    transport.push(activity("OrderFill"))
+    sleep(0.05)
+    expect(updates).to(be_empty)
+  end
+end
@@ -0,0 +1,90 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+
+RSpec.describe(Schwab::Streaming::BarStream) do
+  let(:client) { instance_double("Schwab::Client", access_token: "stream_token") }
+  let(:transport) { FakeStreamTransport.new }
+  let(:streamer) { Schwab::Streaming::Streamer.new(client: client, transport: transport, reconnect_delay: 0) }
+  let(:bars) { Queue.new }
+  let(:preferences) do
+    { streamerInfo: [{ streamerSocketUrl: "wss://streamer.test/ws", schwabClientCustomerId: "customer" }] }
+  end
+  let(:base_time) { Time.utc(2024, 5, 1, 14, 30) }
+
+  def minute_bar(offset_minutes, open, high, low, close, volume)
+    {
+      "key" => "AAPL",
+      "1" => open,
+      "2" => high,
+      "3" => low,
+      "4" => close,
+      "5" => volume,
+      "7" => ((base_time + (offset_minutes * 60)).to_i * 1000),
+    }
+  end
+
+  def push(*items)
+    transport.push({ data: [{ service: "CHART_EQUITY", timestamp: 1_700_000_000_000, content: items }] })
+  end
+
+  def start_stream(frequency)
+    stream = described_class.new("AAPL", frequency: frequency, streamer: streamer) { |bar| bars << bar }
+    stream.start
+    wait_for { streamer.connected? }
+    stream
+  end
+
+  before do
+    allow(Schwab::Accounts).to(receive(:get_user_preferences).and_return(preferences))
+  end
+
+  after { streamer.close }
+
+  it "subscribes to the chart service for equities and futures" do
+    described_class.new(["AAPL", "/ES"], streamer: streamer).start
+    wait_for { streamer.connected? }
+
+    services = transport.requests("SUBS").to_h { |request| [request["service"], request["parameters"]["keys"]] }
+    expect(services).to(eq({ "CHART_EQUITY" => "AAPL", "CHART_FUTURES" => "/ES" }))
+  end
+
+  it "aggregates minute bars into clock-aligned bars" do
+    stream = start_stream(180)
+
+    push(minute_bar(0, 10.0, 11.0, 9.5, 10.5, 100), minute_bar(1, 10.5, 12.0, 10.0, 11.0, 200))
+    wait_for { stream.current_bar("AAPL")&.volume == 300 }
+    expect(stream.current_bar("AAPL").complete?).to(be(false))
+
+    push(minute_bar(2, 11.0, 11.5, 9.0, 9.8, 50))
+    bar = bars.pop
+
+    expect(bar.to_h).to(include(
+      time: base_time,
+      open: 10.0,
+      high: 12.0,
+      low: 9.0,
+      close: 9.8,
+      volume: 350,
+      complete: true,
+      gap: false,
+    ))
+    expect(stream.current_bar("AAPL")).to(be_nil)
+  end
+
+  it "fills intervals without trades with gap bars" do
+    start_stream(60)
+
+    push(minute_bar(0, 10.0, 10.0, 10.0, 10.0, 100))
+    push(minute_bar(3, 11.0, 11.0, 11.0, 11.0, 100))
+
+    received = Array.new(4) { bars.pop }
+    expect(received.map(&:time)).to(eq((0..3).map { |minute| base_time + (minute * 60) }))
+    expect(received[1..2].map { |bar| [bar.gap?, bar.close, bar.volume] }).to(eq([[true, 10.0, 0], [true, 10.0, 0]]))
+    expect(received.last.close).to(eq(11.0))
+  end
+
+  it "rejects frequencies that are not whole minutes" do
+    expect { described_class.new("AAPL", frequency: 90, streamer: streamer) }.to(raise_error(ArgumentError))
+  end
+end
@@ -0,0 +1,65 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+
+RSpec.describe(Schwab::Streaming::BookStream) do
+  let(:client) { instance_double("Schwab::Client", access_token: "stream_token") }
+  let(:transport) { FakeStreamTransport.new }
+  let(:streamer) { Schwab::Streaming::Streamer.new(client: client, transport: transport, reconnect_delay: 0) }
+  let(:updates) { Queue.new }
+  let(:preferences) do
+    { streamerInfo: [{ streamerSocketUrl: "wss://streamer.test/ws", schwabClientCustomerId: "customer" }] }
+  end
+
+  def level(price, size, count)
+    { "0" => price, "1" => size, "2" => count }
+  end
+
+  def book_message(content)
+    { data: [{ service: "NASDAQ_BOOK", timestamp: 1_700_000_000_000, content: [content] }] }
+  end
+
+  before do
+    allow(Schwab::Accounts).to(receive(:get_user_preferences).and_return(preferences))
+  end
+
+  after { streamer.close }
+
+  it "subscribes each book service to the symbols" do
+    described_class.new(["AAPL"], services: ["NASDAQ_BOOK", "NYSE_BOOK"], streamer: streamer).start
+    wait_for { streamer.connected? }
+
+    expect(transport.requests("SUBS").map { |request| request["service"] }).to(eq(["NASDAQ_BOOK", "NYSE_BOOK"]))
+  end
+
+  it "yields snapshots and merges single-side updates" do
+    stream = described_class.new("AAPL", services: ["NASDAQ_BOOK"], streamer: streamer) { |update| updates << update }
+    stream.start
+    wait_for { streamer.connected? }
+
+    transport.push(book_message({
+      "key" => "AAPL",
+      "1" => 1_700_000_000_000,
+      "2" => [level(150.0, 300, 2), level(149.99, 100, 1)],
+      "3" => [level(150.02, 200, 3)],
+    }))
+    snapshot = updates.pop
+
+    expect(snapshot).to(be_snapshot)
+    expect(snapshot.best_bid).to(eq(Schwab::Streaming::BookLevel.new(price: 150.0, size: 300, market_maker_count: 2)))
+    expect(snapshot.asks.size).to(eq(1))
+
+    transport.push(book_message({ "key" => "AAPL", "3" => [level(150.01, 50, 1)] }))
+    update = updates.pop
+
+    expect(update).not_to(be_snapshot)
+    expect(update.bids).to(eq(snapshot.bids))
+    expect(update.best_ask.price).to(eq(150.01))
+    expect(stream.book("AAPL")).to(eq(update))
+  end
+
+  it "rejects unknown book services" do
+    expect { described_class.new("AAPL", services: ["LEVELONE_EQUITIES"], streamer: streamer) }
+      .to(raise_error(ArgumentError, /LEVELONE_EQUITIES/))
+  end
+end
@@ -0,0 +1,110 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+
+RSpec.describe(Schwab::Streaming::Channel) do
+  let(:client) { instance_double("Schwab::Client", access_token: "stream_token") }
+  let(:transport) { FakeStreamTransport.new }
+  let(:preferences) do
+    {
+      "streamerInfo" => [{
+        "streamerSocketUrl" => "wss://streamer.test/ws",
+        "schwabClientCustomerId" => "customer",
+        "schwabClientCorrelId" => "correl",
+        "schwabClientChannel" => "N9",
+        "schwabClientFunctionId" => "APIAPP",
+      }],
+    }
+  end
+  let(:streamer) { Schwab::Streaming::Streamer.new(client: client, transport: transport, reconnect_delay: 0) }
+
+  before do
+    allow(Schwab::Accounts).to(receive(:get_user_preferences).with(client: client).and_return(preferences))
+  end
+
+  after { streamer.close }
+
+  it "opens channels on a fresh streamer and tracks them until closed" do
+    first = streamer.channel("LEVELONE_EQUITIES", ["AAPL"], fields: [0, 3])
+    second = streamer.channel("LEVELONE_EQUITIES", ["MSFT"], fields: [0, 3])
+
+    expect(streamer.subscriptions).to(eq({ "LEVELONE_EQUITIES" => ["AAPL", "MSFT"] }))
+    streamer.close
+    expect([first, second]).to(all(be_closed))
+  end
+
+  it "streams several services over one connection" do
+    equities = streamer.channel("LEVELONE_EQUITIES", ["aapl"], fields: [0, 1, 2, 3])
+    options = streamer.channel("LEVELONE_OPTIONS", ["AAPL  240517C00190000"], fields: [0, 28])
+    activity = streamer.channel("ACCT_ACTIVITY", ["sub-key"])
+    streamer.start
+    wait_for { streamer.connected? }
+
+    transport.push({
+      data: [
+        { service: "LEVELONE_EQUITIES", timestamp: 1_700_000_000_000, content: [{ key: "AAPL", "1" => 189.5, "3" => 189.6 }] },
+        { service: "LEVELONE_OPTIONS", timestamp: 1_700_000_000_000, content: [{ key: "AAPL  240517C00190000", "28" => 0.52 }] },
+        { service: "ACCT_ACTIVITY", timestamp: 1_700_000_000_000, content: [{ key: "sub-key", "2" => "OrderFilled" }] },
+      ],
+    })
+
+    quote = equities.pop(timeout: 2)
+    expect(quote.key).to(eq("AAPL"))
+    expect(quote.fields).to(eq({ "bidPrice" => 189.5, "lastPrice" => 189.6 }))
+    expect(quote.field(:lastPrice)).to(eq(189.6))
+    expect(quote.timestamp).to(eq(Time.at(1_700_000_000)))
+    expect(quote.sequence).to(eq(1))
+    expect(options.pop(timeout: 2).field("delta")).to(eq(0.52))
+    fill = activity.pop(timeout: 2)
+    expect(fill.field(2)).to(eq("OrderFilled"))
+    expect(fill.sequence).to(eq(3))
+    expect(transport.connect_count).to(eq(1))
+    expect(transport.requests("SUBS").map { |request| request["service"] })
+      .to(eq(["LEVELONE_EQUITIES", "LEVELONE_OPTIONS", "ACCT_ACTIVITY"]))
+  end
+
+  it "subscribes to every named field by default" do
+    streamer.channel("ACCT_ACTIVITY", ["sub-key"])
+    streamer.start
+    wait_for { streamer.connected? }
+
+    expect(transport.requests("SUBS").first["parameters"]).to(eq({ "keys" => "SUB-KEY", "fields" => "0,1,2,3" }))
+  end
+
+  it "only delivers messages for its own keys" do
+    aapl = streamer.channel("LEVELONE_EQUITIES", ["AAPL"], fields: [0, 3])
+    msft = streamer.channel("LEVELONE_EQUITIES", ["MSFT"], fields: [0, 3])
+    streamer.start
+    wait_for { streamer.connected? }
+
+    transport.push({ data: [{ service: "LEVELONE_EQUITIES", timestamp: 1, content: [{ key: "MSFT", "3" => 410.0 }] }] })
+
+    expect(msft.pop(timeout: 2).key).to(eq("MSFT"))
+    expect(aapl.pop(timeout: 0.05)).to(be_nil)
+  end
+
+  it "unsubscribes and ends iteration when closed" do
+    channel = streamer.channel("LEVELONE_EQUITIES", ["AAPL"], fields: [0, 3])
+    streamer.start
+    wait_for { streamer.connected? }
+
+    consumer = Thread.new { channel.to_a }
+    channel.close
+
+    expect(consumer.value).to(eq([]))
+    expect(channel).to(be_closed)
+    expect(transport.requests("UNSUBS").first["parameters"]["keys"]).to(eq("AAPL"))
+    expect(streamer.subscriptions).to(eq({}))
+  end
+
+  it "logs out and closes its channels when the streamer closes" do
+    channel = streamer.channel("LEVELONE_EQUITIES", ["AAPL"], fields: [0, 3])
+    streamer.start
+    wait_for { streamer.connected? }
+
+    streamer.close
+
+    expect(transport.requests("LOGOUT").first["service"]).to(eq("ADMIN"))
+    expect(channel).to(be_closed)
+  end
+end
@@ -0,0 +1,50 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+
+RSpec.describe(Schwab::Streaming::SSE) do
+  let(:relay) { "https://relay.test/stream" }
+  let(:sse) { described_class.new(relay, "wss://streamer.test/ws", open_timeout: 1) }
+
+  after { sse.close }
+
+  it "opens a relay session and reads one message per event" do
+    stub_request(:get, relay)
+      .with(query: { url: "wss://streamer.test/ws" }, headers: { "Accept" => "text/event-stream" })
+      .to_return(
+        status: 200,
+        headers: { "Content-Type" => "text/event-stream" },
+        body: "event: session\r\ndata: abc123\r\n\r\n: keepalive\n\ndata: {\"notify\":[]}\n\ndata: line one\ndata: line two\n\n",
+      )
+
+    sse.connect
+
+    expect(sse.session_id).to(eq("abc123"))
+    expect(sse.read).to(eq("{\"notify\":[]}"))
+    expect(sse.read).to(eq("line one\nline two"))
+    expect(sse.read).to(be_nil)
+    expect(sse.read).to(be_nil)
+  end
+
+  it "posts writes to the session" do
+    stub_request(:get, relay).with(query: hash_including({}))
+      .to_return(status: 200, body: "event: session\ndata: abc123\n\n")
+    post = stub_request(:post, relay).with(query: { session: "abc123" }, body: "{\"requests\":[]}").to_return(status: 204)
+
+    sse.connect.write("{\"requests\":[]}")
+
+    expect(post).to(have_been_requested)
+  end
+
+  it "raises when the relay refuses the stream" do
+    stub_request(:get, relay).with(query: hash_including({})).to_return(status: 403)
+
+    expect { sse.connect }.to(raise_error(Schwab::StreamError, /403/))
+  end
+
+  it "raises when the relay sends no session" do
+    stub_request(:get, relay).with(query: hash_including({})).to_return(status: 200, body: "data: early\n\n")
+
+    expect { sse.connect }.to(raise_error(Schwab::StreamError, /no session/))
+  end
+end
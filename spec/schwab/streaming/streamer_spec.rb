@@ -0,0 +1,275 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+
+RSpec.describe(Schwab::Streaming::Streamer) do
+  let(:client) { instance_double("Schwab::Client", access_token: "stream_token") }
+  let(:transport) { FakeStreamTransport.new }
+  let(:preferences) do
+    {
+      "streamerInfo" => [{
+        "streamerSocketUrl" => "wss://streamer.test/ws",
+        "schwabClientCustomerId" => "customer",
+        "schwabClientCorrelId" => "correl",
+        "schwabClientChannel" => "N9",
+        "schwabClientFunctionId" => "APIAPP",
+      }],
+    }
+  end
+  let(:streamer) { described_class.new(client: client, transport: transport, reconnect_delay: 0) }
+
+  before do
+    allow(Schwab::Accounts).to(receive(:get_user_preferences).with(client: client).and_return(preferences))
+  end
+
+  after { streamer.close }
+
+  it "logs in with the access token from the streamer info" do
+    streamer.start
+    wait_for { streamer.connected? }
+
+    login = transport.requests("LOGIN").first
+    expect(transport.url).to(eq("wss://streamer.test/ws"))
+    expect(login["parameters"]).to(include("Authorization" => "stream_token", "SchwabClientChannel" => "N9"))
+    expect(login["SchwabClientCustomerId"]).to(eq("customer"))
+  end
+
+  it "sends subscriptions after login and routes data to handlers" do
+    received = Queue.new
+    streamer.on_data("NASDAQ_BOOK") { |item, timestamp| received << [item["key"], timestamp] }
+    streamer.subscribe("NASDAQ_BOOK", ["aapl"], fields: [0, 1, 2, 3])
+    streamer.start
+    wait_for { streamer.connected? }
+
+    subs = transport.requests("SUBS").first
+    expect(subs["parameters"]).to(eq({ "keys" => "AAPL", "fields" => "0,1,2,3" }))
+
+    transport.push({ data: [{ service: "NASDAQ_BOOK", timestamp: 1_700_000_000_000, content: [{ key: "AAPL" }] }] })
+    expect(received.pop).to(eq(["AAPL", 1_700_000_000_000]))
+  end
+
+  it "numbers data items across services and reconnects" do
+    received = Queue.new
+    streamer.on_data("NASDAQ_BOOK") { |item, _timestamp, sequence| received << [item["key"], sequence] }
+    streamer.on_data("NYSE_BOOK", &->(item, _timestamp) { received << [item["key"], :lambda] })
+    streamer.subscribe("NASDAQ_BOOK", "AAPL", fields: [0])
+    streamer.start
+    wait_for { streamer.connected? }
+
+    transport.push({
+      data: [
+        { service: "NASDAQ_BOOK", timestamp: 1, content: [{ key: "AAPL" }, { key: "MSFT" }] },
+        { service: "NYSE_BOOK", timestamp: 1, content: [{ key: "IBM" }] },
+      ],
+    })
+    expect(Array.new(3) { received.pop }).to(eq([["AAPL", 1], ["MSFT", 2], ["IBM", :lambda]]))
+
+    transport.drop
+    wait_for { transport.connect_count == 2 && streamer.connected? }
+    transport.push({ data: [{ service: "NASDAQ_BOOK", timestamp: 2, content: [{ key: "AAPL" }] }] })
+
+    expect(received.pop).to(eq(["AAPL", 4]))
+    expect(streamer.last_sequence).to(eq(4))
+  end
+
+  it "adds and removes keys on a live connection" do
+    streamer.subscribe("NYSE_BOOK", "IBM", fields: [0])
+    streamer.start
+    wait_for { streamer.connected? }
+
+    streamer.subscribe("NYSE_BOOK", "GE", fields: [0])
+    streamer.unsubscribe("NYSE_BOOK", "IBM")
+
+    expect(transport.requests("ADD").first["parameters"]["keys"]).to(eq("GE"))
+    expect(transport.requests("UNSUBS").first["parameters"]["keys"]).to(eq("IBM"))
+    expect(streamer.subscriptions).to(eq({ "NYSE_BOOK" => ["GE"] }))
+  end
+
+  it "keeps keys subscribed until every consumer releases them" do
+    streamer.start
+    wait_for { streamer.connected? }
+
+    streamer.subscribe("LEVELONE_EQUITIES", ["AAPL", "MSFT"], fields: [0, 1])
+    streamer.subscribe("LEVELONE_EQUITIES", ["MSFT", "TSLA"], fields: [0, 1])
+    expect(transport.requests("SUBS").last["parameters"]["keys"]).to(eq("AAPL,MSFT"))
+    expect(transport.requests("ADD").map { |request| request["parameters"]["keys"] }).to(eq(["TSLA"]))
+    expect(streamer.subscription_count("LEVELONE_EQUITIES", "msft")).to(eq(2))
+
+    streamer.unsubscribe("LEVELONE_EQUITIES", ["AAPL", "MSFT"])
+    expect(transport.requests("UNSUBS").map { |request| request["parameters"]["keys"] }).to(eq(["AAPL"]))
+    expect(streamer.subscriptions).to(eq({ "LEVELONE_EQUITIES" => ["MSFT", "TSLA"] }))
+
+    streamer.unsubscribe("LEVELONE_EQUITIES", "AAPL")
+    streamer.unsubscribe("LEVELONE_EQUITIES", ["MSFT", "TSLA"])
+    expect(transport.requests("UNSUBS").map { |request| request["parameters"]["keys"] }).to(eq(["AAPL", "MSFT,TSLA"]))
+    expect(streamer.subscriptions).to(eq({}))
+    expect(streamer.subscription_count("LEVELONE_EQUITIES", "MSFT")).to(eq(0))
+  end
+
+  it "sends nothing for keys already subscribed and widens fields with VIEW" do
+    streamer.start
+    wait_for { streamer.connected? }
+
+    streamer.subscribe("LEVELONE_EQUITIES", "AAPL", fields: [0, 1])
+    streamer.subscribe("LEVELONE_EQUITIES", "AAPL", fields: [0, 1])
+    streamer.subscribe("LEVELONE_EQUITIES", "AAPL", fields: [0, 3])
+
+    expect(transport.requests("SUBS").size).to(eq(1))
+    expect(transport.requests("ADD")).to(be_empty)
+    expect(transport.requests("VIEW").map { |request| request["parameters"]["fields"] }).to(eq(["0,1,3"]))
+  end
+
+  it "reconnects and resubscribes when the connection drops" do
+    streamer.subscribe("NASDAQ_BOOK", ["AAPL", "MSFT"], fields: [0, 1])
+    streamer.start
+    wait_for { streamer.connected? }
+
+    transport.drop
+    wait_for { transport.connect_count == 2 && streamer.connected? }
+
+    expect(transport.requests("LOGIN").size).to(eq(2))
+    expect(transport.requests("SUBS").last["parameters"]["keys"]).to(eq("AAPL,MSFT"))
+  end
+
+  it "reports login failures to error handlers" do
+    failing_transport = FakeStreamTransport.new(login_code: 3)
+    errors = Queue.new
+    streamer = described_class.new(client: client, transport: failing_transport, reconnect_delay: 0)
+    streamer.on_error { |error| errors << error }
+    streamer.start
+
+    expect(errors.pop).to(be_a(Schwab::StreamError))
+    streamer.close
+  end
+
+  it "gives up with a terminal error after max_reconnect_attempts" do
+    failing_transport = FakeStreamTransport.new(login_code: 3)
+    errors = []
+    streamer = described_class.new(
+      client: client,
+      transport: failing_transport,
+      reconnect_delay: 0,
+      max_reconnect_attempts: 2,
+    )
+    streamer.on_error { |error| errors << error }
+    streamer.start
+
+    wait_for { errors.any?(Schwab::StreamTerminatedError) }
+    expect(failing_transport.connect_count).to(eq(3))
+    expect(streamer.reconnect_attempts).to(eq(3))
+    expect(errors.last).to(be_a(Schwab::StreamTerminatedError))
+    streamer.close
+  end
+
+  it "resets the reconnect count after a successful login" do
+    streamer.start
+    wait_for { streamer.connected? }
+
+    transport.drop
+    wait_for { transport.connect_count == 2 && streamer.connected? }
+
+    expect(streamer.reconnect_attempts).to(eq(0))
+  end
+
+  it "records when the last message arrived, heartbeats included" do
+    streamer.start
+    wait_for { streamer.connected? }
+    login_time = streamer.last_message_time
+
+    sleep(0.01)
+    transport.push({ notify: [{ heartbeat: "1700000000000" }] })
+
+    wait_for { streamer.last_message_time > login_time }
+  end
+
+  it "reconnects when no message arrives within the heartbeat timeout" do
+    errors = Queue.new
+    streamer = described_class.new(client: client, transport: transport, reconnect_delay: 0, heartbeat_timeout: 0.05)
+    streamer.on_error { |error| errors << error }
+    streamer.start
+
+    error = errors.pop
+    expect(error).to(be_a(Schwab::StreamError))
+    expect(error.message).to(match(/No streamer message for .*s; reconnecting/))
+    wait_for { transport.connect_count >= 2 }
+    streamer.close
+  end
+
+  it "keeps a connection that receives heartbeats" do
+    streamer = described_class.new(client: client, transport: transport, reconnect_delay: 0, heartbeat_timeout: 0.2)
+    streamer.start
+    wait_for { streamer.connected? }
+
+    5.times do
+      sleep(0.05)
+      transport.push({ notify: [{ heartbeat: "1700000000000" }] })
+    end
+
+    expect(transport.connect_count).to(eq(1))
+    streamer.close
+  end
+
+  it "backs off exponentially up to max_reconnect_delay" do
+    streamer = described_class.new(client: client, transport: transport, reconnect_delay: 1, max_reconnect_delay: 5)
+
+    delays = [1, 2, 3, 4].map do |attempt|
+      streamer.instance_variable_set(:@reconnect_attempts, attempt)
+      streamer.send(:reconnect_backoff)
+    end
+
+    expect(delays).to(eq([1, 2, 4, 5]))
+  end
+
+  describe "transport selection" do
+    let(:blocked_socket) { instance_double(Schwab::Streaming::WebSocket) }
+
+    before do
+      allow(blocked_socket).to(receive(:connect).and_raise(Schwab::StreamError, "WebSocket handshake failed: HTTP/1.1 403"))
+      allow(Schwab::Streaming::WebSocket).to(receive(:new).with("wss://streamer.test/ws").and_return(blocked_socket))
+      allow(Schwab::Streaming::SSE).to(receive(:new).with("https://relay.test/stream", "wss://streamer.test/ws")
+        .and_return(transport))
+    end
+
+    it "falls back to SSE when the WebSocket handshake fails" do
+      errors = Queue.new
+      streamer = described_class.new(client: client, sse_url: "https://relay.test/stream", reconnect_delay: 0)
+      streamer.on_error { |error| errors << error }
+      streamer.subscribe("LEVELONE_EQUITIES", "AAPL", fields: [0, 1])
+      streamer.start
+      wait_for { streamer.connected? }
+
+      expect(streamer.active_transport).to(eq(:sse))
+      expect(errors.pop.message).to(include("falling back to SSE"))
+      expect(transport.requests("SUBS").first["parameters"]["keys"]).to(eq("AAPL"))
+
+      transport.drop
+      wait_for { transport.connect_count == 2 }
+      expect(Schwab::Streaming::WebSocket).to(have_received(:new).once)
+      streamer.close
+    end
+
+    it "uses SSE without trying a WebSocket when forced" do
+      streamer = described_class.new(client: client, transport: :sse, sse_url: "https://relay.test/stream")
+      streamer.start
+      wait_for { streamer.connected? }
+
+      expect(Schwab::Streaming::WebSocket).not_to(have_received(:new))
+      streamer.close
+    end
+
+    it "reports the WebSocket failure without a relay to fall back to" do
+      errors = Queue.new
+      streamer = described_class.new(client: client, transport: :auto, max_reconnect_attempts: 0)
+      streamer.on_error { |error| errors << error }
+      streamer.start
+
+      expect(errors.pop.message).to(include("403"))
+      expect(Schwab::Streaming::SSE).not_to(have_received(:new))
+    end
+
+    it "rejects unknown transports and :sse without a relay" do
+      expect { described_class.new(client: client, transport: :grpc) }.to(raise_error(ArgumentError, /grpc/))
+      expect { described_class.new(client: client, transport: :sse) }.to(raise_error(ArgumentError, /sse_url/))
+    end
+  end
+end
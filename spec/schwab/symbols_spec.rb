@@ -0,0 +1,96 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "schwab/symbols"
+
+RSpec.describe(Schwab::Symbols) do
+  describe ".normalize" do
+    [
+      ["aapl", nil, "AAPL"],
+      [" brk.b ", "EQUITY", "BRK.B"],
+      ["BRK/B", "EQUITY", "BRK/B"],
+      ["$SPX.X", nil, "$SPX"],
+      ["spx", "INDEX", "$SPX"],
+      ["$compx", nil, "$COMPX"],
+      ["/es", nil, "/ES"],
+      ["ESZ24", "FUTURE", "/ESZ24"],
+      ["AAPL240517C00190000", nil, "AAPL  240517C00190000"],
+      ["aapl  240517p00190000", "OPTION", "AAPL  240517P00190000"],
+      ["SPXW  240517C05000000", "OPTION", "SPXW  240517C05000000"],
+      ["spy", "ETF", "SPY"],
+      ["12345", "MUTUAL_FUND", "12345"],
+    ].each do |symbol, asset_type, expected|
+      it "normalizes #{symbol.inspect} (#{asset_type || "inferred"}) to #{expected.inspect}" do
+        expect(described_class.normalize(symbol, asset_type)).to(eq(expected))
+      end
+    end
+
+    [
+      ["", nil],
+      ["AAPL!", "EQUITY"],
+      ["1ABC", "EQUITY"],
+      ["1ABC", "ETF"],
+      ["$SP X", "INDEX"],
+      ["/TOOLONGFUT", "FUTURE"],
+      ["AAPL 2405C190", "OPTION"],
+      ["TOOLONGROOT240517C00190000", "OPTION"],
+    ].each do |symbol, asset_type|
+      it "rejects malformed #{symbol.inspect} (#{asset_type || "inferred"})" do
+        expect { described_class.normalize(symbol, asset_type) }.to(raise_error(Schwab::InvalidRequestError))
+        expect(described_class.valid?(symbol, asset_type)).to(be(false))
+      end
+    end
+  end
+
+  describe ".normalize_order" do
+    it "normalizes each leg symbol using its asset type" do
+      order = {
+        orderLegCollection: [
+          { instruction: "BUY_TO_OPEN", instrument: { symbol: "AAPL240517C00190000", assetType: "OPTION" } },
+          { instruction: "BUY", instrument: { symbol: "msft", assetType: "EQUITY" } },
+        ],
+      }
+
+      symbols = described_class.normalize_order(order)[:orderLegCollection].map { |leg| leg[:instrument][:symbol] }
+      expect(symbols).to(eq(["AAPL  240517C00190000", "MSFT"]))
+    end
+  end
+
+  describe ".upcase" do
+    {
+      "aapl" => "AAPL",
+      "brk.b" => "BRK.B",
+      "$spx.x" => "$SPX.X",
+      "/esz24" => "/ESZ24",
+      "aapl  240517c00190000" => "AAPL  240517C00190000",
+    }.each do |symbol, expected|
+      it "upper-cases #{symbol.inspect} to #{expected.inspect}" do
+        expect(described_class.upcase(symbol)).to(eq(expected))
+      end
+    end
+  end
+
+  describe ".for_request" do
+    it "upper-cases unless the configuration turns symbol case normalization off" do
+      config = Schwab::Configuration.new
+      expect(described_class.for_request("aapl", config)).to(eq("AAPL"))
+
+      config.normalize_symbol_case = false
+      expect(described_class.for_request("aapl", config)).to(eq("aapl"))
+    end
+  end
+
+  describe ".upcase_order" do
+    it "upper-cases each leg symbol and leaves legs without one alone" do
+      order = {
+        "orderLegCollection" => [
+          { "instruction" => "BUY", "instrument" => { "symbol" => "msft", "assetType" => "EQUITY" } },
+          { "instruction" => "BUY", "instrument" => { "assetType" => "EQUITY" } },
+        ],
+      }
+
+      legs = described_class.upcase_order(order)["orderLegCollection"]
+      expect(legs.map { |leg| leg["instrument"]["symbol"] }).to(eq(["MSFT", nil]))
+    end
+  end
+end
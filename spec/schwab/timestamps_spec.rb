@@ -0,0 +1,83 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "schwab/timestamps"
+
+RSpec.describe(Schwab::Timestamps) do
+  describe ".parse" do
+    it "honors explicit offsets" do
+      expect(described_class.parse("2024-03-15T13:30:00Z")).to(eq(Time.utc(2024, 3, 15, 13, 30)))
+      expect(described_class.parse("2024-03-15T09:30:00-0400")).to(eq(Time.utc(2024, 3, 15, 13, 30)))
+      expect(described_class.parse("2024-03-15T22:30:00+09:00", "UTC")).to(eq(Time.utc(2024, 3, 15, 13, 30)))
+    end
+
+    it "reads bare datetimes as US Eastern wall-clock time" do
+      summer = described_class.parse("2024-07-01 09:30:00")
+      winter = described_class.parse("2024-01-15T09:30:00.250")
+
+      expect([summer.hour, summer.min, summer.utc_offset]).to(eq([9, 30, -4 * 3600]))
+      expect(summer).to(eq(Time.utc(2024, 7, 1, 13, 30)))
+      expect([winter.hour, winter.utc_offset]).to(eq([9, -5 * 3600]))
+      expect(winter.usec).to(eq(250_000))
+    end
+
+    it "switches between standard and daylight time at 2 a.m. local" do
+      offsets = [
+        "2024-03-10 01:59:59",
+        "2024-03-10 03:00:00",
+        "2024-11-03 01:30:00",
+        "2024-11-03 02:00:00",
+      ].map { |value| described_class.parse(value).utc_offset / 3600 }
+
+      expect(offsets).to(eq([-5, -4, -4, -5]))
+    end
+
+    it "reads bare dates as midnight Eastern" do
+      expect(described_class.parse("2024-07-01")).to(eq(Time.utc(2024, 7, 1, 4)))
+    end
+
+    it "reads bare datetimes in the given timezone" do
+      expect(described_class.parse("2024-07-01 09:30:00", "UTC")).to(eq(Time.utc(2024, 7, 1, 9, 30)))
+      expect(described_class.parse("2024-07-01 09:30:00", "+09:00")).to(eq(Time.utc(2024, 7, 1, 0, 30)))
+    end
+
+    it "uses the configured default timezone" do
+      Schwab.configure { |config| config.default_timezone = "UTC" }
+
+      expect(described_class.parse("2024-07-01 09:30:00").utc_offset).to(eq(0))
+    ensure
+      Schwab.reset_configuration!
+    end
+
+    it "raises on values without a date" do
+      expect { described_class.parse("not a time") }.to(raise_error(ArgumentError, /Invalid timestamp/))
+    end
+  end
+
+  describe ".zone" do
+    it "adapts zones responding to local_to_utc, such as TZInfo::Timezone" do
+      tokyo = Object.new
+      def tokyo.local_to_utc(time)
+        time - (9 * 3600)
+      end
+
+      expect(described_class.parse("2024-07-01 09:30:00", tokyo)).to(eq(Time.utc(2024, 7, 1, 0, 30)))
+    end
+
+    it "rejects unsupported timezones" do
+      expect { described_class.zone("Mars/Olympus") }.to(raise_error(ArgumentError, /Unsupported timezone/))
+      expect { Schwab::Configuration.new.default_timezone = "Mars/Olympus" }.to(raise_error(ArgumentError))
+    end
+  end
+
+  it "applies to resource datetime fields using the client's configuration" do
+    config = Schwab::Configuration.new.tap { |c| c.default_timezone = "UTC" }
+    client = instance_double("Schwab::Client", config: config)
+
+    eastern = Schwab::Resources::Order.new({ enteredTime: "2024-07-01T09:30:00" })
+    utc = Schwab::Resources::Order.new({ enteredTime: "2024-07-01T09:30:00" }, client)
+
+    expect(eastern.entered_time).to(eq(Time.utc(2024, 7, 1, 13, 30)))
+    expect(utc.entered_time).to(eq(Time.utc(2024, 7, 1, 9, 30)))
+  end
+end
@@ -0,0 +1,51 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+
+RSpec.describe(Schwab::TokenExpiry) do
+  let(:now) { Time.utc(2024, 3, 15, 12, 0, 0) }
+
+  def expired?(token, drift: 60)
+    described_class.expired?(token, max_clock_drift: drift, now: now)
+  end
+
+  it "treats a token as valid until it is within the drift of expiring" do
+    expect(expired?({ expires_at: now + 120 })).to(be(false))
+    expect(expired?({ expires_at: now + 30 })).to(be(true))
+    expect(expired?({ expires_at: now + 30 }, drift: 0)).to(be(false))
+  end
+
+  it "uses the earlier of expires_at and issued_at plus expires_in" do
+    token = { expires_at: now + 1800, issued_at: now - 1790, expires_in: 1800 }
+
+    expect(described_class.expires_at(token)).to(eq(now + 10))
+    expect(expired?(token)).to(be(true))
+  end
+
+  it "treats tokens issued further in the future than the drift as expired" do
+    expect(expired?({ issued_at: now + 30, expires_in: 1800 })).to(be(false))
+    expect(expired?({ issued_at: now + 600, expires_in: 1800 })).to(be(true))
+  end
+
+  it "reads string keys and serialized times" do
+    token = JSON.parse(JSON.generate({ expires_at: (now + 1800).iso8601, issued_at: now.to_i, expires_in: 1800 }))
+
+    expect(expired?(token)).to(be(false))
+  end
+
+  it "treats tokens without expiry information as expired" do
+    expect(expired?({ access_token: "abc", expires_in: 1800 })).to(be(true))
+  end
+
+  it "defaults the drift to the configured tolerance" do
+    Schwab.configuration.max_clock_drift = 300
+
+    expect(described_class.expired?({ expires_at: now + 120 }, now: now)).to(be(true))
+  ensure
+    Schwab.configuration.max_clock_drift = described_class::DEFAULT_MAX_CLOCK_DRIFT
+  end
+
+  it "rejects negative tolerances" do
+    expect { Schwab::Configuration.new.max_clock_drift = -1 }.to(raise_error(ArgumentError, /max_clock_drift/))
+  end
+end
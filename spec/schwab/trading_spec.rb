@@ -0,0 +1,556 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "schwab/trading"
+
+RSpec.describe(Schwab::Trading) do
+  let(:config) { Schwab::Configuration.new }
+  let(:client) { instance_double("Schwab::Client", config: config) }
+  let(:account_number) { "123456789" }
+  let(:encrypted_account) { "ABC123XYZ" }
+  let(:orders_path) { "/trader/v1/accounts/#{encrypted_account}/orders" }
+  let(:order) do
+    {
+      orderType: "LIMIT",
+      session: "NORMAL",
+      duration: "DAY",
+      price: 150.0,
+      orderStrategyType: "SINGLE",
+      orderLegCollection: [{
+        instruction: "BUY",
+        quantity: 10,
+        instrument: { symbol: "AAPL", assetType: "EQUITY" },
+      }],
+    }
+  end
+
+  def created_response(order_id)
+    instance_double(Faraday::Response, headers: { "Location" => "https://api.schwabapi.com#{orders_path}/#{order_id}" })
+  end
+
+  before do
+    allow(client).to(receive(:resolve_account_number).with(account_number).and_return(encrypted_account))
+  end
+
+  describe ".place_order" do
+    it "submits the order and returns it with the new order ID" do
+      expect(client).to(receive(:raw_request).with(:post, orders_path, order).and_return(created_response("1001")))
+
+      result = described_class.place_order(account_number, order: order, client: client)
+      expect(result).to(include(orderId: "1001", orderType: "LIMIT"))
+    end
+
+    it "sends orders without a session for the regular session" do
+      order.delete(:session)
+      expect(client).to(receive(:raw_request)
+        .with(:post, orders_path, hash_including(session: "NORMAL"))
+        .and_return(created_response("1004")))
+
+      described_class.place_order(account_number, order: order, client: client)
+    end
+
+    it "formats prices when price_precision is set" do
+      config.price_precision = :auto
+      expect(client).to(receive(:raw_request)
+        .with(:post, orders_path, hash_including(price: "150.00"))
+        .and_return(created_response("1005")))
+
+      described_class.place_order(account_number, order: order, client: client)
+    end
+
+    it "upper-cases leg symbols" do
+      order[:orderLegCollection][0][:instrument][:symbol] = "aapl"
+      expect(client).to(receive(:raw_request)
+        .with(:post, orders_path, hash_including(orderLegCollection: [hash_including(instrument: { symbol: "AAPL", assetType: "EQUITY" })]))
+        .and_return(created_response("1006")))
+
+      described_class.place_order(account_number, order: order, client: client)
+    end
+
+    it "rejects invalid orders before sending" do
+      order[:specialInstruction] = "ALL_OR_NONE"
+      order[:duration] = "FILL_OR_KILL"
+      expect(client).not_to(receive(:raw_request))
+
+      expect { described_class.place_order(account_number, order: order, client: client) }
+        .to(raise_error(Schwab::InvalidRequestError))
+    end
+
+    it "can skip local validation" do
+      order[:destination] = "MOON"
+      expect(client).to(receive(:raw_request).and_return(created_response("1002")))
+
+      described_class.place_order(account_number, order: order, validate: false, client: client)
+    end
+  end
+
+  describe ".place_order with max_order_notional" do
+    let(:market_order) do
+      order.except(:price).merge(orderType: "MARKET")
+    end
+
+    before { config.max_order_notional = 2_000 }
+
+    it "places limit orders within the cap" do
+      order[:price] = 150.0
+      expect(client).to(receive(:raw_request).and_return(created_response("1001")))
+
+      described_class.place_order(account_number, order: order, client: client)
+    end
+
+    it "rejects limit orders over the cap before sending" do
+      order[:orderLegCollection][0][:quantity] = 20
+      expect(client).not_to(receive(:raw_request))
+
+      expect { described_class.place_order(account_number, order: order, client: client) }
+        .to(raise_error(Schwab::OrderNotionalError, /3000\.00 exceeds the maximum of 2000\.00/) do |error|
+          expect(error.notional).to(eq(3000.0))
+          expect(error.max_notional).to(eq(2_000))
+        end)
+    end
+
+    it "applies the option contract multiplier" do
+      order[:price] = 2.5
+      order[:orderLegCollection][0][:instrument] = { symbol: "AAPL  250117C00150000", assetType: "OPTION" }
+      expect(client).not_to(receive(:raw_request))
+
+      expect { described_class.place_order(account_number, order: order, client: client) }
+        .to(raise_error(Schwab::OrderNotionalError) { |error| expect(error.notional).to(eq(2500.0)) })
+    end
+
+    it "values market orders at the quoted price" do
+      expect(Schwab::MarketData).to(receive(:get_quotes).with(["AAPL"], client: client)
+        .and_return({ "AAPL" => { quote: { bidPrice: 249.9, askPrice: 250.1 } } }))
+      expect(client).not_to(receive(:raw_request))
+
+      expect { described_class.place_order(account_number, order: market_order, client: client) }
+        .to(raise_error(Schwab::OrderNotionalError) { |error| expect(error.notional).to(eq(2500.0)) })
+    end
+
+    it "places market orders within the cap" do
+      allow(Schwab::MarketData).to(receive(:get_quotes)
+        .and_return({ "AAPL" => { quote: { bidPrice: 149.9, askPrice: 150.1 } } }))
+      expect(client).to(receive(:raw_request).and_return(created_response("1002")))
+
+      described_class.place_order(account_number, order: market_order, client: client)
+    end
+
+    it "rejects market orders that cannot be priced" do
+      allow(Schwab::MarketData).to(receive(:get_quotes).and_return({ "AAPL" => { quote: {} } }))
+      expect(client).not_to(receive(:raw_request))
+
+      expect { described_class.place_order(account_number, order: market_order, client: client) }
+        .to(raise_error(Schwab::InvalidRequestError, /No market price for AAPL/))
+    end
+
+    it "skips the quote and the check for market orders when quote_order_notional is off" do
+      config.quote_order_notional = false
+      expect(Schwab::MarketData).not_to(receive(:get_quotes))
+      expect(client).to(receive(:raw_request).and_return(created_response("1003")))
+
+      described_class.place_order(account_number, order: market_order, client: client)
+    end
+
+    it "checks priced orders in dry runs without quoting" do
+      config.validate_only = true
+      order[:orderLegCollection][0][:quantity] = 20
+      expect(Schwab::MarketData).not_to(receive(:get_quotes))
+
+      expect { described_class.place_order(account_number, order: order, client: client) }
+        .to(raise_error(Schwab::OrderNotionalError))
+      expect(described_class.place_order(account_number, order: market_order, client: client)[:status])
+        .to(eq("VALIDATED_DRY_RUN"))
+    end
+  end
+
+  describe ".place_order_checked" do
+    let(:quote) { { "AAPL" => { symbol: "AAPL", quote: { bidPrice: 149.9, askPrice: 150.1, lastPrice: 151.0 } } } }
+
+    before do
+      allow(Schwab::MarketData).to(receive(:get_quote).with("AAPL", client: client).and_return(quote))
+    end
+
+    it "places limit orders priced near the market" do
+      expect(client).to(receive(:raw_request).with(:post, orders_path, order).and_return(created_response("1001")))
+
+      result = described_class.place_order_checked(account_number, order: order, max_deviation_percent: 1, client: client)
+      expect(result[:orderId]).to(eq("1001"))
+    end
+
+    it "rejects limit prices too far from the mid price with the quote attached" do
+      expect(client).not_to(receive(:raw_request))
+      order[:price] = 165.0
+
+      expect { described_class.place_order_checked(account_number, order: order, max_deviation_percent: 5, client: client) }
+        .to(raise_error(Schwab::PriceDeviationError) do |error|
+          expect(error).to(be_a(Schwab::InvalidRequestError))
+          expect(error.message).to(eq("Limit price 165.00 for AAPL is 10.0% from the market (150.00); the maximum is 5%"))
+          expect(error.quote.last_price).to(eq(151.0))
+          expect([error.reference_price, error.deviation_percent]).to(eq([150.0, 10.0]))
+        end)
+    end
+
+    it "falls back to the last price for one-sided quotes" do
+      quote["AAPL"][:quote].delete(:bidPrice)
+      order[:price] = 150.0
+
+      expect { described_class.place_order_checked(account_number, order: order, max_deviation_percent: 0.5, client: client) }
+        .to(raise_error(Schwab::PriceDeviationError, /from the market \(151.00\)/))
+    end
+
+    it "rejects the order when the quote has no price" do
+      quote["AAPL"][:quote] = {}
+
+      expect { described_class.place_order_checked(account_number, order: order, max_deviation_percent: 5, client: client) }
+        .to(raise_error(Schwab::PriceDeviationError, /No market price for AAPL/))
+    end
+
+    it "places market orders without fetching a quote" do
+      order[:orderType] = "MARKET"
+      order.delete(:price)
+      expect(Schwab::MarketData).not_to(receive(:get_quote))
+      expect(client).to(receive(:raw_request).and_return(created_response("1002")))
+
+      described_class.place_order_checked(account_number, order: order, max_deviation_percent: 5, client: client)
+    end
+
+    it "requires a positive threshold" do
+      expect { described_class.place_order_checked(account_number, order: order, max_deviation_percent: 0, client: client) }
+        .to(raise_error(ArgumentError, /positive/))
+    end
+  end
+
+  describe ".self_crossing_orders" do
+    def open_order(order_id, instruction, order_type: "LIMIT", price: nil, symbol: "AAPL")
+      {
+        orderId: order_id,
+        status: "WORKING",
+        orderType: order_type,
+        price: price,
+        orderLegCollection: [
+          { instruction: instruction, quantity: 10, instrument: { symbol: symbol, assetType: "EQUITY" } },
+        ],
+      }
+    end
+
+    let(:sell) do
+      order.merge(
+        price: 151.0,
+        orderLegCollection: [
+          { instruction: "SELL", quantity: 10, instrument: { symbol: "aapl", assetType: "EQUITY" } },
+        ],
+      )
+    end
+
+    before do
+      allow(Schwab::Accounts).to(receive(:get_orders)
+        .with(account_number, status: Schwab::Resources::Order::OPEN_STATUSES, client: client)
+        .and_return(open_orders))
+    end
+
+    context "with resting orders on both sides" do
+      let(:open_orders) do
+        [
+          open_order(1, "BUY", price: 151.5),
+          open_order(2, "BUY", price: 150.0),
+          open_order(3, "BUY", order_type: "MARKET"),
+          open_order(4, "SELL", price: 149.0),
+          open_order(5, "BUY", price: 160.0, symbol: "MSFT"),
+        ]
+      end
+
+      it "returns opposite-side orders in the same symbol at crossing prices" do
+        conflicts = described_class.self_crossing_orders(account_number, order: sell, client: client)
+
+        expect(conflicts.map { |conflict| conflict[:orderId] }).to(eq([1, 3]))
+        expect(described_class.would_self_cross?(account_number, order: sell, client: client)).to(be(true))
+      end
+
+      it "treats an order without a limit price as crossing any price" do
+        market_buy = order.merge(orderType: "MARKET", price: nil)
+
+        conflicts = described_class.self_crossing_orders(account_number, order: market_buy, client: client)
+
+        expect(conflicts.map { |conflict| conflict[:orderId] }).to(eq([4]))
+      end
+    end
+
+    context "without crossing orders" do
+      let(:open_orders) { [open_order(2, "BUY", price: 150.0), open_order(4, "SELL", price: 149.0)] }
+
+      it "returns no conflicts" do
+        expect(described_class.self_crossing_orders(account_number, order: sell, client: client)).to(eq([]))
+        expect(described_class.would_self_cross?(account_number, order: sell, client: client)).to(be(false))
+      end
+    end
+  end
+
+  describe ".replace_order" do
+    it "replaces the order and returns the new order ID" do
+      expect(client).to(receive(:raw_request)
+        .with(:put, "#{orders_path}/1001", order)
+        .and_return(created_response("1003")))
+
+      result = described_class.replace_order(account_number, "1001", order: order, client: client)
+      expect(result[:orderId]).to(eq("1003"))
+    end
+  end
+
+  describe ".preview_replace_order" do
+    let(:original) do
+      order.merge(orderId: 1001, status: "WORKING", price: 148.0)
+    end
+    let(:preview_response) do
+      { "orderStrategy" => { "price" => 150.0 }, "orderValidationResult" => { "rejects" => [] } }
+    end
+
+    before do
+      allow(client).to(receive(:get)
+        .with("#{orders_path}/1001", {}, Schwab::Resources::Order)
+        .and_return(original))
+    end
+
+    it "previews the replacement without modifying the order" do
+      expect(client).to(receive(:post)
+        .with("/trader/v1/accounts/#{encrypted_account}/previewOrder", order)
+        .and_return(preview_response))
+      expect(client).not_to(receive(:raw_request))
+      expect(client).not_to(receive(:put))
+
+      result = described_class.preview_replace_order(account_number, "1001", order: order, client: client)
+      expect(result["orderValidationResult"]).to(eq({ "rejects" => [] }))
+      expect(result["replacedOrderId"]).to(eq("1001"))
+      expect(result["changes"]).to(eq({ "price" => { "from" => 148.0, "to" => 150.0 } }))
+    end
+
+    it "reports quantity changes from the order legs" do
+      replacement = order.merge(orderLegCollection: [order[:orderLegCollection].first.merge(quantity: 5)])
+      allow(client).to(receive(:post).and_return(preview_response))
+
+      result = described_class.preview_replace_order(account_number, "1001", order: replacement, client: client)
+      expect(result["changes"]["quantity"]).to(eq({ "from" => 10.0, "to" => 5.0 }))
+    end
+
+    it "rejects invalid replacements before previewing" do
+      order[:destination] = "MOON"
+      expect(client).not_to(receive(:post))
+
+      expect { described_class.preview_replace_order(account_number, "1001", order: order, client: client) }
+        .to(raise_error(Schwab::InvalidRequestError))
+    end
+  end
+
+  describe ".cancel_order" do
+    it "cancels the order" do
+      expect(client).to(receive(:delete).with("#{orders_path}/1001"))
+      expect(described_class.cancel_order(account_number, "1001", client: client)).to(be(true))
+    end
+
+    it "logs the reason and labels the cancel request with it" do
+      output = StringIO.new
+      config.logger = Logger.new(output)
+      expect(client).to(receive(:delete).with("#{orders_path}/1001")) do
+        expect(Schwab.current_labels).to(eq(cancel_reason: "stale quote"))
+      end
+
+      expect(described_class.cancel_order(account_number, "1001", reason: "stale quote", client: client)).to(be(true))
+      expect(output.string).to(include('Schwab cancel order 1001 reason="stale quote"'))
+      expect(Schwab.current_labels).to(eq({}))
+    end
+
+    context "when the order is mid-fill" do
+      let(:conflict) do
+        Schwab::ConflictError.new(
+          "Conflict: the server responded with status 409",
+          status: 409,
+          response_body: File.read(File.expand_path("../fixtures/errors/order_conflict.json", __dir__)),
+        )
+      end
+
+      before do
+        allow(client).to(receive(:delete).with("#{orders_path}/1001").and_raise(conflict))
+      end
+
+      it "raises OrderStateConflictError with the order's current status" do
+        allow(client).to(receive(:get)
+          .with("#{orders_path}/1001", {}, Schwab::Resources::Order)
+          .and_return({ "orderId" => 1001, "status" => "WORKING", "filledQuantity" => 4 }))
+
+        expect { described_class.cancel_order(account_number, "1001", client: client) }
+          .to(raise_error(Schwab::OrderStateConflictError, /Order 1001 .* \(WORKING\)/) do |error|
+            expect(error).to(be_a(Schwab::ConflictError))
+            expect(error).not_to(be_a(Schwab::BadRequestError))
+            expect(error.status).to(eq(409))
+            expect(error.order_id).to(eq("1001"))
+            expect(error.order_status).to(eq("WORKING"))
+            expect(error.order["filledQuantity"]).to(eq(4))
+            expect(error.errors).to(eq(["The order is being filled and cannot be canceled at this time."]))
+            expect(error).to(be_retryable)
+          end)
+      end
+
+      it "is not retryable once the order has filled" do
+        allow(client).to(receive(:get).and_return({ "orderId" => 1001, "status" => "FILLED" }))
+
+        expect { described_class.cancel_order(account_number, "1001", client: client) }
+          .to(raise_error(Schwab::OrderStateConflictError) { |error| expect(error).not_to(be_retryable) })
+      end
+
+      it "still raises the conflict when the order cannot be fetched" do
+        allow(client).to(receive(:get).and_raise(Schwab::ServerError, "unavailable"))
+
+        expect { described_class.cancel_order(account_number, "1001", client: client) }
+          .to(raise_error(Schwab::OrderStateConflictError) do |error|
+            expect(error.order_status).to(be_nil)
+            expect(error).to(be_retryable)
+          end)
+      end
+    end
+  end
+
+  describe "request builders" do
+    it "builds the place order request without sending it" do
+      expect(client).not_to(receive(:raw_request))
+      order.delete(:session)
+
+      request = described_class.build_place_order_request(account_number, order: order, client: client)
+
+      expect(request.http_method).to(eq(:post))
+      expect(request.path).to(eq(orders_path))
+      expect(request.body).to(eq(order.merge(session: "NORMAL")))
+      expect(request.headers["Content-Type"]).to(eq("application/json"))
+      expect(request.url("https://api.schwabapi.com")).to(eq("https://api.schwabapi.com#{orders_path}"))
+      expect(JSON.parse(request.body_json)["orderType"]).to(eq("LIMIT"))
+    end
+
+    it "validates the order like place_order" do
+      order[:destination] = "MOON"
+
+      expect { described_class.build_place_order_request(account_number, order: order, client: client) }
+        .to(raise_error(Schwab::InvalidRequestError, /MOON/))
+    end
+
+    it "builds replace and cancel requests" do
+      replace = described_class.build_replace_order_request(account_number, "1001", order: order, client: client)
+      cancel = described_class.build_cancel_order_request(account_number, "1001", client: client)
+
+      expect([replace.http_method, replace.path]).to(eq([:put, "#{orders_path}/1001"]))
+      expect([cancel.http_method, cancel.path, cancel.body]).to(eq([:delete, "#{orders_path}/1001", nil]))
+      expect(cancel.headers).not_to(have_key("Content-Type"))
+    end
+
+    it "sends the built request" do
+      request = described_class.build_place_order_request(account_number, order: order, client: client)
+      expect(client).to(receive(:raw_request).with(:post, orders_path, order).and_return(created_response("1001")))
+
+      expect(request.perform(client).headers["Location"]).to(end_with("/1001"))
+    end
+  end
+
+  describe "duplicate order guard" do
+    before { config.duplicate_order_window = 60 }
+
+    it "refuses a repeat of a recent order unless overridden" do
+      expect(client).to(receive(:raw_request).twice.and_return(created_response("1001"), created_response("1002")))
+
+      described_class.place_order(account_number, order: order, client: client)
+      expect { described_class.place_order(account_number, order: order, client: client) }
+        .to(raise_error(Schwab::DuplicateOrderError))
+
+      result = described_class.place_order(account_number, order: order, allow_duplicate: true, client: client)
+      expect(result[:orderId]).to(eq("1002"))
+    end
+
+    it "allows resubmitting an order Schwab rejected" do
+      rejection = Schwab::BadRequestError.new("Bad request", status: 400)
+      expect(client).to(receive(:raw_request).twice.and_invoke(->(*) { raise rejection }, ->(*) { created_response("1003") }))
+
+      expect { described_class.place_order(account_number, order: order, client: client) }
+        .to(raise_error(Schwab::BadRequestError))
+      expect(described_class.place_order(account_number, order: order, client: client)[:orderId]).to(eq("1003"))
+    end
+
+    it "places orders that differ" do
+      expect(client).to(receive(:raw_request).twice.and_return(created_response("1001"), created_response("1004")))
+
+      described_class.place_order(account_number, order: order, client: client)
+      order[:price] = 151.0
+      expect(described_class.place_order(account_number, order: order, client: client)[:orderId]).to(eq("1004"))
+    end
+  end
+
+  describe "validate-only mode" do
+    before { config.validate_only = true }
+
+    it "returns the validated order without any network calls" do
+      expect(client).not_to(receive(:raw_request))
+
+      result = described_class.place_order(account_number, order: order, validate: false, client: client)
+      expect(result[:status]).to(eq("VALIDATED_DRY_RUN"))
+
+      replaced = described_class.replace_order(account_number, "1001", order: order, client: client)
+      expect(replaced[:status]).to(eq("VALIDATED_DRY_RUN"))
+    end
+
+    it "still rejects invalid orders" do
+      order[:destination] = "MOON"
+
+      expect { described_class.place_order(account_number, order: order, client: client) }
+        .to(raise_error(Schwab::InvalidRequestError, /MOON/))
+    end
+
+    it "wraps the result in an order resource when configured" do
+      config.response_format = :resource
+
+      result = described_class.place_order(account_number, order: order, client: client)
+      expect(result).to(be_a(Schwab::Resources::Order))
+      expect(result.status).to(eq("VALIDATED_DRY_RUN"))
+    end
+  end
+
+  describe ".next_market_open" do
+    def hours(date, open)
+      product = { date: date, marketType: "EQUITY", isOpen: open }
+      if open
+        product[:sessionHours] = {
+          preMarket: [{ start: "#{date}T07:00:00-05:00", end: "#{date}T09:30:00-05:00" }],
+          regularMarket: [{ start: "#{date}T09:30:00-05:00", end: "#{date}T16:00:00-05:00" }],
+        }
+      end
+      { equity: { EQ: product } }
+    end
+
+    before do
+      {
+        "2024-12-20" => true,
+        "2024-12-21" => false,
+        "2024-12-22" => false,
+        "2024-12-23" => true,
+      }.each do |date, open|
+        allow(client).to(receive(:get)
+          .with("/marketdata/v1/markets", { markets: "equity", date: date })
+          .and_return(hours(date, open)))
+      end
+    end
+
+    it "returns today's open when it is still ahead" do
+      open = described_class.next_market_open(from: Time.new(2024, 12, 20, 8, 0, 0, "-05:00"), client: client)
+
+      expect(open).to(eq(Time.new(2024, 12, 20, 9, 30, 0, "-05:00")))
+    end
+
+    it "skips days without a regular session" do
+      open = described_class.next_market_open(from: Time.new(2024, 12, 20, 17, 0, 0, "-05:00"), client: client)
+
+      expect(open).to(eq(Time.new(2024, 12, 23, 9, 30, 0, "-05:00")))
+      expect(client).to(have_received(:get).with("/marketdata/v1/markets", hash_including(date: "2024-12-21")))
+    end
+
+    it "raises when no session opens within the lookahead" do
+      allow(client).to(receive(:get).and_return(hours("2024-12-25", false)))
+
+      expect { described_class.next_market_open(from: Time.new(2024, 12, 25, 12, 0, 0, "-05:00"), client: client) }
+        .to(raise_error(Schwab::Error, /No equity market open/))
+    end
+  end
+end
@@ -0,0 +1,68 @@
+# frozen_string_literal: true
+
+require "spec_helper"
+require "schwab/transaction_type"
+require "schwab/resources/transaction"
+
+RSpec.describe(Schwab::TransactionType) do
+  it "enumerates Schwab's transaction types" do
+    expect(described_class::ALL).to(eq([
+      "TRADE",
+      "RECEIVE_AND_DELIVER",
+      "DIVIDEND_OR_INTEREST",
+      "ACH_RECEIPT",
+      "ACH_DISBURSEMENT",
+      "CASH_RECEIPT",
+      "CASH_DISBURSEMENT",
+      "ELECTRONIC_FUND",
+      "WIRE_OUT",
+      "WIRE_IN",
+      "JOURNAL",
+      "MEMORANDUM",
+      "MARGIN_CALL",
+      "MONEY_MARKET",
+      "SMA_ADJUSTMENT",
+    ]))
+    described_class::ALL.each { |type| expect(described_class.const_get(type)).to(eq(type)) }
+  end
+
+  describe ".valid?" do
+    it "accepts every type in any case" do
+      described_class::ALL.each { |type| expect(described_class.valid?(type.downcase.to_sym)).to(be(true)) }
+    end
+
+    it "rejects unknown types" do
+      expect(described_class.valid?("DIVIDEND")).to(be(false))
+      expect(described_class.valid?("")).to(be(false))
+      expect(described_class.valid?(nil)).to(be(false))
+    end
+  end
+
+  describe ".normalize" do
+    it "accepts a type, a comma-separated list, an array, or a set" do
+      expect(described_class.normalize("trade")).to(eq(["TRADE"]))
+      expect(described_class.normalize("TRADE, wire_in")).to(eq(["TRADE", "WIRE_IN"]))
+      expect(described_class.normalize([:journal, "JOURNAL"])).to(eq(["JOURNAL"]))
+      expect(described_class.normalize(Set["WIRE_OUT", "WIRE_IN"])).to(eq(["WIRE_OUT", "WIRE_IN"]))
+    end
+
+    it "raises on unknown types" do
+      expect { described_class.normalize(["TRADE", "BUY", "SELL"]) }
+        .to(raise_error(ArgumentError, "Unknown transaction type(s): BUY, SELL"))
+    end
+  end
+
+  describe "Resources::Transaction" do
+    let(:transaction) { Schwab::Resources::Transaction.new({ type: "WIRE_IN", netAmount: 500.0 }) }
+
+    it "matches against a set of types" do
+      expect(transaction.type_in?(described_class::WIRE_IN, described_class::ACH_RECEIPT)).to(be(true))
+      expect(transaction.type_in?([described_class::TRADE])).to(be(false))
+    end
+
+    it "reports whether the type is documented" do
+      expect(transaction.known_type?).to(be(true))
+      expect(Schwab::Resources::Transaction.new({ type: "DIVIDEND" }).known_type?).to(be(false))
+    end
+  end
+end
@@ -15,6 +15,8 @@ Dotenv.load
 
 # Load VCR configuration
 require_relative "support/vcr"
+require_relative "support/fake_stream_transport"
+require_relative "support/streaming_helpers"
 
 RSpec.configure do |config|
   # Enable flags like --only-failures and --next-failure
@@ -26,4 +28,6 @@ RSpec.configure do |config|
   config.expect_with(:rspec) do |c|
     c.syntax = :expect
   end
+
+  config.include(StreamingHelpers)
 end
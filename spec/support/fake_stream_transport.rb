@@ -0,0 +1,58 @@
+# frozen_string_literal: true
+
+require "json"
+
+# In-memory stand-in for the streamer WebSocket
+# Acknowledges LOGIN automatically; tests push server messages with #push and inspect #requests
+class FakeStreamTransport
+  attr_reader :url, :connect_count
+
+  def initialize(login_code: 0)
+    @login_code = login_code
+    @incoming = Queue.new
+    @sent = []
+    @mutex = Mutex.new
+    @connect_count = 0
+  end
+
+  # Transport factory interface
+  def call(url)
+    @url = url
+    self
+  end
+
+  def connect
+    @incoming.clear
+    @connect_count += 1
+    self
+  end
+
+  def write(message)
+    payload = JSON.parse(message)
+    @mutex.synchronize { @sent.concat(payload["requests"]) }
+    payload["requests"].each do |request|
+      next unless request["command"] == "LOGIN"
+
+      push({ response: [{ service: "ADMIN", command: "LOGIN", content: { code: @login_code, msg: "login" } }] })
+    end
+  end
+
+  def read
+    @incoming.pop
+  end
+
+  def close
+    @incoming << nil
+  end
+
+  # Simulate the server dropping the connection
+  alias_method :drop, :close
+
+  def push(message)
+    @incoming << JSON.generate(message)
+  end
+
+  def requests(command = nil)
+    @mutex.synchronize { command ? @sent.select { |request| request["command"] == command } : @sent.dup }
+  end
+end
@@ -0,0 +1,15 @@
+# frozen_string_literal: true
+
+# Helpers for specs that assert against background threads (streamers, pollers, the event bus)
+module StreamingHelpers
+  # Poll until the block returns a truthy value
+  def wait_for(timeout: 2)
+    deadline = Time.now + timeout
+    until (result = yield)
+      raise "Timed out waiting for condition" if Time.now > deadline
+
+      sleep(0.01)
+    end
+    result
+  end
+end